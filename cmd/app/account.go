@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"todo_app/pkg/auth"
+	"todo_app/pkg/mailer"
+	"todo_app/pkg/store"
+	"todo_app/pkg/tasks"
+
+	"github.com/google/uuid"
+)
+
+// accountMenu lets user change their password, email and username or delete
+// their account. It returns the user as it is now, and false once the account
+// is deleted so the caller logs out.
+func accountMenu(reader *bufio.Reader, out io.Writer, users auth.UserDatabase, userTasks map[uuid.UUID]tasks.TaskList, apiKeys *auth.APIKeys, verifications *auth.EmailVerifications, sender mailer.Mailer, user auth.User) (auth.User, bool) {
+	for {
+		fmt.Fprintf(out, "Account of %q (%s)\n", user.Username, user.Email)
+		fmt.Fprintln(out, "1.- Change password")
+		fmt.Fprintln(out, "2.- Change email")
+		fmt.Fprintln(out, "3.- Change username")
+		fmt.Fprintln(out, "4.- Delete account")
+		fmt.Fprintln(out, "0.- Return to the previous menu")
+		option, optionErr := reader.ReadString('\n')
+		if optionErr != nil {
+			fmt.Fprintln(out, optionErr)
+			return user, true
+		}
+		var err error
+		switch strings.TrimSpace(option) {
+		case "0":
+			return user, true
+		case "1":
+			current := readLine(reader, out, "Please enter your current password")
+			password := readLine(reader, out, "Please enter your new password ("+auth.CurrentPasswordPolicy().Describe()+")")
+			// saved sessions are ended like on a reset, a running API server
+			// keeps its own until it is restarted
+			sessions := auth.NewSessions(settings.SessionIdleTimeout, settings.SessionMaxLifetime)
+			err = store.LoadSessions(dataPath("sessions.csv"), sessions)
+			if err != nil {
+				fmt.Fprintln(out, "sessions file could not be read")
+				continue
+			}
+			user, err = auth.ChangePassword(users, sessions, user.Username, current, password)
+			if err != nil {
+				fmt.Fprintln(out, err)
+				continue
+			}
+			err = store.SaveSessions(dataPath("sessions.csv"), sessions)
+			if err != nil {
+				fmt.Fprintln(out, "couldnt write sessions file")
+			}
+			fmt.Fprintln(out, "Password changed")
+		case "2":
+			email := readLine(reader, out, "Please enter your new email")
+			password := readLine(reader, out, "Please enter your password")
+			previous := user.Email
+			user, err = auth.ChangeEmail(users, user.Username, password, email)
+			if err != nil {
+				fmt.Fprintln(out, err)
+				continue
+			}
+			if user.Email != previous {
+				sendVerification(out, users, verifications, sender, user.Username)
+			}
+		case "3":
+			username := readLine(reader, out, "Please enter your new username")
+			user, err = auth.ChangeUsername(users, user.Username, username)
+			if err != nil {
+				fmt.Fprintln(out, err)
+				continue
+			}
+		case "4":
+			fmt.Fprintln(out, "Deleting your account also deletes all your tasks, this cannot be undone")
+			password := readLine(reader, out, "Please enter your password to confirm, or 0 to cancel")
+			if password == "0" {
+				continue
+			}
+			_, err = auth.DeleteAccount(users, userTasks, user.Username, password)
+			if _, wrong := err.(auth.PasswordErr); wrong {
+				fmt.Fprintln(out, err)
+				continue
+			}
+			if err != nil {
+				fmt.Fprintln(out, err)
+			}
+			deleteCredentials(out, apiKeys, user.Id)
+			err = store.SaveUsers(dataPath("users.csv"), users)
+			if err != nil {
+				fmt.Fprintln(out, "error writing to file")
+			}
+			err = store.SaveTasks(dataPath("tasks.csv"), userTasks)
+			if err != nil {
+				fmt.Fprintln(out, "couldnt write tasks file")
+			}
+			fmt.Fprintln(out, "Your account was deleted")
+			return auth.User{}, false
+		default:
+			fmt.Fprintln(out, "Please enter an appropiate input")
+			continue
+		}
+		err = store.SaveUsers(dataPath("users.csv"), users)
+		if err != nil {
+			fmt.Fprintln(out, "error writing to file")
+		}
+	}
+}
+
+// deleteCredentials ends the API sessions and revokes the API keys of a
+// deleted user.
+func deleteCredentials(out io.Writer, apiKeys *auth.APIKeys, userId uuid.UUID) {
+	sessions := auth.NewSessions(settings.SessionIdleTimeout, settings.SessionMaxLifetime)
+	err := store.LoadSessions(dataPath("sessions.csv"), sessions)
+	if err == nil {
+		sessions.RevokeAll(userId)
+		err = store.SaveSessions(dataPath("sessions.csv"), sessions)
+	}
+	if err != nil {
+		fmt.Fprintln(out, "couldnt write sessions file")
+	}
+	apiKeys.RevokeAll(userId)
+	err = store.SaveAPIKeys(dataPath("apikeys.csv"), apiKeys)
+	if err != nil {
+		fmt.Fprintln(out, "couldnt write API keys file")
+	}
+}
+
+func readLine(reader *bufio.Reader, out io.Writer, prompt string) string {
+	fmt.Fprintln(out, prompt)
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		fmt.Fprintln(out, err)
+	}
+	return strings.TrimSpace(line)
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"todo_app/pkg/audit"
+	"todo_app/pkg/auth"
+	"todo_app/pkg/mailer"
+	"todo_app/pkg/store"
+	"todo_app/pkg/tasks"
+
+	"github.com/google/uuid"
+)
+
+// adminMenu lets administrators manage the accounts of other users. Changes
+// are saved to the data files as soon as they are made. A running API server
+// keeps its own copy of users and sessions and would overwrite them on its
+// next save, so it has to be stopped first and started again afterwards, or
+// the change made through its /v1/admin routes instead.
+func adminMenu(reader *bufio.Reader, out io.Writer, users auth.UserDatabase, userTasks map[uuid.UUID]tasks.TaskList, sender mailer.Mailer, events audit.Querier, throttle *auth.LoginThrottle, admin auth.User) {
+	for {
+		fmt.Fprintln(out, "Enter one of the following commands or 0 to return to the previous menu:")
+		fmt.Fprintln(out, "  list")
+		fmt.Fprintln(out, "  disable <username or email>")
+		fmt.Fprintln(out, "  enable <username or email>")
+		fmt.Fprintln(out, "  reset <username or email>")
+		fmt.Fprintln(out, "  unlock <username or email>")
+		fmt.Fprintln(out, "  tasks <username or email>")
+		fmt.Fprintln(out, "  role <username or email> <user or admin>")
+		fmt.Fprintf(out, "  events <username, email or all> [from %s] [to %s]\n", settings.DateFormat, settings.DateFormat)
+		input, inputErr := reader.ReadString('\n')
+		if inputErr != nil {
+			fmt.Fprintln(out, inputErr)
+			return
+		}
+		fields := strings.Fields(input)
+		if len(fields) == 0 {
+			fmt.Fprintln(out, "Please enter an appropiate input")
+			continue
+		}
+		command := strings.ToLower(fields[0])
+		if command == "0" {
+			return
+		}
+		if command == "list" {
+			list, err := auth.ListUsers(users, admin)
+			if err != nil {
+				fmt.Fprintln(out, err)
+				continue
+			}
+			printUsers(out, list)
+			continue
+		}
+		if command == "events" {
+			printEvents(out, users, events, admin, fields[1:])
+			continue
+		}
+		arguments := 2
+		if command == "role" {
+			arguments = 3
+		}
+		if len(fields) != arguments {
+			fmt.Fprintln(out, "Please enter an appropiate input")
+			continue
+		}
+		var summary auth.UserSummary
+		var err error
+		switch command {
+		case "disable", "enable", "reset":
+			// only the saved sessions are ended, a running API server keeps its own
+			// until it is restarted
+			sessions := auth.NewSessions(settings.SessionIdleTimeout, settings.SessionMaxLifetime)
+			err = store.LoadSessions(dataPath("sessions.csv"), sessions)
+			if err != nil {
+				fmt.Fprintln(out, "sessions file could not be read")
+				continue
+			}
+			if command == "reset" {
+				summary, err = auth.ForcePasswordReset(users, sessions, sender, admin, fields[1])
+			} else {
+				summary, err = auth.SetDisabled(users, sessions, admin, fields[1], command == "disable")
+			}
+			if err != nil && summary.Username == "" {
+				fmt.Fprintln(out, err)
+				continue
+			}
+			// the reset is required even when the notice could not be mailed
+			if err != nil {
+				fmt.Fprintln(out, "couldnt send the notice, tell the user to use Forgot password")
+			}
+			err = store.SaveSessions(dataPath("sessions.csv"), sessions)
+			if err != nil {
+				fmt.Fprintln(out, "couldnt write sessions file")
+			}
+		case "unlock":
+			// failed logins are counted by each app and server on its own,
+			// this forgets the ones of this app
+			summary, err = auth.UnlockLogins(users, throttle, admin, fields[1])
+			if err != nil {
+				fmt.Fprintln(out, err)
+				continue
+			}
+			fmt.Fprintf(out, "Failed logins of %s were forgotten\n", summary.Username)
+			continue
+		case "tasks":
+			counts, err := auth.CountTasks(users, userTasks, admin, fields[1])
+			if err != nil {
+				fmt.Fprintln(out, err)
+				continue
+			}
+			fmt.Fprintf(out, "%d tasks, %d pending and %d complete\n", counts.Total, counts.Pending, counts.Completed)
+			continue
+		case "role":
+			role, err := auth.ParseRole(fields[2])
+			if err != nil {
+				fmt.Fprintln(out, err)
+				continue
+			}
+			summary, err = auth.SetRole(users, admin, fields[1], role)
+			if err != nil {
+				fmt.Fprintln(out, err)
+				continue
+			}
+		default:
+			fmt.Fprintln(out, "Please enter an appropiate input")
+			continue
+		}
+		printUsers(out, []auth.UserSummary{summary})
+		err = store.SaveUsers(dataPath("users.csv"), users)
+		if err != nil {
+			fmt.Fprintln(out, "error writing to file")
+		}
+	}
+}
+
+func printUsers(out io.Writer, list []auth.UserSummary) {
+	table := tabwriter.NewWriter(out, 1, 1, 1, ' ', 0)
+	fmt.Fprintln(table, "Username\t", "Email\t", "Role\t", "Verified\t", "Two-factor\t", "Status\t")
+	for _, user := range list {
+		status := "active"
+		if user.Disabled {
+			status = "disabled"
+		} else if user.PasswordResetRequired {
+			status = "must reset password"
+		}
+		fmt.Fprintf(table, "%s\t %s\t %s\t %t\t %t\t %s\t\n", user.Username, user.Email, user.Role, user.Verified, user.TwoFactor, status)
+	}
+	table.Flush()
+}
+
+// printEvents shows the security events of a user, or of all users, in the
+// dates given as arguments; the last day is included.
+func printEvents(out io.Writer, users auth.UserDatabase, events audit.Querier, admin auth.User, arguments []string) {
+	if len(arguments) < 1 || len(arguments) > 3 {
+		fmt.Fprintln(out, "Please enter an appropiate input")
+		return
+	}
+	id := arguments[0]
+	if strings.EqualFold(id, "all") {
+		id = ""
+	}
+	var dates [2]time.Time
+	for i, argument := range arguments[1:] {
+		date, err := time.ParseInLocation(settings.DateLayout(), argument, time.Local)
+		if err != nil {
+			fmt.Fprintf(out, "Please enter dates as %s\n", settings.DateFormat)
+			return
+		}
+		dates[i] = date
+	}
+	if !dates[1].IsZero() {
+		dates[1] = dates[1].AddDate(0, 0, 1)
+	}
+	list, err := auth.SecurityEvents(users, events, admin, id, dates[0], dates[1])
+	if err != nil {
+		fmt.Fprintln(out, err)
+		return
+	}
+	if len(list) == 0 {
+		fmt.Fprintln(out, "No events found")
+		return
+	}
+	table := tabwriter.NewWriter(out, 1, 1, 1, ' ', 0)
+	fmt.Fprintln(table, "Time\t", "Event\t", "User\t", "Source\t", "Reason\t")
+	for _, event := range list {
+		user := event.Username
+		if user == "" {
+			user = event.Login
+		}
+		fmt.Fprintf(table, "%s\t %s\t %s\t %s\t %s\t\n", event.Time.Local().Format(settings.DateLayout()+" 15:04:05"), event.Type, user, event.Source, event.Reason)
+	}
+	table.Flush()
+}
+
+// makeAdmin gives the admin role to a user from the command line. Anyone who
+// runs the app can name the first administrator, after that it takes the
+// session of an administrator, from app login or TODO_TOKEN.
+func makeAdmin(ctx appContext, id string) error {
+	user, err := auth.NameFirstAdmin(ctx.users, id)
+	if err != nil && auth.HasAdmin(ctx.users) {
+		token, tokenErr := ctx.token()
+		if tokenErr != nil {
+			return tokenErr
+		}
+		// API keys are for tasks only, like in the API
+		admin, sessionErr := ctx.sessions.Lookup(ctx.users, token)
+		if sessionErr != nil {
+			return sessionErr
+		}
+		user, err = auth.SetRole(ctx.users, admin, id, auth.RoleAdmin)
+	}
+	if err != nil {
+		return err
+	}
+	err = store.SaveUsers(dataPath("users.csv"), ctx.users)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(ctx.stdout, "%q is now an administrator\n", user.Username)
+	return nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"todo_app/pkg/auth"
+	"todo_app/pkg/store"
+)
+
+// apiKeysMenu manages the keys the user gives to scripts calling the API.
+// Keys are saved as soon as they change. A running API server only reads them
+// when it starts and would overwrite them on its next save, so it has to be
+// stopped while keys are managed here and started again afterwards.
+func apiKeysMenu(reader *bufio.Reader, out io.Writer, path string, keys *auth.APIKeys, user auth.User) {
+	for {
+		printAPIKeys(out, keys.List(user.Id))
+		fmt.Fprintln(out, "Enter one of the following commands or 0 to return to the previous menu:")
+		fmt.Fprintln(out, "  create <name> [scopes, default tasks:read,tasks:write] [days until it expires]")
+		fmt.Fprintln(out, "  revoke <name or id>")
+		input, inputErr := reader.ReadString('\n')
+		if inputErr != nil {
+			fmt.Fprintln(out, inputErr)
+			return
+		}
+		fields := strings.Fields(input)
+		if len(fields) == 0 {
+			fmt.Fprintln(out, "Please enter an appropiate input")
+			continue
+		}
+		switch strings.ToLower(fields[0]) {
+		case "0":
+			return
+		case "create":
+			if len(fields) < 2 || len(fields) > 4 {
+				fmt.Fprintln(out, "Please enter an appropiate input")
+				continue
+			}
+			scopes := auth.Scopes
+			if len(fields) > 2 {
+				parsed, err := auth.ParseScopes(fields[2])
+				if err != nil {
+					fmt.Fprintln(out, err)
+					continue
+				}
+				scopes = parsed
+			}
+			var expires time.Time
+			if len(fields) > 3 {
+				days, err := strconv.Atoi(fields[3])
+				if err != nil || days < 1 {
+					fmt.Fprintln(out, "Please enter a valid number of days")
+					continue
+				}
+				expires = time.Now().AddDate(0, 0, days)
+			}
+			token, _, err := keys.Create(user, fields[1], scopes, expires)
+			if err != nil {
+				fmt.Fprintln(out, err)
+				continue
+			}
+			fmt.Fprintln(out, "Your new API key, copy it now, it will not be shown again:")
+			fmt.Fprintln(out, token)
+		case "revoke":
+			if len(fields) < 2 {
+				fmt.Fprintln(out, "Please enter the name or id of the key to revoke")
+				continue
+			}
+			err := keys.Revoke(user.Id, strings.Join(fields[1:], " "))
+			if err != nil {
+				fmt.Fprintln(out, err)
+				continue
+			}
+		default:
+			fmt.Fprintln(out, "Please enter an appropiate input")
+			continue
+		}
+		err := store.SaveAPIKeys(path, keys)
+		if err != nil {
+			fmt.Fprintln(out, "couldnt write API keys file")
+		}
+	}
+}
+
+func printAPIKeys(out io.Writer, list []auth.APIKey) {
+	if len(list) == 0 {
+		fmt.Fprintln(out, "You have no API keys")
+		return
+	}
+	now := time.Now()
+	table := tabwriter.NewWriter(out, 1, 1, 1, ' ', 0)
+	fmt.Fprintln(table, "Id\t", "Name\t", "Scopes\t", "Created\t", "Expires\t", "Last used\t")
+	for _, key := range list {
+		expires := "never"
+		if key.Expired(now) {
+			expires = "expired"
+		} else if !key.Expires.IsZero() {
+			expires = key.Expires.Format(settings.DateLayout())
+		}
+		lastUsed := "never"
+		if !key.LastUsed.IsZero() {
+			lastUsed = key.LastUsed.Format(settings.DateLayout() + " 15:04")
+		}
+		fmt.Fprintf(table, "%s\t %s\t %s\t %s\t %s\t %s\t\n", key.Id, key.Name, strings.Join(key.Scopes, ","), key.Created.Format(settings.DateLayout()), expires, lastUsed)
+	}
+	table.Flush()
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"todo_app/pkg/blobs"
+	"todo_app/pkg/tasks"
+)
+
+// printTaskTable writes the tasks of taskList in the default order, with
+// dates in the date format.
+func printTaskTable(out io.Writer, taskList tasks.TaskList) {
+	table := tabwriter.NewWriter(out, 1, 1, 1, ' ', 0)
+	fmt.Fprintln(table, "Task Number\t", "Name\t", "Description\t", "Date\t", "Task Status\t")
+	for _, task := range taskList.Sorted(settings.DefaultSort) {
+		shown := *task
+		shown.Date = settings.FormatDate(task.Date)
+		fmt.Fprintln(table, shown.String())
+	}
+	table.Flush()
+}
+
+func attachmentsMenu(reader *bufio.Reader, out io.Writer, taskList tasks.TaskList, store *blobs.Store) {
+	for {
+		fmt.Fprintln(out, "Enter one of the following commands or 0 to return to the previous menu:")
+		fmt.Fprintln(out, "  attach <task number> <file path>")
+		fmt.Fprintln(out, "  list <task number>")
+		fmt.Fprintln(out, "  extract <task number> <attachment name> <destination path>")
+		fmt.Fprintln(out, "  remove <task number> <attachment name>")
+		printTaskTable(out, taskList)
+		input, inputErr := reader.ReadString('\n')
+		if inputErr != nil {
+			fmt.Fprintln(out, inputErr)
+			return
+		}
+		fields := strings.Fields(input)
+		if len(fields) == 1 && fields[0] == "0" {
+			return
+		}
+		if len(fields) < 2 {
+			fmt.Fprintln(out, "Please enter an appropiate input")
+			continue
+		}
+		taskId, err := strconv.Atoi(fields[1])
+		if err != nil {
+			fmt.Fprintln(out, "Please enter a valid input for the task number")
+			continue
+		}
+		switch strings.ToLower(fields[0]) {
+		case "attach":
+			if len(fields) < 3 {
+				fmt.Fprintln(out, "Please enter the path of the file to attach")
+				continue
+			}
+			filePath := strings.Join(fields[2:], " ")
+			file, err := os.Open(filePath)
+			if err != nil {
+				fmt.Fprintln(out, err)
+				continue
+			}
+			attachment, err := taskList.Attach(taskId, filepath.Base(filePath), file)
+			file.Close()
+			if err != nil {
+				fmt.Fprintln(out, err)
+				continue
+			}
+			fmt.Fprintf(out, "Attached %q (%d bytes) to task #%d\n", attachment.Name, attachment.Size, taskId)
+		case "list":
+			task, err := taskList.GetTask(taskId)
+			if err != nil {
+				fmt.Fprintln(out, err)
+				continue
+			}
+			table := tabwriter.NewWriter(out, 1, 1, 1, ' ', 0)
+			fmt.Fprintln(table, "Name\t", "Size\t", "SHA-256\t")
+			for _, attachment := range task.Attachments {
+				fmt.Fprintf(table, "%q\t %d\t %s\t\n", attachment.Name, attachment.Size, attachment.Hash)
+			}
+			table.Flush()
+		case "extract":
+			if len(fields) < 4 {
+				fmt.Fprintln(out, "Please enter the attachment name and where to save it")
+				continue
+			}
+			task, err := taskList.GetTask(taskId)
+			if err != nil {
+				fmt.Fprintln(out, err)
+				continue
+			}
+			attachment, err := task.GetAttachment(strings.Join(fields[2:len(fields)-1], " "))
+			if err != nil {
+				fmt.Fprintln(out, err)
+				continue
+			}
+			err = extractBlob(store, attachment.Hash, fields[len(fields)-1])
+			if err != nil {
+				fmt.Fprintln(out, err)
+				continue
+			}
+			fmt.Fprintf(out, "Saved %q to %q\n", attachment.Name, fields[len(fields)-1])
+		case "remove":
+			if len(fields) < 3 {
+				fmt.Fprintln(out, "Please enter the name of the attachment to remove")
+				continue
+			}
+			err := taskList.Detach(taskId, strings.Join(fields[2:], " "))
+			if err != nil {
+				fmt.Fprintln(out, err)
+				continue
+			}
+		default:
+			fmt.Fprintln(out, "Please enter an appropiate input")
+		}
+	}
+}
+
+func extractBlob(store *blobs.Store, hash, destination string) error {
+	blob, err := store.Open(hash)
+	if err != nil {
+		return err
+	}
+	defer blob.Close()
+	out, err := os.OpenFile(destination, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, blob)
+	if err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
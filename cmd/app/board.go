@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"todo_app/pkg/board"
+	"todo_app/pkg/tasks"
+
+	"github.com/google/uuid"
+)
+
+// loadBoards reads the columns each user configured, in the order they are
+// shown. Users without any saved column get the default board.
+func loadBoards(path string) map[uuid.UUID]*board.Board {
+	boards := make(map[uuid.UUID]*board.Board)
+	file, fileErr := os.Open(path)
+	if fileErr != nil {
+		log.Println("boards file could not be opened")
+		return boards
+	}
+	defer file.Close()
+
+	csvReader := csv.NewReader(file)
+	for {
+		rec, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		userId := uuid.MustParse(rec[0])
+		wipLimit, convErr := strconv.Atoi(rec[3])
+		if convErr != nil {
+			log.Fatal("invalid board record")
+		}
+		userBoard, found := boards[userId]
+		if !found {
+			userBoard = &board.Board{}
+			boards[userId] = userBoard
+		}
+		userBoard.Columns = append(userBoard.Columns, board.Column{Status: rec[1], Title: rec[2], WIPLimit: wipLimit})
+	}
+	return boards
+}
+
+func saveBoards(path string, boards map[uuid.UUID]*board.Board) {
+	file, fileErr := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if fileErr != nil {
+		log.Fatal("boards file could not be open")
+	}
+	defer file.Close()
+
+	csvWriter := csv.NewWriter(file)
+	for userId, userBoard := range boards {
+		for _, column := range userBoard.Columns {
+			record := []string{userId.String(), column.Status, column.Title, strconv.Itoa(column.WIPLimit)}
+			err := csvWriter.Write(record)
+			if err != nil {
+				log.Fatal("couldnt write column to boards file")
+			}
+		}
+	}
+	csvWriter.Flush()
+}
+
+func boardMenu(reader *bufio.Reader, out io.Writer, taskList tasks.TaskList, userBoard *board.Board) {
+	for {
+		userBoard.Render(out, taskList, terminalWidth())
+		fmt.Fprintln(out, "Enter one of the following commands or 0 to return to the previous menu:")
+		fmt.Fprintln(out, "  move <task number> <column>")
+		fmt.Fprintln(out, "  add <status> [wip limit] [title]")
+		fmt.Fprintln(out, "  remove <column>")
+		fmt.Fprintln(out, "  limit <column> <wip limit> (0 for no limit)")
+		input, inputErr := reader.ReadString('\n')
+		if inputErr != nil {
+			fmt.Fprintln(out, inputErr)
+			return
+		}
+		fields := strings.Fields(input)
+		if len(fields) == 0 {
+			fmt.Fprintln(out, "Please enter an appropiate input")
+			continue
+		}
+		switch strings.ToLower(fields[0]) {
+		case "0":
+			return
+		case "move":
+			if len(fields) < 3 {
+				fmt.Fprintln(out, "Please enter an appropiate input")
+				continue
+			}
+			taskId, err := strconv.Atoi(fields[1])
+			if err != nil {
+				fmt.Fprintln(out, "Please enter a valid input for the task number")
+				continue
+			}
+			err = userBoard.Move(taskList, taskId, strings.Join(fields[2:], " "))
+			if err != nil {
+				fmt.Fprintln(out, err)
+				continue
+			}
+		case "add":
+			if len(fields) < 2 {
+				fmt.Fprintln(out, "Please enter the status of the new column")
+				continue
+			}
+			wipLimit := 0
+			title := ""
+			if len(fields) > 2 {
+				limit, err := strconv.Atoi(fields[2])
+				if err != nil {
+					fmt.Fprintln(out, "Please enter a valid input for the WIP limit")
+					continue
+				}
+				wipLimit = limit
+				title = strings.Join(fields[3:], " ")
+			}
+			err := userBoard.AddColumn(fields[1], title, wipLimit)
+			if err != nil {
+				fmt.Fprintln(out, err)
+				continue
+			}
+		case "remove":
+			if len(fields) < 2 {
+				fmt.Fprintln(out, "Please enter the column to remove")
+				continue
+			}
+			err := userBoard.RemoveColumn(strings.Join(fields[1:], " "))
+			if err != nil {
+				fmt.Fprintln(out, err)
+				continue
+			}
+		case "limit":
+			if len(fields) < 3 {
+				fmt.Fprintln(out, "Please enter an appropiate input")
+				continue
+			}
+			wipLimit, err := strconv.Atoi(fields[len(fields)-1])
+			if err != nil {
+				fmt.Fprintln(out, "Please enter a valid input for the WIP limit")
+				continue
+			}
+			err = userBoard.SetWIPLimit(strings.Join(fields[1:len(fields)-1], " "), wipLimit)
+			if err != nil {
+				fmt.Fprintln(out, err)
+				continue
+			}
+		default:
+			fmt.Fprintln(out, "Please enter an appropiate input")
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"todo_app/pkg/tasks"
+)
+
+func bulkMenu(reader *bufio.Reader, out io.Writer, taskList tasks.TaskList) {
+	for {
+		fmt.Fprintln(out, "Enter one of the following commands or 0 to return to the previous menu.")
+		fmt.Fprintln(out, "Select tasks by number and range (example: '3-7,9') or by filter (example: 'status:pending,tag:release')")
+		fmt.Fprintln(out, "  complete <selection>")
+		fmt.Fprintln(out, "  delete <selection>")
+		fmt.Fprintln(out, "  tag <selection> <+tag to add|-tag to remove>...")
+		fmt.Fprintln(out, "  reschedule <selection> <days to move, can be negative>")
+		fmt.Fprintln(out, "  priority <selection> <low|medium|high>")
+		printTaskTable(out, taskList)
+		input, inputErr := reader.ReadString('\n')
+		if inputErr != nil {
+			fmt.Fprintln(out, inputErr)
+			return
+		}
+		fields := strings.Fields(input)
+		if len(fields) == 1 && fields[0] == "0" {
+			return
+		}
+		if len(fields) < 2 {
+			fmt.Fprintln(out, "Please enter an appropiate input")
+			continue
+		}
+		ids, err := taskList.Select(fields[1])
+		if err != nil {
+			fmt.Fprintln(out, err)
+			continue
+		}
+		var results []tasks.BatchResult
+		switch strings.ToLower(fields[0]) {
+		case "complete":
+			results, err = taskList.BatchComplete(ids)
+		case "delete":
+			fmt.Fprintf(out, "Deleting %d tasks, type Y to confirm, any other input to cancel\n", len(ids))
+			confirm, confErr := reader.ReadString('\n')
+			if confErr != nil {
+				fmt.Fprintln(out, confErr)
+				continue
+			}
+			if strings.ToLower(strings.TrimSpace(confirm)) != "y" {
+				continue
+			}
+			results, err = taskList.BatchDelete(ids)
+		case "tag":
+			var add, remove []string
+			for _, tag := range fields[2:] {
+				if strings.HasPrefix(tag, "-") {
+					remove = append(remove, tag[1:])
+				} else {
+					add = append(add, strings.TrimPrefix(tag, "+"))
+				}
+			}
+			if len(add) == 0 && len(remove) == 0 {
+				fmt.Fprintln(out, "Please enter the tags to add or remove")
+				continue
+			}
+			results, err = taskList.BatchRetag(ids, add, remove)
+		case "reschedule":
+			if len(fields) != 3 {
+				fmt.Fprintln(out, "Please enter how many days to move the tasks")
+				continue
+			}
+			days, convErr := strconv.Atoi(fields[2])
+			if convErr != nil {
+				fmt.Fprintln(out, "Please enter a valid number of days")
+				continue
+			}
+			results, err = taskList.BatchReschedule(ids, days)
+		case "priority":
+			if len(fields) != 3 {
+				fmt.Fprintln(out, "Please enter the new priority")
+				continue
+			}
+			results, err = taskList.BatchSetPriority(ids, fields[2])
+		default:
+			fmt.Fprintln(out, "Please enter an appropiate input")
+			continue
+		}
+		for _, result := range results {
+			if result.Err != nil {
+				fmt.Fprintf(out, "Task #%d: %v\n", result.TaskId, result.Err)
+			} else {
+				fmt.Fprintf(out, "Task #%d: ok\n", result.TaskId)
+			}
+		}
+		if err != nil {
+			fmt.Fprintln(out, err)
+		}
+	}
+}
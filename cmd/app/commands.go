@@ -0,0 +1,515 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"todo_app/pkg/auth"
+	"todo_app/pkg/config"
+	"todo_app/pkg/store"
+	"todo_app/pkg/tasks"
+)
+
+// Exit codes of the commands, for scripts to tell failures apart.
+const (
+	exitOK           = 0
+	exitFailure      = 1
+	exitUsage        = 2
+	exitUnauthorized = 3
+)
+
+const (
+	sessionFile = "cli_session"
+	tokenEnv    = "TODO_TOKEN"
+)
+
+const usage = `usage: app [settings] [command]
+Without a command the interactive menu starts.
+
+Commands:
+  login [--print] <username or email>
+        reads the password, and a two-factor code if asked for one, from stdin.
+        --print writes the session token to stdout, for ` + tokenEnv + `, instead of keeping it
+  logout
+  add <name> [--desc text, - to read it from stdin] [--due date, default today]
+        [--priority low|medium|high] [--tags a,b] [--json] [--editor]
+        prints the number of the new task. --editor opens the task in $VISUAL or $EDITOR
+        to finish it, then the name is optional
+  list [--status pending|complete] [--tag tag] [--json]
+  done <selection>
+  rm <selection>
+  edit <task number> [--name name] [--desc text or -] [--due date]
+        [--priority low|medium|high] [--tags a,b] [--json] [--editor]
+  make-admin <username or email>
+        anyone can name the first administrator, then it takes the session of one
+  tui   full-screen mode to browse and change tasks with the keyboard
+
+A selection is task numbers and ranges like 3-7,9 or a filter like status:pending,tag:release.
+Task commands use the session of the last login, or the session token or API key in ` + tokenEnv + `.
+Exit codes: 0 done, 1 failed, 2 wrong usage, 3 not logged in or not allowed.
+
+Settings are taken from these flags, else the TODO_ environment variables,
+else the config file, else their defaults. Dates are typed in the date format.
+`
+
+var (
+	usageErr       = errors.New("wrong usage")
+	notLoggedInErr = auth.SessionErr("You are not logged in, run app login or set " + tokenEnv)
+)
+
+// taskJSON is how tasks are printed with --json, the same as the API.
+type taskJSON struct {
+	Id          int      `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Date        string   `json:"date"`
+	Status      string   `json:"status"`
+	Priority    string   `json:"priority"`
+	Tags        []string `json:"tags"`
+}
+
+func newTaskJSON(task tasks.Task) taskJSON {
+	tags := task.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	return taskJSON{Id: task.Id, Name: task.Name, Description: task.Description, Date: task.Date, Status: task.TaskStatus, Priority: task.Priority, Tags: tags}
+}
+
+// runCommand runs the command in args and returns the exit code. Results go
+// to stdout, everything else to stderr.
+func runCommand(ctx appContext, args []string) int {
+	var err error
+	switch args[0] {
+	case "login":
+		err = ctx.login(args[1:])
+	case "logout":
+		err = ctx.logout(args[1:])
+	case "add":
+		err = ctx.add(args[1:])
+	case "list":
+		err = ctx.list(args[1:])
+	case "done":
+		err = ctx.batch(args[1:], func(taskList tasks.TaskList, ids []int) ([]tasks.BatchResult, error) {
+			return taskList.BatchComplete(ids)
+		})
+	case "rm":
+		err = ctx.batch(args[1:], func(taskList tasks.TaskList, ids []int) ([]tasks.BatchResult, error) {
+			return taskList.BatchDelete(ids)
+		})
+	case "edit":
+		err = ctx.edit(args[1:])
+	case "tui":
+		err = ctx.fullScreen(args[1:])
+	case "make-admin":
+		if len(args) != 2 {
+			err = usageErr
+			break
+		}
+		err = makeAdmin(ctx, args[1])
+	case "help", "-h", "--help":
+		fmt.Fprint(ctx.stdout, usage, config.Help())
+		return exitOK
+	default:
+		err = usageErr
+	}
+	if err == nil {
+		return exitOK
+	}
+	if err == usageErr || err == flag.ErrHelp {
+		fmt.Fprint(ctx.stderr, usage, config.Help())
+		return exitUsage
+	}
+	fmt.Fprintln(ctx.stderr, err)
+	switch err.(type) {
+	case auth.SessionErr, auth.APIKeyErr, auth.ScopeErr, auth.AccessErr, auth.VerificationErr, auth.PermissionErr:
+		return exitUnauthorized
+	}
+	return exitFailure
+}
+
+// parseArgs parses flags wherever they are among args, so the name of a task
+// can come first, and returns the other arguments. Everything after "--" is
+// an argument.
+func parseArgs(flags *flag.FlagSet, args []string) ([]string, error) {
+	var rest []string
+	for i, arg := range args {
+		if arg == "--" {
+			args, rest = args[:i], args[i+1:]
+			break
+		}
+	}
+	var positional []string
+	for {
+		err := flags.Parse(args)
+		if err != nil {
+			return nil, usageErr
+		}
+		args = flags.Args()
+		if len(args) == 0 {
+			return append(positional, rest...), nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+func (ctx appContext) newFlags(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(ctx.stderr)
+	flags.Usage = func() {}
+	return flags
+}
+
+// readText reads value, or all of stdin when value is "-".
+func (ctx appContext) readText(value string) (string, error) {
+	if value != "-" {
+		return value, nil
+	}
+	text, err := io.ReadAll(ctx.stdin)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(text), "\r\n"), nil
+}
+
+// token is the session token or API key commands are run with.
+func (ctx appContext) token() (string, error) {
+	token := os.Getenv(tokenEnv)
+	if token != "" {
+		return token, nil
+	}
+	stored, err := os.ReadFile(dataPath(sessionFile))
+	if err != nil || strings.TrimSpace(string(stored)) == "" {
+		return "", notLoggedInErr
+	}
+	return strings.TrimSpace(string(stored)), nil
+}
+
+// authenticate returns who the token belongs to. API keys need scope.
+func (ctx appContext) authenticate(scope string) (auth.User, error) {
+	token, err := ctx.token()
+	if err != nil {
+		return auth.User{}, err
+	}
+	if auth.IsAPIKey(token) {
+		user, _, err := ctx.apiKeys.Verify(ctx.users, token, scope)
+		return user, err
+	}
+	return ctx.sessions.Lookup(ctx.users, token)
+}
+
+// save writes everything a command can change: tasks, and the sessions and
+// keys whose last use was updated.
+func (ctx appContext) save() error {
+	err := store.SaveTasks(dataPath("tasks.csv"), ctx.userTasks)
+	if err != nil {
+		return errors.New("couldnt write tasks file")
+	}
+	err = store.SaveAttachments(dataPath("attachments.csv"), ctx.userTasks)
+	if err != nil {
+		return errors.New("couldnt write attachments file")
+	}
+	err = store.SaveTimeEntries(dataPath("time_entries.csv"), ctx.userTasks)
+	if err != nil {
+		return errors.New("couldnt write time entries file")
+	}
+	return ctx.saveCredentials()
+}
+
+// saveCredentials writes only the sessions and keys, for commands that change
+// no tasks, so they don't overwrite tasks a running server changed since.
+func (ctx appContext) saveCredentials() error {
+	err := store.SaveSessions(dataPath("sessions.csv"), ctx.sessions)
+	if err != nil {
+		return errors.New("couldnt write sessions file")
+	}
+	err = store.SaveAPIKeys(dataPath("apikeys.csv"), ctx.apiKeys)
+	if err != nil {
+		return errors.New("couldnt write API keys file")
+	}
+	return nil
+}
+
+func (ctx appContext) login(args []string) error {
+	flags := ctx.newFlags("login")
+	printToken := flags.Bool("print", false, "")
+	positional, err := parseArgs(flags, args)
+	if err != nil || len(positional) != 1 {
+		return usageErr
+	}
+	fmt.Fprintln(ctx.stderr, "Please enter your password")
+	password, err := ctx.readLine()
+	if err != nil {
+		return err
+	}
+	user, err := ctx.throttle.LogIn(ctx.users, positional[0], password, "cli")
+	if required, ok := err.(auth.SecondFactorRequired); ok {
+		fmt.Fprintln(ctx.stderr, required)
+		code, readErr := ctx.readLine()
+		if readErr != nil {
+			return readErr
+		}
+		user, err = ctx.throttle.CompleteLogIn(ctx.users, required.Challenge, code, "cli")
+	}
+	if err != nil {
+		return err
+	}
+	// logging in can upgrade the password hash and spends two-factor codes
+	err = store.SaveUsers(dataPath("users.csv"), ctx.users)
+	if err != nil {
+		return errors.New("error writing to file")
+	}
+	token, _, err := ctx.sessions.Create(user)
+	if err != nil {
+		return err
+	}
+	err = store.SaveSessions(dataPath("sessions.csv"), ctx.sessions)
+	if err != nil {
+		return errors.New("couldnt write sessions file")
+	}
+	if *printToken {
+		fmt.Fprintln(ctx.stdout, token)
+		return nil
+	}
+	err = os.WriteFile(dataPath(sessionFile), []byte(token+"\n"), 0600)
+	if err != nil {
+		return errors.New("couldnt write session file")
+	}
+	fmt.Fprintf(ctx.stderr, "Logged in as %q\n", user.Username)
+	return nil
+}
+
+func (ctx appContext) logout(args []string) error {
+	if len(args) != 0 {
+		return usageErr
+	}
+	token, err := ctx.token()
+	if err != nil {
+		return err
+	}
+	if auth.IsAPIKey(token) {
+		return auth.APIKeyErr("API keys are revoked from the API keys menu, not logged out")
+	}
+	// the stored session goes either way, it is of no use once revoked
+	if os.Getenv(tokenEnv) == "" {
+		os.Remove(dataPath(sessionFile))
+	}
+	err = ctx.sessions.Revoke(token)
+	if err != nil {
+		return err
+	}
+	return ctx.saveCredentials()
+}
+
+func (ctx appContext) add(args []string) error {
+	flags := ctx.newFlags("add")
+	desc := flags.String("desc", "", "")
+	due := flags.String("due", time.Now().Format(settings.DateLayout()), "")
+	priority := flags.String("priority", "", "")
+	tags := flags.String("tags", "", "")
+	asJSON := flags.Bool("json", false, "")
+	useEditor := flags.Bool("editor", false, "")
+	positional, err := parseArgs(flags, args)
+	if err != nil || len(positional) > 1 || (len(positional) == 0 && !*useEditor) {
+		return usageErr
+	}
+	user, err := ctx.authenticate(auth.ScopeTasksWrite)
+	if err != nil {
+		return err
+	}
+	err = auth.CanCreateTasks(user)
+	if err != nil {
+		return err
+	}
+	if *priority != "" {
+		*priority, err = tasks.ParsePriority(*priority)
+		if err != nil {
+			return err
+		}
+	}
+	date, err := settings.ParseDate(*due)
+	if err != nil {
+		return err
+	}
+	description, err := ctx.readText(*desc)
+	if err != nil {
+		return err
+	}
+	written := tasks.Task{Description: description, Date: date, TaskStatus: "pending", Priority: *priority, Tags: tasks.NormalizeTags(tasks.SplitTags(*tags))}
+	if len(positional) == 1 {
+		written.Name = positional[0]
+	}
+	if *useEditor {
+		written, err = ctx.writeTask(written)
+		if err != nil {
+			return err
+		}
+	}
+	taskList := ctx.taskList(user)
+	newTask, err := addWritten(taskList, written)
+	if err != nil {
+		return err
+	}
+	err = ctx.save()
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return json.NewEncoder(ctx.stdout).Encode(newTaskJSON(*taskList[newTask.Id]))
+	}
+	fmt.Fprintln(ctx.stdout, newTask.Id)
+	return nil
+}
+
+func (ctx appContext) list(args []string) error {
+	flags := ctx.newFlags("list")
+	status := flags.String("status", "", "")
+	tag := flags.String("tag", "", "")
+	asJSON := flags.Bool("json", false, "")
+	positional, err := parseArgs(flags, args)
+	if err != nil || len(positional) != 0 {
+		return usageErr
+	}
+	if *status != "" && *status != "pending" && *status != "complete" {
+		return usageErr
+	}
+	user, err := ctx.authenticate(auth.ScopeTasksRead)
+	if err != nil {
+		return err
+	}
+	taskList := ctx.taskList(user)
+	var ids []int
+	for _, task := range taskList.Sorted(settings.DefaultSort) {
+		if (*status == "" || task.TaskStatus == *status) && (*tag == "" || task.HasTag(*tag)) {
+			ids = append(ids, task.Id)
+		}
+	}
+	err = ctx.saveCredentials()
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		list := []taskJSON{}
+		for _, id := range ids {
+			list = append(list, newTaskJSON(*taskList[id]))
+		}
+		return json.NewEncoder(ctx.stdout).Encode(list)
+	}
+	filtered := make(tasks.TaskList)
+	for _, id := range ids {
+		filtered[id] = taskList[id]
+	}
+	printTaskTable(ctx.stdout, filtered)
+	return nil
+}
+
+// batch runs apply on the tasks selected by args. Like the bulk menu it
+// changes all of them or none, the ones that could not be changed are listed.
+func (ctx appContext) batch(args []string, apply func(taskList tasks.TaskList, ids []int) ([]tasks.BatchResult, error)) error {
+	if len(args) == 0 {
+		return usageErr
+	}
+	user, err := ctx.authenticate(auth.ScopeTasksWrite)
+	if err != nil {
+		return err
+	}
+	taskList := ctx.taskList(user)
+	ids, err := taskList.Select(strings.Join(args, ","))
+	if err != nil {
+		return err
+	}
+	results, err := apply(taskList, ids)
+	for _, result := range results {
+		if result.Err != nil {
+			fmt.Fprintf(ctx.stderr, "Task #%d: %v\n", result.TaskId, result.Err)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return ctx.save()
+}
+
+func (ctx appContext) edit(args []string) error {
+	flags := ctx.newFlags("edit")
+	name := flags.String("name", "", "")
+	desc := flags.String("desc", "", "")
+	due := flags.String("due", "", "")
+	priority := flags.String("priority", "", "")
+	tags := flags.String("tags", "", "")
+	asJSON := flags.Bool("json", false, "")
+	useEditor := flags.Bool("editor", false, "")
+	positional, err := parseArgs(flags, args)
+	if err != nil || len(positional) != 1 || flags.NFlag() == 0 || (flags.NFlag() == 1 && *asJSON) {
+		return usageErr
+	}
+	id, err := strconv.Atoi(positional[0])
+	if err != nil {
+		return usageErr
+	}
+	set := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	user, err := ctx.authenticate(auth.ScopeTasksWrite)
+	if err != nil {
+		return err
+	}
+	taskList := ctx.taskList(user)
+	task, err := taskList.GetTask(id)
+	if err != nil {
+		return err
+	}
+	// everything is checked before anything changes
+	edited := *task
+	if set["name"] {
+		if strings.TrimSpace(*name) == "" {
+			return tasks.TaskNameErr
+		}
+		edited.Name = *name
+	}
+	if set["desc"] {
+		edited.Description, err = ctx.readText(*desc)
+		if err != nil {
+			return err
+		}
+	}
+	if set["due"] {
+		edited.Date, err = settings.ParseDate(*due)
+		if err != nil {
+			return err
+		}
+	}
+	if set["priority"] {
+		edited.Priority, err = tasks.ParsePriority(*priority)
+		if err != nil {
+			return err
+		}
+	}
+	if set["tags"] {
+		edited.Tags = tasks.NormalizeTags(tasks.SplitTags(*tags))
+	}
+	if *useEditor {
+		written, err := ctx.writeTask(edited)
+		if err != nil {
+			return err
+		}
+		applyWritten(&edited, written)
+	}
+	*task = edited
+	err = ctx.save()
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return json.NewEncoder(ctx.stdout).Encode(newTaskJSON(*task))
+	}
+	return nil
+}
@@ -0,0 +1,390 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"todo_app/pkg/auth"
+	"todo_app/pkg/config"
+	"todo_app/pkg/tasks"
+)
+
+func TestParseArgs(t *testing.T) {
+	tests := []struct {
+		name           string
+		args           []string
+		expected       []string
+		expected_due   string
+		expected_error error
+	}{
+		{name: "flags after the name", args: []string{"Write report", "--due", "01-01-2030"}, expected: []string{"Write report"}, expected_due: "01-01-2030"},
+		{name: "flags before the name", args: []string{"-due=01-01-2030", "Write report"}, expected: []string{"Write report"}, expected_due: "01-01-2030"},
+		{name: "arguments after --", args: []string{"--due", "01-01-2030", "--", "--not-a-flag"}, expected: []string{"--not-a-flag"}, expected_due: "01-01-2030"},
+		{name: "unknown flag", args: []string{"Write report", "--when", "today"}, expected_error: usageErr},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			flags := flag.NewFlagSet("add", flag.ContinueOnError)
+			flags.SetOutput(io.Discard)
+			due := flags.String("due", "", "")
+
+			got, err := parseArgs(flags, test.args)
+
+			if err != test.expected_error {
+				t.Fatalf("got %q, expected %q", err, test.expected_error)
+			}
+			if test.expected_error == nil && (!reflect.DeepEqual(got, test.expected) || *due != test.expected_due) {
+				t.Errorf("got %q and due %q, expected %q and %q", got, *due, test.expected, test.expected_due)
+			}
+		})
+	}
+}
+
+func TestMakeAdminCommand(t *testing.T) {
+	settings.DataDir = t.TempDir()
+	t.Cleanup(func() { settings = config.Default() })
+	ctx, out, _ := newTestContext(t, "")
+	ctx.sessions = auth.NewSessions(time.Hour, time.Hour)
+	ctx.users.RegisterUser("other@gmail.com", "other", "Abc12345!")
+	ctx.users.RegisterUser("third@gmail.com", "third", "Abc12345!")
+
+	code := runCommand(ctx, []string{"make-admin", "tester"})
+
+	if code != exitOK || ctx.users.UsersByUsername["tester"].Role != auth.RoleAdmin {
+		t.Fatalf("expected anyone to name the first administrator, got %d: %s", code, out)
+	}
+
+	t.Run("without a session", func(t *testing.T) {
+		t.Setenv(tokenEnv, "")
+
+		code := runCommand(ctx, []string{"make-admin", "other"})
+
+		if code != exitUnauthorized || ctx.users.UsersByUsername["other"].Role != auth.RoleUser {
+			t.Errorf("expected a second administrator to need a session, got %d", code)
+		}
+	})
+
+	t.Run("session of a user", func(t *testing.T) {
+		token, _, _ := ctx.sessions.Create(*ctx.users.UsersByUsername["other"])
+		t.Setenv(tokenEnv, token)
+
+		code := runCommand(ctx, []string{"make-admin", "other"})
+
+		if code != exitUnauthorized || ctx.users.UsersByUsername["other"].Role != auth.RoleUser {
+			t.Errorf("expected users not to make themselves administrators, got %d", code)
+		}
+	})
+
+	t.Run("session of an administrator", func(t *testing.T) {
+		token, _, _ := ctx.sessions.Create(*ctx.users.UsersByUsername["tester"])
+		t.Setenv(tokenEnv, token)
+
+		code := runCommand(ctx, []string{"make-admin", "third"})
+
+		if code != exitOK || ctx.users.UsersByUsername["third"].Role != auth.RoleAdmin {
+			t.Errorf("expected administrators to name others, got %d: %s", code, out)
+		}
+	})
+}
+
+func TestTaskCommands(t *testing.T) {
+	tests := []struct {
+		name          string
+		args          []string
+		expected_code int
+		output        string
+		check         func(t *testing.T, taskList tasks.TaskList)
+	}{
+		{name: "add", args: []string{"add", "Write report", "--due", "01-01-2030", "--priority", "high", "--tags", "Docs"}, expected_code: exitOK, output: "3\n", check: func(t *testing.T, taskList tasks.TaskList) {
+			if task := taskList[3]; task == nil || task.Priority != "high" || !task.HasTag("docs") {
+				t.Errorf("expected the task with its flags, got %v", task)
+			}
+		}},
+		{name: "add without a name", args: []string{"add", "--due", "01-01-2030"}, expected_code: exitUsage},
+		{name: "add with an invalid date", args: []string{"add", "Write report", "--due", "someday"}, expected_code: exitFailure},
+		{name: "add with an unknown flag", args: []string{"add", "Write report", "--when", "today"}, expected_code: exitUsage},
+		{name: "list pending", args: []string{"list", "--status", "pending"}, expected_code: exitOK, output: `"first"`},
+		{name: "list by tag", args: []string{"list", "--tag", "release"}, expected_code: exitOK, output: `"second"`},
+		{name: "list an unknown status", args: []string{"list", "--status", "late"}, expected_code: exitUsage},
+		{name: "done", args: []string{"done", "1"}, expected_code: exitOK, check: func(t *testing.T, taskList tasks.TaskList) {
+			if taskList[1].TaskStatus != "complete" {
+				t.Errorf("expected the task to be complete, got %v", taskList[1])
+			}
+		}},
+		{name: "done an unknown task", args: []string{"done", "9"}, expected_code: exitFailure},
+		{name: "rm a range", args: []string{"rm", "1-2"}, expected_code: exitOK, check: func(t *testing.T, taskList tasks.TaskList) {
+			if len(taskList) != 0 {
+				t.Errorf("expected the tasks to be deleted, got %v", taskList)
+			}
+		}},
+		{name: "rm without a selection", args: []string{"rm"}, expected_code: exitUsage},
+		{name: "edit", args: []string{"edit", "1", "--name", "Renamed"}, expected_code: exitOK, check: func(t *testing.T, taskList tasks.TaskList) {
+			if taskList[1].Name != "Renamed" {
+				t.Errorf("expected the task to be renamed, got %v", taskList[1])
+			}
+		}},
+		{name: "edit without changes", args: []string{"edit", "1"}, expected_code: exitUsage},
+		{name: "edit with an empty name", args: []string{"edit", "1", "--name", " "}, expected_code: exitFailure},
+		{name: "unknown command", args: []string{"archive", "1"}, expected_code: exitUsage},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, stdout, stderr := newCommandContext(t, "")
+			logInTester(t, ctx)
+			user := *ctx.users.UsersByUsername["tester"]
+			taskList := ctx.taskList(user)
+			taskList.AddTask("first", "", "01-01-2030")
+			second, _ := taskList.AddTask("second", "", "02-01-2030")
+			taskList.SetTags(second.Id, []string{"release"})
+			taskList.CompleteTask(second.Id)
+
+			code := runCommand(ctx, test.args)
+
+			if code != test.expected_code {
+				t.Fatalf("got exit code %d, expected %d: %s", code, test.expected_code, stderr)
+			}
+			if !strings.Contains(stdout.String(), test.output) {
+				t.Errorf("expected %q in the output, got %s", test.output, stdout)
+			}
+			if test.check != nil {
+				test.check(t, taskList)
+			}
+		})
+	}
+}
+
+func TestCommandsJSON(t *testing.T) {
+	ctx, stdout, _ := newCommandContext(t, "")
+	logInTester(t, ctx)
+
+	t.Run("add", func(t *testing.T) {
+		stdout.Reset()
+
+		code := runCommand(ctx, []string{"add", "Write report", "--due", "01-01-2030", "--tags", "docs", "--json"})
+
+		var got taskJSON
+		err := json.Unmarshal(stdout.Bytes(), &got)
+		expected := taskJSON{Id: 1, Name: "Write report", Date: "01-01-2030", Status: "pending", Tags: []string{"docs"}}
+		if code != exitOK || err != nil || !reflect.DeepEqual(got, expected) {
+			t.Errorf("got %d and %s, expected %v", code, stdout, expected)
+		}
+	})
+
+	t.Run("edit", func(t *testing.T) {
+		stdout.Reset()
+
+		code := runCommand(ctx, []string{"edit", "1", "--priority", "high", "--json"})
+
+		var got taskJSON
+		err := json.Unmarshal(stdout.Bytes(), &got)
+		if code != exitOK || err != nil || got.Priority != "high" {
+			t.Errorf("got %d and %s, expected the edited task", code, stdout)
+		}
+	})
+
+	t.Run("list", func(t *testing.T) {
+		stdout.Reset()
+
+		code := runCommand(ctx, []string{"list", "--json"})
+
+		var got []taskJSON
+		err := json.Unmarshal(stdout.Bytes(), &got)
+		if code != exitOK || err != nil || len(got) != 1 || got[0].Name != "Write report" {
+			t.Errorf("got %d and %s, expected the task", code, stdout)
+		}
+	})
+
+	t.Run("list nothing", func(t *testing.T) {
+		stdout.Reset()
+
+		runCommand(ctx, []string{"list", "--status", "complete", "--json"})
+
+		if strings.TrimSpace(stdout.String()) != "[]" {
+			t.Errorf("expected an empty list, got %s", stdout)
+		}
+	})
+}
+
+func TestCommandAuthentication(t *testing.T) {
+	t.Run("not logged in", func(t *testing.T) {
+		ctx, _, stderr := newCommandContext(t, "")
+
+		code := runCommand(ctx, []string{"list"})
+
+		if code != exitUnauthorized || !strings.Contains(stderr.String(), notLoggedInErr.Error()) {
+			t.Errorf("got %d and %s, expected to be asked to log in", code, stderr)
+		}
+	})
+
+	t.Run("revoked session", func(t *testing.T) {
+		ctx, _, _ := newCommandContext(t, "")
+		token := logInTester(t, ctx)
+		ctx.sessions.Revoke(token)
+
+		code := runCommand(ctx, []string{"list"})
+
+		if code != exitUnauthorized {
+			t.Errorf("got exit code %d, expected %d", code, exitUnauthorized)
+		}
+	})
+
+	t.Run("token in the environment over the session file", func(t *testing.T) {
+		ctx, _, _ := newCommandContext(t, "")
+		logInTester(t, ctx)
+		t.Setenv(tokenEnv, "not a token")
+
+		code := runCommand(ctx, []string{"list"})
+
+		if code != exitUnauthorized {
+			t.Errorf("got exit code %d, expected %d", code, exitUnauthorized)
+		}
+	})
+
+	t.Run("API key in the environment", func(t *testing.T) {
+		ctx, stdout, _ := newCommandContext(t, "")
+		key, _, _ := ctx.apiKeys.Create(*ctx.users.UsersByUsername["tester"], "script", []string{auth.ScopeTasksRead}, time.Time{})
+		t.Setenv(tokenEnv, key)
+
+		listed := runCommand(ctx, []string{"list", "--json"})
+		added := runCommand(ctx, []string{"add", "Write report"})
+
+		if listed != exitOK || strings.TrimSpace(stdout.String()) != "[]" {
+			t.Errorf("expected the key to list tasks, got %d and %s", listed, stdout)
+		}
+		if added != exitUnauthorized {
+			t.Errorf("expected a read-only key not to add tasks, got %d", added)
+		}
+	})
+}
+
+func TestLoginAndLogoutCommands(t *testing.T) {
+	t.Run("session kept in the session file", func(t *testing.T) {
+		ctx, _, stderr := newCommandContext(t, "Abc12345!\n")
+
+		code := runCommand(ctx, []string{"login", "tester"})
+
+		if code != exitOK || !strings.Contains(stderr.String(), `Logged in as "tester"`) {
+			t.Fatalf("got %d and %s, expected to log in", code, stderr)
+		}
+		if runCommand(ctx, []string{"list"}) != exitOK {
+			t.Error("expected commands to use the stored session")
+		}
+		if runCommand(ctx, []string{"logout"}) != exitOK {
+			t.Fatalf("expected to log out, got %s", stderr)
+		}
+		if _, err := os.Stat(dataPath(sessionFile)); !os.IsNotExist(err) {
+			t.Errorf("expected the session file to be removed, got %v", err)
+		}
+		if code := runCommand(ctx, []string{"list"}); code != exitUnauthorized {
+			t.Errorf("expected to be logged out, got %d", code)
+		}
+	})
+
+	t.Run("session printed", func(t *testing.T) {
+		ctx, stdout, _ := newCommandContext(t, "Abc12345!\n")
+
+		code := runCommand(ctx, []string{"login", "--print", "tester"})
+
+		token := strings.TrimSpace(stdout.String())
+		if code != exitOK || token == "" {
+			t.Fatalf("got %d and %q, expected the session token", code, token)
+		}
+		if _, err := os.Stat(dataPath(sessionFile)); !os.IsNotExist(err) {
+			t.Errorf("expected no session file, got %v", err)
+		}
+		t.Setenv(tokenEnv, token)
+		if runCommand(ctx, []string{"logout"}) != exitOK {
+			t.Fatal("expected to log out with the token")
+		}
+		if _, err := ctx.sessions.Lookup(ctx.users, token); err == nil {
+			t.Error("expected the session to be revoked")
+		}
+	})
+
+	t.Run("wrong password", func(t *testing.T) {
+		ctx, _, _ := newCommandContext(t, "Wrong12345!\n")
+
+		code := runCommand(ctx, []string{"login", "tester"})
+
+		if code != exitFailure {
+			t.Errorf("got exit code %d, expected %d", code, exitFailure)
+		}
+	})
+
+	t.Run("without a login", func(t *testing.T) {
+		ctx, _, _ := newCommandContext(t, "")
+
+		code := runCommand(ctx, []string{"login"})
+
+		if code != exitUsage {
+			t.Errorf("got exit code %d, expected %d", code, exitUsage)
+		}
+	})
+
+	t.Run("logout of an API key", func(t *testing.T) {
+		ctx, _, _ := newCommandContext(t, "")
+		key, _, _ := ctx.apiKeys.Create(*ctx.users.UsersByUsername["tester"], "script", []string{auth.ScopeTasksRead}, time.Time{})
+		t.Setenv(tokenEnv, key)
+
+		code := runCommand(ctx, []string{"logout"})
+
+		if code != exitUnauthorized {
+			t.Errorf("got exit code %d, expected %d", code, exitUnauthorized)
+		}
+	})
+}
+
+func TestListCommandLeavesTasks(t *testing.T) {
+	ctx, _, _ := newCommandContext(t, "")
+	logInTester(t, ctx)
+	// e.g. a task a running server added after the app loaded the file
+	os.WriteFile(dataPath("tasks.csv"), []byte("written by the server\n"), 0644)
+
+	code := runCommand(ctx, []string{"list"})
+
+	stored, _ := os.ReadFile(dataPath("tasks.csv"))
+	if code != exitOK || string(stored) != "written by the server\n" {
+		t.Errorf("expected list to leave the tasks file alone, got %d and %q", code, stored)
+	}
+	if _, err := os.Stat(dataPath("sessions.csv")); err != nil {
+		t.Errorf("expected the last use of the session to be saved, got %q", err)
+	}
+}
+
+//helpers
+
+// newCommandContext is newTestContext for commands, with the data files in a
+// temporary folder and no session yet. Results go to stdout, the rest to
+// stderr.
+func newCommandContext(t testing.TB, input string) (appContext, *bytes.Buffer, *bytes.Buffer) {
+	t.Helper()
+	settings.DataDir = t.TempDir()
+	t.Cleanup(func() { settings = config.Default() })
+	t.Setenv(tokenEnv, "")
+	ctx, _, _ := newTestContext(t, input)
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	ctx.stdout, ctx.stderr = stdout, stderr
+	ctx.sessions = auth.NewSessions(time.Hour, time.Hour)
+	ctx.apiKeys = auth.NewAPIKeys()
+	ctx.throttle = auth.NewLoginThrottle()
+	return ctx, stdout, stderr
+}
+
+// logInTester stores a session of tester the way app login does.
+func logInTester(t testing.TB, ctx appContext) string {
+	t.Helper()
+	token, _, err := ctx.sessions.Create(*ctx.users.UsersByUsername["tester"])
+	if err != nil {
+		t.Fatalf("could not log in tester: %q", err)
+	}
+	os.WriteFile(dataPath(sessionFile), []byte(token+"\n"), 0600)
+	return token
+}
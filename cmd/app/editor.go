@@ -0,0 +1,127 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"todo_app/pkg/tasks"
+)
+
+// editorHelp goes above the fields of a task opened in the editor.
+const editorHelp = `# Save and close the editor to keep the task. The description goes after the
+# second ---, lines starting with # above it are left out. Dates are %s.
+`
+
+// problemMark starts the lines listing what was wrong with the last save.
+const problemMark = "# ! "
+
+var notSavedErr = errors.New("Nothing was saved")
+
+// editor is the command tasks are written with: $VISUAL, else $EDITOR, else
+// vi. They can have arguments, like "code --wait".
+func editor() []string {
+	for _, env := range []string{"VISUAL", "EDITOR"} {
+		command := strings.Fields(os.Getenv(env))
+		if len(command) > 0 {
+			return command
+		}
+	}
+	return []string{"vi"}
+}
+
+// runEditor opens text in the editor in a temporary file and returns what
+// was saved.
+func runEditor(text string) (string, error) {
+	file, err := os.CreateTemp("", "task-*.md")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(file.Name())
+	_, err = file.WriteString(text)
+	closeErr := file.Close()
+	if err != nil || closeErr != nil {
+		return "", errors.New("couldnt write the file to edit")
+	}
+	command := editor()
+	cmd := exec.Command(command[0], append(command[1:], file.Name())...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	err = cmd.Run()
+	if err != nil {
+		return "", errors.New("the editor " + command[0] + " failed: " + err.Error())
+	}
+	saved, err := os.ReadFile(file.Name())
+	if err != nil {
+		return "", errors.New("couldnt read the edited file")
+	}
+	return string(saved), nil
+}
+
+// writeTask lets the user write task in their editor. When what they saved
+// is not a valid task the problems are shown and they can edit it again,
+// with the problems listed at the top. notSavedErr means they gave up.
+func (ctx appContext) writeTask(task tasks.Task) (tasks.Task, error) {
+	text := fmt.Sprintf(editorHelp, settings.DateFormat) + tasks.FormatText(task, settings.DateLayout())
+	for {
+		saved, err := runEditor(text)
+		if err != nil {
+			return tasks.Task{}, err
+		}
+		written, err := tasks.ParseText(saved, settings.DateLayout())
+		if err == nil {
+			return written, nil
+		}
+		fmt.Fprintln(ctx.stderr, err)
+		fmt.Fprintln(ctx.stderr, "Edit the task again? (y/n)")
+		answer, readErr := ctx.readLine()
+		if readErr != nil || strings.ToLower(strings.TrimSpace(answer)) != "y" {
+			return tasks.Task{}, notSavedErr
+		}
+		text = markProblems(saved, err)
+	}
+}
+
+// markProblems lists the problems of err at the top of text, instead of the
+// ones from the save before, moving the line numbers they point at along.
+func markProblems(text string, err error) string {
+	lines := strings.Split(text, "\n")
+	marked := 0
+	for marked < len(lines) && strings.HasPrefix(lines[marked], problemMark) {
+		marked++
+	}
+	var problems tasks.TextErr
+	if !errors.As(err, &problems) {
+		return problemMark + err.Error() + "\n" + strings.Join(lines[marked:], "\n")
+	}
+	moved := make(tasks.TextErr, len(problems))
+	for i, problem := range problems {
+		if problem.Line > 0 {
+			problem.Line += len(problems) - marked
+		}
+		moved[i] = problem
+	}
+	var marks strings.Builder
+	for _, line := range strings.Split(moved.Error(), "\n") {
+		marks.WriteString(problemMark + line + "\n")
+	}
+	return marks.String() + strings.Join(lines[marked:], "\n")
+}
+
+// applyWritten changes task to one written with writeTask, keeping its
+// number, attachments and time entries.
+func applyWritten(task *tasks.Task, written tasks.Task) {
+	task.Name, task.Description, task.Date = written.Name, written.Description, written.Date
+	task.Priority, task.Tags, task.TaskStatus = written.Priority, written.Tags, written.TaskStatus
+}
+
+// addWritten adds a task written with writeTask to taskList.
+func addWritten(taskList tasks.TaskList, written tasks.Task) (tasks.Task, error) {
+	newTask, err := taskList.AddTask(written.Name, written.Description, written.Date)
+	if err != nil {
+		return tasks.Task{}, err
+	}
+	task := taskList[newTask.Id]
+	applyWritten(task, written)
+	return *task, nil
+}
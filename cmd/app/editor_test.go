@@ -0,0 +1,89 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"todo_app/pkg/tasks"
+)
+
+func TestWriteTask(t *testing.T) {
+	tests := []struct {
+		name           string
+		script         string
+		input          string
+		expected       string
+		expected_error error
+		output         string
+	}{
+		{name: "saved", script: sedScript(`s/^name: .*/name: Read report/`), expected: "Read report"},
+		{name: "edited again", script: `if grep -q '^# ! line 6, date' "$1"; then ` + sedScript(`s/^date: .*/date: 31-12-2030/`) + `; else ` + sedScript(`s/^date: .*/date: someday/`) + `; fi`, input: "y\n", expected: "Write report", output: "line 5, date: " + tasks.TaskDateErr.Error()},
+		{name: "given up", script: sedScript(`s/^date: .*/date: someday/`), input: "n\n", expected_error: notSavedErr, output: "Edit the task again?"},
+		{name: "stdin ends", script: sedScript(`s/^---$//`), expected_error: notSavedErr, output: tasks.NoFieldsErr.Error()},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			useTestEditor(t, test.script)
+			ctx, out, _ := newTestContext(t, test.input)
+
+			got, err := ctx.writeTask(tasks.Task{Name: "Write report", Description: "for the team", Date: "01-01-2030", TaskStatus: "pending"})
+
+			if err != test.expected_error {
+				t.Fatalf("got %q, expected %q", err, test.expected_error)
+			}
+			if got.Name != test.expected {
+				t.Errorf("got %q, expected %q", got.Name, test.expected)
+			}
+			assertOutput(t, out, test.output)
+		})
+	}
+}
+
+func TestWriteTaskEditorFails(t *testing.T) {
+	useTestEditor(t, "exit 1")
+	ctx, _, _ := newTestContext(t, "y\n")
+
+	_, err := ctx.writeTask(tasks.Task{Name: "Write report", Date: "01-01-2030"})
+
+	if err == nil || !strings.Contains(err.Error(), "the editor sh failed") {
+		t.Errorf("got %q, expected the editor to fail", err)
+	}
+}
+
+func TestMarkProblems(t *testing.T) {
+	text := "# ! line 3, date: old problem\n---\nname:\ndate: 01-01-2030\n---\n"
+	problems := tasks.TextErr{{Line: 3, Field: "name", Err: tasks.TaskNameErr}, {Field: "date", Err: tasks.TaskDateErr}}
+	expected := "# ! line 4, name: " + tasks.TaskNameErr.Error() + "\n# ! date: " + tasks.TaskDateErr.Error() + "\n---\nname:\ndate: 01-01-2030\n---\n"
+
+	got := markProblems(text, problems)
+
+	if got != expected {
+		t.Errorf("got %q, expected %q", got, expected)
+	}
+	if lines := strings.Split(got, "\n"); lines[3] != "name:" {
+		t.Errorf("expected line 4 to be the name, got %q", lines[3])
+	}
+}
+
+//helpers
+
+// useTestEditor makes script, run by sh with the file to edit as $1, the
+// editor for the test.
+func useTestEditor(t testing.TB, script string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "editor.sh")
+	err := os.WriteFile(path, []byte(script+"\n"), 0o700)
+	if err != nil {
+		t.Fatalf("could not write the editor script: %q", err)
+	}
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", "sh "+path)
+}
+
+// sedScript is an editor script running the sed program on $1. sed writes to
+// another file that replaces $1, as -i differs between sed versions.
+func sedScript(program string) string {
+	return `sed '` + program + `' "$1" > "$1.tmp" && mv "$1.tmp" "$1"`
+}
@@ -0,0 +1,447 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"todo_app/pkg/audit"
+	"todo_app/pkg/auth"
+	"todo_app/pkg/blobs"
+	"todo_app/pkg/board"
+	"todo_app/pkg/mailer"
+	"todo_app/pkg/store"
+	"todo_app/pkg/tasks"
+	"todo_app/pkg/templates"
+
+	"github.com/google/uuid"
+)
+
+// appContext is what the menus and commands work on, loaded once by main,
+// and where their input comes from and output goes. The menus write
+// everything, errors too, to stdout.
+type appContext struct {
+	users         auth.UserDatabase
+	userTasks     map[uuid.UUID]tasks.TaskList
+	sessions      *auth.Sessions
+	apiKeys       *auth.APIKeys
+	throttle      *auth.LoginThrottle
+	verifications *auth.EmailVerifications
+	resets        *auth.PasswordResets
+	sender        mailer.Mailer
+	events        audit.Querier
+	blobStore     *blobs.Store
+	boards        map[uuid.UUID]*board.Board
+	userTemplates map[uuid.UUID]templates.Library
+	stdin         *bufio.Reader
+	stdout        io.Writer
+	stderr        io.Writer
+}
+
+func (ctx appContext) taskList(user auth.User) tasks.TaskList {
+	taskList, found := ctx.userTasks[user.Id]
+	if !found {
+		taskList = make(tasks.TaskList)
+		ctx.userTasks[user.Id] = taskList
+	}
+	return taskList
+}
+
+// readLine reads a line of stdin without its line break, a last line without
+// one is fine.
+func (ctx appContext) readLine() (string, error) {
+	line, err := ctx.stdin.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// menuAction is one entry of the menu of a logged in user. run returns false
+// when the user logged out, it can replace the user e.g. after a rename.
+type menuAction struct {
+	name string
+	// allowed hides the action from users it is not for, nil shows it to all
+	allowed func(user auth.User) bool
+	run     func(ctx appContext, user *auth.User) bool
+}
+
+// menuActions are numbered in order from 1.
+var menuActions = []menuAction{
+	{name: "Add task", run: appContext.addTaskAction},
+	{name: "See all tasks", run: appContext.listTasksAction},
+	{name: "Edit task", run: appContext.editTaskAction},
+	{name: "Delete task", run: appContext.deleteTaskAction},
+	{name: "Mark task as complete", run: appContext.completeTaskAction},
+	{name: "Attachments", run: func(ctx appContext, user *auth.User) bool {
+		attachmentsMenu(ctx.stdin, ctx.stdout, ctx.taskList(*user), ctx.blobStore)
+		return true
+	}},
+	{name: "Time tracking", run: func(ctx appContext, user *auth.User) bool {
+		timeTrackingMenu(ctx.stdin, ctx.stdout, ctx.taskList(*user))
+		return true
+	}},
+	{name: "Board", run: func(ctx appContext, user *auth.User) bool {
+		userBoard, found := ctx.boards[user.Id]
+		if !found {
+			defaultBoard := board.Default()
+			userBoard = &defaultBoard
+			ctx.boards[user.Id] = userBoard
+		}
+		boardMenu(ctx.stdin, ctx.stdout, ctx.taskList(*user), userBoard)
+		return true
+	}},
+	{name: "Templates", run: func(ctx appContext, user *auth.User) bool {
+		library, found := ctx.userTemplates[user.Id]
+		if !found {
+			library = make(templates.Library)
+			ctx.userTemplates[user.Id] = library
+		}
+		templatesMenu(ctx.stdin, ctx.stdout, ctx.taskList(*user), library, *user)
+		return true
+	}},
+	{name: "Bulk operations", run: func(ctx appContext, user *auth.User) bool {
+		bulkMenu(ctx.stdin, ctx.stdout, ctx.taskList(*user))
+		return true
+	}},
+	{name: "API keys", run: func(ctx appContext, user *auth.User) bool {
+		apiKeysMenu(ctx.stdin, ctx.stdout, dataPath("apikeys.csv"), ctx.apiKeys, *user)
+		return true
+	}},
+	{name: "Two-factor authentication", run: func(ctx appContext, user *auth.User) bool {
+		twoFactorMenu(ctx.stdin, ctx.stdout, ctx.users, *user)
+		return true
+	}},
+	{name: "Account settings", run: func(ctx appContext, user *auth.User) bool {
+		id := user.Id
+		var loggedIn bool
+		*user, loggedIn = accountMenu(ctx.stdin, ctx.stdout, ctx.users, ctx.userTasks, ctx.apiKeys, ctx.verifications, ctx.sender, *user)
+		if !loggedIn {
+			// the account was deleted, templates and boards are saved on exit
+			delete(ctx.userTemplates, id)
+			delete(ctx.boards, id)
+		}
+		return loggedIn
+	}},
+	{name: "Log out", run: appContext.logOutAction},
+	{name: "Administration", allowed: func(user auth.User) bool {
+		return auth.Can(user, auth.PermissionListUsers)
+	}, run: func(ctx appContext, user *auth.User) bool {
+		adminMenu(ctx.stdin, ctx.stdout, ctx.users, ctx.userTasks, ctx.sender, ctx.events, ctx.throttle, *user)
+		return true
+	}},
+}
+
+// run is the interactive app, until the user exits or stdin ends.
+func (ctx appContext) run() {
+	for {
+		fmt.Fprintln(ctx.stdout, "welcome to the best to do list app")
+		fmt.Fprintln(ctx.stdout, "1.- Register")
+		fmt.Fprintln(ctx.stdout, "2.- Login")
+		fmt.Fprintln(ctx.stdout, "3.- Forgot password")
+		fmt.Fprintln(ctx.stdout, "4.- Verify email")
+		fmt.Fprintln(ctx.stdout, "5.- Exit")
+		userInput, err := ctx.readLine()
+		if err != nil {
+			return
+		}
+		switch userInput {
+		case "1":
+			user, loggedIn := ctx.register()
+			if loggedIn {
+				ctx.loggedInMenu(user)
+			}
+		case "2":
+			user, loggedIn := ctx.logIn()
+			if loggedIn {
+				ctx.loggedInMenu(user)
+			}
+		case "3":
+			forgotPassword(ctx.stdin, ctx.stdout, ctx.users, ctx.resets, ctx.sender)
+		case "4":
+			verifyEmail(ctx.stdin, ctx.stdout, ctx.users, ctx.verifications, ctx.sender)
+		case "5":
+			fmt.Fprintln(ctx.stdout, "cya")
+			return
+		default:
+			fmt.Fprintln(ctx.stdout, "u stupid")
+		}
+	}
+}
+
+// register signs a user up, who is logged in right away unless they must
+// verify their email first.
+func (ctx appContext) register() (auth.User, bool) {
+	fmt.Fprintln(ctx.stdout, "Please enter your email")
+	email, err := ctx.readLine()
+	if err != nil {
+		fmt.Fprintln(ctx.stdout, err)
+		return auth.User{}, false
+	}
+	fmt.Fprintln(ctx.stdout, "Please enter your username")
+	username, err := ctx.readLine()
+	if err != nil {
+		fmt.Fprintln(ctx.stdout, err)
+		return auth.User{}, false
+	}
+	fmt.Fprintf(ctx.stdout, "Please enter your password (%s)\n", auth.CurrentPasswordPolicy().Describe())
+	password, err := ctx.readLine()
+	if err != nil {
+		fmt.Fprintln(ctx.stdout, err)
+		return auth.User{}, false
+	}
+	user, err := ctx.users.RegisterUser(email, username, password)
+	if err != nil {
+		fmt.Fprintln(ctx.stdout, err)
+		return auth.User{}, false
+	}
+	err = store.SaveUsers(dataPath("users.csv"), ctx.users)
+	if err != nil {
+		fmt.Fprintln(ctx.stdout, "error writing to file")
+		return auth.User{}, false
+	}
+	sendVerification(ctx.stdout, ctx.users, ctx.verifications, ctx.sender, user.Username)
+	return user, auth.CanLogIn(user) == nil
+}
+
+func (ctx appContext) logIn() (auth.User, bool) {
+	fmt.Fprintln(ctx.stdout, "Please enter your username or email")
+	id, err := ctx.readLine()
+	if err != nil {
+		fmt.Fprintln(ctx.stdout, err)
+		return auth.User{}, false
+	}
+	fmt.Fprintln(ctx.stdout, "Please enter your password")
+	password, err := ctx.readLine()
+	if err != nil {
+		fmt.Fprintln(ctx.stdout, err)
+		return auth.User{}, false
+	}
+	// everyone at this terminal is the same source
+	user, err := ctx.throttle.LogIn(ctx.users, id, password, "cli")
+	if required, ok := err.(auth.SecondFactorRequired); ok {
+		user, err = logInSecondFactor(ctx.stdin, ctx.stdout, ctx.users, ctx.throttle, required)
+	}
+	if err != nil {
+		fmt.Fprintln(ctx.stdout, err)
+		return auth.User{}, false
+	}
+	// logging in can upgrade the password hash
+	err = store.SaveUsers(dataPath("users.csv"), ctx.users)
+	if err != nil {
+		fmt.Fprintln(ctx.stdout, "error writing to file")
+	}
+	return user, true
+}
+
+// loggedInMenu runs the actions user picks until they log out or stdin ends.
+func (ctx appContext) loggedInMenu(user auth.User) {
+	for {
+		fmt.Fprintf(ctx.stdout, "Welcome %q, what would you like to do today\n", user.Username)
+		for i, action := range menuActions {
+			if action.allowed == nil || action.allowed(user) {
+				fmt.Fprintf(ctx.stdout, "%d.- %s\n", i+1, action.name)
+			}
+		}
+		userInput, err := ctx.readLine()
+		if err != nil {
+			return
+		}
+		number, err := strconv.Atoi(userInput)
+		if err != nil || number < 1 || number > len(menuActions) {
+			fmt.Fprintln(ctx.stdout, "u stupid")
+			continue
+		}
+		action := menuActions[number-1]
+		if action.allowed != nil && !action.allowed(user) {
+			fmt.Fprintln(ctx.stdout, "u stupid")
+			continue
+		}
+		if !action.run(ctx, &user) {
+			return
+		}
+	}
+}
+
+// addTaskAction asks for a task until it is valid.
+func (ctx appContext) addTaskAction(user *auth.User) bool {
+	createErr := auth.CanCreateTasks(*user)
+	if createErr != nil {
+		fmt.Fprintln(ctx.stdout, createErr)
+		return true
+	}
+	for {
+		fmt.Fprintln(ctx.stdout, "Enter the name of the task (or :e to write the whole task in your editor):")
+		taskName, err := ctx.readLine()
+		if err != nil {
+			fmt.Fprintln(ctx.stdout, err)
+			return true
+		}
+		if strings.TrimSpace(taskName) == ":e" {
+			ctx.addInEditor(user)
+			return true
+		}
+		fmt.Fprintln(ctx.stdout, "Enter the description of the task:")
+		taskDesc, err := ctx.readLine()
+		if err != nil {
+			fmt.Fprintln(ctx.stdout, err)
+			return true
+		}
+		fmt.Fprintf(ctx.stdout, "Enter the date when you want to complete the task (%s):\n", settings.DateFormat)
+		taskDate, err := ctx.readLine()
+		if err != nil {
+			fmt.Fprintln(ctx.stdout, err)
+			return true
+		}
+		taskDate, err = settings.ParseDate(taskDate)
+		if err != nil {
+			fmt.Fprintln(ctx.stdout, err)
+			continue
+		}
+		newTask, err := ctx.taskList(*user).AddTask(taskName, taskDesc, taskDate)
+		if err != nil {
+			fmt.Fprintln(ctx.stdout, err)
+			continue
+		}
+		fmt.Fprintf(ctx.stdout, "Succesfully added new task:%v\n", newTask)
+		return true
+	}
+}
+
+// addInEditor adds a task the user writes in their editor.
+func (ctx appContext) addInEditor(user *auth.User) {
+	written, err := ctx.writeTask(tasks.Task{Date: time.Now().Format(tasks.DateLayout), TaskStatus: "pending"})
+	if err == nil {
+		written, err = addWritten(ctx.taskList(*user), written)
+	}
+	if err != nil {
+		fmt.Fprintln(ctx.stdout, err)
+		return
+	}
+	fmt.Fprintf(ctx.stdout, "Succesfully added new task:%v\n", written)
+}
+
+// editInEditor opens the task numbered id in the editor, changing it when
+// what was saved is valid.
+func (ctx appContext) editInEditor(taskList tasks.TaskList, id int) {
+	task, err := taskList.GetTask(id)
+	if err != nil {
+		fmt.Fprintln(ctx.stdout, err)
+		return
+	}
+	written, err := ctx.writeTask(*task)
+	if err != nil {
+		fmt.Fprintln(ctx.stdout, err)
+		return
+	}
+	applyWritten(task, written)
+}
+
+func (ctx appContext) listTasksAction(user *auth.User) bool {
+	fmt.Fprintln(ctx.stdout, "Your tasks")
+	printTaskTable(ctx.stdout, ctx.taskList(*user))
+	return true
+}
+
+// editTaskAction changes one field of a task, asking again until the input
+// is valid.
+func (ctx appContext) editTaskAction(user *auth.User) bool {
+	taskList := ctx.taskList(*user)
+	for {
+		fmt.Fprintln(ctx.stdout, "Enter the number of the task you want to edit, the field you want to change and the new value. (example: '2 name New Name', or '2 :e' to edit the whole task in your editor)")
+		printTaskTable(ctx.stdout, taskList)
+		editInput, err := ctx.readLine()
+		if err != nil {
+			fmt.Fprintln(ctx.stdout, err)
+			return true
+		}
+		fields := strings.Fields(editInput)
+		if len(fields) == 2 && fields[1] == ":e" {
+			editId, err := strconv.Atoi(fields[0])
+			if err != nil {
+				fmt.Fprintln(ctx.stdout, "Please enter a valid input for the task number")
+				continue
+			}
+			ctx.editInEditor(taskList, editId)
+			return true
+		}
+		if len(fields) < 3 {
+			fmt.Fprintln(ctx.stdout, "Please enter an appropiate input")
+			continue
+		}
+		editId, err := strconv.Atoi(fields[0])
+		if err != nil {
+			fmt.Fprintln(ctx.stdout, "Please enter a valid input for the task number")
+			continue
+		}
+		value := strings.Join(fields[2:], " ")
+		if field := strings.ToLower(fields[1]); field == "date" || field == "3" {
+			value, err = settings.ParseDate(value)
+			if err != nil {
+				fmt.Fprintln(ctx.stdout, err)
+				continue
+			}
+		}
+		_, err = taskList.UpdateField(editId, fields[1], value)
+		if err != nil {
+			fmt.Fprintln(ctx.stdout, err)
+			continue
+		}
+		return true
+	}
+}
+
+func (ctx appContext) deleteTaskAction(user *auth.User) bool {
+	taskList := ctx.taskList(*user)
+	ctx.confirmTask(taskList, "delete", "Deleting", taskList.DeleteTask)
+	return true
+}
+
+func (ctx appContext) completeTaskAction(user *auth.User) bool {
+	taskList := ctx.taskList(*user)
+	ctx.confirmTask(taskList, "complete", "Completing", taskList.CompleteTask)
+	return true
+}
+
+// confirmTask asks for a task number and applies apply to it once the user
+// confirms, until it succeeds or the user picks 0.
+func (ctx appContext) confirmTask(taskList tasks.TaskList, verb, doing string, apply func(id int) error) {
+	for {
+		fmt.Fprintf(ctx.stdout, "Select the number of the task you wish to %s or 0 to return to the previous menu\n", verb)
+		printTaskTable(ctx.stdout, taskList)
+		taskId, err := ctx.readLine()
+		if err != nil {
+			fmt.Fprintln(ctx.stdout, err)
+			return
+		}
+		numId, err := strconv.Atoi(strings.TrimSpace(taskId))
+		if err != nil {
+			fmt.Fprintln(ctx.stdout, err)
+			continue
+		}
+		if numId == 0 {
+			return
+		}
+		fmt.Fprintf(ctx.stdout, "%s task #%q, type Y to confirm, any other input to cancel\n", doing, strings.TrimSpace(taskId))
+		confirm, err := ctx.readLine()
+		if err != nil {
+			fmt.Fprintln(ctx.stdout, err)
+			return
+		}
+		if strings.ToLower(strings.TrimSpace(confirm)) != "y" {
+			continue
+		}
+		err = apply(numId)
+		if err != nil {
+			fmt.Fprintln(ctx.stdout, err)
+			continue
+		}
+		return
+	}
+}
+
+func (ctx appContext) logOutAction(user *auth.User) bool {
+	return false
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"todo_app/pkg/auth"
+	"todo_app/pkg/board"
+	"todo_app/pkg/config"
+	"todo_app/pkg/tasks"
+	"todo_app/pkg/templates"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestMain(m *testing.M) {
+	auth.UsePasswordHasher(auth.BcryptHasher{Cost: bcrypt.MinCost})
+	os.Exit(m.Run())
+}
+
+func TestAddTaskAction(t *testing.T) {
+	ctx, out, user := newTestContext(t, "\nno name\n01-01-2030\nWrite report\nfor the team\n01-01-2030\n")
+
+	loggedIn := ctx.addTaskAction(&user)
+
+	if !loggedIn {
+		t.Errorf("expected the user to stay logged in")
+	}
+	expected := tasks.Task{Id: 1, Name: "Write report", Description: "for the team", Date: "01-01-2030", TaskStatus: "pending"}
+	if got := ctx.userTasks[user.Id][1]; got == nil || !reflect.DeepEqual(*got, expected) {
+		t.Errorf("got %v, expected %v", got, expected)
+	}
+	assertOutput(t, out, tasks.TaskNameErr.Error(), "Succesfully added new task")
+}
+
+func TestAddTaskActionUnverified(t *testing.T) {
+	auth.RequireVerifiedEmail(auth.VerificationForTasks)
+	t.Cleanup(func() { auth.RequireVerifiedEmail(auth.VerificationOptional) })
+	ctx, out, user := newTestContext(t, "Write report\n\n01-01-2030\n")
+
+	ctx.addTaskAction(&user)
+
+	if len(ctx.userTasks[user.Id]) != 0 {
+		t.Errorf("expected no task to be added, got %v", ctx.userTasks[user.Id])
+	}
+	assertOutput(t, out, "Please verify your email first")
+}
+
+func TestAddTaskActionDateFormat(t *testing.T) {
+	settings.DateFormat = "yyyy/mm/dd"
+	t.Cleanup(func() { settings = config.Default() })
+	ctx, out, user := newTestContext(t, "Write report\n\n01-01-2030\nWrite report\n\n2030/12/31\n")
+
+	ctx.addTaskAction(&user)
+
+	if got := ctx.userTasks[user.Id][1]; got == nil || got.Date != "31-12-2030" {
+		t.Errorf("got %v, expected the task to be due 31-12-2030", got)
+	}
+	assertOutput(t, out, "(yyyy/mm/dd)", tasks.TaskDateErr.Error())
+}
+
+func TestTimeTrackingMenuDateFormat(t *testing.T) {
+	settings.DateFormat = "yyyy/mm/dd"
+	t.Cleanup(func() { settings = config.Default() })
+	taskList := tasks.TaskList{1: {Id: 1, Name: "Write report", Date: "31-12-2030"}}
+	var out bytes.Buffer
+
+	timeTrackingMenu(bufio.NewReader(strings.NewReader("log 1 31-12-2030 1h\nlog 1 2030/12/31 1h\n0\n")), &out, taskList)
+
+	entries := taskList[1].TimeEntries
+	if len(entries) != 1 || entries[0].Start.Format(tasks.DateLayout) != "31-12-2030" {
+		t.Errorf("got %v, expected one hour logged on 31-12-2030", entries)
+	}
+	assertOutput(t, &out, "'log 2 2024/03/31 1h30m'", tasks.TaskDateErr.Error())
+}
+
+func TestAddTaskActionEditor(t *testing.T) {
+	useTestEditor(t, `{ sed 's/^name:.*/name: Write report/' "$1"; printf 'for the team\nby friday\n'; } > "$1.tmp" && mv "$1.tmp" "$1"`)
+	ctx, out, user := newTestContext(t, ":e\n")
+
+	ctx.addTaskAction(&user)
+
+	got := ctx.userTasks[user.Id][1]
+	if got == nil || got.Name != "Write report" || got.Description != "for the team\nby friday" {
+		t.Errorf("got %v, expected the task written in the editor", got)
+	}
+	assertOutput(t, out, "Succesfully added new task")
+}
+
+func TestListTasksAction(t *testing.T) {
+	ctx, out, user := newTestContext(t, "")
+	ctx.taskList(user).AddTask("first", "", "01-01-2030")
+	ctx.taskList(user).AddTask("second", "", "02-01-2030")
+
+	ctx.listTasksAction(&user)
+
+	first := strings.Index(out.String(), `"first"`)
+	second := strings.Index(out.String(), `"second"`)
+	if first < 0 || second < first {
+		t.Errorf("expected the tasks by number, got %s", out)
+	}
+}
+
+func TestEditTaskAction(t *testing.T) {
+	ctx, out, user := newTestContext(t, "1 name\none name x\n1 date tomorrow\n1 name New Name\n")
+	ctx.taskList(user).AddTask("first", "", "01-01-2030")
+
+	ctx.editTaskAction(&user)
+
+	if got := ctx.userTasks[user.Id][1].Name; got != "New Name" {
+		t.Errorf("got %q, expected the new name", got)
+	}
+	assertOutput(t, out, "Please enter an appropiate input", "Please enter a valid input for the task number", tasks.TaskDateErr.Error())
+}
+
+func TestDeleteTaskAction(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected int
+		output   string
+	}{
+		{name: "back to the menu", input: "0\n", expected: 2},
+		{name: "cancelled", input: "1\nn\n0\n", expected: 2},
+		{name: "unknown task", input: "9\ny\n0\n", expected: 2, output: tasks.TaskNotFoundErr.Error()},
+		{name: "not a number", input: "one\n0\n", expected: 2, output: "invalid syntax"},
+		{name: "deleted", input: "1\ny\n", expected: 1},
+		{name: "stdin ends", input: "1\n", expected: 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, out, user := newTestContext(t, test.input)
+			ctx.taskList(user).AddTask("first", "", "01-01-2030")
+			ctx.taskList(user).AddTask("second", "", "01-01-2030")
+
+			ctx.deleteTaskAction(&user)
+
+			if len(ctx.userTasks[user.Id]) != test.expected {
+				t.Errorf("got %d tasks, expected %d", len(ctx.userTasks[user.Id]), test.expected)
+			}
+			assertOutput(t, out, test.output)
+		})
+	}
+}
+
+func TestCompleteTaskAction(t *testing.T) {
+	ctx, out, user := newTestContext(t, "1\nn\n1\nY\n")
+	ctx.taskList(user).AddTask("first", "", "01-01-2030")
+
+	ctx.completeTaskAction(&user)
+
+	if got := ctx.userTasks[user.Id][1].TaskStatus; got != "complete" {
+		t.Errorf("got %q, expected the task to be complete", got)
+	}
+	assertOutput(t, out, `Completing task #"1"`)
+}
+
+func TestLogOutAction(t *testing.T) {
+	ctx, _, user := newTestContext(t, "")
+
+	if ctx.logOutAction(&user) {
+		t.Errorf("expected the user to be logged out")
+	}
+}
+
+func TestDeleteAccountAction(t *testing.T) {
+	settings.DataDir = t.TempDir()
+	t.Cleanup(func() { settings = config.Default() })
+	ctx, _, user := newTestContext(t, "13\n4\nAbc12345!\n")
+	defaultBoard := board.Default()
+	ctx.apiKeys = auth.NewAPIKeys()
+	ctx.boards = map[uuid.UUID]*board.Board{user.Id: &defaultBoard}
+	ctx.userTemplates = map[uuid.UUID]templates.Library{user.Id: {}}
+
+	ctx.loggedInMenu(user)
+
+	if _, found := ctx.users.UsersByUsername["tester"]; found {
+		t.Fatalf("expected the account to be deleted")
+	}
+	if len(ctx.boards) != 0 || len(ctx.userTemplates) != 0 {
+		t.Errorf("expected the board and templates of the user to be deleted, got %v and %v", ctx.boards, ctx.userTemplates)
+	}
+}
+
+func TestLoggedInMenu(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		role    auth.Role
+		output  []string
+		missing string
+	}{
+		{name: "log out", input: "2\n14\n", role: auth.RoleUser, output: []string{"Your tasks"}, missing: "Administration"},
+		{name: "unknown option", input: "16\nabc\n14\n", role: auth.RoleUser, output: []string{"u stupid"}},
+		{name: "hidden option", input: "15\n14\n", role: auth.RoleUser, output: []string{"u stupid"}},
+		{name: "admin", input: "14\n", role: auth.RoleAdmin, output: []string{"15.- Administration"}},
+		{name: "stdin ends", input: "", role: auth.RoleUser, output: []string{"14.- Log out"}},
+		{name: "submenu", input: "10\n0\n14\n", role: auth.RoleUser, output: []string{"complete <selection>"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, out, user := newTestContext(t, test.input)
+			user.Role = test.role
+
+			ctx.loggedInMenu(user)
+
+			assertOutput(t, out, test.output...)
+			if test.missing != "" && strings.Contains(out.String(), test.missing) {
+				t.Errorf("expected no %q in %s", test.missing, out)
+			}
+		})
+	}
+}
+
+//helpers
+
+// newTestContext is an app with one user reading input, with everything it
+// writes in the returned buffer. Nothing is saved to files.
+func newTestContext(t testing.TB, input string) (appContext, *bytes.Buffer, auth.User) {
+	t.Helper()
+	users := auth.UserDatabase{UsersByEmail: map[string]*auth.User{}, UsersByUsername: map[string]*auth.User{}}
+	user, err := users.RegisterUser("tester@gmail.com", "tester", "Abc12345!")
+	if err != nil {
+		t.Fatalf("could not register test user: %q", err)
+	}
+	out := &bytes.Buffer{}
+	ctx := appContext{
+		users:     users,
+		userTasks: map[uuid.UUID]tasks.TaskList{},
+		stdin:     bufio.NewReader(strings.NewReader(input)),
+		stdout:    out,
+		stderr:    out,
+	}
+	return ctx, out, user
+}
+
+func assertOutput(t testing.TB, out *bytes.Buffer, expected ...string) {
+	t.Helper()
+	for _, text := range expected {
+		if !strings.Contains(out.String(), text) {
+			t.Errorf("expected %q in the output, got %s", text, out)
+		}
+	}
+}
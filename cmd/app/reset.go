@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"todo_app/pkg/auth"
+	"todo_app/pkg/mailer"
+	"todo_app/pkg/store"
+)
+
+func forgotPassword(reader *bufio.Reader, out io.Writer, users auth.UserDatabase, resets *auth.PasswordResets, sender mailer.Mailer) {
+	fmt.Fprintln(out, "Please enter your username or email")
+	id, idErr := reader.ReadString('\n')
+	if idErr != nil {
+		fmt.Fprintln(out, idErr)
+		return
+	}
+	err := resets.Request(users, sender, strings.TrimSpace(id))
+	if _, unknownUser := err.(auth.UserErr); err != nil && !unknownUser {
+		fmt.Fprintln(out, err)
+		return
+	}
+	// the same answer whether the account exists or not, so this can't be used
+	// to find out who has an account
+	fmt.Fprintln(out, "If that account exists a reset code was sent to its email")
+	if fileMailer, ok := sender.(*mailer.File); ok {
+		fmt.Fprintf(out, "Mail is not set up, emails are saved in %s\n", fileMailer.Dir)
+	}
+
+	fmt.Fprintln(out, "Please enter the code from the email, or 0 to cancel")
+	code, codeErr := reader.ReadString('\n')
+	if codeErr != nil {
+		fmt.Fprintln(out, codeErr)
+		return
+	}
+	code = strings.TrimSpace(code)
+	if code == "0" {
+		return
+	}
+	for {
+		fmt.Fprintf(out, "Please enter your new password (%s)\n", auth.CurrentPasswordPolicy().Describe())
+		password, passErr := reader.ReadString('\n')
+		if passErr != nil {
+			fmt.Fprintln(out, passErr)
+			return
+		}
+		// whoever knew the old password may have logged in elsewhere, their
+		// saved sessions are ended; a running API server keeps its own until
+		// it is restarted
+		sessions := auth.NewSessions(settings.SessionIdleTimeout, settings.SessionMaxLifetime)
+		err := store.LoadSessions(dataPath("sessions.csv"), sessions)
+		if err != nil {
+			fmt.Fprintln(out, "sessions file could not be read")
+			return
+		}
+		user, err := resets.ResetPassword(users, sessions, code, strings.TrimSpace(password))
+		if _, weak := err.(auth.PasswordRulesErr); weak {
+			fmt.Fprintln(out, err)
+			continue
+		}
+		if err != nil {
+			fmt.Fprintln(out, err)
+			return
+		}
+		err = store.SaveUsers(dataPath("users.csv"), users)
+		if err != nil {
+			fmt.Fprintln(out, "error writing to file")
+			return
+		}
+		err = store.SaveSessions(dataPath("sessions.csv"), sessions)
+		if err != nil {
+			fmt.Fprintln(out, "couldnt write sessions file")
+		}
+		fmt.Fprintf(out, "Password changed, you can now log in as %q\n", user.Username)
+		return
+	}
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"todo_app/pkg/auth"
+	"todo_app/pkg/tasks"
+	"todo_app/pkg/templates"
+
+	"github.com/google/uuid"
+)
+
+// loadTemplates reads one task template per row, the tasks of a template are
+// kept in the order they appear in the file.
+func loadTemplates(path string) map[uuid.UUID]templates.Library {
+	libraries := make(map[uuid.UUID]templates.Library)
+	file, fileErr := os.Open(path)
+	if fileErr != nil {
+		log.Println("templates file could not be opened")
+		return libraries
+	}
+	defer file.Close()
+
+	csvReader := csv.NewReader(file)
+	for {
+		rec, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		userId := uuid.MustParse(rec[0])
+		offset, convErr := strconv.Atoi(rec[4])
+		if convErr != nil {
+			log.Fatal("invalid template record")
+		}
+		library, found := libraries[userId]
+		if !found {
+			library = make(templates.Library)
+			libraries[userId] = library
+		}
+		template, found := library[rec[1]]
+		if !found {
+			template = &templates.Template{Name: rec[1]}
+			library[rec[1]] = template
+		}
+		template.Tasks = append(template.Tasks, templates.TaskTemplate{Name: rec[2], Description: rec[3], DueOffset: offset, Tags: tasks.SplitTags(rec[5])})
+	}
+	return libraries
+}
+
+func saveTemplates(path string, libraries map[uuid.UUID]templates.Library) {
+	file, fileErr := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if fileErr != nil {
+		log.Fatal("templates file could not be open")
+	}
+	defer file.Close()
+
+	csvWriter := csv.NewWriter(file)
+	for userId, library := range libraries {
+		for _, template := range library {
+			for _, task := range template.Tasks {
+				record := []string{userId.String(), template.Name, task.Name, task.Description, strconv.Itoa(task.DueOffset), strings.Join(task.Tags, ",")}
+				err := csvWriter.Write(record)
+				if err != nil {
+					log.Fatal("couldnt write template to templates file")
+				}
+			}
+		}
+	}
+	csvWriter.Flush()
+}
+
+func templatesMenu(reader *bufio.Reader, out io.Writer, taskList tasks.TaskList, library templates.Library, user auth.User) {
+	for {
+		fmt.Fprintln(out, "Enter one of the following commands or 0 to return to the previous menu:")
+		fmt.Fprintln(out, "  list")
+		fmt.Fprintln(out, "  show <template>")
+		fmt.Fprintln(out, "  create <template>")
+		fmt.Fprintln(out, "  use <template> <base date>")
+		fmt.Fprintln(out, "  delete <template>")
+		fmt.Fprintln(out, "  export <template> <file path>")
+		fmt.Fprintln(out, "  import <file path>")
+		input, inputErr := reader.ReadString('\n')
+		if inputErr != nil {
+			fmt.Fprintln(out, inputErr)
+			return
+		}
+		fields := strings.Fields(input)
+		if len(fields) == 0 {
+			fmt.Fprintln(out, "Please enter an appropiate input")
+			continue
+		}
+		if fields[0] == "0" {
+			return
+		}
+		if fields[0] == "list" {
+			fmt.Fprintln(out, "Your templates")
+			for _, name := range library.Names() {
+				fmt.Fprintf(out, "%s (%d tasks)\n", name, len(library[name].Tasks))
+			}
+			continue
+		}
+		if len(fields) < 2 {
+			fmt.Fprintln(out, "Please enter an appropiate input")
+			continue
+		}
+		switch strings.ToLower(fields[0]) {
+		case "show":
+			template, err := library.GetTemplate(fields[1])
+			if err != nil {
+				fmt.Fprintln(out, err)
+				continue
+			}
+			err = templates.Export(out, *template)
+			if err != nil {
+				fmt.Fprintln(out, err)
+			}
+		case "create":
+			template := templates.Template{Name: fields[1]}
+			for {
+				fmt.Fprintln(out, "Enter the name of the task, {{variable}} will be asked for when using the template (leave empty to finish):")
+				taskName, nameErr := reader.ReadString('\n')
+				if nameErr != nil {
+					fmt.Fprintln(out, nameErr)
+					break
+				}
+				if strings.TrimSpace(taskName) == "" {
+					break
+				}
+				fmt.Fprintln(out, "Enter the description of the task:")
+				taskDesc, descErr := reader.ReadString('\n')
+				if descErr != nil {
+					fmt.Fprintln(out, descErr)
+				}
+				fmt.Fprintln(out, "Enter how many days after the base date the task is due:")
+				offsetInput, offsetErr := reader.ReadString('\n')
+				if offsetErr != nil {
+					fmt.Fprintln(out, offsetErr)
+				}
+				offset, err := strconv.Atoi(strings.TrimSpace(offsetInput))
+				if err != nil {
+					fmt.Fprintln(out, "Please enter a valid number of days, the task was not added")
+					continue
+				}
+				fmt.Fprintln(out, "Enter the tags of the task separated by commas:")
+				tagsInput, tagsErr := reader.ReadString('\n')
+				if tagsErr != nil {
+					fmt.Fprintln(out, tagsErr)
+				}
+				template.Tasks = append(template.Tasks, templates.TaskTemplate{
+					Name:        strings.TrimSpace(taskName),
+					Description: strings.TrimSpace(taskDesc),
+					DueOffset:   offset,
+					Tags:        tasks.SplitTags(tagsInput),
+				})
+			}
+			err := library.SaveTemplate(template)
+			if err != nil {
+				fmt.Fprintln(out, err)
+				continue
+			}
+			fmt.Fprintf(out, "Saved template %q\n", template.Name)
+		case "use":
+			if len(fields) != 3 {
+				fmt.Fprintln(out, "Please enter the template and the base date")
+				continue
+			}
+			err := auth.CanCreateTasks(user)
+			if err != nil {
+				fmt.Fprintln(out, err)
+				continue
+			}
+			template, err := library.GetTemplate(fields[1])
+			if err != nil {
+				fmt.Fprintln(out, err)
+				continue
+			}
+			base, err := time.Parse(settings.DateLayout(), fields[2])
+			if err != nil {
+				fmt.Fprintln(out, tasks.TaskDateErr)
+				continue
+			}
+			values := make(map[string]string)
+			for _, variable := range template.Variables() {
+				fmt.Fprintf(out, "Enter the value for %q:\n", variable)
+				value, valueErr := reader.ReadString('\n')
+				if valueErr != nil {
+					fmt.Fprintln(out, valueErr)
+					break
+				}
+				values[variable] = strings.TrimSpace(value)
+			}
+			created, err := template.Instantiate(taskList, base, values)
+			if err != nil {
+				fmt.Fprintln(out, err)
+				continue
+			}
+			fmt.Fprintf(out, "Added %d tasks from %q\n", len(created), template.Name)
+			for _, task := range created {
+				fmt.Fprintln(out, task.String())
+			}
+		case "delete":
+			err := library.DeleteTemplate(fields[1])
+			if err != nil {
+				fmt.Fprintln(out, err)
+			}
+		case "export":
+			if len(fields) < 3 {
+				fmt.Fprintln(out, "Please enter the path of the file to export to")
+				continue
+			}
+			template, err := library.GetTemplate(fields[1])
+			if err != nil {
+				fmt.Fprintln(out, err)
+				continue
+			}
+			err = exportTemplate(strings.Join(fields[2:], " "), *template)
+			if err != nil {
+				fmt.Fprintln(out, err)
+			}
+		case "import":
+			file, err := os.Open(strings.Join(fields[1:], " "))
+			if err != nil {
+				fmt.Fprintln(out, err)
+				continue
+			}
+			template, err := templates.Import(file)
+			file.Close()
+			if err != nil {
+				fmt.Fprintln(out, err)
+				continue
+			}
+			if _, err := library.GetTemplate(template.Name); err == nil {
+				fmt.Fprintf(out, "Replacing template %q, type Y to confirm, any other input to cancel\n", template.Name)
+				confirm, confErr := reader.ReadString('\n')
+				if confErr != nil || strings.ToLower(strings.TrimSpace(confirm)) != "y" {
+					continue
+				}
+			}
+			err = library.SaveTemplate(template)
+			if err != nil {
+				fmt.Fprintln(out, err)
+				continue
+			}
+			fmt.Fprintf(out, "Imported template %q\n", template.Name)
+		default:
+			fmt.Fprintln(out, "Please enter an appropiate input")
+		}
+	}
+}
+
+func exportTemplate(path string, template templates.Template) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = templates.Export(file, template)
+	if err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
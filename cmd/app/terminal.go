@@ -0,0 +1,27 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"strconv"
+)
+
+var notATerminalErr = errors.New("the full-screen mode needs a terminal")
+
+// fallbackTerminalWidth is used when stdout is not a terminal we can ask
+// for its size, e.g. when the output is piped.
+func fallbackTerminalWidth() int {
+	columns, err := strconv.Atoi(os.Getenv("COLUMNS"))
+	if err == nil && columns > 0 {
+		return columns
+	}
+	return 80
+}
+
+func fallbackTerminalHeight() int {
+	lines, err := strconv.Atoi(os.Getenv("LINES"))
+	if err == nil && lines > 0 {
+		return lines
+	}
+	return 24
+}
@@ -0,0 +1,15 @@
+//go:build !linux && !darwin
+
+package main
+
+func terminalWidth() int {
+	return fallbackTerminalWidth()
+}
+
+func terminalSize() (int, int) {
+	return fallbackTerminalWidth(), fallbackTerminalHeight()
+}
+
+func makeRaw() (func(), error) {
+	return nil, notATerminalErr
+}
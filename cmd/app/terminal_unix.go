@@ -0,0 +1,52 @@
+//go:build linux || darwin
+
+package main
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+func terminalWidth() int {
+	width, _ := terminalSize()
+	return width
+}
+
+// terminalSize is the columns and rows of the terminal on stdout.
+func terminalSize() (int, int) {
+	var size struct {
+		rows, cols, xpixel, ypixel uint16
+	}
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, os.Stdout.Fd(), uintptr(syscall.TIOCGWINSZ), uintptr(unsafe.Pointer(&size)))
+	if errno == 0 && size.cols > 0 && size.rows > 0 {
+		return int(size.cols), int(size.rows)
+	}
+	return fallbackTerminalWidth(), fallbackTerminalHeight()
+}
+
+// makeRaw switches the terminal on stdin to raw mode, where every key press
+// is read as it happens and not echoed, and returns how to switch it back.
+func makeRaw() (func(), error) {
+	fd := os.Stdin.Fd()
+	var saved syscall.Termios
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, getTermios, uintptr(unsafe.Pointer(&saved)))
+	if errno != 0 {
+		return nil, notATerminalErr
+	}
+	raw := saved
+	raw.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP | syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+	raw.Oflag &^= syscall.OPOST
+	raw.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	raw.Cflag &^= syscall.CSIZE | syscall.PARENB
+	raw.Cflag |= syscall.CS8
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+	_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, setTermios, uintptr(unsafe.Pointer(&raw)))
+	if errno != 0 {
+		return nil, errno
+	}
+	return func() {
+		syscall.Syscall(syscall.SYS_IOCTL, fd, setTermios, uintptr(unsafe.Pointer(&saved)))
+	}, nil
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"todo_app/pkg/tasks"
+)
+
+func timeTrackingMenu(reader *bufio.Reader, out io.Writer, taskList tasks.TaskList) {
+	for {
+		fmt.Fprintln(out, "Enter one of the following commands or 0 to return to the previous menu:")
+		fmt.Fprintln(out, "  start <task number>")
+		fmt.Fprintln(out, "  stop")
+		fmt.Fprintf(out, "  log <task number> <date> <duration> (example: 'log 2 %s 1h30m')\n", time.Date(2024, time.March, 31, 0, 0, 0, 0, time.Local).Format(settings.DateLayout()))
+		fmt.Fprintln(out, "  estimate <task number> <duration>")
+		fmt.Fprintln(out, "  timesheet [date in the week]")
+		fmt.Fprintln(out, "  export <file path> [date in the week]")
+		printTimeTable(out, taskList, time.Now())
+		input, inputErr := reader.ReadString('\n')
+		if inputErr != nil {
+			fmt.Fprintln(out, inputErr)
+			return
+		}
+		fields := strings.Fields(input)
+		if len(fields) == 0 {
+			fmt.Fprintln(out, "Please enter an appropiate input")
+			continue
+		}
+		now := time.Now()
+		switch strings.ToLower(fields[0]) {
+		case "0":
+			return
+		case "start":
+			if len(fields) != 2 {
+				fmt.Fprintln(out, "Please enter an appropiate input")
+				continue
+			}
+			taskId, err := strconv.Atoi(fields[1])
+			if err != nil {
+				fmt.Fprintln(out, "Please enter a valid input for the task number")
+				continue
+			}
+			err = taskList.StartTimer(taskId, now)
+			if err != nil {
+				fmt.Fprintln(out, err)
+				continue
+			}
+			fmt.Fprintf(out, "Started timer on task #%d\n", taskId)
+		case "stop":
+			task, entry, err := taskList.StopTimer(now)
+			if err != nil {
+				fmt.Fprintln(out, err)
+				continue
+			}
+			fmt.Fprintf(out, "Stopped timer on task #%d after %v\n", task.Id, entry.Duration(now).Round(time.Second))
+		case "log":
+			if len(fields) != 4 {
+				fmt.Fprintln(out, "Please enter an appropiate input")
+				continue
+			}
+			taskId, err := strconv.Atoi(fields[1])
+			if err != nil {
+				fmt.Fprintln(out, "Please enter a valid input for the task number")
+				continue
+			}
+			day, err := time.ParseInLocation(settings.DateLayout(), fields[2], time.Local)
+			if err != nil {
+				fmt.Fprintln(out, tasks.TaskDateErr)
+				continue
+			}
+			duration, err := time.ParseDuration(fields[3])
+			if err != nil {
+				fmt.Fprintln(out, "Please enter a valid duration (example: 1h30m)")
+				continue
+			}
+			_, err = taskList.AddTimeEntry(taskId, day, day.Add(duration))
+			if err != nil {
+				fmt.Fprintln(out, err)
+				continue
+			}
+		case "estimate":
+			if len(fields) != 3 {
+				fmt.Fprintln(out, "Please enter an appropiate input")
+				continue
+			}
+			taskId, err := strconv.Atoi(fields[1])
+			if err != nil {
+				fmt.Fprintln(out, "Please enter a valid input for the task number")
+				continue
+			}
+			estimate, err := time.ParseDuration(fields[2])
+			if err != nil {
+				fmt.Fprintln(out, "Please enter a valid duration (example: 1h30m)")
+				continue
+			}
+			err = taskList.SetEstimate(taskId, estimate)
+			if err != nil {
+				fmt.Fprintln(out, err)
+				continue
+			}
+		case "timesheet":
+			weekStart, err := parseWeek(fields[1:], now)
+			if err != nil {
+				fmt.Fprintln(out, err)
+				continue
+			}
+			printTimesheet(out, taskList.Timesheet(weekStart, now), weekStart)
+		case "export":
+			if len(fields) < 2 {
+				fmt.Fprintln(out, "Please enter the path of the file to export to")
+				continue
+			}
+			weekStart, err := parseWeek(fields[2:], now)
+			if err != nil {
+				fmt.Fprintln(out, err)
+				continue
+			}
+			err = exportTimesheet(fields[1], taskList.Timesheet(weekStart, now), weekStart)
+			if err != nil {
+				fmt.Fprintln(out, err)
+				continue
+			}
+			fmt.Fprintf(out, "Timesheet for the week of %s saved to %q\n", weekStart.Format(settings.DateLayout()), fields[1])
+		default:
+			fmt.Fprintln(out, "Please enter an appropiate input")
+		}
+	}
+}
+
+func parseWeek(fields []string, now time.Time) (time.Time, error) {
+	if len(fields) == 0 {
+		return tasks.WeekStart(now), nil
+	}
+	day, err := time.ParseInLocation(settings.DateLayout(), fields[0], time.Local)
+	if err != nil {
+		return time.Time{}, tasks.TaskDateErr
+	}
+	return tasks.WeekStart(day), nil
+}
+
+func printTimeTable(out io.Writer, taskList tasks.TaskList, now time.Time) {
+	table := tabwriter.NewWriter(out, 1, 1, 1, ' ', 0)
+	fmt.Fprintln(table, "Task Number\t", "Name\t", "Estimate\t", "Spent\t", "Difference\t", "Timer\t")
+	running, _ := taskList.RunningTimer()
+	for id, task := range taskList {
+		timer := ""
+		if running == task {
+			timer = "running"
+		}
+		fmt.Fprintf(table, "%d.-\t %q\t %v\t %v\t %v\t %s\t\n", id, strings.TrimSpace(task.Name), task.Estimate, task.TimeSpent(now).Round(time.Minute), task.OverEstimate(now).Round(time.Minute), timer)
+	}
+	table.Flush()
+}
+
+func timesheetRecords(rows []tasks.TimesheetRow, weekStart time.Time) [][]string {
+	header := []string{"Task Number", "Name"}
+	for day := 0; day < 7; day++ {
+		header = append(header, weekStart.AddDate(0, 0, day).Format("Mon 02-01"))
+	}
+	header = append(header, "Total")
+	records := [][]string{header}
+
+	var totals [7]time.Duration
+	var total time.Duration
+	for _, row := range rows {
+		record := []string{strconv.Itoa(row.TaskId), strings.TrimSpace(row.Name)}
+		for day, spent := range row.Days {
+			record = append(record, formatHours(spent))
+			totals[day] += spent
+		}
+		record = append(record, formatHours(row.Total))
+		total += row.Total
+		records = append(records, record)
+	}
+	footer := []string{"", "Total"}
+	for _, spent := range totals {
+		footer = append(footer, formatHours(spent))
+	}
+	return append(records, append(footer, formatHours(total)))
+}
+
+func formatHours(d time.Duration) string {
+	return strconv.FormatFloat(d.Hours(), 'f', 2, 64)
+}
+
+func printTimesheet(out io.Writer, rows []tasks.TimesheetRow, weekStart time.Time) {
+	table := tabwriter.NewWriter(out, 1, 1, 1, ' ', 0)
+	for _, record := range timesheetRecords(rows, weekStart) {
+		fmt.Fprintln(table, strings.Join(record, "\t ")+"\t")
+	}
+	table.Flush()
+}
+
+func exportTimesheet(path string, rows []tasks.TimesheetRow, weekStart time.Time) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	csvWriter := csv.NewWriter(file)
+	csvWriter.WriteAll(timesheetRecords(rows, weekStart))
+	if err := csvWriter.Error(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
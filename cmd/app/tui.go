@@ -0,0 +1,32 @@
+package main
+
+import (
+	"time"
+	"todo_app/pkg/auth"
+	"todo_app/pkg/tui"
+)
+
+// fullScreen runs the full-screen mode on the tasks of the logged in user and
+// saves what was changed once they quit.
+func (ctx appContext) fullScreen(args []string) error {
+	if len(args) != 0 {
+		return usageErr
+	}
+	user, err := ctx.authenticate(auth.ScopeTasksWrite)
+	if err != nil {
+		return err
+	}
+	restore, err := makeRaw()
+	if err != nil {
+		return err
+	}
+	app := tui.New(ctx.taskList(user), user, time.Now())
+	app.DateLayout = settings.DateLayout()
+	app.Sort = settings.DefaultSort
+	err = tui.Run(app, ctx.stdin, ctx.stdout, terminalSize)
+	restore()
+	if err != nil {
+		return err
+	}
+	return ctx.save()
+}
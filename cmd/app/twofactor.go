@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"todo_app/pkg/auth"
+	"todo_app/pkg/store"
+
+	"rsc.io/qr"
+)
+
+const totpIssuer = "Todo App"
+
+// twoFactorMenu turns two-factor authentication on and off for user. Changes
+// are saved right away as they change how the user logs in.
+func twoFactorMenu(reader *bufio.Reader, out io.Writer, users auth.UserDatabase, user auth.User) {
+	for {
+		current, err := users.GetUserById(user.Id)
+		if err != nil {
+			fmt.Fprintln(out, err)
+			return
+		}
+		if current.HasTOTP() {
+			fmt.Fprintf(out, "Two-factor authentication is on, %d recovery codes left\n", len(current.RecoveryCodes))
+			fmt.Fprintln(out, "Enter one of the following commands or 0 to return to the previous menu:")
+			fmt.Fprintln(out, "  off <code>")
+			fmt.Fprintln(out, "  recovery <code>, to get new recovery codes")
+		} else {
+			fmt.Fprintln(out, "Two-factor authentication is off")
+			fmt.Fprintln(out, "Enter one of the following commands or 0 to return to the previous menu:")
+			fmt.Fprintln(out, "  on")
+		}
+		input, inputErr := reader.ReadString('\n')
+		if inputErr != nil {
+			fmt.Fprintln(out, inputErr)
+			return
+		}
+		fields := strings.Fields(input)
+		if len(fields) == 0 {
+			fmt.Fprintln(out, "Please enter an appropiate input")
+			continue
+		}
+		switch strings.ToLower(fields[0]) {
+		case "0":
+			return
+		case "on":
+			if !enrollTOTP(reader, out, users, user) {
+				continue
+			}
+		case "off", "recovery":
+			if len(fields) != 2 {
+				fmt.Fprintln(out, "Please enter the code from your authenticator app or a recovery code")
+				continue
+			}
+			if strings.ToLower(fields[0]) == "off" {
+				err = auth.DisableTOTP(users, user.Username, fields[1], time.Now())
+			} else {
+				var codes []string
+				codes, err = auth.RegenerateRecoveryCodes(users, user.Username, fields[1], time.Now())
+				if err == nil {
+					printRecoveryCodes(out, codes)
+				}
+			}
+			if err != nil {
+				fmt.Fprintln(out, err)
+				continue
+			}
+		default:
+			fmt.Fprintln(out, "Please enter an appropiate input")
+			continue
+		}
+		err = store.SaveUsers(dataPath("users.csv"), users)
+		if err != nil {
+			fmt.Fprintln(out, "error writing to file")
+		}
+	}
+}
+
+// enrollTOTP shows a new secret and turns two-factor authentication on once
+// the user enters a code from their app.
+func enrollTOTP(reader *bufio.Reader, out io.Writer, users auth.UserDatabase, user auth.User) bool {
+	secret, err := auth.NewTOTPSecret()
+	if err != nil {
+		fmt.Fprintln(out, err)
+		return false
+	}
+	uri := auth.TOTPURI(totpIssuer, user.Username, secret)
+	fmt.Fprintln(out, "Scan this code with your authenticator app:")
+	err = printQR(out, uri)
+	if err != nil {
+		fmt.Fprintln(out, uri)
+	}
+	fmt.Fprintf(out, "Or enter this key by hand: %s\n", secret)
+	for {
+		fmt.Fprintln(out, "Please enter the code your app shows, or 0 to cancel")
+		code, codeErr := reader.ReadString('\n')
+		if codeErr != nil {
+			fmt.Fprintln(out, codeErr)
+			return false
+		}
+		if strings.TrimSpace(code) == "0" {
+			return false
+		}
+		codes, err := auth.EnableTOTP(users, user.Username, secret, code, time.Now())
+		if _, wrong := err.(auth.SecondFactorErr); wrong {
+			fmt.Fprintln(out, err)
+			continue
+		}
+		if err != nil {
+			fmt.Fprintln(out, err)
+			return false
+		}
+		fmt.Fprintln(out, "Two-factor authentication is on")
+		printRecoveryCodes(out, codes)
+		return true
+	}
+}
+
+// logInSecondFactor asks for the code LogIn wants before letting the user
+// in. The code is spent so the users file is saved.
+func logInSecondFactor(reader *bufio.Reader, out io.Writer, users auth.UserDatabase, throttle *auth.LoginThrottle, required auth.SecondFactorRequired) (auth.User, error) {
+	fmt.Fprintln(out, required)
+	code, err := reader.ReadString('\n')
+	if err != nil {
+		return auth.User{}, err
+	}
+	user, err := throttle.CompleteLogIn(users, required.Challenge, code, "cli")
+	if err != nil {
+		return auth.User{}, err
+	}
+	err = store.SaveUsers(dataPath("users.csv"), users)
+	if err != nil {
+		fmt.Fprintln(out, "error writing to file")
+	}
+	return user, nil
+}
+
+func printRecoveryCodes(out io.Writer, codes []string) {
+	fmt.Fprintln(out, "Keep these recovery codes somewhere safe, each logs you in once if you lose your app.")
+	fmt.Fprintln(out, "They will not be shown again:")
+	for _, code := range codes {
+		fmt.Fprintf(out, "  %s\n", code)
+	}
+}
+
+// printQR draws text as a QR code, two modules per character so it keeps its
+// shape in a terminal. Colors are set so it scans on dark themes too.
+func printQR(out io.Writer, text string) error {
+	code, err := qr.Encode(text, qr.M)
+	if err != nil {
+		return err
+	}
+	const quiet = 4
+	var drawing strings.Builder
+	for y := -quiet; y < code.Size+quiet; y += 2 {
+		drawing.WriteString("\x1b[30;47m")
+		for x := -quiet; x < code.Size+quiet; x++ {
+			top, bottom := code.Black(x, y), code.Black(x, y+1)
+			switch {
+			case top && bottom:
+				drawing.WriteString("█")
+			case top:
+				drawing.WriteString("▀")
+			case bottom:
+				drawing.WriteString("▄")
+			default:
+				drawing.WriteString(" ")
+			}
+		}
+		drawing.WriteString("\x1b[0m\n")
+	}
+	fmt.Fprint(out, drawing.String())
+	return nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"todo_app/pkg/auth"
+	"todo_app/pkg/mailer"
+	"todo_app/pkg/store"
+)
+
+func sendVerification(out io.Writer, users auth.UserDatabase, verifications *auth.EmailVerifications, sender mailer.Mailer, id string) {
+	err := verifications.Send(users, sender, id)
+	if err != nil {
+		fmt.Fprintln(out, err)
+		return
+	}
+	err = store.SaveVerifications(dataPath("verifications.csv"), verifications)
+	if err != nil {
+		fmt.Fprintln(out, "couldnt write verifications file")
+	}
+	fmt.Fprintln(out, "We sent a verification code to your email, enter it with the Verify email option")
+	if fileMailer, ok := sender.(*mailer.File); ok {
+		fmt.Fprintf(out, "Mail is not set up, emails are saved in %s\n", fileMailer.Dir)
+	}
+}
+
+func verifyEmail(reader *bufio.Reader, out io.Writer, users auth.UserDatabase, verifications *auth.EmailVerifications, sender mailer.Mailer) {
+	fmt.Fprintln(out, "Please enter the code from the email, or 'resend <username or email>' to get a new one")
+	input, inputErr := reader.ReadString('\n')
+	if inputErr != nil {
+		fmt.Fprintln(out, inputErr)
+		return
+	}
+	fields := strings.Fields(input)
+	if len(fields) == 2 && strings.ToLower(fields[0]) == "resend" {
+		sendVerification(out, users, verifications, sender, fields[1])
+		return
+	}
+	if len(fields) != 1 {
+		fmt.Fprintln(out, "Please enter an appropiate input")
+		return
+	}
+	user, err := verifications.Verify(users, fields[0])
+	if err != nil {
+		fmt.Fprintln(out, err)
+		return
+	}
+	err = store.SaveUsers(dataPath("users.csv"), users)
+	if err != nil {
+		fmt.Fprintln(out, "error writing to file")
+		return
+	}
+	err = store.SaveVerifications(dataPath("verifications.csv"), verifications)
+	if err != nil {
+		fmt.Fprintln(out, "couldnt write verifications file")
+	}
+	fmt.Fprintf(out, "Thanks %q, your email is verified\n", user.Username)
+}
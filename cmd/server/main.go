@@ -0,0 +1,179 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+	"todo_app/pkg/api"
+	"todo_app/pkg/audit"
+	"todo_app/pkg/auth"
+	"todo_app/pkg/blobs"
+	"todo_app/pkg/config"
+	"todo_app/pkg/mailer"
+	"todo_app/pkg/oidc"
+	"todo_app/pkg/store"
+	"todo_app/pkg/tasks"
+)
+
+func main() {
+	addr := flag.String("addr", ":8080", "address to listen on")
+	requireVerified := flag.String("require-verified", "off", "what users cannot do before verifying their email: off, tasks or login")
+	blocklistPath := flag.String("password-blocklist", "", "file of passwords too common to allow, one per line (default common_passwords.txt in the data folder)")
+	eventsPath := flag.String("events", "", "file security events are appended to as JSON lines (default events.jsonl in the data folder)")
+	eventsMaxSize := flag.Int64("events-max-size", audit.DefaultMaxSize, "bytes the events file can grow to before it is rotated")
+	eventsMaxFiles := flag.Int("events-max-files", audit.DefaultMaxFiles, "rotated events files to keep")
+	oidcIssuer := flag.String("oidc-issuer", "", "issuer URL of an OpenID Connect provider users can log in through at /v1/oidc/login, its client secret is read from TODO_OIDC_CLIENT_SECRET")
+	oidcClientID := flag.String("oidc-client-id", "", "client id of the app at the OpenID Connect provider")
+	oidcRedirectURL := flag.String("oidc-redirect-url", "", "public URL of /v1/oidc/callback, as registered at the OpenID Connect provider")
+	oidcProvision := flag.Bool("oidc-provision", false, "create accounts for OpenID Connect users whose email has none")
+	cfg, _, err := config.Load(flag.CommandLine, os.Args[1:], os.Getenv)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.MkdirAll(cfg.DataDir, 0700); err != nil {
+		log.Fatal(err)
+	}
+
+	enforcement, err := auth.ParseEnforcement(*requireVerified)
+	if err != nil {
+		log.Fatal(err)
+	}
+	auth.RequireVerifiedEmail(enforcement)
+
+	if *blocklistPath == "" {
+		*blocklistPath = filepath.Join(cfg.DataDir, "common_passwords.txt")
+	}
+	passwordPolicy := cfg.PasswordPolicy()
+	passwordPolicy.Blocklist, err = auth.LoadBlocklist(*blocklistPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = auth.UsePasswordPolicy(passwordPolicy)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *eventsPath == "" {
+		*eventsPath = filepath.Join(cfg.DataDir, "events.jsonl")
+	}
+	events, err := audit.NewFile(*eventsPath, *eventsMaxSize, *eventsMaxFiles)
+	if err != nil {
+		log.Fatal(err)
+	}
+	auth.UseEventSink(events)
+
+	err = auth.UsePasswordHasher(cfg.PasswordHasher())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	usersPath := filepath.Join(cfg.DataDir, "users.csv")
+	tasksPath := filepath.Join(cfg.DataDir, "tasks.csv")
+	sessionsPath := filepath.Join(cfg.DataDir, "sessions.csv")
+	apiKeysPath := filepath.Join(cfg.DataDir, "apikeys.csv")
+	verificationsPath := filepath.Join(cfg.DataDir, "verifications.csv")
+	attachmentsPath := filepath.Join(cfg.DataDir, "attachments.csv")
+	timeEntriesPath := filepath.Join(cfg.DataDir, "time_entries.csv")
+
+	users, err := store.LoadUsers(usersPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	userTasks, err := store.LoadTasks(tasksPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// deleting a task through the API releases its attachments
+	blobStore, err := blobs.NewStore(filepath.Join(cfg.DataDir, "blobs"), blobs.DefaultMaxSize)
+	if err != nil {
+		log.Fatal(err)
+	}
+	tasks.UseBlobStore(blobStore)
+	err = store.LoadAttachments(attachmentsPath, userTasks, blobStore)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = blobStore.Sweep()
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = store.LoadTimeEntries(timeEntriesPath, userTasks)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	sessions := auth.NewSessions(cfg.SessionIdleTimeout, cfg.SessionMaxLifetime)
+	err = store.LoadSessions(sessionsPath, sessions)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	apiKeys := auth.NewAPIKeys()
+	err = store.LoadAPIKeys(apiKeysPath, apiKeys)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	verifications := auth.NewEmailVerifications(auth.DefaultVerificationTTL)
+	err = store.LoadVerifications(verificationsPath, verifications)
+	if err != nil {
+		log.Fatal(err)
+	}
+	sender, err := mailer.FromEnv(filepath.Join(cfg.DataDir, "mail"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	handler := api.NewServer(users, userTasks, sessions, func() error {
+		err := store.SaveUsers(usersPath, users)
+		if err != nil {
+			return err
+		}
+		err = store.SaveSessions(sessionsPath, sessions)
+		if err != nil {
+			return err
+		}
+		err = store.SaveAPIKeys(apiKeysPath, apiKeys)
+		if err != nil {
+			return err
+		}
+		err = store.SaveVerifications(verificationsPath, verifications)
+		if err != nil {
+			return err
+		}
+		err = store.SaveTasks(tasksPath, userTasks)
+		if err != nil {
+			return err
+		}
+		err = store.SaveAttachments(attachmentsPath, userTasks)
+		if err != nil {
+			return err
+		}
+		return store.SaveTimeEntries(timeEntriesPath, userTasks)
+	})
+	handler.UseAPIKeys(apiKeys)
+	handler.UseEmailVerification(verifications, sender)
+	if *oidcIssuer != "" {
+		provider, err := oidc.Discover(oidc.NewClient(), *oidcIssuer)
+		if err != nil {
+			log.Fatal(err)
+		}
+		handler.UseOIDC(oidc.NewRelyingParty(provider, oidc.Config{
+			ClientID:     *oidcClientID,
+			ClientSecret: os.Getenv("TODO_OIDC_CLIENT_SECRET"),
+			RedirectURL:  *oidcRedirectURL,
+		}), *oidcProvision)
+	}
+
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	log.Printf("listening on %s", *addr)
+	log.Fatal(server.ListenAndServe())
+}
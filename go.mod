@@ -5,4 +5,8 @@ go 1.22.3
 require (
 	github.com/google/uuid v1.6.0
 	golang.org/x/crypto v0.23.0
+	golang.org/x/text v0.15.0
+	rsc.io/qr v0.2.0
 )
+
+require golang.org/x/sys v0.20.0 // indirect
@@ -0,0 +1,768 @@
+package api
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"todo_app/pkg/auth"
+	"todo_app/pkg/mailer"
+	"todo_app/pkg/oidc"
+	"todo_app/pkg/tasks"
+
+	"github.com/google/uuid"
+)
+
+const (
+	maxBodySize     = 1 << 20
+	InvalidJSONErr  = APIError("Request body is not valid JSON")
+	InvalidLoginErr = APIError("Incorrect username, email or password")
+	NotFoundErr     = APIError("Resource not found")
+	InvalidIdErr    = APIError("Task id must be a number")
+	InternalErr     = APIError("Something went wrong, try again later")
+	NoAccountErr    = APIError("No account matches your single sign-on login")
+	oidcStateCookie = "oidc_state"
+)
+
+type APIError string
+
+func (err APIError) Error() string {
+	return string(err)
+}
+
+// Server serves the users and tasks of the app as a JSON API. Save is called
+// after every change so it can be persisted, with the server locked.
+type Server struct {
+	mu        sync.Mutex
+	users     auth.UserDatabase
+	tasks     map[uuid.UUID]tasks.TaskList
+	sessions  *auth.Sessions
+	apiKeys   *auth.APIKeys
+	verify    *auth.EmailVerifications
+	mailer    mailer.Mailer
+	throttle  *auth.LoginThrottle
+	sso       *oidc.RelyingParty
+	provision bool
+	save      func() error
+	mux       *http.ServeMux
+}
+
+func NewServer(users auth.UserDatabase, userTasks map[uuid.UUID]tasks.TaskList, sessions *auth.Sessions, save func() error) *Server {
+	server := &Server{users: users, tasks: userTasks, sessions: sessions, throttle: auth.NewLoginThrottle(), save: save, mux: http.NewServeMux()}
+	server.handle("POST /v1/users", server.register)
+	server.handle("POST /v1/login", server.login)
+	server.handle("GET /v1/oidc/login", server.oidcLogin)
+	// the callback talks to the identity provider first and only locks after
+	server.mux.HandleFunc("GET /v1/oidc/callback", server.oidcCallback)
+	server.handle("POST /v1/verify", server.verifyEmail)
+	server.handle("POST /v1/verify/resend", server.resendVerification)
+	server.handle("POST /v1/logout", server.authenticated(server.logout))
+	server.handle("POST /v1/logout/all", server.authenticated(server.logoutAll))
+	server.handle("POST /v1/admin/users/{user}/disable", server.authenticated(server.disableUser))
+	server.handle("POST /v1/admin/users/{user}/enable", server.authenticated(server.enableUser))
+	server.handle("PUT /v1/admin/users/{user}/role", server.authenticated(server.setRole))
+	server.handle("POST /v1/admin/users/{user}/unlock", server.authenticated(server.unlockUser))
+	server.handle("POST /v1/admin/sources/{source}/unlock", server.authenticated(server.unlockSource))
+	server.handle("GET /v1/tasks", server.authorized(auth.ScopeTasksRead, server.listTasks))
+	server.handle("POST /v1/tasks", server.authorized(auth.ScopeTasksWrite, server.createTask))
+	server.handle("GET /v1/tasks/{id}", server.authorized(auth.ScopeTasksRead, server.getTask))
+	server.handle("PATCH /v1/tasks/{id}", server.authorized(auth.ScopeTasksWrite, server.updateTask))
+	server.handle("DELETE /v1/tasks/{id}", server.authorized(auth.ScopeTasksWrite, server.deleteTask))
+	server.handle("POST /v1/tasks/{id}/complete", server.authorized(auth.ScopeTasksWrite, server.completeTask))
+	server.mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, NotFoundErr)
+	})
+	return server
+}
+
+// UseAPIKeys lets the task routes be called with the API keys of keys as
+// bearer tokens, within the scopes of each key.
+func (server *Server) UseAPIKeys(keys *auth.APIKeys) {
+	server.apiKeys = keys
+}
+
+// UseLoginThrottle replaces the throttle failed logins are counted by, e.g.
+// to share it with other servers or to change its policies.
+func (server *Server) UseLoginThrottle(throttle *auth.LoginThrottle) {
+	server.throttle = throttle
+}
+
+// UseEmailVerification mails a verification code to every new user and to
+// whoever asks through /v1/verify/resend, to be checked by /v1/verify.
+func (server *Server) UseEmailVerification(verifications *auth.EmailVerifications, sender mailer.Mailer) {
+	server.verify = verifications
+	server.mailer = sender
+}
+
+// UseOIDC lets users log in through the identity provider of rp, starting at
+// /v1/oidc/login. Users who log in there for the first time are linked by
+// email, or get a new account when provision is true.
+func (server *Server) UseOIDC(rp *oidc.RelyingParty, provision bool) {
+	server.sso = rp
+	server.provision = provision
+}
+
+func (server *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	server.mux.ServeHTTP(w, r)
+}
+
+// handle serves pattern with the server locked, as handlers share the users,
+// sessions and tasks it keeps in memory.
+func (server *Server) handle(pattern string, handler http.HandlerFunc) {
+	server.mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		server.mu.Lock()
+		defer server.mu.Unlock()
+		handler(w, r)
+	})
+}
+
+type userJSON struct {
+	Id       uuid.UUID `json:"id"`
+	Email    string    `json:"email"`
+	Username string    `json:"username"`
+	Verified bool      `json:"verified"`
+}
+
+// adminUserJSON is what administrators see of an account, see
+// auth.UserSummary.
+type adminUserJSON struct {
+	Id                    uuid.UUID `json:"id"`
+	Email                 string    `json:"email"`
+	Username              string    `json:"username"`
+	Role                  string    `json:"role"`
+	Verified              bool      `json:"verified"`
+	Disabled              bool      `json:"disabled"`
+	TwoFactor             bool      `json:"two_factor"`
+	PasswordResetRequired bool      `json:"password_reset_required"`
+}
+
+type taskJSON struct {
+	Id          int      `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Date        string   `json:"date"`
+	Status      string   `json:"status"`
+	Priority    string   `json:"priority"`
+	Tags        []string `json:"tags"`
+}
+
+type sessionJSON struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+	User      userJSON  `json:"user"`
+}
+
+type errorJSON struct {
+	Error errorBody `json:"error"`
+}
+
+type errorBody struct {
+	Status  int    `json:"status"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// Details lists every rule a password broke
+	Details []string `json:"details,omitempty"`
+}
+
+func newUserJSON(user auth.User) userJSON {
+	return userJSON{Id: user.Id, Email: user.Email, Username: user.Username, Verified: user.Verified}
+}
+
+func newAdminUserJSON(summary auth.UserSummary) adminUserJSON {
+	return adminUserJSON{
+		Id:                    summary.Id,
+		Email:                 summary.Email,
+		Username:              summary.Username,
+		Role:                  summary.Role.String(),
+		Verified:              summary.Verified,
+		Disabled:              summary.Disabled,
+		TwoFactor:             summary.TwoFactor,
+		PasswordResetRequired: summary.PasswordResetRequired,
+	}
+}
+
+func newTaskJSON(task tasks.Task) taskJSON {
+	tags := task.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	return taskJSON{
+		Id:          task.Id,
+		Name:        strings.TrimSpace(task.Name),
+		Description: strings.TrimSpace(task.Description),
+		Date:        task.Date,
+		Status:      task.TaskStatus,
+		Priority:    task.Priority,
+		Tags:        tags,
+	}
+}
+
+// errorStatus maps the errors of the auth and tasks packages to a status code
+// and a stable code clients can switch on.
+func errorStatus(err error) (int, string) {
+	switch err := err.(type) {
+	case tasks.TaskError:
+		if err == tasks.TaskNotFoundErr {
+			return http.StatusNotFound, "task_not_found"
+		}
+		return http.StatusUnprocessableEntity, "invalid_task"
+	case auth.EmailErr:
+		return http.StatusUnprocessableEntity, "invalid_email"
+	case auth.UsernameErr:
+		return http.StatusUnprocessableEntity, "invalid_username"
+	case auth.PasswordErr, auth.PasswordRulesErr:
+		return http.StatusUnprocessableEntity, "invalid_password"
+	case auth.UserErr:
+		return http.StatusConflict, "user_exists"
+	case auth.SessionErr, auth.APIKeyErr:
+		return http.StatusUnauthorized, "unauthorized"
+	case auth.ScopeErr:
+		return http.StatusForbidden, "forbidden"
+	case auth.VerificationErr:
+		return http.StatusForbidden, "email_not_verified"
+	case auth.AccessErr:
+		return http.StatusForbidden, "account_restricted"
+	case auth.PermissionErr:
+		return http.StatusForbidden, "forbidden"
+	case auth.PolicyErr:
+		return http.StatusUnprocessableEntity, "invalid_role"
+	case auth.TokenErr:
+		return http.StatusUnprocessableEntity, "invalid_token"
+	case auth.LockedErr:
+		return http.StatusTooManyRequests, "too_many_attempts"
+	case auth.SecondFactorRequired:
+		return http.StatusUnauthorized, "second_factor_required"
+	case oidc.OIDCError:
+		return http.StatusUnauthorized, "sso_failed"
+	case APIError:
+		switch err {
+		case InvalidJSONErr, InvalidIdErr:
+			return http.StatusBadRequest, "bad_request"
+		case InvalidLoginErr:
+			return http.StatusUnauthorized, "unauthorized"
+		case NotFoundErr:
+			return http.StatusNotFound, "not_found"
+		case NoAccountErr:
+			return http.StatusForbidden, "no_account"
+		}
+	}
+	return http.StatusInternalServerError, "internal_error"
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status, code := errorStatus(err)
+	if status == http.StatusInternalServerError {
+		err = InternalErr
+	}
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="todo_app"`)
+	}
+	body := errorBody{Status: status, Code: code, Message: err.Error()}
+	if rules, ok := err.(auth.PasswordRulesErr); ok {
+		for _, rule := range rules {
+			body.Details = append(body.Details, string(rule))
+		}
+	}
+	writeJSON(w, status, errorJSON{Error: body})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, body any) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(body)
+	if err != nil {
+		return InvalidJSONErr
+	}
+	return nil
+}
+
+// authenticated only lets requests with the bearer token of a session
+// started through /v1/login through.
+func (server *Server) authenticated(handler func(w http.ResponseWriter, r *http.Request, user auth.User)) http.HandlerFunc {
+	middleware := server.sessions.Middleware(server.users, func(w http.ResponseWriter, r *http.Request, err error) {
+		writeError(w, err)
+	})
+	return middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, _ := auth.UserFromContext(r.Context())
+		handler(w, r, user)
+	})).ServeHTTP
+}
+
+// authorized also accepts API keys that have scope, on top of sessions.
+func (server *Server) authorized(scope string, handler func(w http.ResponseWriter, r *http.Request, user auth.User)) http.HandlerFunc {
+	withSession := server.authenticated(handler)
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, err := auth.BearerToken(r)
+		if err != nil || server.apiKeys == nil || !auth.IsAPIKey(token) {
+			withSession(w, r)
+			return
+		}
+		user, _, err := server.apiKeys.Verify(server.users, token, scope)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		handler(w, r, user)
+	}
+}
+
+func (server *Server) persist(w http.ResponseWriter) bool {
+	if server.save == nil {
+		return true
+	}
+	err := server.save()
+	if err != nil {
+		writeError(w, err)
+		return false
+	}
+	return true
+}
+
+func (server *Server) userTasks(user auth.User) tasks.TaskList {
+	taskList, found := server.tasks[user.Id]
+	if !found {
+		taskList = make(tasks.TaskList)
+		server.tasks[user.Id] = taskList
+	}
+	return taskList
+}
+
+func (server *Server) register(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Email    string `json:"email"`
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	err := readJSON(w, r, &body)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	user, err := server.users.RegisterUser(body.Email, body.Username, body.Password)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if server.verify != nil {
+		// the user can ask for another code if this one could not be sent
+		server.verify.Send(server.users, server.mailer, user.Username)
+	}
+	if server.persist(w) {
+		writeJSON(w, http.StatusCreated, newUserJSON(user))
+	}
+}
+
+func (server *Server) login(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Login    string `json:"login"`
+		Password string `json:"password"`
+		Code     string `json:"code"`
+	}
+	err := readJSON(w, r, &body)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	user, err := server.throttle.LogIn(server.users, body.Login, body.Password, clientAddress(r))
+	// users with two-factor authentication send the code of their app, or a
+	// recovery code, along with the password
+	if required, ok := err.(auth.SecondFactorRequired); ok && body.Code != "" {
+		user, err = server.throttle.CompleteLogIn(server.users, required.Challenge, body.Code, clientAddress(r))
+	}
+	if locked, ok := err.(auth.LockedErr); ok {
+		wait := locked.Until.Sub(server.throttle.Now())
+		w.Header().Set("Retry-After", strconv.Itoa(int((wait+time.Second-1)/time.Second)))
+		writeError(w, err)
+		return
+	}
+	if _, required := err.(auth.SecondFactorRequired); required {
+		writeError(w, err)
+		return
+	}
+	if _, unverified := err.(auth.VerificationErr); unverified {
+		writeError(w, err)
+		return
+	}
+	if _, restricted := err.(auth.AccessErr); restricted {
+		writeError(w, err)
+		return
+	}
+	if err != nil {
+		writeError(w, InvalidLoginErr)
+		return
+	}
+	token, session, err := server.sessions.Create(user)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if server.persist(w) {
+		writeJSON(w, http.StatusOK, sessionJSON{Token: token, ExpiresAt: session.Expires, User: newUserJSON(user)})
+	}
+}
+
+// oidcLogin sends the browser to the identity provider. The state is also
+// kept in a cookie so a callback only finishes logins its browser started.
+func (server *Server) oidcLogin(w http.ResponseWriter, r *http.Request) {
+	if server.sso == nil {
+		writeError(w, NotFoundErr)
+		return
+	}
+	authURL, state, err := server.sso.AuthCodeURL("")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name: oidcStateCookie, Value: state, Path: "/v1/oidc/", MaxAge: int(server.sso.FlowTTL / time.Second),
+		HttpOnly: true, Secure: r.TLS != nil, SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+func (server *Server) oidcCallback(w http.ResponseWriter, r *http.Request) {
+	if server.sso == nil {
+		writeError(w, NotFoundErr)
+		return
+	}
+	query := r.URL.Query()
+	started, err := r.Cookie(oidcStateCookie)
+	if err != nil || started.Value != query.Get("state") {
+		writeError(w, oidc.InvalidStateErr)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: oidcStateCookie, Path: "/v1/oidc/", MaxAge: -1})
+	// the provider sends an error instead of a code when the user refused or
+	// could not log in
+	if query.Get("error") != "" {
+		writeError(w, oidc.ProviderErr)
+		return
+	}
+	token, err := server.sso.Exchange(query.Get("state"), query.Get("code"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	identity := auth.ExternalIdentity{
+		Identity:      auth.Identity{Issuer: token.Issuer, Subject: token.Subject},
+		Email:         token.Email,
+		EmailVerified: bool(token.EmailVerified),
+		Username:      token.PreferredUsername,
+	}
+	user, err := auth.LogInExternal(server.users, identity, server.provision)
+	if _, unknown := err.(auth.UserErr); unknown {
+		writeError(w, NoAccountErr)
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	sessionToken, session, err := server.sessions.Create(user)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if server.persist(w) {
+		writeJSON(w, http.StatusOK, sessionJSON{Token: sessionToken, ExpiresAt: session.Expires, User: newUserJSON(user)})
+	}
+}
+
+// clientAddress is the host failed logins are counted against. Forwarding
+// headers are ignored as anyone can set them.
+func clientAddress(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func (server *Server) verifyEmail(w http.ResponseWriter, r *http.Request) {
+	if server.verify == nil {
+		writeError(w, NotFoundErr)
+		return
+	}
+	var body struct {
+		Token string `json:"token"`
+	}
+	err := readJSON(w, r, &body)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	user, err := server.verify.Verify(server.users, body.Token)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if server.persist(w) {
+		writeJSON(w, http.StatusOK, newUserJSON(user))
+	}
+}
+
+// resendVerification always answers 202 so it can't be used to find out which
+// users exist or are verified.
+func (server *Server) resendVerification(w http.ResponseWriter, r *http.Request) {
+	if server.verify == nil {
+		writeError(w, NotFoundErr)
+		return
+	}
+	var body struct {
+		Login string `json:"login"`
+	}
+	err := readJSON(w, r, &body)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	server.verify.Send(server.users, server.mailer, body.Login)
+	if server.persist(w) {
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+func (server *Server) logout(w http.ResponseWriter, r *http.Request, user auth.User) {
+	token, _ := auth.TokenFromContext(r.Context())
+	err := server.sessions.Revoke(token)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if server.persist(w) {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// logoutAll ends every session of the user, on any device.
+func (server *Server) logoutAll(w http.ResponseWriter, r *http.Request, user auth.User) {
+	server.sessions.RevokeAll(user.Id)
+	if server.persist(w) {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// disableUser stops an account from logging in and ends its sessions. Admin
+// changes go through the server so they act on the users it has in memory;
+// the files it saves to would be overwritten by its next save otherwise.
+func (server *Server) disableUser(w http.ResponseWriter, r *http.Request, admin auth.User) {
+	summary, err := auth.SetDisabled(server.users, server.sessions, admin, r.PathValue("user"), true)
+	server.writeAdminChange(w, summary, err)
+}
+
+func (server *Server) enableUser(w http.ResponseWriter, r *http.Request, admin auth.User) {
+	summary, err := auth.SetDisabled(server.users, server.sessions, admin, r.PathValue("user"), false)
+	server.writeAdminChange(w, summary, err)
+}
+
+func (server *Server) setRole(w http.ResponseWriter, r *http.Request, admin auth.User) {
+	var body struct {
+		Role string `json:"role"`
+	}
+	err := readJSON(w, r, &body)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	role, err := auth.ParseRole(body.Role)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	summary, err := auth.SetRole(server.users, admin, r.PathValue("user"), role)
+	server.writeAdminChange(w, summary, err)
+}
+
+// unlockUser forgets the failed logins of an account, failures are only kept
+// in memory so there is nothing to save.
+func (server *Server) unlockUser(w http.ResponseWriter, r *http.Request, admin auth.User) {
+	summary, err := auth.UnlockLogins(server.users, server.throttle, admin, r.PathValue("user"))
+	if _, missing := err.(auth.UserErr); missing {
+		err = NotFoundErr
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newAdminUserJSON(summary))
+}
+
+// unlockSource forgets the failed logins of a client address.
+func (server *Server) unlockSource(w http.ResponseWriter, r *http.Request, admin auth.User) {
+	err := auth.UnlockSource(server.users, server.throttle, admin, r.PathValue("source"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeAdminChange saves and returns the account an administrator changed.
+func (server *Server) writeAdminChange(w http.ResponseWriter, summary auth.UserSummary, err error) {
+	if _, missing := err.(auth.UserErr); missing {
+		err = NotFoundErr
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if server.persist(w) {
+		writeJSON(w, http.StatusOK, newAdminUserJSON(summary))
+	}
+}
+
+func (server *Server) listTasks(w http.ResponseWriter, r *http.Request, user auth.User) {
+	list := []taskJSON{}
+	for _, task := range server.userTasks(user) {
+		list = append(list, newTaskJSON(*task))
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Id < list[j].Id })
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (server *Server) createTask(w http.ResponseWriter, r *http.Request, user auth.User) {
+	var body struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+		Date        string `json:"date"`
+	}
+	err := readJSON(w, r, &body)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	err = auth.CanCreateTasks(user)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	task, err := server.userTasks(user).AddTask(strings.TrimSpace(body.Name), body.Description, body.Date)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if server.persist(w) {
+		w.Header().Set("Location", "/v1/tasks/"+strconv.Itoa(task.Id))
+		writeJSON(w, http.StatusCreated, newTaskJSON(task))
+	}
+}
+
+func (server *Server) findTask(w http.ResponseWriter, r *http.Request, user auth.User) (*tasks.Task, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, InvalidIdErr)
+		return nil, false
+	}
+	task, err := server.userTasks(user).GetTask(id)
+	if err != nil {
+		writeError(w, err)
+		return nil, false
+	}
+	return task, true
+}
+
+func (server *Server) getTask(w http.ResponseWriter, r *http.Request, user auth.User) {
+	task, found := server.findTask(w, r, user)
+	if found {
+		writeJSON(w, http.StatusOK, newTaskJSON(*task))
+	}
+}
+
+// updateTask changes the fields present in the body. Every field is checked
+// before any is changed so an invalid request leaves the task as it was.
+func (server *Server) updateTask(w http.ResponseWriter, r *http.Request, user auth.User) {
+	task, found := server.findTask(w, r, user)
+	if !found {
+		return
+	}
+	var body struct {
+		Name        *string   `json:"name"`
+		Description *string   `json:"description"`
+		Date        *string   `json:"date"`
+		Priority    *string   `json:"priority"`
+		Tags        *[]string `json:"tags"`
+	}
+	err := readJSON(w, r, &body)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	taskList := server.userTasks(user)
+	if body.Name != nil && strings.TrimSpace(*body.Name) == "" {
+		writeError(w, tasks.TaskNameErr)
+		return
+	}
+	if body.Priority != nil {
+		_, err = tasks.ParsePriority(*body.Priority)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+	if body.Date != nil {
+		_, err = taskList.UpdateField(task.Id, "date", *body.Date)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+	if body.Name != nil {
+		taskList.UpdateField(task.Id, "name", strings.TrimSpace(*body.Name))
+	}
+	if body.Description != nil {
+		taskList.UpdateField(task.Id, "description", *body.Description)
+	}
+	if body.Priority != nil {
+		taskList.SetPriority(task.Id, *body.Priority)
+	}
+	if body.Tags != nil {
+		taskList.SetTags(task.Id, *body.Tags)
+	}
+	if server.persist(w) {
+		writeJSON(w, http.StatusOK, newTaskJSON(*task))
+	}
+}
+
+func (server *Server) deleteTask(w http.ResponseWriter, r *http.Request, user auth.User) {
+	task, found := server.findTask(w, r, user)
+	if !found {
+		return
+	}
+	err := server.userTasks(user).DeleteTask(task.Id)
+	// the task is gone even when one of its attachments could not be released
+	if !server.persist(w) {
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (server *Server) completeTask(w http.ResponseWriter, r *http.Request, user auth.User) {
+	task, found := server.findTask(w, r, user)
+	if !found {
+		return
+	}
+	err := server.userTasks(user).CompleteTask(task.Id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if server.persist(w) {
+		writeJSON(w, http.StatusOK, newTaskJSON(*task))
+	}
+}
@@ -0,0 +1,679 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"todo_app/pkg/auth"
+	"todo_app/pkg/blobs"
+	"todo_app/pkg/mailer"
+	"todo_app/pkg/oidc"
+	"todo_app/pkg/oidc/oidctest"
+	"todo_app/pkg/tasks"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestMain(m *testing.M) {
+	auth.UsePasswordHasher(auth.BcryptHasher{Cost: bcrypt.MinCost})
+	os.Exit(m.Run())
+}
+
+func TestRegister(t *testing.T) {
+	server := newTestServer(t)
+	tests := []struct {
+		name            string
+		body            string
+		expected_status int
+		expected_code   string
+	}{
+		{name: "valid register", body: `{"email": "new@gmail.com", "username": "newuser", "password": "Abc12345!"}`, expected_status: http.StatusCreated},
+		{name: "taken username", body: `{"email": "other@gmail.com", "username": "newuser", "password": "Abc12345!"}`, expected_status: http.StatusConflict, expected_code: "user_exists"},
+		{name: "invalid email", body: `{"email": "nope", "username": "newuser2", "password": "Abc12345!"}`, expected_status: http.StatusUnprocessableEntity, expected_code: "invalid_email"},
+		{name: "weak password", body: `{"email": "x@gmail.com", "username": "newuser3", "password": "abc"}`, expected_status: http.StatusUnprocessableEntity, expected_code: "invalid_password"},
+		{name: "invalid json", body: `{"email": `, expected_status: http.StatusBadRequest, expected_code: "bad_request"},
+		{name: "unknown field", body: `{"email": "x@gmail.com", "admin": true}`, expected_status: http.StatusBadRequest, expected_code: "bad_request"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response := server.request(t, http.MethodPost, "/v1/users", test.body, false)
+
+			assertResponse(t, response, test.expected_status, test.expected_code)
+		})
+	}
+
+	var session sessionJSON
+	json.NewDecoder(server.request(t, http.MethodPost, "/v1/login", `{"login": "new@gmail.com", "password": "Abc12345!"}`, false).Body).Decode(&session)
+	if session.User.Username != "newuser" || session.User.Id == uuid.Nil || session.Token == "" {
+		t.Errorf("expected to log in as the registered user, got %v", session)
+	}
+
+	t.Run("every broken password rule", func(t *testing.T) {
+		response := server.request(t, http.MethodPost, "/v1/users", `{"email": "x@gmail.com", "username": "newuser3", "password": "abc"}`, false)
+
+		var body errorJSON
+		json.NewDecoder(response.Body).Decode(&body)
+		if len(body.Error.Details) != 4 {
+			t.Errorf("expected the four rules the password broke, got %v", body.Error.Details)
+		}
+	})
+}
+
+func TestLogin(t *testing.T) {
+	server := newTestServer(t)
+	tests := []struct {
+		name            string
+		body            string
+		expected_status int
+		expected_code   string
+	}{
+		{name: "valid login", body: `{"login": "tester", "password": "Abc12345!"}`, expected_status: http.StatusOK},
+		{name: "wrong password", body: `{"login": "tester", "password": "Wrong123!"}`, expected_status: http.StatusUnauthorized, expected_code: "unauthorized"},
+		{name: "unknown user", body: `{"login": "nobody", "password": "Abc12345!"}`, expected_status: http.StatusUnauthorized, expected_code: "unauthorized"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response := server.request(t, http.MethodPost, "/v1/login", test.body, false)
+
+			assertResponse(t, response, test.expected_status, test.expected_code)
+		})
+	}
+}
+
+func TestLoginThrottle(t *testing.T) {
+	server := newTestServer(t)
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	throttle := auth.NewLoginThrottle()
+	throttle.Account = auth.ThrottlePolicy{FreeFailures: 1, MaxFailures: 2, BaseDelay: time.Second, Lockout: time.Minute}
+	throttle.Now = func() time.Time { return now }
+	server.UseLoginThrottle(throttle)
+	for range 2 {
+		server.request(t, http.MethodPost, "/v1/login", `{"login": "tester", "password": "Wrong123!"}`, false)
+	}
+
+	response := server.request(t, http.MethodPost, "/v1/login", `{"login": "tester", "password": "Abc12345!"}`, false)
+
+	assertResponse(t, response, http.StatusTooManyRequests, "too_many_attempts")
+	if response.Header().Get("Retry-After") != "60" {
+		t.Errorf("got Retry-After %q, expected 60", response.Header().Get("Retry-After"))
+	}
+	now = now.Add(time.Minute)
+	response = server.request(t, http.MethodPost, "/v1/login", `{"login": "tester", "password": "Abc12345!"}`, false)
+	assertResponse(t, response, http.StatusOK, "")
+}
+
+func TestLoginSecondFactor(t *testing.T) {
+	server := newTestServer(t)
+	secret, _ := auth.NewTOTPSecret()
+	code, _ := auth.TOTPCode(secret, time.Now())
+	codes, err := auth.EnableTOTP(server.users, "tester", secret, code, time.Now())
+	if err != nil {
+		t.Fatalf("could not enroll test user: %q", err)
+	}
+	tests := []struct {
+		name            string
+		body            string
+		expected_status int
+		expected_code   string
+	}{
+		{name: "password only", body: `{"login": "tester", "password": "Abc12345!"}`, expected_status: http.StatusUnauthorized, expected_code: "second_factor_required"},
+		{name: "wrong code", body: `{"login": "tester", "password": "Abc12345!", "code": "000000"}`, expected_status: http.StatusUnauthorized, expected_code: "unauthorized"},
+		{name: "wrong password with code", body: `{"login": "tester", "password": "Wrong123!", "code": "` + codes[0] + `"}`, expected_status: http.StatusUnauthorized, expected_code: "unauthorized"},
+		{name: "recovery code", body: `{"login": "tester", "password": "Abc12345!", "code": "` + codes[0] + `"}`, expected_status: http.StatusOK},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response := server.request(t, http.MethodPost, "/v1/login", test.body, false)
+
+			assertResponse(t, response, test.expected_status, test.expected_code)
+		})
+	}
+}
+
+func TestLogout(t *testing.T) {
+	server := newTestServer(t)
+	var session sessionJSON
+	json.NewDecoder(server.request(t, http.MethodPost, "/v1/login", `{"login": "tester", "password": "Abc12345!"}`, false).Body).Decode(&session)
+
+	t.Run("session token works", func(t *testing.T) {
+		response := server.requestWithToken(t, http.MethodGet, "/v1/tasks", "", session.Token)
+
+		assertResponse(t, response, http.StatusOK, "")
+	})
+
+	t.Run("logout", func(t *testing.T) {
+		response := server.requestWithToken(t, http.MethodPost, "/v1/logout", "", session.Token)
+
+		assertResponse(t, response, http.StatusNoContent, "")
+		response = server.requestWithToken(t, http.MethodGet, "/v1/tasks", "", session.Token)
+		assertResponse(t, response, http.StatusUnauthorized, "unauthorized")
+	})
+
+	t.Run("logout everywhere", func(t *testing.T) {
+		response := server.request(t, http.MethodPost, "/v1/logout/all", "", true)
+
+		assertResponse(t, response, http.StatusNoContent, "")
+		response = server.request(t, http.MethodGet, "/v1/tasks", "", true)
+		assertResponse(t, response, http.StatusUnauthorized, "unauthorized")
+	})
+}
+
+func TestAPIKeys(t *testing.T) {
+	server := newTestServer(t)
+	user := *server.users.UsersByUsername["tester"]
+	keys := auth.NewAPIKeys()
+	server.UseAPIKeys(keys)
+	readKey, _, _ := keys.Create(user, "read", []string{auth.ScopeTasksRead}, time.Time{})
+	writeKey, _, _ := keys.Create(user, "write", auth.Scopes, time.Time{})
+	tests := []struct {
+		name            string
+		method          string
+		path            string
+		body            string
+		token           string
+		expected_status int
+		expected_code   string
+	}{
+		{name: "read key lists tasks", method: http.MethodGet, path: "/v1/tasks", token: readKey, expected_status: http.StatusOK},
+		{name: "read key cannot create", method: http.MethodPost, path: "/v1/tasks", body: `{"name": "a", "date": "10-10-2030"}`, token: readKey, expected_status: http.StatusForbidden, expected_code: "forbidden"},
+		{name: "write key creates", method: http.MethodPost, path: "/v1/tasks", body: `{"name": "a", "date": "10-10-2030"}`, token: writeKey, expected_status: http.StatusCreated},
+		{name: "unknown key", method: http.MethodGet, path: "/v1/tasks", token: auth.APIKeyPrefix + "nope", expected_status: http.StatusUnauthorized, expected_code: "unauthorized"},
+		{name: "keys cannot log out", method: http.MethodPost, path: "/v1/logout/all", token: writeKey, expected_status: http.StatusUnauthorized, expected_code: "unauthorized"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response := server.requestWithToken(t, test.method, test.path, test.body, test.token)
+
+			assertResponse(t, response, test.expected_status, test.expected_code)
+		})
+	}
+
+	t.Run("revoked key", func(t *testing.T) {
+		keys.Revoke(user.Id, "read")
+
+		response := server.requestWithToken(t, http.MethodGet, "/v1/tasks", "", readKey)
+		assertResponse(t, response, http.StatusUnauthorized, "unauthorized")
+	})
+}
+
+func TestEmailVerification(t *testing.T) {
+	server := newTestServer(t)
+	sent := &mailer.Memory{}
+	server.UseEmailVerification(auth.NewEmailVerifications(time.Hour), sent)
+	auth.RequireVerifiedEmail(auth.VerificationForLogin)
+	t.Cleanup(func() { auth.RequireVerifiedEmail(auth.VerificationOptional) })
+	login := `{"login": "new", "password": "Abc12345!"}`
+
+	response := server.request(t, http.MethodPost, "/v1/users", `{"email": "new@gmail.com", "username": "new", "password": "Abc12345!"}`, false)
+	assertResponse(t, response, http.StatusCreated, "")
+	if len(sent.Messages()) != 1 || sent.Messages()[0].To != "new@gmail.com" {
+		t.Fatalf("expected a verification mail, got %v", sent.Messages())
+	}
+	_, code, _ := strings.Cut(sent.Messages()[0].Body, "verify your email:\n\n")
+	code, _, _ = strings.Cut(code, "\n")
+
+	tests := []struct {
+		name            string
+		path            string
+		body            string
+		expected_status int
+		expected_code   string
+	}{
+		{name: "login before verifying", path: "/v1/login", body: login, expected_status: http.StatusForbidden, expected_code: "email_not_verified"},
+		{name: "resend", path: "/v1/verify/resend", body: `{"login": "new"}`, expected_status: http.StatusAccepted},
+		{name: "resend to unknown user", path: "/v1/verify/resend", body: `{"login": "nobody"}`, expected_status: http.StatusAccepted},
+		{name: "wrong code", path: "/v1/verify", body: `{"token": "nope"}`, expected_status: http.StatusUnprocessableEntity, expected_code: "invalid_token"},
+		{name: "verify", path: "/v1/verify", body: `{"token": "` + code + `"}`, expected_status: http.StatusOK},
+		{name: "login after verifying", path: "/v1/login", body: login, expected_status: http.StatusOK},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response := server.request(t, http.MethodPost, test.path, test.body, false)
+
+			assertResponse(t, response, test.expected_status, test.expected_code)
+		})
+	}
+
+	t.Run("unverified users cannot add tasks", func(t *testing.T) {
+		auth.RequireVerifiedEmail(auth.VerificationForTasks)
+
+		response := server.request(t, http.MethodPost, "/v1/tasks", `{"name": "a", "date": "10-10-2030"}`, true)
+
+		assertResponse(t, response, http.StatusForbidden, "email_not_verified")
+	})
+}
+
+func TestDisabledAccount(t *testing.T) {
+	server := newTestServer(t)
+	server.users.UsersByUsername["tester"].Disabled = true
+
+	t.Run("login", func(t *testing.T) {
+		response := server.request(t, http.MethodPost, "/v1/login", `{"login": "tester", "password": "Abc12345!"}`, false)
+
+		assertResponse(t, response, http.StatusForbidden, "account_restricted")
+	})
+
+	t.Run("existing session", func(t *testing.T) {
+		response := server.request(t, http.MethodGet, "/v1/tasks", "", true)
+
+		assertResponse(t, response, http.StatusUnauthorized, "unauthorized")
+	})
+}
+
+func TestAdminUsers(t *testing.T) {
+	server := newTestServer(t)
+	server.users.UsersByUsername["tester"].Role = auth.RoleAdmin
+	other, err := server.users.RegisterUser("other@gmail.com", "other", "Abc12345!")
+	if err != nil {
+		t.Fatalf("could not register other user: %q", err)
+	}
+	otherToken, _, _ := server.sessions.Create(other)
+
+	t.Run("not an admin", func(t *testing.T) {
+		response := server.requestWithToken(t, http.MethodPost, "/v1/admin/users/tester/disable", "", otherToken)
+
+		assertResponse(t, response, http.StatusForbidden, "forbidden")
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		response := server.request(t, http.MethodPost, "/v1/admin/users/nobody/disable", "", true)
+
+		assertResponse(t, response, http.StatusNotFound, "not_found")
+	})
+
+	t.Run("invalid role", func(t *testing.T) {
+		response := server.request(t, http.MethodPut, "/v1/admin/users/other/role", `{"role": "owner"}`, true)
+
+		assertResponse(t, response, http.StatusUnprocessableEntity, "invalid_role")
+	})
+
+	t.Run("disable", func(t *testing.T) {
+		saves := server.saves
+		response := server.request(t, http.MethodPost, "/v1/admin/users/other/disable", "", true)
+
+		assertResponse(t, response, http.StatusOK, "")
+		var user adminUserJSON
+		json.NewDecoder(response.Body).Decode(&user)
+		if !user.Disabled || user.Username != "other" {
+			t.Errorf("got %+v, expected other to be disabled", user)
+		}
+		if server.saves != saves+1 {
+			t.Errorf("got %d saves, expected %d", server.saves, saves+1)
+		}
+		response = server.requestWithToken(t, http.MethodGet, "/v1/tasks", "", otherToken)
+		assertResponse(t, response, http.StatusUnauthorized, "unauthorized")
+	})
+
+	t.Run("enable", func(t *testing.T) {
+		response := server.request(t, http.MethodPost, "/v1/admin/users/other/enable", "", true)
+
+		assertResponse(t, response, http.StatusOK, "")
+		response = server.request(t, http.MethodPost, "/v1/login", `{"login": "other", "password": "Abc12345!"}`, false)
+		assertResponse(t, response, http.StatusOK, "")
+	})
+
+	t.Run("unlock", func(t *testing.T) {
+		server.throttle.Account = auth.ThrottlePolicy{MaxFailures: 1, Lockout: time.Hour}
+		server.throttle.Source = auth.ThrottlePolicy{MaxFailures: 1, Lockout: time.Hour}
+		server.request(t, http.MethodPost, "/v1/login", `{"login": "other", "password": "wrong"}`, false)
+		response := server.request(t, http.MethodPost, "/v1/login", `{"login": "other", "password": "Abc12345!"}`, false)
+		assertResponse(t, response, http.StatusTooManyRequests, "too_many_attempts")
+
+		response = server.request(t, http.MethodPost, "/v1/admin/users/other/unlock", "", true)
+		assertResponse(t, response, http.StatusOK, "")
+		response = server.request(t, http.MethodPost, "/v1/admin/sources/192.0.2.1/unlock", "", true)
+		assertResponse(t, response, http.StatusNoContent, "")
+
+		response = server.request(t, http.MethodPost, "/v1/login", `{"login": "other", "password": "Abc12345!"}`, false)
+		assertResponse(t, response, http.StatusOK, "")
+	})
+
+	t.Run("role", func(t *testing.T) {
+		response := server.request(t, http.MethodPut, "/v1/admin/users/other/role", `{"role": "admin"}`, true)
+
+		assertResponse(t, response, http.StatusOK, "")
+		var user adminUserJSON
+		json.NewDecoder(response.Body).Decode(&user)
+		if user.Role != "admin" {
+			t.Errorf("got role %q, expected admin", user.Role)
+		}
+	})
+}
+
+func TestOIDCLogin(t *testing.T) {
+	server := newTestServer(t)
+	provider := newTestProvider(t, server)
+
+	t.Run("not configured", func(t *testing.T) {
+		response := newTestServer(t).request(t, http.MethodGet, "/v1/oidc/login", "", false)
+
+		assertResponse(t, response, http.StatusNotFound, "not_found")
+	})
+
+	t.Run("no account with the email", func(t *testing.T) {
+		provider.SetUser(oidctest.User{Subject: "1234", Email: "new@gmail.com", EmailVerified: true})
+
+		response := server.oidcLogin(t, provider, true)
+
+		assertResponse(t, response, http.StatusForbidden, "no_account")
+	})
+
+	t.Run("unverified email", func(t *testing.T) {
+		provider.SetUser(oidctest.User{Subject: "1234", Email: "tester@gmail.com"})
+
+		response := server.oidcLogin(t, provider, true)
+
+		assertResponse(t, response, http.StatusForbidden, "email_not_verified")
+	})
+
+	t.Run("callback from another browser", func(t *testing.T) {
+		provider.SetUser(oidctest.User{Subject: "1234", Email: "tester@gmail.com", EmailVerified: true})
+
+		response := server.oidcLogin(t, provider, false)
+
+		assertResponse(t, response, http.StatusUnauthorized, "sso_failed")
+	})
+
+	t.Run("account not verified", func(t *testing.T) {
+		response := server.oidcLogin(t, provider, true)
+
+		assertResponse(t, response, http.StatusForbidden, "email_not_verified")
+		if len(server.users.UsersByUsername["tester"].Identities) != 0 {
+			t.Errorf("expected the identity not to be linked")
+		}
+	})
+
+	t.Run("linked by email", func(t *testing.T) {
+		server.users.UsersByUsername["tester"].Verified = true
+		saves := server.saves
+
+		response := server.oidcLogin(t, provider, true)
+
+		assertResponse(t, response, http.StatusOK, "")
+		var body sessionJSON
+		json.NewDecoder(response.Body).Decode(&body)
+		if body.User.Username != "tester" || !body.User.Verified || server.saves != saves+1 {
+			t.Errorf("expected tester to be logged in and saved, got %v", body)
+		}
+		response = server.requestWithToken(t, http.MethodGet, "/v1/tasks", "", body.Token)
+		assertResponse(t, response, http.StatusOK, "")
+	})
+
+	t.Run("provisioned", func(t *testing.T) {
+		server.UseOIDC(server.sso, true)
+		provider.SetUser(oidctest.User{Subject: "5678", Email: "new@gmail.com", EmailVerified: true, PreferredUsername: "newcomer"})
+
+		response := server.oidcLogin(t, provider, true)
+
+		assertResponse(t, response, http.StatusOK, "")
+		if server.users.UsersByUsername["newcomer"] == nil {
+			t.Errorf("expected newcomer to be registered")
+		}
+	})
+
+	t.Run("other requests go on during the exchange", func(t *testing.T) {
+		client := server.sso.Client
+		t.Cleanup(func() { server.sso.Client = client })
+		served := make(chan int, 1)
+		server.sso.Client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			if r.URL.String() == server.sso.Provider.TokenEndpoint {
+				go func() {
+					served <- server.request(t, http.MethodGet, "/v1/tasks", "", true).Code
+				}()
+				select {
+				case <-served:
+				case <-time.After(5 * time.Second):
+					t.Errorf("a request waited for the identity provider")
+				}
+			}
+			return http.DefaultTransport.RoundTrip(r)
+		})}
+
+		response := server.oidcLogin(t, provider, true)
+
+		assertResponse(t, response, http.StatusOK, "")
+	})
+}
+
+func TestTasks(t *testing.T) {
+	server := newTestServer(t)
+
+	t.Run("requires credentials", func(t *testing.T) {
+		response := server.request(t, http.MethodGet, "/v1/tasks", "", false)
+
+		assertResponse(t, response, http.StatusUnauthorized, "unauthorized")
+	})
+
+	t.Run("create", func(t *testing.T) {
+		response := server.request(t, http.MethodPost, "/v1/tasks", `{"name": "Write API", "description": "net/http", "date": "31-03-2024"}`, true)
+
+		assertResponse(t, response, http.StatusCreated, "")
+		assertTaskJSON(t, response, taskJSON{Id: 1, Name: "Write API", Description: "net/http", Date: "31-03-2024", Status: "pending", Tags: []string{}})
+		if response.Header().Get("Location") != "/v1/tasks/1" {
+			t.Errorf("got location %q", response.Header().Get("Location"))
+		}
+	})
+
+	t.Run("create with invalid date", func(t *testing.T) {
+		response := server.request(t, http.MethodPost, "/v1/tasks", `{"name": "a", "date": "tomorrow"}`, true)
+
+		assertResponse(t, response, http.StatusUnprocessableEntity, "invalid_task")
+	})
+
+	t.Run("list", func(t *testing.T) {
+		response := server.request(t, http.MethodGet, "/v1/tasks", "", true)
+
+		assertResponse(t, response, http.StatusOK, "")
+		var list []taskJSON
+		json.NewDecoder(response.Body).Decode(&list)
+		if len(list) != 1 || list[0].Name != "Write API" {
+			t.Errorf("got %v, expected the created task", list)
+		}
+	})
+
+	t.Run("update", func(t *testing.T) {
+		response := server.request(t, http.MethodPatch, "/v1/tasks/1", `{"name": "Write tests", "priority": "high", "tags": ["API"]}`, true)
+
+		assertResponse(t, response, http.StatusOK, "")
+		assertTaskJSON(t, response, taskJSON{Id: 1, Name: "Write tests", Description: "net/http", Date: "31-03-2024", Status: "pending", Priority: "high", Tags: []string{"api"}})
+	})
+
+	t.Run("invalid update changes nothing", func(t *testing.T) {
+		response := server.request(t, http.MethodPatch, "/v1/tasks/1", `{"name": "Changed", "date": "99-99-9999"}`, true)
+
+		assertResponse(t, response, http.StatusUnprocessableEntity, "invalid_task")
+		response = server.request(t, http.MethodGet, "/v1/tasks/1", "", true)
+		assertTaskJSON(t, response, taskJSON{Id: 1, Name: "Write tests", Description: "net/http", Date: "31-03-2024", Status: "pending", Priority: "high", Tags: []string{"api"}})
+	})
+
+	t.Run("complete", func(t *testing.T) {
+		response := server.request(t, http.MethodPost, "/v1/tasks/1/complete", "", true)
+
+		assertResponse(t, response, http.StatusOK, "")
+		assertTaskJSON(t, response, taskJSON{Id: 1, Name: "Write tests", Description: "net/http", Date: "31-03-2024", Status: "complete", Priority: "high", Tags: []string{"api"}})
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		response := server.request(t, http.MethodDelete, "/v1/tasks/1", "", true)
+
+		assertResponse(t, response, http.StatusNoContent, "")
+		response = server.request(t, http.MethodGet, "/v1/tasks/1", "", true)
+		assertResponse(t, response, http.StatusNotFound, "task_not_found")
+	})
+
+	t.Run("invalid id", func(t *testing.T) {
+		response := server.request(t, http.MethodGet, "/v1/tasks/one", "", true)
+
+		assertResponse(t, response, http.StatusBadRequest, "bad_request")
+	})
+
+	t.Run("unknown route", func(t *testing.T) {
+		response := server.request(t, http.MethodGet, "/v2/tasks", "", true)
+
+		assertResponse(t, response, http.StatusNotFound, "not_found")
+	})
+
+	if server.saves != 4 {
+		t.Errorf("expected every change to be saved, got %d saves", server.saves)
+	}
+}
+
+func TestCreateAfterDelete(t *testing.T) {
+	server := newTestServer(t)
+	server.request(t, http.MethodPost, "/v1/tasks", `{"name": "first", "date": "01-01-2030"}`, true)
+	server.request(t, http.MethodPost, "/v1/tasks", `{"name": "second", "date": "01-01-2030"}`, true)
+	server.request(t, http.MethodDelete, "/v1/tasks/1", "", true)
+
+	response := server.request(t, http.MethodPost, "/v1/tasks", `{"name": "third", "date": "01-01-2030"}`, true)
+
+	assertResponse(t, response, http.StatusCreated, "")
+	assertTaskJSON(t, response, taskJSON{Id: 3, Name: "third", Date: "01-01-2030", Status: "pending", Tags: []string{}})
+	response = server.request(t, http.MethodGet, "/v1/tasks/2", "", true)
+	assertTaskJSON(t, response, taskJSON{Id: 2, Name: "second", Date: "01-01-2030", Status: "pending", Tags: []string{}})
+}
+
+func TestDeleteTaskReleasesAttachments(t *testing.T) {
+	blobStore, _ := blobs.NewStore(t.TempDir(), blobs.DefaultMaxSize)
+	tasks.UseBlobStore(blobStore)
+	t.Cleanup(func() { tasks.UseBlobStore(nil) })
+	server := newTestServer(t)
+	server.request(t, http.MethodPost, "/v1/tasks", `{"name": "first", "date": "01-01-2030"}`, true)
+	user := server.users.UsersByUsername["tester"]
+	attachment, _ := server.tasks[user.Id].Attach(1, "notes.txt", strings.NewReader("notes"))
+
+	response := server.request(t, http.MethodDelete, "/v1/tasks/1", "", true)
+
+	assertResponse(t, response, http.StatusNoContent, "")
+	if blobStore.Refs(attachment.Hash) != 0 {
+		t.Errorf("expected the attachment to be released, got %d references", blobStore.Refs(attachment.Hash))
+	}
+}
+
+//helpers
+
+type testServer struct {
+	*Server
+	token string
+	saves int
+}
+
+func newTestServer(t testing.TB) *testServer {
+	t.Helper()
+	users := auth.UserDatabase{
+		UsersByEmail:    map[string]*auth.User{},
+		UsersByUsername: map[string]*auth.User{},
+	}
+	user, err := users.RegisterUser("tester@gmail.com", "tester", "Abc12345!")
+	if err != nil {
+		t.Fatalf("could not register test user: %q", err)
+	}
+	sessions := auth.NewSessions(auth.DefaultSessionIdleTimeout, auth.DefaultSessionMaxLifetime)
+	token, _, _ := sessions.Create(user)
+	server := &testServer{token: token}
+	server.Server = NewServer(users, map[uuid.UUID]tasks.TaskList{}, sessions, func() error {
+		server.saves++
+		return nil
+	})
+	return server
+}
+
+func (server *testServer) request(t testing.TB, method, path, body string, authenticated bool) *httptest.ResponseRecorder {
+	t.Helper()
+	token := ""
+	if authenticated {
+		token = server.token
+	}
+	return server.requestWithToken(t, method, path, body, token)
+}
+
+func (server *testServer) requestWithToken(t testing.TB, method, path, body, token string) *httptest.ResponseRecorder {
+	t.Helper()
+	request := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+	return response
+}
+
+// newTestProvider starts an identity provider server logs in through.
+func newTestProvider(t testing.TB, server *testServer) *oidctest.Provider {
+	t.Helper()
+	provider, err := oidctest.NewProvider("todo-app", "secret")
+	if err != nil {
+		t.Fatalf("could not start the test provider: %q", err)
+	}
+	t.Cleanup(provider.Close)
+	discovered, err := oidc.Discover(http.DefaultClient, provider.Issuer())
+	if err != nil {
+		t.Fatalf("could not discover the test provider: %q", err)
+	}
+	server.UseOIDC(oidc.NewRelyingParty(discovered, oidc.Config{ClientID: "todo-app", ClientSecret: "secret", RedirectURL: "http://todo.example/v1/oidc/callback"}), false)
+	return provider
+}
+
+// oidcLogin logs in through provider the way a browser would, keeping the
+// state cookie or not.
+func (server *testServer) oidcLogin(t testing.TB, provider *oidctest.Provider, keepCookie bool) *httptest.ResponseRecorder {
+	t.Helper()
+	started := server.request(t, http.MethodGet, "/v1/oidc/login", "", false)
+	assertResponse(t, started, http.StatusFound, "")
+	back, err := provider.Approve(started.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("could not log in at the test provider: %q", err)
+	}
+	request := httptest.NewRequest(http.MethodGet, back.RequestURI(), nil)
+	if keepCookie {
+		for _, cookie := range started.Result().Cookies() {
+			request.AddCookie(cookie)
+		}
+	}
+	response := httptest.NewRecorder()
+	server.ServeHTTP(response, request)
+	return response
+}
+
+type roundTripFunc func(r *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func assertResponse(t testing.TB, response *httptest.ResponseRecorder, expected_status int, expected_code string) {
+	t.Helper()
+	if response.Code != expected_status {
+		t.Fatalf("got status %d, expected %d, body: %s", response.Code, expected_status, response.Body.String())
+	}
+	if expected_code == "" {
+		return
+	}
+	var body errorJSON
+	err := json.NewDecoder(strings.NewReader(response.Body.String())).Decode(&body)
+	if err != nil {
+		t.Fatalf("expected an error body, got %s", response.Body.String())
+	}
+	if body.Error.Code != expected_code || body.Error.Status != expected_status || body.Error.Message == "" {
+		t.Errorf("got error %v, expected code %q", body.Error, expected_code)
+	}
+}
+
+func assertTaskJSON(t testing.TB, response *httptest.ResponseRecorder, expected taskJSON) {
+	t.Helper()
+	var task taskJSON
+	json.NewDecoder(response.Body).Decode(&task)
+	if !reflect.DeepEqual(task, expected) {
+		t.Errorf("got %v, expected %v", task, expected)
+	}
+}
@@ -0,0 +1,218 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultMaxSize  = 10 << 20
+	DefaultMaxFiles = 5
+	InvalidSizeErr  = AuditError("Event log needs a maximum size and number of files of at least 1")
+)
+
+type AuditError string
+
+func (err AuditError) Error() string {
+	return string(err)
+}
+
+type EventType string
+
+const (
+	Register       EventType = "register"
+	LoginSuccess   EventType = "login_success"
+	LoginFailure   EventType = "login_failure"
+	PasswordChange EventType = "password_change"
+	PasswordReset  EventType = "password_reset"
+	Lockout        EventType = "lockout"
+)
+
+// Event is something that happened to the security of an account. UserId is
+// the nil UUID when the login did not match any user, Login is what was
+// typed to log in then.
+type Event struct {
+	Time     time.Time `json:"time"`
+	Type     EventType `json:"type"`
+	UserId   uuid.UUID `json:"user_id"`
+	Username string    `json:"username,omitempty"`
+	Login    string    `json:"login,omitempty"`
+	Source   string    `json:"source,omitempty"`
+	Reason   string    `json:"reason,omitempty"`
+}
+
+// Sink keeps security events, e.g. to look into them after an attack.
+type Sink interface {
+	Record(event Event) error
+}
+
+// Filter picks events of a user between two times. The nil UUID and zero
+// times match everything.
+type Filter struct {
+	UserId uuid.UUID
+	Since  time.Time
+	Until  time.Time
+}
+
+func (filter Filter) Match(event Event) bool {
+	if filter.UserId != uuid.Nil && event.UserId != filter.UserId {
+		return false
+	}
+	if !filter.Since.IsZero() && event.Time.Before(filter.Since) {
+		return false
+	}
+	if !filter.Until.IsZero() && !event.Time.Before(filter.Until) {
+		return false
+	}
+	return true
+}
+
+// Querier finds the events kept by a Sink.
+type Querier interface {
+	Query(filter Filter) ([]Event, error)
+}
+
+// File appends events to Path as JSON lines. Once Path would grow over
+// MaxSize bytes it is renamed to Path.1, the older files move one number up
+// and the one past MaxFiles is deleted.
+type File struct {
+	Path     string
+	MaxSize  int64
+	MaxFiles int
+	mu       sync.Mutex
+}
+
+func NewFile(path string, maxSize int64, maxFiles int) (*File, error) {
+	if maxSize < 1 || maxFiles < 1 {
+		return nil, InvalidSizeErr
+	}
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return nil, err
+	}
+	return &File{Path: path, MaxSize: maxSize, MaxFiles: maxFiles}, nil
+}
+
+func (log *File) Record(event Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	log.mu.Lock()
+	defer log.mu.Unlock()
+	// another process can write to the same file, so its size is checked
+	// every time
+	info, err := os.Stat(log.Path)
+	if err == nil && info.Size() > 0 && info.Size()+int64(len(line)) > log.MaxSize {
+		err = log.rotate()
+		if err != nil {
+			return err
+		}
+	}
+	file, err := os.OpenFile(log.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(line)
+	if err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func (log *File) rotated(n int) string {
+	return fmt.Sprintf("%s.%d", log.Path, n)
+}
+
+func (log *File) rotate() error {
+	err := os.Remove(log.rotated(log.MaxFiles))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	for n := log.MaxFiles - 1; n >= 1; n-- {
+		err = os.Rename(log.rotated(n), log.rotated(n+1))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return os.Rename(log.Path, log.rotated(1))
+}
+
+// Query reads the current and rotated files, oldest event first. Lines that
+// cannot be read, e.g. cut short by a crash, are skipped.
+func (log *File) Query(filter Filter) ([]Event, error) {
+	log.mu.Lock()
+	defer log.mu.Unlock()
+	var events []Event
+	for n := log.MaxFiles; n >= 0; n-- {
+		path := log.Path
+		if n > 0 {
+			path = log.rotated(n)
+		}
+		file, err := os.Open(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			var event Event
+			if json.Unmarshal(scanner.Bytes(), &event) == nil && filter.Match(event) {
+				events = append(events, event)
+			}
+		}
+		file.Close()
+		if scanner.Err() != nil {
+			return nil, scanner.Err()
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Time.Before(events[j].Time)
+	})
+	return events, nil
+}
+
+// Memory keeps the events it is given, for tests.
+type Memory struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (log *Memory) Record(event Event) error {
+	log.mu.Lock()
+	defer log.mu.Unlock()
+	log.events = append(log.events, event)
+	return nil
+}
+
+func (log *Memory) Query(filter Filter) ([]Event, error) {
+	log.mu.Lock()
+	defer log.mu.Unlock()
+	var events []Event
+	for _, event := range log.events {
+		if filter.Match(event) {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+func (log *Memory) Events() []Event {
+	log.mu.Lock()
+	defer log.mu.Unlock()
+	return append([]Event(nil), log.events...)
+}
@@ -0,0 +1,101 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestFilter(t *testing.T) {
+	userId := uuid.New()
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	event := Event{Time: start.Add(time.Hour), Type: LoginSuccess, UserId: userId}
+	tests := []struct {
+		name     string
+		filter   Filter
+		expected bool
+	}{
+		{name: "empty filter", filter: Filter{}, expected: true},
+		{name: "same user", filter: Filter{UserId: userId}, expected: true},
+		{name: "another user", filter: Filter{UserId: uuid.New()}, expected: false},
+		{name: "inside the range", filter: Filter{Since: start, Until: start.Add(2 * time.Hour)}, expected: true},
+		{name: "before since", filter: Filter{Since: start.Add(2 * time.Hour)}, expected: false},
+		{name: "until is left out", filter: Filter{Until: start.Add(time.Hour)}, expected: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := test.filter.Match(event)
+
+			if got != test.expected {
+				t.Errorf("got %v, expected %v", got, test.expected)
+			}
+		})
+	}
+}
+
+func TestNewFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "events.jsonl")
+
+	_, err := NewFile(path, 0, DefaultMaxFiles)
+	if err != InvalidSizeErr {
+		t.Errorf("got %q, expected %q", err, InvalidSizeErr)
+	}
+	_, err = NewFile(path, DefaultMaxSize, DefaultMaxFiles)
+	if err != nil {
+		t.Fatalf("expected no error, got %q", err)
+	}
+	if _, err := os.Stat(filepath.Dir(path)); err != nil {
+		t.Errorf("expected the folder of the log to be created, got %q", err)
+	}
+}
+
+func TestFileRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	// every event is over 100 bytes, so each file holds one
+	log, err := NewFile(path, 150, 2)
+	if err != nil {
+		t.Fatalf("expected no error, got %q", err)
+	}
+	for i := 0; i < 4; i++ {
+		err := log.Record(Event{Time: start.Add(time.Duration(i) * time.Minute), Type: LoginFailure, Login: "tester", Reason: "wrong_password"})
+		if err != nil {
+			t.Fatalf("expected no error recording, got %q", err)
+		}
+	}
+
+	for _, name := range []string{"events.jsonl", "events.jsonl.1", "events.jsonl.2"} {
+		if _, err := os.Stat(filepath.Join(filepath.Dir(path), name)); err != nil {
+			t.Errorf("expected %s to exist, got %q", name, err)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); err == nil {
+		t.Error("expected files past the maximum to be deleted")
+	}
+	events, err := log.Query(Filter{})
+	if err != nil {
+		t.Fatalf("expected no error querying, got %q", err)
+	}
+	if len(events) != 3 || !events[0].Time.Equal(start.Add(time.Minute)) || !events[2].Time.Equal(start.Add(3*time.Minute)) {
+		t.Errorf("expected the last three events oldest first, got %v", events)
+	}
+}
+
+func TestQuerySkipsBrokenLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	os.WriteFile(path, []byte(`{"time":"2024-03-01T00:00:00Z","type":"register"}`+"\n"+`{"time":"2024-03`), 0600)
+	log, _ := NewFile(path, DefaultMaxSize, DefaultMaxFiles)
+
+	events, err := log.Query(Filter{})
+
+	if err != nil {
+		t.Fatalf("expected no error, got %q", err)
+	}
+	if len(events) != 1 || events[0].Type != Register {
+		t.Errorf("expected only the complete event, got %v", events)
+	}
+}
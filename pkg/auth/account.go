@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"time"
+	"todo_app/pkg/audit"
+	"todo_app/pkg/tasks"
+
+	"github.com/google/uuid"
+)
+
+const samePasswordErr = PasswordErr("New password must be different from the current one")
+
+// checkPassword finds the user with the given email or username and makes
+// sure password is theirs, for changes that could take over the account.
+func (users UserDatabase) checkPassword(id, password string) (*User, error) {
+	user, err := users.getUser(id)
+	if err != nil {
+		return nil, userNotFoundErr
+	}
+	if !comparePassword(user.Password, password) {
+		return nil, wrongPasswordErr
+	}
+	return user, nil
+}
+
+// ChangePassword replaces the password of a user who knows the current one.
+// The new password follows the rules of registration and, when sessions is
+// not nil, every session of the user is ended.
+func ChangePassword(users UserDatabase, sessions *Sessions, id, current, password string) (User, error) {
+	user, err := users.checkPassword(id, current)
+	if err != nil {
+		return User{}, err
+	}
+	if current == password {
+		return User{}, samePasswordErr
+	}
+	err = validatePassword(password, user.Username, user.Email)
+	if err != nil {
+		return User{}, err
+	}
+	hashed, err := hashPassword(password)
+	if err != nil {
+		return User{}, err
+	}
+	user.Password = hashed
+	if sessions != nil {
+		sessions.RevokeAll(user.Id)
+	}
+	recordUserEvent(audit.PasswordChange, user, "", "", "", time.Time{})
+	return *user, nil
+}
+
+// ChangeEmail moves a user to a new email after checking their password, as
+// password resets are mailed there. The new email is not verified yet.
+func ChangeEmail(users UserDatabase, id, password, email string) (User, error) {
+	user, err := users.checkPassword(id, password)
+	if err != nil {
+		return User{}, err
+	}
+	email = displayForm(email)
+	if email == user.Email {
+		return *user, nil
+	}
+	err = validateEmail(email)
+	if err != nil {
+		return User{}, err
+	}
+	owner, err := users.getUser(email)
+	if err == nil && owner != user {
+		return User{}, emailRegisteredErr
+	}
+	users.remove(user)
+	// only the way it is written changed, it is still the address that was
+	// verified
+	if CanonicalEmail(email) != CanonicalEmail(user.Email) {
+		user.Verified = false
+	}
+	user.Email = email
+	users.add(user)
+	return *user, nil
+}
+
+// ChangeUsername renames the user with the given email or username.
+func ChangeUsername(users UserDatabase, id, username string) (User, error) {
+	user, err := users.getUser(id)
+	if err != nil {
+		return User{}, userNotFoundErr
+	}
+	username = displayForm(username)
+	if username == user.Username {
+		return *user, nil
+	}
+	err = validateUsername(username)
+	if err != nil {
+		return User{}, err
+	}
+	owner, err := users.getUser(username)
+	if err == nil && owner != user {
+		return User{}, usernameRegisteredErr
+	}
+	users.remove(user)
+	user.Username = username
+	users.add(user)
+	return *user, nil
+}
+
+// DeleteAccount removes a user who confirmed their password, along with
+// their tasks in userTasks and the attachments only those tasks used.
+// Sessions and keys of the user stop working as their owner is gone; whatever
+// else the caller keeps per user, e.g. templates and boards, is theirs to
+// delete.
+func DeleteAccount(users UserDatabase, userTasks map[uuid.UUID]tasks.TaskList, id, password string) (User, error) {
+	user, err := users.checkPassword(id, password)
+	if err != nil {
+		return User{}, err
+	}
+	users.remove(user)
+	taskList := userTasks[user.Id]
+	delete(userTasks, user.Id)
+	// the account is gone either way, the first attachment that could not be
+	// released is reported after trying the rest
+	var releaseErr error
+	for taskId := range taskList {
+		err := taskList.DeleteTask(taskId)
+		if err != nil && releaseErr == nil {
+			releaseErr = err
+		}
+	}
+	return *user, releaseErr
+}
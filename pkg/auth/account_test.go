@@ -0,0 +1,159 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"todo_app/pkg/blobs"
+	"todo_app/pkg/tasks"
+
+	"github.com/google/uuid"
+)
+
+func TestChangePassword(t *testing.T) {
+	users := accountTestUsers(t)
+	sessions := NewSessions(DefaultSessionIdleTimeout, DefaultSessionMaxLifetime)
+	token, _, _ := sessions.Create(*users.UsersByUsername["tester"])
+	tests := []struct {
+		name           string
+		id             string
+		current        string
+		password       string
+		expected_error error
+	}{
+		{name: "unknown user", id: "nobody", current: "Abc12345!", password: "Xyz12345!", expected_error: userNotFoundErr},
+		{name: "wrong current password", id: "tester", current: "Wrong123!", password: "Xyz12345!", expected_error: wrongPasswordErr},
+		{name: "same password", id: "tester", current: "Abc12345!", password: "Abc12345!", expected_error: samePasswordErr},
+		{name: "weak password", id: "tester", current: "Abc12345!", password: "short", expected_error: PasswordRulesErr{passMinLengthErr, passNoUpperErr, passNoDigitErr, passNoSymbolErr}},
+		{name: "valid change", id: "mail@gmail.com", current: "Abc12345!", password: "Xyz12345!", expected_error: nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ChangePassword(users, sessions, test.id, test.current, test.password)
+
+			assertError(t, err, test.expected_error)
+		})
+	}
+	_, err := LogIn(users, NewLoginChallenges(), "tester", "Xyz12345!")
+	assertError(t, err, nil)
+	_, err = sessions.Lookup(users, token)
+	assertError(t, err, invalidSessionErr)
+}
+
+func TestChangeEmail(t *testing.T) {
+	users := accountTestUsers(t)
+	users.UsersByUsername["tester"].Verified = true
+	tests := []struct {
+		name           string
+		password       string
+		email          string
+		expected_error error
+	}{
+		{name: "wrong password", password: "Wrong123!", email: "new@gmail.com", expected_error: wrongPasswordErr},
+		{name: "invalid email", password: "Abc12345!", email: "not an email", expected_error: invalidEmailErr},
+		{name: "taken email", password: "Abc12345!", email: "other@gmail.com", expected_error: emailRegisteredErr},
+		{name: "taken in another case", password: "Abc12345!", email: "Other@Gmail.com", expected_error: emailRegisteredErr},
+		{name: "only the case changes", password: "Abc12345!", email: "Mail@gmail.com", expected_error: nil},
+		{name: "valid change", password: "Abc12345!", email: "new@gmail.com", expected_error: nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ChangeEmail(users, "tester", test.password, test.email)
+
+			assertError(t, err, test.expected_error)
+		})
+	}
+	user, found := users.UsersByEmail["new@gmail.com"]
+	if !found || user != users.UsersByUsername["tester"] || user.Verified {
+		t.Errorf("expected the user under the new unverified email, got %v", user)
+	}
+	if _, found := users.UsersByEmail["mail@gmail.com"]; found {
+		t.Error("expected the old email to be free")
+	}
+}
+
+func TestChangeUsername(t *testing.T) {
+	users := accountTestUsers(t)
+	tests := []struct {
+		name           string
+		id             string
+		username       string
+		expected_error error
+	}{
+		{name: "unknown user", id: "nobody", username: "renamed", expected_error: userNotFoundErr},
+		{name: "invalid username", id: "tester", username: "no spaces", expected_error: userInvalidCharErr},
+		{name: "taken username", id: "tester", username: "other", expected_error: usernameRegisteredErr},
+		{name: "same username", id: "tester", username: "tester", expected_error: nil},
+		{name: "only the case changes", id: "tester", username: "Tester", expected_error: nil},
+		{name: "taken in another case", id: "tester", username: "OTHER", expected_error: usernameRegisteredErr},
+		{name: "valid change", id: "mail@gmail.com", username: "renamed", expected_error: nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ChangeUsername(users, test.id, test.username)
+
+			assertError(t, err, test.expected_error)
+		})
+	}
+	if _, found := users.UsersByUsername["tester"]; found {
+		t.Error("expected the old username to be free")
+	}
+	if users.UsersByEmail["mail@gmail.com"].Username != "renamed" {
+		t.Errorf("expected the user to be renamed, got %v", users.UsersByEmail["mail@gmail.com"])
+	}
+}
+
+func TestDeleteAccount(t *testing.T) {
+	users := accountTestUsers(t)
+	tester := *users.UsersByUsername["tester"]
+	other := *users.UsersByUsername["other"]
+	userTasks := map[uuid.UUID]tasks.TaskList{tester.Id: {}, other.Id: {}}
+	userTasks[tester.Id].AddTask("test", "test", "09-09-2029")
+	userTasks[other.Id].AddTask("test", "test", "09-09-2029")
+	blobStore, err := blobs.NewStore(t.TempDir(), blobs.DefaultMaxSize)
+	if err != nil {
+		t.Fatalf("could not create the blob store: %q", err)
+	}
+	tasks.UseBlobStore(blobStore)
+	t.Cleanup(func() { tasks.UseBlobStore(nil) })
+	attachment, err := userTasks[tester.Id].Attach(1, "notes.txt", strings.NewReader("only tester has this"))
+	if err != nil {
+		t.Fatalf("could not attach the test file: %q", err)
+	}
+
+	_, err = DeleteAccount(users, userTasks, "tester", "Wrong123!")
+	assertError(t, err, wrongPasswordErr)
+	deleted, err := DeleteAccount(users, userTasks, "tester", "Abc12345!")
+	assertError(t, err, nil)
+
+	if deleted.Id != tester.Id {
+		t.Errorf("got %v, expected the deleted user", deleted)
+	}
+	if _, err := users.getUser("tester"); err == nil {
+		t.Error("expected the username to be gone")
+	}
+	if _, err := users.getUser("mail@gmail.com"); err == nil {
+		t.Error("expected the email to be gone")
+	}
+	if _, found := userTasks[tester.Id]; found || len(userTasks[other.Id]) != 1 {
+		t.Errorf("expected only the tasks of the user to be deleted, got %v", userTasks)
+	}
+	err = blobStore.Release(attachment.Hash)
+	assertError(t, err, blobs.BlobNotFoundErr)
+}
+
+//helpers
+
+func accountTestUsers(t testing.TB) UserDatabase {
+	t.Helper()
+	users := UserDatabase{UsersByEmail: map[string]*User{}, UsersByUsername: map[string]*User{}}
+	for _, user := range [][2]string{{"mail@gmail.com", "tester"}, {"other@gmail.com", "other"}} {
+		_, err := users.RegisterUser(user[0], user[1], "Abc12345!")
+		if err != nil {
+			t.Fatalf("could not register test user: %q", err)
+		}
+	}
+	return users
+}
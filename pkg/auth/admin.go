@@ -0,0 +1,238 @@
+package auth
+
+import (
+	"fmt"
+	"sort"
+	"time"
+	"todo_app/pkg/audit"
+	"todo_app/pkg/mailer"
+	"todo_app/pkg/tasks"
+
+	"github.com/google/uuid"
+)
+
+// UserSummary is what administrators see of an account, never its password
+// hash, second factor secret or recovery codes.
+type UserSummary struct {
+	Id                    uuid.UUID
+	Email                 string
+	Username              string
+	Role                  Role
+	Verified              bool
+	Disabled              bool
+	TwoFactor             bool
+	PasswordResetRequired bool
+}
+
+// TaskCounts tells how many tasks a user has, not what they are.
+type TaskCounts struct {
+	Total     int
+	Pending   int
+	Completed int
+}
+
+func summarize(user *User) UserSummary {
+	return UserSummary{
+		Id:                    user.Id,
+		Email:                 user.Email,
+		Username:              user.Username,
+		Role:                  user.Role,
+		Verified:              user.Verified,
+		Disabled:              user.Disabled,
+		TwoFactor:             user.HasTOTP(),
+		PasswordResetRequired: user.PasswordResetRequired,
+	}
+}
+
+// authorizeAdmin checks permission against the stored account of admin, so a
+// role taken away or an account disabled since admin was loaded counts.
+func authorizeAdmin(users UserDatabase, admin User, permission Permission) (*User, error) {
+	current, err := users.GetUserById(admin.Id)
+	if err != nil || current.Disabled {
+		return nil, permissionDeniedErr
+	}
+	return current, authorize(*current, permission)
+}
+
+// ListUsers returns every user sorted by username.
+func ListUsers(users UserDatabase, admin User) ([]UserSummary, error) {
+	_, err := authorizeAdmin(users, admin, PermissionListUsers)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]UserSummary, 0, len(users.UsersByUsername))
+	for _, user := range users.UsersByUsername {
+		list = append(list, summarize(user))
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return CanonicalUsername(list[i].Username) < CanonicalUsername(list[j].Username)
+	})
+	return list, nil
+}
+
+// SetDisabled disables or enables the user with the given email or username.
+// Disabled users cannot log in and, when sessions is not nil, their sessions
+// are ended; their API keys stop working while they stay disabled.
+func SetDisabled(users UserDatabase, sessions *Sessions, admin User, id string, disabled bool) (UserSummary, error) {
+	current, err := authorizeAdmin(users, admin, PermissionDisableUsers)
+	if err != nil {
+		return UserSummary{}, err
+	}
+	user, err := users.getUser(id)
+	if err != nil {
+		return UserSummary{}, userNotFoundErr
+	}
+	if user == current && disabled {
+		return UserSummary{}, selfAdministrationErr
+	}
+	user.Disabled = disabled
+	if disabled && sessions != nil {
+		sessions.RevokeAll(user.Id)
+	}
+	return summarize(user), nil
+}
+
+// ForcePasswordReset makes the user with the given email or username choose
+// a new password before logging in again and mails them a notice to do it
+// with Forgot password. No code is mailed, reset codes only live in the
+// process that asked for them. Their sessions are ended when sessions is not
+// nil. If the notice could not be mailed the reset is still required.
+func ForcePasswordReset(users UserDatabase, sessions *Sessions, sender mailer.Mailer, admin User, id string) (UserSummary, error) {
+	_, err := authorizeAdmin(users, admin, PermissionResetPasswords)
+	if err != nil {
+		return UserSummary{}, err
+	}
+	user, err := users.getUser(id)
+	if err != nil {
+		return UserSummary{}, userNotFoundErr
+	}
+	user.PasswordResetRequired = true
+	if sessions != nil {
+		sessions.RevokeAll(user.Id)
+	}
+	return summarize(user), sender.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Choose a new password",
+		Body: fmt.Sprintf("Hi %s,\n\nAn administrator asked you to choose a new password. Use Forgot password to get a code and set it, until then you cannot log in.\n",
+			user.Username),
+	})
+}
+
+// UnlockLogins forgets the failed logins throttle counted against the user
+// with the given email or username, e.g. after someone guessed at their
+// password until they were locked out.
+func UnlockLogins(users UserDatabase, throttle *LoginThrottle, admin User, id string) (UserSummary, error) {
+	_, err := authorizeAdmin(users, admin, PermissionUnlockLogins)
+	if err != nil {
+		return UserSummary{}, err
+	}
+	user, err := users.getUser(id)
+	if err != nil {
+		return UserSummary{}, userNotFoundErr
+	}
+	throttle.Unlock(users, user.Username)
+	return summarize(user), nil
+}
+
+// UnlockSource forgets the failed logins throttle counted against source,
+// e.g. an address many users share.
+func UnlockSource(users UserDatabase, throttle *LoginThrottle, admin User, source string) error {
+	_, err := authorizeAdmin(users, admin, PermissionUnlockLogins)
+	if err != nil {
+		return err
+	}
+	throttle.UnlockSource(source)
+	return nil
+}
+
+// CountTasks tells how many tasks in userTasks belong to the user with the
+// given email or username.
+func CountTasks(users UserDatabase, userTasks map[uuid.UUID]tasks.TaskList, admin User, id string) (TaskCounts, error) {
+	_, err := authorizeAdmin(users, admin, PermissionViewTaskCounts)
+	if err != nil {
+		return TaskCounts{}, err
+	}
+	user, err := users.getUser(id)
+	if err != nil {
+		return TaskCounts{}, userNotFoundErr
+	}
+	var counts TaskCounts
+	for _, task := range userTasks[user.Id] {
+		counts.Total++
+		if task.TaskStatus == "complete" {
+			counts.Completed++
+		} else {
+			counts.Pending++
+		}
+	}
+	return counts, nil
+}
+
+// SecurityEvents returns the events between since and until of the user with
+// the given email or username, or of everyone when id is empty. Zero times
+// leave the range open.
+func SecurityEvents(users UserDatabase, events audit.Querier, admin User, id string, since, until time.Time) ([]audit.Event, error) {
+	_, err := authorizeAdmin(users, admin, PermissionViewEvents)
+	if err != nil {
+		return nil, err
+	}
+	filter := audit.Filter{Since: since, Until: until}
+	if id != "" {
+		user, err := users.getUser(id)
+		if err != nil {
+			return nil, userNotFoundErr
+		}
+		filter.UserId = user.Id
+	}
+	return events.Query(filter)
+}
+
+// SetRole gives the user with the given email or username another role.
+// Administrators cannot change their own role, so there is always one left.
+func SetRole(users UserDatabase, admin User, id string, role Role) (UserSummary, error) {
+	current, err := authorizeAdmin(users, admin, PermissionManageRoles)
+	if err != nil {
+		return UserSummary{}, err
+	}
+	user, err := users.getUser(id)
+	if err != nil {
+		return UserSummary{}, userNotFoundErr
+	}
+	if user == current {
+		return UserSummary{}, selfAdministrationErr
+	}
+	return assignRole(users, user.Username, role)
+}
+
+// HasAdmin tells whether any user is an administrator.
+func HasAdmin(users UserDatabase) bool {
+	for _, user := range users.UsersByUsername {
+		if user.Role == RoleAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// NameFirstAdmin gives a user the admin role without checking who asks, as
+// long as there is no administrator yet. After that only administrators give
+// roles, with SetRole.
+func NameFirstAdmin(users UserDatabase, id string) (UserSummary, error) {
+	if HasAdmin(users) {
+		return UserSummary{}, adminExistsErr
+	}
+	return assignRole(users, id, RoleAdmin)
+}
+
+// assignRole gives a user a role without checking who asks.
+func assignRole(users UserDatabase, id string, role Role) (UserSummary, error) {
+	if role != RoleUser && role != RoleAdmin {
+		return UserSummary{}, invalidRoleErr
+	}
+	user, err := users.getUser(id)
+	if err != nil {
+		return UserSummary{}, userNotFoundErr
+	}
+	user.Role = role
+	return summarize(user), nil
+}
@@ -0,0 +1,248 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"todo_app/pkg/audit"
+	"todo_app/pkg/mailer"
+	"todo_app/pkg/tasks"
+
+	"github.com/google/uuid"
+)
+
+func TestListUsers(t *testing.T) {
+	users, admin := adminTestUsers(t)
+	tester := *users.UsersByUsername["tester"]
+
+	_, err := ListUsers(users, tester)
+	assertError(t, err, permissionDeniedErr)
+	list, err := ListUsers(users, admin)
+	assertError(t, err, nil)
+
+	if len(list) != 2 || list[0].Username != "other" || list[1].Username != "tester" {
+		t.Fatalf("expected both users sorted by username, got %v", list)
+	}
+	if list[0].Role != RoleAdmin || list[1].Role != RoleUser {
+		t.Errorf("expected the roles of the users, got %v", list)
+	}
+}
+
+func TestSetDisabled(t *testing.T) {
+	users, admin := adminTestUsers(t)
+	sessions := NewSessions(DefaultSessionIdleTimeout, DefaultSessionMaxLifetime)
+	token, _, _ := sessions.Create(*users.UsersByUsername["tester"])
+	tests := []struct {
+		name           string
+		admin          User
+		id             string
+		disabled       bool
+		expected_error error
+	}{
+		{name: "not an admin", admin: *users.UsersByUsername["tester"], id: "other", disabled: true, expected_error: permissionDeniedErr},
+		{name: "unknown user", admin: admin, id: "nobody", disabled: true, expected_error: userNotFoundErr},
+		{name: "disable themselves", admin: admin, id: "other", disabled: true, expected_error: selfAdministrationErr},
+		{name: "disable a user", admin: admin, id: "mail@gmail.com", disabled: true, expected_error: nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := SetDisabled(users, sessions, test.admin, test.id, test.disabled)
+
+			assertError(t, err, test.expected_error)
+		})
+	}
+	_, err := LogIn(users, NewLoginChallenges(), "tester", "Abc12345!")
+	assertError(t, err, accountDisabledErr)
+	_, err = sessions.Lookup(users, token)
+	assertError(t, err, invalidSessionErr)
+
+	_, err = SetDisabled(users, sessions, admin, "tester", false)
+	assertError(t, err, nil)
+	_, err = LogIn(users, NewLoginChallenges(), "tester", "Abc12345!")
+	assertError(t, err, nil)
+}
+
+func TestUnlockLogins(t *testing.T) {
+	users, admin := adminTestUsers(t)
+	throttle := NewLoginThrottle()
+	throttle.Account = ThrottlePolicy{MaxFailures: 2, Lockout: time.Hour}
+	throttle.Source = ThrottlePolicy{MaxFailures: 2, Lockout: time.Hour}
+	throttle.LogIn(users, "tester", "wrong", "a")
+	throttle.LogIn(users, "tester", "wrong", "a")
+	tests := []struct {
+		name           string
+		admin          User
+		id             string
+		expected_error error
+	}{
+		{name: "not an admin", admin: *users.UsersByUsername["tester"], id: "tester", expected_error: permissionDeniedErr},
+		{name: "unknown user", admin: admin, id: "nobody", expected_error: userNotFoundErr},
+		{name: "unlock a user", admin: admin, id: "mail@gmail.com", expected_error: nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := UnlockLogins(users, throttle, test.admin, test.id)
+
+			assertError(t, err, test.expected_error)
+		})
+	}
+	_, err := throttle.LogIn(users, "tester", "Abc12345!", "b")
+	assertError(t, err, nil)
+	// the source stays locked until it is unlocked too
+	_, err = throttle.LogIn(users, "other", "Abc12345!", "a")
+	if _, locked := err.(LockedErr); !locked {
+		t.Fatalf("expected the source to be locked, got %v", err)
+	}
+	err = UnlockSource(users, throttle, *users.UsersByUsername["tester"], "a")
+	assertError(t, err, permissionDeniedErr)
+	err = UnlockSource(users, throttle, admin, "a")
+	assertError(t, err, nil)
+	_, err = throttle.LogIn(users, "tester", "Abc12345!", "a")
+	assertError(t, err, nil)
+}
+
+func TestForcePasswordReset(t *testing.T) {
+	users, admin := adminTestUsers(t)
+	sessions := NewSessions(DefaultSessionIdleTimeout, DefaultSessionMaxLifetime)
+	token, _, _ := sessions.Create(*users.UsersByUsername["tester"])
+	resets := NewPasswordResets(DefaultResetTokenTTL)
+	sent := &mailer.Memory{}
+
+	_, err := ForcePasswordReset(users, sessions, sent, *users.UsersByUsername["tester"], "tester")
+	assertError(t, err, permissionDeniedErr)
+	summary, err := ForcePasswordReset(users, sessions, sent, admin, "tester")
+	assertError(t, err, nil)
+
+	if !summary.PasswordResetRequired {
+		t.Errorf("expected the reset to be required, got %v", summary)
+	}
+	_, err = sessions.Lookup(users, token)
+	assertError(t, err, invalidSessionErr)
+	_, err = LogIn(users, NewLoginChallenges(), "tester", "Abc12345!")
+	assertError(t, err, passwordResetForcedErr)
+	messages := sent.Messages()
+	if len(messages) != 1 || !strings.Contains(messages[0].Body, "Forgot password") {
+		t.Fatalf("expected a notice to use Forgot password, got %v", messages)
+	}
+	// the code comes from Forgot password, in any process
+	assertError(t, resets.Request(users, sent, "tester"), nil)
+	_, err = resets.ResetPassword(users, nil, resetCode(t, sent), "Xyz12345!")
+	assertError(t, err, nil)
+	_, err = LogIn(users, NewLoginChallenges(), "tester", "Xyz12345!")
+	assertError(t, err, nil)
+}
+
+func TestCountTasks(t *testing.T) {
+	users, admin := adminTestUsers(t)
+	tester := *users.UsersByUsername["tester"]
+	userTasks := map[uuid.UUID]tasks.TaskList{tester.Id: {}}
+	userTasks[tester.Id].AddTask("first", "test", "09-09-2029")
+	second, _ := userTasks[tester.Id].AddTask("second", "test", "09-09-2029")
+	userTasks[tester.Id].BatchComplete([]int{second.Id})
+
+	_, err := CountTasks(users, userTasks, tester, "tester")
+	assertError(t, err, permissionDeniedErr)
+	counts, err := CountTasks(users, userTasks, admin, "tester")
+	assertError(t, err, nil)
+
+	expected := TaskCounts{Total: 2, Pending: 1, Completed: 1}
+	if counts != expected {
+		t.Errorf("got %v, expected %v", counts, expected)
+	}
+}
+
+func TestSecurityEvents(t *testing.T) {
+	users, admin := adminTestUsers(t)
+	tester := *users.UsersByUsername["tester"]
+	start := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	events := &audit.Memory{}
+	events.Record(audit.Event{Time: start, Type: audit.LoginSuccess, UserId: tester.Id})
+	events.Record(audit.Event{Time: start.Add(time.Hour), Type: audit.LoginSuccess, UserId: admin.Id})
+	events.Record(audit.Event{Time: start.Add(2 * time.Hour), Type: audit.PasswordChange, UserId: tester.Id})
+	tests := []struct {
+		name           string
+		admin          User
+		id             string
+		since          time.Time
+		expected       int
+		expected_error error
+	}{
+		{name: "not an admin", admin: tester, id: "tester", expected: 0, expected_error: permissionDeniedErr},
+		{name: "unknown user", admin: admin, id: "nobody", expected: 0, expected_error: userNotFoundErr},
+		{name: "everyone", admin: admin, id: "", expected: 3, expected_error: nil},
+		{name: "one user", admin: admin, id: "mail@gmail.com", expected: 2, expected_error: nil},
+		{name: "one user since a time", admin: admin, id: "tester", since: start.Add(time.Hour), expected: 1, expected_error: nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := SecurityEvents(users, events, test.admin, test.id, test.since, time.Time{})
+
+			assertError(t, err, test.expected_error)
+			if len(got) != test.expected {
+				t.Errorf("got %v, expected %d events", got, test.expected)
+			}
+		})
+	}
+}
+
+func TestSetRole(t *testing.T) {
+	users, admin := adminTestUsers(t)
+	tests := []struct {
+		name           string
+		admin          User
+		id             string
+		role           Role
+		expected_error error
+	}{
+		{name: "not an admin", admin: *users.UsersByUsername["tester"], id: "tester", role: RoleAdmin, expected_error: permissionDeniedErr},
+		{name: "own role", admin: admin, id: "other", role: RoleUser, expected_error: selfAdministrationErr},
+		{name: "unknown role", admin: admin, id: "tester", role: Role(7), expected_error: invalidRoleErr},
+		{name: "promote a user", admin: admin, id: "tester", role: RoleAdmin, expected_error: nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := SetRole(users, test.admin, test.id, test.role)
+
+			assertError(t, err, test.expected_error)
+		})
+	}
+	// the admin given to the functions is only trusted as far as its stored
+	// account goes
+	_, err := SetRole(users, *users.UsersByUsername["tester"], "other", RoleUser)
+	assertError(t, err, nil)
+	_, err = ListUsers(users, admin)
+	assertError(t, err, permissionDeniedErr)
+}
+
+func TestNameFirstAdmin(t *testing.T) {
+	users := accountTestUsers(t)
+
+	first, err := NameFirstAdmin(users, "other")
+
+	assertError(t, err, nil)
+	if first.Role != RoleAdmin || !HasAdmin(users) {
+		t.Errorf("expected other to be the first administrator, got %v", first)
+	}
+	_, err = NameFirstAdmin(users, "tester")
+	assertError(t, err, adminExistsErr)
+	if users.UsersByUsername["tester"].Role != RoleUser {
+		t.Error("expected no second administrator without an administrator asking")
+	}
+}
+
+//helpers
+
+// adminTestUsers are the users of accountTestUsers with other as admin.
+func adminTestUsers(t testing.TB) (UserDatabase, User) {
+	t.Helper()
+	users := accountTestUsers(t)
+	_, err := assignRole(users, "other", RoleAdmin)
+	if err != nil {
+		t.Fatalf("could not make the test admin: %q", err)
+	}
+	return users, *users.UsersByUsername["other"]
+}
@@ -0,0 +1,238 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	ScopeTasksRead      = "tasks:read"
+	ScopeTasksWrite     = "tasks:write"
+	APIKeyPrefix        = "todo_"
+	apiKeyNameMaxLength = 40
+	invalidAPIKeyErr    = APIKeyErr("API key is invalid, revoked or has expired")
+	apiKeyNameErr       = APIKeyErr("API key name must be between 1 and 40 characters")
+	apiKeyExistsErr     = APIKeyErr("You already have an API key with that name")
+	apiKeyNotFoundErr   = APIKeyErr("API key not found")
+	apiKeyExpiryErr     = APIKeyErr("API key expiry must be in the future")
+	invalidScopeErr     = APIKeyErr("Scopes must be tasks:read or tasks:write")
+	missingScopeErr     = ScopeErr("API key does not have the scope this request needs")
+)
+
+var Scopes = []string{ScopeTasksRead, ScopeTasksWrite}
+
+type APIKeyErr string
+
+func (e APIKeyErr) Error() string {
+	return string(e)
+}
+
+// ScopeErr means the API key is valid but not allowed to do what was asked.
+type ScopeErr string
+
+func (e ScopeErr) Error() string {
+	return string(e)
+}
+
+// APIKey lets scripts use the API as a user without their password. Like
+// sessions only the SHA-256 of the key is kept, the key itself is shown once
+// when it is created. A zero Expires means the key never expires.
+type APIKey struct {
+	Id       string
+	UserId   uuid.UUID
+	Name     string
+	Hash     string
+	Scopes   []string
+	Created  time.Time
+	Expires  time.Time
+	LastUsed time.Time
+}
+
+func (key APIKey) HasScope(scope string) bool {
+	return slices.Contains(key.Scopes, scope)
+}
+
+func (key APIKey) Expired(now time.Time) bool {
+	return !key.Expires.IsZero() && !now.Before(key.Expires)
+}
+
+type APIKeys struct {
+	Now  func() time.Time
+	mu   sync.Mutex
+	keys map[string]*APIKey
+}
+
+func NewAPIKeys() *APIKeys {
+	return &APIKeys{Now: time.Now, keys: make(map[string]*APIKey)}
+}
+
+// ParseScopes reads a comma separated list of scopes, all of them when the
+// list is empty.
+func ParseScopes(list string) ([]string, error) {
+	if strings.TrimSpace(list) == "" {
+		return slices.Clone(Scopes), nil
+	}
+	var scopes []string
+	for _, scope := range strings.Split(list, ",") {
+		scope = strings.ToLower(strings.TrimSpace(scope))
+		if !slices.Contains(Scopes, scope) {
+			return nil, invalidScopeErr
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes, nil
+}
+
+// Create makes a new key for user and returns it. The returned string is the
+// only copy of the key, it cannot be recovered later.
+func (keys *APIKeys) Create(user User, name string, scopes []string, expires time.Time) (string, APIKey, error) {
+	name = strings.TrimSpace(name)
+	if len(name) == 0 || len(name) > apiKeyNameMaxLength {
+		return "", APIKey{}, apiKeyNameErr
+	}
+	if len(scopes) == 0 {
+		return "", APIKey{}, invalidScopeErr
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(Scopes, scope) {
+			return "", APIKey{}, invalidScopeErr
+		}
+	}
+	id := make([]byte, 4)
+	secret := make([]byte, 32)
+	_, err := rand.Read(id)
+	if err != nil {
+		return "", APIKey{}, err
+	}
+	_, err = rand.Read(secret)
+	if err != nil {
+		return "", APIKey{}, err
+	}
+
+	keys.mu.Lock()
+	defer keys.mu.Unlock()
+	now := keys.Now()
+	if !expires.IsZero() && !now.Before(expires) {
+		return "", APIKey{}, apiKeyExpiryErr
+	}
+	for _, key := range keys.keys {
+		if key.UserId == user.Id && strings.EqualFold(key.Name, name) {
+			return "", APIKey{}, apiKeyExistsErr
+		}
+	}
+	token := APIKeyPrefix + hex.EncodeToString(id) + "_" + base64.RawURLEncoding.EncodeToString(secret)
+	key := APIKey{
+		Id:      hex.EncodeToString(id),
+		UserId:  user.Id,
+		Name:    name,
+		Hash:    hashToken(token),
+		Scopes:  slices.Clone(scopes),
+		Created: now,
+		Expires: expires,
+	}
+	keys.keys[key.Hash] = &key
+	return token, key, nil
+}
+
+// IsAPIKey tells API keys apart from other bearer tokens.
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, APIKeyPrefix)
+}
+
+// Verify returns the user a key belongs to if the key is valid and has scope.
+func (keys *APIKeys) Verify(users UserDatabase, token, scope string) (User, APIKey, error) {
+	keys.mu.Lock()
+	defer keys.mu.Unlock()
+	now := keys.Now()
+	key, found := keys.keys[hashToken(token)]
+	if !found || key.Expired(now) {
+		return User{}, APIKey{}, invalidAPIKeyErr
+	}
+	user, err := users.GetUserById(key.UserId)
+	// keys of users who cannot log in, e.g. disabled or made to reset their
+	// password, work again once they can
+	if err != nil || CanLogIn(*user) != nil {
+		return User{}, APIKey{}, invalidAPIKeyErr
+	}
+	if !key.HasScope(scope) {
+		return User{}, APIKey{}, missingScopeErr
+	}
+	key.LastUsed = now
+	return *user, *key, nil
+}
+
+// List returns the keys of a user by name, to manage them.
+func (keys *APIKeys) List(userId uuid.UUID) []APIKey {
+	keys.mu.Lock()
+	defer keys.mu.Unlock()
+	var list []APIKey
+	for _, key := range keys.keys {
+		if key.UserId == userId {
+			list = append(list, *key)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Revoke deletes the key of a user with the given name or id.
+func (keys *APIKeys) Revoke(userId uuid.UUID, nameOrId string) error {
+	keys.mu.Lock()
+	defer keys.mu.Unlock()
+	for hash, key := range keys.keys {
+		if key.UserId == userId && (strings.EqualFold(key.Name, nameOrId) || key.Id == nameOrId) {
+			delete(keys.keys, hash)
+			return nil
+		}
+	}
+	return apiKeyNotFoundErr
+}
+
+// RevokeAll deletes every key of a user and returns how many there were.
+func (keys *APIKeys) RevokeAll(userId uuid.UUID) int {
+	keys.mu.Lock()
+	defer keys.mu.Unlock()
+	revoked := 0
+	for hash, key := range keys.keys {
+		if key.UserId == userId {
+			delete(keys.keys, hash)
+			revoked++
+		}
+	}
+	return revoked
+}
+
+// All returns every key so they can be saved. Expired keys are kept until
+// their owner revokes them, so they still show up in List.
+func (keys *APIKeys) All() []APIKey {
+	keys.mu.Lock()
+	defer keys.mu.Unlock()
+	list := make([]APIKey, 0, len(keys.keys))
+	for _, key := range keys.keys {
+		list = append(list, *key)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Created.Equal(list[j].Created) {
+			return list[i].Id < list[j].Id
+		}
+		return list[i].Created.Before(list[j].Created)
+	})
+	return list
+}
+
+// Restore puts back a key returned by All, e.g. after a restart.
+func (keys *APIKeys) Restore(key APIKey) {
+	keys.mu.Lock()
+	defer keys.mu.Unlock()
+	keys.keys[key.Hash] = &key
+}
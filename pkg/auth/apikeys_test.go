@@ -0,0 +1,162 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestCreateAPIKey(t *testing.T) {
+	_, user := sessionTestUsers()
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	keys := NewAPIKeys()
+	keys.Now = func() time.Time { return now }
+	keys.Create(user, "cron", []string{ScopeTasksRead}, time.Time{})
+	tests := []struct {
+		name           string
+		key_name       string
+		scopes         []string
+		expires        time.Time
+		expected_error error
+	}{
+		{name: "valid key", key_name: "backup", scopes: []string{ScopeTasksRead, ScopeTasksWrite}, expires: now.Add(time.Hour), expected_error: nil},
+		{name: "empty name", key_name: "  ", scopes: []string{ScopeTasksRead}, expected_error: apiKeyNameErr},
+		{name: "name too long", key_name: strings.Repeat("a", 41), scopes: []string{ScopeTasksRead}, expected_error: apiKeyNameErr},
+		{name: "name already used", key_name: "CRON", scopes: []string{ScopeTasksRead}, expected_error: apiKeyExistsErr},
+		{name: "no scopes", key_name: "none", scopes: nil, expected_error: invalidScopeErr},
+		{name: "unknown scope", key_name: "admin", scopes: []string{"users:write"}, expected_error: invalidScopeErr},
+		{name: "expiry in the past", key_name: "old", scopes: []string{ScopeTasksRead}, expires: now, expected_error: apiKeyExpiryErr},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			token, key, err := keys.Create(user, test.key_name, test.scopes, test.expires)
+
+			assertError(t, err, test.expected_error)
+			if err != nil {
+				return
+			}
+			if !strings.HasPrefix(token, APIKeyPrefix+key.Id+"_") || key.Hash != hashToken(token) {
+				t.Errorf("expected a prefixed key stored hashed, got %q and %v", token, key)
+			}
+		})
+	}
+}
+
+func TestVerifyAPIKey(t *testing.T) {
+	users, user := sessionTestUsers()
+	clock := &fakeClock{now: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)}
+	keys := NewAPIKeys()
+	keys.Now = clock.Now
+	readOnly, _, _ := keys.Create(user, "read", []string{ScopeTasksRead}, time.Time{})
+	expiring, _, _ := keys.Create(user, "expiring", []string{ScopeTasksRead, ScopeTasksWrite}, clock.now.Add(time.Hour))
+	orphan, _, _ := keys.Create(User{Id: uuid.New()}, "orphan", []string{ScopeTasksRead}, time.Time{})
+	tests := []struct {
+		name           string
+		token          string
+		scope          string
+		advance        time.Duration
+		expected_error error
+	}{
+		{name: "read key reading", token: readOnly, scope: ScopeTasksRead, expected_error: nil},
+		{name: "read key writing", token: readOnly, scope: ScopeTasksWrite, expected_error: missingScopeErr},
+		{name: "write key before expiry", token: expiring, scope: ScopeTasksWrite, expected_error: nil},
+		{name: "unknown key", token: APIKeyPrefix + "nope", scope: ScopeTasksRead, expected_error: invalidAPIKeyErr},
+		{name: "key of a deleted user", token: orphan, scope: ScopeTasksRead, expected_error: invalidAPIKeyErr},
+		{name: "expired key", token: expiring, scope: ScopeTasksRead, advance: time.Hour, expected_error: invalidAPIKeyErr},
+		{name: "key without expiry", token: readOnly, scope: ScopeTasksRead, advance: 365 * 24 * time.Hour, expected_error: nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clock.now = clock.now.Add(test.advance)
+
+			got, key, err := keys.Verify(users, test.token, test.scope)
+
+			assertError(t, err, test.expected_error)
+			if err == nil {
+				assertUsers(t, got, user)
+				if !key.LastUsed.Equal(clock.now) {
+					t.Errorf("expected last use to be %v, got %v", clock.now, key.LastUsed)
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyAPIKeyRestrictedAccount(t *testing.T) {
+	users, user := sessionTestUsers()
+	keys := NewAPIKeys()
+	token, _, _ := keys.Create(user, "read", []string{ScopeTasksRead}, time.Time{})
+	stored, _ := users.GetUserById(user.Id)
+
+	stored.Disabled = true
+	_, _, err := keys.Verify(users, token, ScopeTasksRead)
+	assertError(t, err, invalidAPIKeyErr)
+
+	stored.Disabled = false
+	stored.PasswordResetRequired = true
+	_, _, err = keys.Verify(users, token, ScopeTasksRead)
+	assertError(t, err, invalidAPIKeyErr)
+
+	stored.PasswordResetRequired = false
+	_, _, err = keys.Verify(users, token, ScopeTasksRead)
+	assertError(t, err, nil)
+}
+
+func TestRevokeAPIKey(t *testing.T) {
+	users, user := sessionTestUsers()
+	keys := NewAPIKeys()
+	first, firstKey, _ := keys.Create(user, "first", []string{ScopeTasksRead}, time.Time{})
+	second, _, _ := keys.Create(user, "second", []string{ScopeTasksRead}, time.Time{})
+
+	assertError(t, keys.Revoke(user.Id, "first"), nil)
+	assertError(t, keys.Revoke(uuid.New(), "second"), apiKeyNotFoundErr)
+
+	_, _, err := keys.Verify(users, first, ScopeTasksRead)
+	assertError(t, err, invalidAPIKeyErr)
+	_, _, err = keys.Verify(users, second, ScopeTasksRead)
+	assertError(t, err, nil)
+	assertError(t, keys.Revoke(user.Id, firstKey.Id), apiKeyNotFoundErr)
+	if list := keys.List(user.Id); len(list) != 1 || list[0].Name != "second" {
+		t.Errorf("expected only the second key left, got %v", list)
+	}
+
+	t.Run("every key of the user", func(t *testing.T) {
+		other := User{Id: uuid.New(), Username: "other"}
+		keys.Create(other, "other", []string{ScopeTasksRead}, time.Time{})
+
+		if revoked := keys.RevokeAll(user.Id); revoked != 1 {
+			t.Errorf("got %d keys revoked, expected 1", revoked)
+		}
+		if len(keys.List(user.Id)) != 0 || len(keys.List(other.Id)) != 1 {
+			t.Error("expected only the keys of the user to be revoked")
+		}
+	})
+}
+
+func TestParseScopes(t *testing.T) {
+	tests := []struct {
+		input          string
+		expected       []string
+		expected_error error
+	}{
+		{input: "", expected: Scopes, expected_error: nil},
+		{input: "tasks:read", expected: []string{ScopeTasksRead}, expected_error: nil},
+		{input: " Tasks:Write , tasks:read,tasks:write", expected: []string{ScopeTasksWrite, ScopeTasksRead}, expected_error: nil},
+		{input: "tasks:delete", expected: nil, expected_error: invalidScopeErr},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			got, err := ParseScopes(test.input)
+
+			assertError(t, err, test.expected_error)
+			if strings.Join(got, ",") != strings.Join(test.expected, ",") {
+				t.Errorf("got %v, expected %v", got, test.expected)
+			}
+		})
+	}
+}
@@ -3,35 +3,20 @@ package auth
 import (
 	"net/mail"
 	"regexp"
+	"time"
+	"todo_app/pkg/audit"
 
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
 )
 
-var (
-	userRegex        = regexp.MustCompile("^[a-zA-Z0-9_.]+$")
-	lowercaseRegex   = regexp.MustCompile(`[a-z]`)
-	uppercaseRegex   = regexp.MustCompile(`[A-Z]`)
-	digitRegex       = regexp.MustCompile(`\d`)
-	specialCharRegex = regexp.MustCompile(`[@$!%*?&]`)
-	passwordRules    = []*regexp.Regexp{
-		lowercaseRegex,
-		uppercaseRegex,
-		digitRegex,
-		specialCharRegex,
-		specialCharRegex,
-	}
-)
+var userRegex = regexp.MustCompile("^[a-zA-Z0-9_.]+$")
 
 const (
 	usernameMaxLength     = 17
 	usernameMinLength     = 3
-	passMinLength         = 8
 	userMinimumLengthErr  = UsernameErr("Username must be at least 3 characters long")
 	userMaximumLengthErr  = UsernameErr("Username must be below 17 characters long")
 	userInvalidCharErr    = UsernameErr("Username cannot contain spaces or special characters beside commas and dots")
-	passMinLengthErr      = PasswordErr("Password must have at least 8 characters")
-	passInvalidCharErr    = PasswordErr("Password must contain at least one uppercase and lowercase letter, one number and one of the following symbols: @$!%*?&")
 	userNotFoundErr       = UserErr("User not found")
 	usernameRegisteredErr = UserErr("This username has already been registered")
 	invalidEmailErr       = EmailErr("Your email address is not in a valid format")
@@ -44,6 +29,19 @@ type User struct {
 	Email    string
 	Username string
 	Password string
+	Verified bool
+	// TOTPSecret is empty unless two-factor authentication is on,
+	// TOTPLastStep keeps its codes from being used twice
+	TOTPSecret    string
+	TOTPLastStep  int64
+	RecoveryCodes []string
+	Role          Role
+	// Disabled and PasswordResetRequired are set by administrators
+	Disabled              bool
+	PasswordResetRequired bool
+	// Identities are the accounts at identity providers that log in as the
+	// user
+	Identities []Identity
 }
 
 type UserDatabase struct {
@@ -52,18 +50,29 @@ type UserDatabase struct {
 }
 
 func (users UserDatabase) getUser(id string) (*User, error) {
-	user_by_email, user_found := users.UsersByEmail[id]
+	user_by_email, user_found := users.UsersByEmail[CanonicalEmail(id)]
 	if user_found {
 		return user_by_email, nil
 	}
-	user_by_username, user_found := users.UsersByUsername[id]
+	user_by_username, user_found := users.UsersByUsername[CanonicalUsername(id)]
 	if user_found {
 		return user_by_username, nil
 	}
 	return nil, userNotFoundErr
 }
 
+func (users UserDatabase) GetUserById(id uuid.UUID) (*User, error) {
+	for _, user := range users.UsersByUsername {
+		if user.Id == id {
+			return user, nil
+		}
+	}
+	return nil, userNotFoundErr
+}
+
 func (users UserDatabase) RegisterUser(email, username, password string) (User, error) {
+	email = displayForm(email)
+	username = displayForm(username)
 	validEmailError := validateEmail(email)
 	if validEmailError != nil {
 		return User{}, validEmailError
@@ -73,7 +82,7 @@ func (users UserDatabase) RegisterUser(email, username, password string) (User,
 	if validUsernameError != nil {
 		return User{}, validUsernameError
 	}
-	validPasswordError := validatePassword(password)
+	validPasswordError := validatePassword(password, username, email)
 	if validPasswordError != nil {
 		return User{}, validPasswordError
 	}
@@ -85,12 +94,12 @@ func (users UserDatabase) RegisterUser(email, username, password string) (User,
 
 	if emailAvailable {
 		if usernameAvailable {
-			hashed_pass, pass_error := bcrypt.GenerateFromPassword([]byte(password), 12)
+			hashed_pass, pass_error := hashPassword(password)
 			if pass_error == nil {
-				newUser := User{Email: email, Username: username, Password: string(hashed_pass)}
+				newUser := User{Email: email, Username: username, Password: hashed_pass}
 				generateUUID(&newUser)
-				users.UsersByUsername[username] = &newUser
-				users.UsersByEmail[email] = &newUser
+				users.add(&newUser)
+				recordUserEvent(audit.Register, &newUser, "", "", "", time.Now())
 				return newUser, nil
 			}
 			return User{}, pass_error
@@ -138,18 +147,6 @@ func validateUsername(username string) error {
 	return nil
 }
 
-func validatePassword(password string) error {
-	if len(password) < passMinLength {
-		return passMinLengthErr
-	}
-	for _, rule := range passwordRules {
-		if !rule.MatchString(password) {
-			return passInvalidCharErr
-		}
-	}
-	return nil
-}
-
 func validateEmail(email string) error {
 	_, err := mail.ParseAddress(email)
 
@@ -164,14 +161,39 @@ func generateUUID(user *User) {
 	user.Id = uuid.New()
 }
 
-func LogIn(users UserDatabase, id, password string) (loggedUser User, err error) {
+// LogIn checks the password of a user, users with two-factor authentication
+// get a SecondFactorRequired kept in challenges instead.
+func LogIn(users UserDatabase, challenges *LoginChallenges, id, password string) (loggedUser User, err error) {
+	return logIn(users, challenges, id, password, "", time.Now())
+}
+
+// logIn is LogIn for a login from source at now, which is recorded along with
+// it.
+func logIn(users UserDatabase, challenges *LoginChallenges, id, password, source string, now time.Time) (User, error) {
 	user, err := users.getUser(id)
 	if err == nil {
-		correct_password := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) == nil
+		correct_password := comparePassword(user.Password, password)
 		if correct_password {
+			// the caller saves the users to keep an upgraded hash
+			rehashPassword(user, password)
+			err = CanLogIn(*user)
+			if err != nil {
+				recordLogin(user, id, source, err, now)
+				return User{}, err
+			}
+			if user.HasTOTP() {
+				required, err := challenges.add(user, now)
+				if err != nil {
+					return User{}, err
+				}
+				return User{}, required
+			}
+			recordLogin(user, id, source, nil, now)
 			return *user, nil
 		}
+		recordLogin(user, id, source, wrongPasswordErr, now)
 		return User{}, wrongPasswordErr
 	}
+	recordLogin(nil, id, source, userNotFoundErr, now)
 	return User{}, userNotFoundErr
 }
@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"os"
 	"reflect"
 	"testing"
 
@@ -8,6 +9,15 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+// testHasher keeps the tests fast, bcrypt at its default cost takes too long
+// under the race detector.
+var testHasher = BcryptHasher{Cost: bcrypt.MinCost}
+
+func TestMain(m *testing.M) {
+	UsePasswordHasher(testHasher)
+	os.Exit(m.Run())
+}
+
 func TestValidateUsername(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -42,18 +52,18 @@ func TestValidatePassword(t *testing.T) {
 		expected_error error
 	}{
 		{name: "valid password", input: "Abc12345!", expected_error: nil},
-		{name: "no uppercase", input: "abc12345!", expected_error: passInvalidCharErr},
-		{name: "no special char", input: "Abc12345", expected_error: passInvalidCharErr},
-		{name: "no number", input: "Abcasdasasd!", expected_error: passInvalidCharErr},
-		{name: "no lowercase", input: "A123123123123!", expected_error: passInvalidCharErr},
-		{name: "no letter", input: "123123123123!", expected_error: passInvalidCharErr},
-		{name: "below minimum", input: "ola", expected_error: passMinLengthErr},
+		{name: "no uppercase", input: "abc12345!", expected_error: PasswordRulesErr{passNoUpperErr}},
+		{name: "no special char", input: "Abc12345", expected_error: PasswordRulesErr{passNoSymbolErr}},
+		{name: "no number", input: "Abcasdasasd!", expected_error: PasswordRulesErr{passNoDigitErr}},
+		{name: "no lowercase", input: "A123123123123!", expected_error: PasswordRulesErr{passNoLowerErr}},
+		{name: "no letter", input: "123123123123!", expected_error: PasswordRulesErr{passNoLowerErr, passNoUpperErr}},
+		{name: "below minimum", input: "ola", expected_error: PasswordRulesErr{passMinLengthErr, passNoUpperErr, passNoDigitErr, passNoSymbolErr}},
 		{name: "minimum", input: "Abc1234!", expected_error: nil},
 	}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			got := validatePassword(test.input)
+			got := validatePassword(test.input, "tester", "mail@gmail.com")
 
 			assertError(t, got, test.expected_error)
 		})
@@ -81,7 +91,7 @@ func TestRegister(t *testing.T) {
 	}{
 		{name: "valid register", input: [3]string{"mail@gmail.com", "testestestestes", "Abc12345!"}, expected_error: nil},
 		{name: "invalid Username", input: [3]string{"mail2@gmail.com", "test test", "Abc12345!"}, expected_error: userInvalidCharErr},
-		{name: "invalid password", input: [3]string{"mail3@gmail.com", "testestestes", "Abc123!"}, expected_error: passMinLengthErr},
+		{name: "invalid password", input: [3]string{"mail3@gmail.com", "testestestes", "Abc123!"}, expected_error: PasswordRulesErr{passMinLengthErr}},
 		{name: "invalid email", input: [3]string{"hiimnotvalid", "testestestestes", "Abc12345!"}, expected_error: invalidEmailErr},
 	}
 
@@ -89,7 +99,7 @@ func TestRegister(t *testing.T) {
 		t.Run(test.name, func(t *testing.T) {
 			registered_user, err := users.RegisterUser(test.input[0], test.input[1], test.input[2])
 
-			if err != test.expected_error {
+			if !reflect.DeepEqual(err, test.expected_error) {
 				t.Fatalf("unexpected error, got %q, expected %q", err, test.expected_error)
 			}
 
@@ -123,6 +133,22 @@ func TestRegister(t *testing.T) {
 
 		assertUsers(t, user, User{})
 	})
+
+	t.Run("existing email in another case", func(t *testing.T) {
+		_, err := users.RegisterUser(" MAIL@Gmail.com", "hiiiiiiiiii", "Abc12345!")
+
+		if err != emailRegisteredErr {
+			t.Fatalf("unexpected error, got %q, expected %q", err, emailRegisteredErr)
+		}
+	})
+
+	t.Run("existing user in another case", func(t *testing.T) {
+		_, err := users.RegisterUser("mail5@gmail.com", "TestEstEstEstEs", "Abc12345!")
+
+		if err != usernameRegisteredErr {
+			t.Fatalf("unexpected error, got %q, expected %q", err, usernameRegisteredErr)
+		}
+	})
 }
 
 func TestGetUser(t *testing.T) {
@@ -140,6 +166,8 @@ func TestGetUser(t *testing.T) {
 		{name: "existing email", input: "mail@gmail.com", expected_user: test_user, expected_error: nil},
 		{name: "non-existing user", input: "idontexist", expected_user: User{}, expected_error: userNotFoundErr},
 		{name: "existing Username", input: "testestsetsetst", expected_user: test_user, expected_error: nil},
+		{name: "email in another case", input: " Mail@GMAIL.com ", expected_user: test_user, expected_error: nil},
+		{name: "Username in another case", input: "TestEstSetSetSt", expected_user: test_user, expected_error: nil},
 	}
 
 	for _, test := range tests {
@@ -210,7 +238,7 @@ func TestLogin(t *testing.T) {
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			logged_user, log_err := LogIn(users, test.input[0], test.input[1])
+			logged_user, log_err := LogIn(users, NewLoginChallenges(), test.input[0], test.input[1])
 
 			if test.expected_error != log_err {
 				t.Fatalf("unexpected error, expected %q, got %q", test.expected_error, log_err)
@@ -226,7 +254,7 @@ func TestLogin(t *testing.T) {
 
 func assertError(t testing.TB, actual_error, expected_error error) {
 	t.Helper()
-	if actual_error != expected_error {
+	if !reflect.DeepEqual(actual_error, expected_error) {
 		t.Errorf("got %q, expected %q", actual_error, expected_error)
 	}
 }
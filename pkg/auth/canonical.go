@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"strings"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Users are looked up by a canonical form of their email and username so
+// "Test@Mail.com" and "test@mail.com" are the same account. The form the user
+// typed is what the User keeps and shows.
+
+// fold trims s and folds its case. Text is put in NFC before and after, as
+// folding can leave decomposed characters.
+func fold(s string) string {
+	s = norm.NFC.String(strings.TrimSpace(s))
+	return norm.NFC.String(cases.Fold().String(s))
+}
+
+// CanonicalUsername is the form usernames are unique in.
+func CanonicalUsername(username string) string {
+	return fold(username)
+}
+
+// CanonicalEmail is the form emails are unique in. The local part is case
+// folded like a username, the domain is only lowercased as domains have no
+// other case rules.
+func CanonicalEmail(email string) string {
+	email = norm.NFC.String(strings.TrimSpace(email))
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return fold(email)
+	}
+	return fold(email[:at]) + "@" + strings.ToLower(email[at+1:])
+}
+
+// displayForm is how an email or username is kept on the User: as typed,
+// without surrounding spaces and with accents composed the same way.
+func displayForm(s string) string {
+	return norm.NFC.String(strings.TrimSpace(s))
+}
+
+func (users UserDatabase) add(user *User) {
+	users.UsersByEmail[CanonicalEmail(user.Email)] = user
+	users.UsersByUsername[CanonicalUsername(user.Username)] = user
+}
+
+func (users UserDatabase) remove(user *User) {
+	delete(users.UsersByEmail, CanonicalEmail(user.Email))
+	delete(users.UsersByUsername, CanonicalUsername(user.Username))
+}
@@ -0,0 +1,47 @@
+package auth
+
+import "testing"
+
+func TestCanonicalForms(t *testing.T) {
+	tests := []struct {
+		name      string
+		canonical func(string) string
+		input     string
+		expected  string
+	}{
+		{name: "username case", canonical: CanonicalUsername, input: "TestUser", expected: "testuser"},
+		{name: "username spaces", canonical: CanonicalUsername, input: " tester\n", expected: "tester"},
+		{name: "email case", canonical: CanonicalEmail, input: "Test@Mail.COM", expected: "test@mail.com"},
+		{name: "email spaces", canonical: CanonicalEmail, input: "  test@mail.com ", expected: "test@mail.com"},
+		{name: "email folded local part", canonical: CanonicalEmail, input: "STRASSE@mail.com", expected: "strasse@mail.com"},
+		{name: "email full case folding", canonical: CanonicalEmail, input: "Straße@mail.com", expected: "strasse@mail.com"},
+		{name: "email composed accent", canonical: CanonicalEmail, input: "José@mail.com", expected: "josé@mail.com"},
+		{name: "email decomposed accent", canonical: CanonicalEmail, input: "José@mail.com", expected: "josé@mail.com"},
+		{name: "not an email", canonical: CanonicalEmail, input: "Tester", expected: "tester"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := test.canonical(test.input)
+
+			if got != test.expected {
+				t.Errorf("got %q, expected %q", got, test.expected)
+			}
+		})
+	}
+}
+
+func TestRegisterKeepsDisplayForm(t *testing.T) {
+	users := UserDatabase{UsersByEmail: map[string]*User{}, UsersByUsername: map[string]*User{}}
+
+	user, err := users.RegisterUser(" José@Mail.com", "TestUser", "Abc12345!")
+
+	assertError(t, err, nil)
+	if user.Email != "José@Mail.com" || user.Username != "TestUser" {
+		t.Errorf("expected the display form to be kept, got %q and %q", user.Email, user.Username)
+	}
+	_, err = users.RegisterUser("josé@mail.com", "other", "Abc12345!")
+	assertError(t, err, emailRegisteredErr)
+	_, err = LogIn(users, NewLoginChallenges(), "JOSÉ@mail.com", "Abc12345!")
+	assertError(t, err, nil)
+}
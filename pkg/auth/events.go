@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"time"
+	"todo_app/pkg/audit"
+)
+
+var eventSink audit.Sink
+
+// UseEventSink sets where security events are recorded, by default nowhere.
+// An event that cannot be recorded never stops what it is about.
+func UseEventSink(sink audit.Sink) {
+	eventSink = sink
+}
+
+func recordEvent(event audit.Event) {
+	if eventSink == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	eventSink.Record(event)
+}
+
+// recordUserEvent records something that happened to user, who may be nil
+// when login matched nobody.
+func recordUserEvent(eventType audit.EventType, user *User, login, source, reason string, now time.Time) {
+	event := audit.Event{Time: now, Type: eventType, Login: login, Source: source, Reason: reason}
+	if user != nil {
+		event.UserId = user.Id
+		event.Username = user.Username
+	}
+	recordEvent(event)
+}
+
+// recordLogin records a successful login when err is nil, or a failed one
+// with the reason of err.
+func recordLogin(user *User, login, source string, err error, now time.Time) {
+	if err == nil {
+		recordUserEvent(audit.LoginSuccess, user, login, source, "", now)
+		return
+	}
+	recordUserEvent(audit.LoginFailure, user, login, source, failureReason(err), now)
+}
+
+func failureReason(err error) string {
+	switch err {
+	case userNotFoundErr:
+		return "unknown_user"
+	case wrongPasswordErr:
+		return "wrong_password"
+	case wrongCodeErr:
+		return "wrong_code"
+	case accountDisabledErr:
+		return "account_disabled"
+	case passwordResetForcedErr:
+		return "password_reset_required"
+	case noLinkedAccountErr:
+		return "no_linked_account"
+	}
+	switch err.(type) {
+	case VerificationErr:
+		return "email_not_verified"
+	case LockedErr:
+		return "locked"
+	}
+	return err.Error()
+}
@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"testing"
+	"time"
+	"todo_app/pkg/audit"
+)
+
+func TestLoginEvents(t *testing.T) {
+	events := eventTestSink(t)
+	users := UserDatabase{UsersByEmail: map[string]*User{}, UsersByUsername: map[string]*User{}}
+	tester, _ := users.RegisterUser("mail@gmail.com", "tester", "Abc12345!")
+	start := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	throttle := NewLoginThrottle()
+	throttle.Account = ThrottlePolicy{FreeFailures: 2, MaxFailures: 2, BaseDelay: time.Second, Lockout: time.Minute}
+	throttle.Now = (&fakeClock{now: start}).Now
+
+	throttle.LogIn(users, "nobody", "Abc12345!", "a")
+	throttle.LogIn(users, "mail@gmail.com", "Abc12345!", "a")
+	throttle.LogIn(users, "tester", "wrong", "a")
+	throttle.LogIn(users, "tester", "wrong", "a")
+	throttle.LogIn(users, "tester", "Abc12345!", "a")
+
+	expected := []audit.Event{
+		{Type: audit.Register, UserId: tester.Id, Username: "tester"},
+		{Time: start, Type: audit.LoginFailure, Login: "nobody", Source: "a", Reason: "unknown_user"},
+		{Time: start, Type: audit.LoginSuccess, UserId: tester.Id, Username: "tester", Login: "mail@gmail.com", Source: "a"},
+		{Time: start, Type: audit.LoginFailure, UserId: tester.Id, Username: "tester", Login: "tester", Source: "a", Reason: "wrong_password"},
+		{Time: start, Type: audit.LoginFailure, UserId: tester.Id, Username: "tester", Login: "tester", Source: "a", Reason: "wrong_password"},
+		{Time: start, Type: audit.Lockout, UserId: tester.Id, Username: "tester", Login: "tester", Source: "a", Reason: "account"},
+		{Time: start, Type: audit.LoginFailure, UserId: tester.Id, Username: "tester", Login: "tester", Source: "a", Reason: "locked"},
+	}
+	got := events.Events()
+	if len(got) != len(expected) {
+		t.Fatalf("got %v, expected %v", got, expected)
+	}
+	// registration is recorded at the real time
+	got[0].Time = time.Time{}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("event %d: got %v, expected %v", i, got[i], expected[i])
+		}
+	}
+}
+
+func TestPasswordChangeEvent(t *testing.T) {
+	events := eventTestSink(t)
+	users := accountTestUsers(t)
+
+	_, err := ChangePassword(users, nil, "tester", "Abc12345!", "Xyz12345!")
+	assertError(t, err, nil)
+
+	got := events.Events()
+	last := got[len(got)-1]
+	if last.Type != audit.PasswordChange || last.UserId != users.UsersByUsername["tester"].Id {
+		t.Errorf("expected a password change of tester, got %v", last)
+	}
+}
+
+//helpers
+
+func eventTestSink(t testing.TB) *audit.Memory {
+	t.Helper()
+	events := &audit.Memory{}
+	UseEventSink(events)
+	t.Cleanup(func() { UseEventSink(nil) })
+	return events
+}
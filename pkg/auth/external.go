@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"strconv"
+	"strings"
+	"time"
+	"todo_app/pkg/audit"
+)
+
+const (
+	externalSource             = "oidc"
+	unverifiedExternalEmailErr = VerificationErr("Your identity provider has not verified your email, so it cannot be used to log in here")
+	unverifiedLocalAccountErr  = VerificationErr("The account with your email has not verified it, please verify it before logging in through your identity provider")
+	noLinkedAccountErr         = UserErr("No account uses the email of your identity provider, please register first")
+	invalidIdentityErr         = UserErr("Identity provider did not say who you are")
+)
+
+// Identity is an account at an external identity provider, linked to a User
+// so logging in there logs in as the User.
+type Identity struct {
+	Issuer  string
+	Subject string
+}
+
+// ExternalIdentity is who an identity provider says logged in, from a
+// verified ID token.
+type ExternalIdentity struct {
+	Identity
+	Email         string
+	EmailVerified bool
+	// Username is the username the provider suggests, if any
+	Username string
+}
+
+// LogInExternal logs in the user linked to identity. An identity logging in
+// for the first time is linked to the user with its email, as long as the
+// provider verified it and so did the user, or when provision is true a new
+// user is made for it. An account whose email is not verified is not linked,
+// whoever registered it may not own the email. Users made this way have no
+// password until they reset one. Two-factor authentication is left to the
+// provider. The caller saves the users.
+func LogInExternal(users UserDatabase, identity ExternalIdentity, provision bool) (User, error) {
+	return logInExternal(users, identity, provision, time.Now())
+}
+
+func logInExternal(users UserDatabase, identity ExternalIdentity, provision bool, now time.Time) (User, error) {
+	if identity.Issuer == "" || identity.Subject == "" {
+		return User{}, invalidIdentityErr
+	}
+	user := users.getUserByIdentity(identity.Identity)
+	if user == nil {
+		var err error
+		user, err = users.linkIdentity(identity, provision, now)
+		if err != nil {
+			recordUserEvent(audit.LoginFailure, nil, identity.Email, externalSource, failureReason(err), now)
+			return User{}, err
+		}
+	}
+	err := CanLogIn(*user)
+	recordLogin(user, identity.Email, externalSource, err, now)
+	if err != nil {
+		return User{}, err
+	}
+	return *user, nil
+}
+
+func (users UserDatabase) getUserByIdentity(identity Identity) *User {
+	for _, user := range users.UsersByUsername {
+		for _, linked := range user.Identities {
+			if linked == identity {
+				return user
+			}
+		}
+	}
+	return nil
+}
+
+func (users UserDatabase) linkIdentity(identity ExternalIdentity, provision bool, now time.Time) (*User, error) {
+	if !identity.EmailVerified || validateEmail(identity.Email) != nil {
+		return nil, unverifiedExternalEmailErr
+	}
+	user, found := users.UsersByEmail[CanonicalEmail(identity.Email)]
+	if found {
+		if !user.Verified {
+			return nil, unverifiedLocalAccountErr
+		}
+		user.Identities = append(user.Identities, identity.Identity)
+		return user, nil
+	}
+	if !provision {
+		return nil, noLinkedAccountErr
+	}
+	newUser := User{
+		Email:      displayForm(identity.Email),
+		Username:   users.availableUsername(identity),
+		Verified:   true,
+		Identities: []Identity{identity.Identity},
+	}
+	generateUUID(&newUser)
+	users.add(&newUser)
+	recordUserEvent(audit.Register, &newUser, identity.Email, externalSource, "", now)
+	return &newUser, nil
+}
+
+// availableUsername makes a valid username no one has from the one suggested
+// by the provider, or the local part of the email.
+func (users UserDatabase) availableUsername(identity ExternalIdentity) string {
+	suggested := identity.Username
+	if suggested == "" {
+		suggested = identity.Email[:strings.LastIndex(identity.Email, "@")]
+	}
+	var base strings.Builder
+	for _, r := range suggested {
+		if userRegex.MatchString(string(r)) {
+			base.WriteRune(r)
+		}
+	}
+	username := base.String()
+	if len(username) < usernameMinLength {
+		username = "user" + username
+	}
+	if len(username) > usernameMaxLength {
+		username = username[:usernameMaxLength]
+	}
+	candidate := username
+	for i := 2; ; i++ {
+		if _, taken := users.UsersByUsername[CanonicalUsername(candidate)]; !taken {
+			return candidate
+		}
+		suffix := strconv.Itoa(i)
+		candidate = username[:min(len(username), usernameMaxLength-len(suffix))] + suffix
+	}
+}
@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"testing"
+	"todo_app/pkg/audit"
+)
+
+func TestLogInExternal(t *testing.T) {
+	users := accountTestUsers(t)
+	users.UsersByUsername["other"].Verified = true
+	sso := Identity{Issuer: "https://sso.example.com", Subject: "1234"}
+	tests := []struct {
+		name           string
+		identity       ExternalIdentity
+		provision      bool
+		expected       string
+		expected_error error
+	}{
+		{name: "no subject", identity: ExternalIdentity{Identity: Identity{Issuer: sso.Issuer}, Email: "mail@gmail.com", EmailVerified: true}, expected_error: invalidIdentityErr},
+		{name: "unverified email", identity: ExternalIdentity{Identity: sso, Email: "Other@gmail.com"}, expected_error: unverifiedExternalEmailErr},
+		{name: "no account with the email", identity: ExternalIdentity{Identity: sso, Email: "new@gmail.com", EmailVerified: true}, expected_error: noLinkedAccountErr},
+		{name: "link by email", identity: ExternalIdentity{Identity: sso, Email: "Other@gmail.com", EmailVerified: true}, expected: "other", expected_error: nil},
+		{name: "linked identity", identity: ExternalIdentity{Identity: sso, Email: "changed@gmail.com"}, expected: "other", expected_error: nil},
+		{name: "same subject at another issuer", identity: ExternalIdentity{Identity: Identity{Issuer: "https://evil.example", Subject: "1234"}}, expected_error: unverifiedExternalEmailErr},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := LogInExternal(users, test.identity, test.provision)
+
+			assertError(t, err, test.expected_error)
+			if got.Username != test.expected {
+				t.Errorf("got %q, expected %q", got.Username, test.expected)
+			}
+		})
+	}
+	// the password of a verified account keeps working
+	_, err := LogIn(users, NewLoginChallenges(), "other", "Abc12345!")
+	assertError(t, err, nil)
+}
+
+func TestLogInExternalUnverifiedAccount(t *testing.T) {
+	users := accountTestUsers(t)
+	squatter := users.UsersByUsername["tester"]
+	squatter.TOTPSecret = "JBSWY3DPEHPK3PXP"
+
+	got, err := LogInExternal(users, ExternalIdentity{Identity: Identity{Issuer: "https://sso.example.com", Subject: "1234"}, Email: "mail@gmail.com", EmailVerified: true}, false)
+	assertError(t, err, unverifiedLocalAccountErr)
+
+	if got.Username != "" || len(squatter.Identities) != 0 || squatter.Verified || !squatter.HasTOTP() {
+		t.Errorf("expected tester to be left as it was, got %v", squatter)
+	}
+	_, err = LogIn(users, NewLoginChallenges(), "tester", "Abc12345!")
+	if err == wrongPasswordErr {
+		t.Errorf("expected the password of tester to be kept")
+	}
+}
+
+func TestLogInExternalProvision(t *testing.T) {
+	events := eventTestSink(t)
+	users := accountTestUsers(t)
+	tests := []struct {
+		name     string
+		identity ExternalIdentity
+		expected string
+	}{
+		{name: "suggested username", identity: ExternalIdentity{Email: "new@gmail.com", Username: "newcomer"}, expected: "newcomer"},
+		{name: "taken username", identity: ExternalIdentity{Email: "new2@gmail.com", Username: "Tester"}, expected: "Tester2"},
+		{name: "from the email", identity: ExternalIdentity{Email: "jane.doe+todo@gmail.com"}, expected: "jane.doetodo"},
+		{name: "short username", identity: ExternalIdentity{Email: "new3@gmail.com", Username: "j d"}, expected: "userjd"},
+		{name: "long username", identity: ExternalIdentity{Email: "new4@gmail.com", Username: "a_very_long_username"}, expected: "a_very_long_usern"},
+		{name: "long taken username", identity: ExternalIdentity{Email: "new5@gmail.com", Username: "a_very_long_username"}, expected: "a_very_long_user2"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.identity.Identity = Identity{Issuer: "https://sso.example.com", Subject: test.name}
+			test.identity.EmailVerified = true
+
+			got, err := LogInExternal(users, test.identity, true)
+			assertError(t, err, nil)
+
+			if got.Username != test.expected || !got.Verified || got.Password != "" {
+				t.Errorf("got %v, expected a verified user without a password called %q", got, test.expected)
+			}
+			if users.UsersByEmail[CanonicalEmail(test.identity.Email)] == nil {
+				t.Errorf("expected the user to be added")
+			}
+		})
+	}
+	got := events.Events()
+	if got[len(got)-2].Type != audit.Register || got[len(got)-1].Type != audit.LoginSuccess || got[len(got)-1].Source != "oidc" {
+		t.Errorf("expected a registration and login through oidc, got %v", got[len(got)-2:])
+	}
+}
+
+func TestLogInExternalDisabled(t *testing.T) {
+	users, admin := adminTestUsers(t)
+	users.UsersByUsername["tester"].Verified = true
+	sso := ExternalIdentity{Identity: Identity{Issuer: "https://sso.example.com", Subject: "1234"}, Email: "mail@gmail.com", EmailVerified: true}
+	LogInExternal(users, sso, false)
+	SetDisabled(users, NewSessions(DefaultSessionIdleTimeout, DefaultSessionMaxLifetime), admin, "tester", true)
+
+	_, err := LogInExternal(users, sso, false)
+
+	assertError(t, err, accountDisabledErr)
+}
@@ -0,0 +1,194 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	invalidHasherErr   = PolicyErr("Password hasher must be bcrypt or argon2id")
+	invalidBcryptErr   = PolicyErr("bcrypt cost must be between 4 and 31")
+	invalidArgon2Err   = PolicyErr("argon2id needs a time, memory, threads, key and salt length of at least 1")
+	unknownHashErr     = PasswordErr("Stored password hash is in an unknown format")
+	argon2idPrefix     = "$argon2id$"
+	argon2idHashFields = 6
+)
+
+const DefaultBcryptCost = 12
+
+// PasswordHasher turns passwords into the hashes kept in users.csv. Every
+// hasher can check hashes of the other algorithms, so users keep logging in
+// when the hasher changes and their hash is upgraded the next time they do.
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	// Outdated tells whether hash should be made again with this hasher,
+	// because it uses another algorithm or weaker parameters
+	Outdated(hash string) bool
+}
+
+// BcryptHasher hashes with bcrypt, Cost is its work factor.
+type BcryptHasher struct {
+	Cost int
+}
+
+// Argon2idHasher hashes with Argon2id, Memory is in KiB.
+type Argon2idHasher struct {
+	Time       uint32
+	Memory     uint32
+	Threads    uint8
+	KeyLength  uint32
+	SaltLength uint32
+}
+
+var (
+	DefaultBcryptHasher = BcryptHasher{Cost: DefaultBcryptCost}
+	// DefaultArgon2idHasher follows the second recommended option of RFC
+	// 9106, for machines with less memory
+	DefaultArgon2idHasher = Argon2idHasher{Time: 3, Memory: 64 * 1024, Threads: 4, KeyLength: 32, SaltLength: 16}
+)
+
+var (
+	hasherNames                   = []string{"bcrypt", "argon2id"}
+	passwordHasher PasswordHasher = DefaultBcryptHasher
+)
+
+// ParsePasswordHasher reads a hasher by name: bcrypt or argon2id, with its
+// default parameters.
+func ParsePasswordHasher(value string) (PasswordHasher, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case hasherNames[0]:
+		return DefaultBcryptHasher, nil
+	case hasherNames[1]:
+		return DefaultArgon2idHasher, nil
+	}
+	return nil, invalidHasherErr
+}
+
+// UsePasswordHasher sets how new passwords are hashed, by default with
+// DefaultBcryptHasher.
+func UsePasswordHasher(hasher PasswordHasher) error {
+	var err error
+	switch hasher := hasher.(type) {
+	case BcryptHasher:
+		err = hasher.validate()
+	case Argon2idHasher:
+		err = hasher.validate()
+	case nil:
+		err = invalidHasherErr
+	}
+	if err != nil {
+		return err
+	}
+	passwordHasher = hasher
+	return nil
+}
+
+func (hasher BcryptHasher) validate() error {
+	if hasher.Cost < bcrypt.MinCost || hasher.Cost > bcrypt.MaxCost {
+		return invalidBcryptErr
+	}
+	return nil
+}
+
+func (hasher BcryptHasher) Hash(password string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), hasher.Cost)
+	return string(hashed), err
+}
+
+func (hasher BcryptHasher) Outdated(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost < hasher.Cost
+}
+
+func (hasher Argon2idHasher) validate() error {
+	if hasher.Time < 1 || hasher.Memory < 1 || hasher.Threads < 1 || hasher.KeyLength < 1 || hasher.SaltLength < 1 {
+		return invalidArgon2Err
+	}
+	return nil
+}
+
+// Hash encodes the parameters, salt and key like the reference
+// implementation: $argon2id$v=19$m=65536,t=3,p=4$salt$key
+func (hasher Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, hasher.SaltLength)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, hasher.Time, hasher.Memory, hasher.Threads, hasher.KeyLength)
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2idPrefix, argon2.Version, hasher.Memory, hasher.Time, hasher.Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (hasher Argon2idHasher) Outdated(hash string) bool {
+	stored, _, key, err := decodeArgon2id(hash)
+	return err != nil || stored.Time < hasher.Time || stored.Memory < hasher.Memory ||
+		stored.Threads < hasher.Threads || uint32(len(key)) < hasher.KeyLength
+}
+
+// decodeArgon2id reads back the parameters, salt and key of a hash made by
+// Argon2idHasher.
+func decodeArgon2id(hash string) (Argon2idHasher, []byte, []byte, error) {
+	fields := strings.Split(hash, "$")
+	if !strings.HasPrefix(hash, argon2idPrefix) || len(fields) != argon2idHashFields {
+		return Argon2idHasher{}, nil, nil, unknownHashErr
+	}
+	var version int
+	var hasher Argon2idHasher
+	_, err := fmt.Sscanf(fields[2], "v=%d", &version)
+	if err != nil || version != argon2.Version {
+		return Argon2idHasher{}, nil, nil, unknownHashErr
+	}
+	_, err = fmt.Sscanf(fields[3], "m=%d,t=%d,p=%d", &hasher.Memory, &hasher.Time, &hasher.Threads)
+	if err != nil {
+		return Argon2idHasher{}, nil, nil, unknownHashErr
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(fields[4])
+	if err != nil {
+		return Argon2idHasher{}, nil, nil, unknownHashErr
+	}
+	key, err := base64.RawStdEncoding.DecodeString(fields[5])
+	if err != nil || len(key) == 0 {
+		return Argon2idHasher{}, nil, nil, unknownHashErr
+	}
+	hasher.SaltLength = uint32(len(salt))
+	hasher.KeyLength = uint32(len(key))
+	return hasher, salt, key, nil
+}
+
+func hashPassword(password string) (string, error) {
+	return passwordHasher.Hash(password)
+}
+
+// comparePassword tells whether password is the one hashed, whichever
+// algorithm made the hash.
+func comparePassword(hash, password string) bool {
+	if strings.HasPrefix(hash, argon2idPrefix) {
+		stored, salt, key, err := decodeArgon2id(hash)
+		if err != nil {
+			return false
+		}
+		other := argon2.IDKey([]byte(password), salt, stored.Time, stored.Memory, stored.Threads, stored.KeyLength)
+		return subtle.ConstantTimeCompare(key, other) == 1
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// rehashPassword upgrades the hash of user, who just proved to know
+// password, when the current hasher considers it outdated. A failed upgrade
+// keeps the old hash, which still works.
+func rehashPassword(user *User, password string) {
+	if !passwordHasher.Outdated(user.Password) {
+		return
+	}
+	hashed, err := hashPassword(password)
+	if err == nil {
+		user.Password = hashed
+	}
+}
@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParsePasswordHasher(t *testing.T) {
+	tests := []struct {
+		name           string
+		input          string
+		expected       PasswordHasher
+		expected_error error
+	}{
+		{name: "bcrypt", input: "bcrypt", expected: DefaultBcryptHasher, expected_error: nil},
+		{name: "argon2id in another case", input: " Argon2id ", expected: DefaultArgon2idHasher, expected_error: nil},
+		{name: "unknown hasher", input: "md5", expected: nil, expected_error: invalidHasherErr},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParsePasswordHasher(test.input)
+
+			assertError(t, err, test.expected_error)
+			if got != test.expected {
+				t.Errorf("got %v, expected %v", got, test.expected)
+			}
+		})
+	}
+}
+
+func TestUsePasswordHasher(t *testing.T) {
+	t.Cleanup(func() { UsePasswordHasher(testHasher) })
+	tests := []struct {
+		name           string
+		hasher         PasswordHasher
+		expected_error error
+	}{
+		{name: "no hasher", hasher: nil, expected_error: invalidHasherErr},
+		{name: "bcrypt cost too low", hasher: BcryptHasher{Cost: 3}, expected_error: invalidBcryptErr},
+		{name: "bcrypt cost too high", hasher: BcryptHasher{Cost: 32}, expected_error: invalidBcryptErr},
+		{name: "argon2id without memory", hasher: Argon2idHasher{Time: 1, Threads: 1, KeyLength: 32, SaltLength: 16}, expected_error: invalidArgon2Err},
+		{name: "valid bcrypt", hasher: BcryptHasher{Cost: 10}, expected_error: nil},
+		{name: "valid argon2id", hasher: DefaultArgon2idHasher, expected_error: nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := UsePasswordHasher(test.hasher)
+
+			assertError(t, err, test.expected_error)
+		})
+	}
+}
+
+func TestPasswordHashers(t *testing.T) {
+	for _, hasher := range []PasswordHasher{BcryptHasher{Cost: 4}, hashTestArgon2id()} {
+		hash, err := hasher.Hash("Abc12345!")
+		assertError(t, err, nil)
+
+		if !comparePassword(hash, "Abc12345!") {
+			t.Errorf("expected %q to match its password", hash)
+		}
+		if comparePassword(hash, "Abc12345?") {
+			t.Errorf("expected %q not to match another password", hash)
+		}
+		if hasher.Outdated(hash) {
+			t.Errorf("expected %q to be up to date with the hasher that made it", hash)
+		}
+	}
+	for _, hash := range []string{"", "$argon2id$v=19$m=8,t=1,p=1$c2FsdA", "$argon2id$v=16$m=8,t=1,p=1$c2FsdA$a2V5"} {
+		if comparePassword(hash, "Abc12345!") {
+			t.Errorf("expected malformed hash %q not to match", hash)
+		}
+	}
+}
+
+func TestOutdatedHash(t *testing.T) {
+	weakBcrypt, _ := BcryptHasher{Cost: 4}.Hash("Abc12345!")
+	weakArgon2id, _ := hashTestArgon2id().Hash("Abc12345!")
+	stronger := hashTestArgon2id()
+	stronger.Time++
+	tests := []struct {
+		name     string
+		hasher   PasswordHasher
+		hash     string
+		expected bool
+	}{
+		{name: "bcrypt with a lower cost", hasher: BcryptHasher{Cost: 5}, hash: weakBcrypt, expected: true},
+		{name: "bcrypt with a higher cost", hasher: BcryptHasher{Cost: 4}, hash: weakBcrypt, expected: false},
+		{name: "argon2id under bcrypt", hasher: BcryptHasher{Cost: 4}, hash: weakArgon2id, expected: true},
+		{name: "bcrypt under argon2id", hasher: hashTestArgon2id(), hash: weakBcrypt, expected: true},
+		{name: "argon2id with fewer passes", hasher: stronger, hash: weakArgon2id, expected: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := test.hasher.Outdated(test.hash)
+
+			if got != test.expected {
+				t.Errorf("got %v, expected %v", got, test.expected)
+			}
+		})
+	}
+}
+
+func TestRehashOnLogIn(t *testing.T) {
+	t.Cleanup(func() { UsePasswordHasher(testHasher) })
+	UsePasswordHasher(BcryptHasher{Cost: 4})
+	users := UserDatabase{UsersByEmail: map[string]*User{}, UsersByUsername: map[string]*User{}}
+	_, err := users.RegisterUser("mail@gmail.com", "tester", "Abc12345!")
+	assertError(t, err, nil)
+	UsePasswordHasher(hashTestArgon2id())
+
+	_, err = LogIn(users, NewLoginChallenges(), "tester", "Wrong123!")
+	assertError(t, err, wrongPasswordErr)
+	if strings.HasPrefix(users.UsersByUsername["tester"].Password, argon2idPrefix) {
+		t.Error("expected a wrong password to keep the old hash")
+	}
+	user, err := LogIn(users, NewLoginChallenges(), "tester", "Abc12345!")
+	assertError(t, err, nil)
+
+	if !strings.HasPrefix(user.Password, argon2idPrefix) || users.UsersByUsername["tester"].Password != user.Password {
+		t.Errorf("expected the hash to be upgraded to argon2id, got %q", user.Password)
+	}
+	_, err = LogIn(users, NewLoginChallenges(), "tester", "Abc12345!")
+	assertError(t, err, nil)
+}
+
+//helpers
+
+// hashTestArgon2id is cheap enough for tests
+func hashTestArgon2id() Argon2idHasher {
+	return Argon2idHasher{Time: 1, Memory: 8, Threads: 1, KeyLength: 16, SaltLength: 8}
+}
@@ -0,0 +1,406 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	AlgorithmHS256          = "HS256"
+	AlgorithmEdDSA          = "EdDSA"
+	DefaultAccessTokenTTL   = 15 * time.Minute
+	DefaultRefreshTokenTTL  = 30 * 24 * time.Hour
+	MinHMACSecretLength     = 32
+	clockLeeway             = 30 * time.Second
+	invalidTokenErr         = TokenErr("Token is malformed or its signature is invalid")
+	expiredTokenErr         = TokenErr("Token has expired")
+	unknownKeyErr           = TokenErr("Token was signed with an unknown key")
+	noSigningKeyErr         = TokenErr("There is no key to sign tokens with")
+	duplicateKeyErr         = TokenErr("A key with that id already exists")
+	unsupportedAlgorithmErr = TokenErr("Signing algorithm is not supported")
+	shortSecretErr          = TokenErr("HS256 secrets must be at least 32 bytes")
+	invalidRefreshTokenErr  = TokenErr("Refresh token is invalid or has expired")
+	refreshTokenReusedErr   = TokenErr("Refresh token was already used, every token issued from it has been revoked")
+)
+
+type TokenErr string
+
+func (e TokenErr) Error() string {
+	return string(e)
+}
+
+// SigningKey signs and verifies JWTs. HS256 keys use Secret for both, EdDSA
+// keys sign with PrivateKey and verify with PublicKey, so a key without its
+// private half can still verify tokens.
+type SigningKey struct {
+	Id         string
+	Algorithm  string
+	Secret     []byte
+	PrivateKey ed25519.PrivateKey
+	PublicKey  ed25519.PublicKey
+}
+
+func NewHMACKey(id string) (SigningKey, error) {
+	secret := make([]byte, MinHMACSecretLength)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return SigningKey{}, err
+	}
+	return SigningKey{Id: id, Algorithm: AlgorithmHS256, Secret: secret}, nil
+}
+
+func NewEd25519Key(id string) (SigningKey, error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return SigningKey{}, err
+	}
+	return SigningKey{Id: id, Algorithm: AlgorithmEdDSA, PrivateKey: private, PublicKey: public}, nil
+}
+
+func (key SigningKey) sign(data []byte) ([]byte, error) {
+	switch key.Algorithm {
+	case AlgorithmHS256:
+		mac := hmac.New(sha256.New, key.Secret)
+		mac.Write(data)
+		return mac.Sum(nil), nil
+	case AlgorithmEdDSA:
+		if len(key.PrivateKey) != ed25519.PrivateKeySize {
+			return nil, noSigningKeyErr
+		}
+		return ed25519.Sign(key.PrivateKey, data), nil
+	}
+	return nil, unsupportedAlgorithmErr
+}
+
+func (key SigningKey) verify(data, signature []byte) bool {
+	switch key.Algorithm {
+	case AlgorithmHS256:
+		expected, _ := key.sign(data)
+		return hmac.Equal(expected, signature)
+	case AlgorithmEdDSA:
+		return len(key.PublicKey) == ed25519.PublicKeySize && ed25519.Verify(key.PublicKey, data, signature)
+	}
+	return false
+}
+
+// KeySet holds the keys tokens are verified with, by id. New tokens are
+// signed with the current key; rotating adds a new current key while older
+// ones keep verifying the tokens they signed until they are removed.
+type KeySet struct {
+	mu      sync.RWMutex
+	current string
+	keys    map[string]SigningKey
+}
+
+func NewKeySet() *KeySet {
+	return &KeySet{keys: make(map[string]SigningKey)}
+}
+
+// Add makes a key available for verification only. HS256 secrets shorter
+// than MinHMACSecretLength are refused, they could be guessed.
+func (keys *KeySet) Add(key SigningKey) error {
+	keys.mu.Lock()
+	defer keys.mu.Unlock()
+	if key.Algorithm != AlgorithmHS256 && key.Algorithm != AlgorithmEdDSA {
+		return unsupportedAlgorithmErr
+	}
+	if key.Algorithm == AlgorithmHS256 && len(key.Secret) < MinHMACSecretLength {
+		return shortSecretErr
+	}
+	if _, found := keys.keys[key.Id]; found {
+		return duplicateKeyErr
+	}
+	keys.keys[key.Id] = key
+	return nil
+}
+
+// Rotate adds a key and signs every new token with it.
+func (keys *KeySet) Rotate(key SigningKey) error {
+	err := keys.Add(key)
+	if err != nil {
+		return err
+	}
+	keys.mu.Lock()
+	defer keys.mu.Unlock()
+	keys.current = key.Id
+	return nil
+}
+
+// Remove retires a key, tokens it signed stop verifying.
+func (keys *KeySet) Remove(id string) {
+	keys.mu.Lock()
+	defer keys.mu.Unlock()
+	delete(keys.keys, id)
+	if keys.current == id {
+		keys.current = ""
+	}
+}
+
+func (keys *KeySet) key(id string) (SigningKey, bool) {
+	keys.mu.RLock()
+	defer keys.mu.RUnlock()
+	key, found := keys.keys[id]
+	return key, found
+}
+
+func (keys *KeySet) currentKey() (SigningKey, error) {
+	keys.mu.RLock()
+	defer keys.mu.RUnlock()
+	key, found := keys.keys[keys.current]
+	if !found {
+		return SigningKey{}, noSigningKeyErr
+	}
+	return key, nil
+}
+
+// Public returns the EdDSA keys without their private half, enough for a
+// service to verify tokens offline without being able to mint them.
+func (keys *KeySet) Public() *KeySet {
+	keys.mu.RLock()
+	defer keys.mu.RUnlock()
+	public := NewKeySet()
+	for id, key := range keys.keys {
+		if key.Algorithm == AlgorithmEdDSA {
+			public.keys[id] = SigningKey{Id: id, Algorithm: AlgorithmEdDSA, PublicKey: key.PublicKey}
+		}
+	}
+	return public
+}
+
+type Claims struct {
+	Subject   string `json:"sub"`
+	Username  string `json:"username,omitempty"`
+	Issuer    string `json:"iss,omitempty"`
+	Id        string `json:"jti"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+type jwtHeader struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ"`
+	KeyId     string `json:"kid"`
+}
+
+// SignJWT signs claims with the current key of keys.
+func SignJWT(keys *KeySet, claims Claims) (string, error) {
+	key, err := keys.currentKey()
+	if err != nil {
+		return "", err
+	}
+	header, err := json.Marshal(jwtHeader{Algorithm: key.Algorithm, Type: "JWT", KeyId: key.Id})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	signature, err := key.sign([]byte(signingInput))
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// VerifyJWT checks the signature of a token against the key named by its kid
+// header and that it has not expired at now. It needs nothing but the keys, so
+// tokens can be verified offline.
+func VerifyJWT(keys *KeySet, token string, now time.Time) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Claims{}, invalidTokenErr
+	}
+	var header jwtHeader
+	err := decodeSegment(parts[0], &header)
+	if err != nil {
+		return Claims{}, invalidTokenErr
+	}
+	key, found := keys.key(header.KeyId)
+	if !found {
+		return Claims{}, unknownKeyErr
+	}
+	// the algorithm comes from our key, never from the token, so a token can't
+	// ask to be checked with a weaker algorithm than the key was made for
+	if header.Algorithm != key.Algorithm {
+		return Claims{}, invalidTokenErr
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !key.verify([]byte(parts[0]+"."+parts[1]), signature) {
+		return Claims{}, invalidTokenErr
+	}
+	var claims Claims
+	err = decodeSegment(parts[1], &claims)
+	if err != nil {
+		return Claims{}, invalidTokenErr
+	}
+	if !now.Before(time.Unix(claims.ExpiresAt, 0)) {
+		return Claims{}, expiredTokenErr
+	}
+	if time.Unix(claims.IssuedAt, 0).After(now.Add(clockLeeway)) {
+		return Claims{}, invalidTokenErr
+	}
+	return claims, nil
+}
+
+func decodeSegment(segment string, value any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, value)
+}
+
+type TokenPair struct {
+	AccessToken    string
+	AccessExpires  time.Time
+	RefreshToken   string
+	RefreshExpires time.Time
+}
+
+// refreshToken is stored under the SHA-256 of the token. Every refresh token
+// descends from one login, its family, so reusing any of them can revoke all.
+type refreshToken struct {
+	UserId  uuid.UUID
+	Family  string
+	Expires time.Time
+	Used    bool
+}
+
+// TokenIssuer mints short lived JWT access tokens and long lived refresh
+// tokens. A refresh token can be exchanged once for a new pair; presenting it
+// again means it leaked, so its whole family is revoked. It is a library for
+// services that verify tokens offline: the API server keeps sessions, which
+// it can end at once, and does not mount one. Attach it to the Sessions of
+// the app with UseTokenIssuer so refresh tokens are revoked with them.
+type TokenIssuer struct {
+	Keys       *KeySet
+	Issuer     string
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+	Now        func() time.Time
+	mu         sync.Mutex
+	refresh    map[string]*refreshToken
+}
+
+func NewTokenIssuer(keys *KeySet, issuer string) *TokenIssuer {
+	return &TokenIssuer{
+		Keys:       keys,
+		Issuer:     issuer,
+		AccessTTL:  DefaultAccessTokenTTL,
+		RefreshTTL: DefaultRefreshTokenTTL,
+		Now:        time.Now,
+		refresh:    make(map[string]*refreshToken),
+	}
+}
+
+// Issue starts a new token family for user, e.g. after LogIn.
+func (issuer *TokenIssuer) Issue(user User) (TokenPair, error) {
+	issuer.mu.Lock()
+	defer issuer.mu.Unlock()
+	return issuer.issue(user, uuid.NewString())
+}
+
+func (issuer *TokenIssuer) issue(user User, family string) (TokenPair, error) {
+	now := issuer.Now()
+	issuer.prune(now)
+	accessExpires := now.Add(issuer.AccessTTL)
+	access, err := SignJWT(issuer.Keys, Claims{
+		Subject:   user.Id.String(),
+		Username:  user.Username,
+		Issuer:    issuer.Issuer,
+		Id:        uuid.NewString(),
+		IssuedAt:  now.Unix(),
+		ExpiresAt: accessExpires.Unix(),
+	})
+	if err != nil {
+		return TokenPair{}, err
+	}
+	secret := make([]byte, 32)
+	_, err = rand.Read(secret)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	refresh := base64.RawURLEncoding.EncodeToString(secret)
+	refreshExpires := now.Add(issuer.RefreshTTL)
+	issuer.refresh[hashToken(refresh)] = &refreshToken{UserId: user.Id, Family: family, Expires: refreshExpires}
+	return TokenPair{AccessToken: access, AccessExpires: time.Unix(accessExpires.Unix(), 0), RefreshToken: refresh, RefreshExpires: refreshExpires}, nil
+}
+
+// Refresh exchanges a refresh token for a new pair in the same family, as
+// long as the user can still log in.
+func (issuer *TokenIssuer) Refresh(users UserDatabase, token string) (TokenPair, error) {
+	issuer.mu.Lock()
+	defer issuer.mu.Unlock()
+	stored, found := issuer.refresh[hashToken(token)]
+	if !found || !issuer.Now().Before(stored.Expires) {
+		return TokenPair{}, invalidRefreshTokenErr
+	}
+	if stored.Used {
+		issuer.revokeFamily(stored.Family)
+		return TokenPair{}, refreshTokenReusedErr
+	}
+	user, err := users.GetUserById(stored.UserId)
+	if err != nil {
+		issuer.revokeFamily(stored.Family)
+		return TokenPair{}, invalidRefreshTokenErr
+	}
+	// a disabled user or one who has to reset their password logs in again
+	err = CanLogIn(*user)
+	if err != nil {
+		issuer.revokeFamily(stored.Family)
+		return TokenPair{}, err
+	}
+	stored.Used = true
+	return issuer.issue(*user, stored.Family)
+}
+
+// Verify checks an access token was issued by this issuer and is still valid.
+func (issuer *TokenIssuer) Verify(token string) (Claims, error) {
+	claims, err := VerifyJWT(issuer.Keys, token, issuer.Now())
+	if err != nil {
+		return Claims{}, err
+	}
+	if claims.Issuer != issuer.Issuer {
+		return Claims{}, invalidTokenErr
+	}
+	return claims, nil
+}
+
+// RevokeUser revokes every refresh token of a user. Access tokens already
+// handed out stay valid until they expire, which is why they are short lived.
+func (issuer *TokenIssuer) RevokeUser(userId uuid.UUID) {
+	issuer.mu.Lock()
+	defer issuer.mu.Unlock()
+	for hash, stored := range issuer.refresh {
+		if stored.UserId == userId {
+			delete(issuer.refresh, hash)
+		}
+	}
+}
+
+func (issuer *TokenIssuer) revokeFamily(family string) {
+	for hash, stored := range issuer.refresh {
+		if stored.Family == family {
+			delete(issuer.refresh, hash)
+		}
+	}
+}
+
+func (issuer *TokenIssuer) prune(now time.Time) {
+	for hash, stored := range issuer.refresh {
+		if !now.Before(stored.Expires) {
+			delete(issuer.refresh, hash)
+		}
+	}
+}
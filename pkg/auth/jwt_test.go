@@ -0,0 +1,264 @@
+package auth
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestVerifyJWT(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	hmacKey, _ := NewHMACKey("hs-1")
+	edKey, _ := NewEd25519Key("ed-1")
+	claims := Claims{Subject: "someone", Id: "1", IssuedAt: now.Unix(), ExpiresAt: now.Add(time.Minute).Unix()}
+
+	for _, key := range []SigningKey{hmacKey, edKey} {
+		t.Run(key.Algorithm, func(t *testing.T) {
+			keys := NewKeySet()
+			keys.Rotate(key)
+			token, err := SignJWT(keys, claims)
+			assertError(t, err, nil)
+			parts := strings.Split(token, ".")
+			tampered := parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"admin","exp":9999999999}`)) + "." + parts[2]
+			tests := []struct {
+				name           string
+				token          string
+				at             time.Time
+				expected_error error
+			}{
+				{name: "valid token", token: token, at: now, expected_error: nil},
+				{name: "expired", token: token, at: now.Add(time.Minute), expected_error: expiredTokenErr},
+				{name: "issued in the future", token: token, at: now.Add(-time.Hour), expected_error: invalidTokenErr},
+				{name: "tampered payload", token: tampered, at: now, expected_error: invalidTokenErr},
+				{name: "missing signature", token: parts[0] + "." + parts[1], at: now, expected_error: invalidTokenErr},
+				{name: "garbage", token: "not.a.jwt", at: now, expected_error: invalidTokenErr},
+			}
+
+			for _, test := range tests {
+				t.Run(test.name, func(t *testing.T) {
+					got, err := VerifyJWT(keys, test.token, test.at)
+
+					assertError(t, err, test.expected_error)
+					if err == nil && got != claims {
+						t.Errorf("got %v, expected %v", got, claims)
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestVerifyJWTAlgorithmMismatch(t *testing.T) {
+	edKey, _ := NewEd25519Key("ed-1")
+	keys := NewKeySet()
+	keys.Rotate(edKey)
+	now := time.Now()
+	token, _ := SignJWT(keys, Claims{Subject: "someone", IssuedAt: now.Unix(), ExpiresAt: now.Add(time.Minute).Unix()})
+
+	// re-sign the same claims as HS256 using the public key as the secret
+	parts := strings.Split(token, ".")
+	forgedKey := SigningKey{Id: "ed-1", Algorithm: AlgorithmHS256, Secret: edKey.PublicKey}
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT","kid":"ed-1"}`))
+	signature, _ := forgedKey.sign([]byte(header + "." + parts[1]))
+	forged := header + "." + parts[1] + "." + base64.RawURLEncoding.EncodeToString(signature)
+
+	_, err := VerifyJWT(keys, forged, now)
+
+	assertError(t, err, invalidTokenErr)
+}
+
+func TestKeyRotation(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	claims := Claims{Subject: "someone", IssuedAt: now.Unix(), ExpiresAt: now.Add(time.Hour).Unix()}
+	oldKey, _ := NewEd25519Key("2024-05")
+	newKey, _ := NewHMACKey("2024-06")
+	keys := NewKeySet()
+	keys.Rotate(oldKey)
+	oldToken, _ := SignJWT(keys, claims)
+
+	assertError(t, keys.Rotate(newKey), nil)
+	newToken, _ := SignJWT(keys, claims)
+
+	t.Run("new tokens use the new key", func(t *testing.T) {
+		var header jwtHeader
+		decodeSegment(strings.Split(newToken, ".")[0], &header)
+		if header.KeyId != newKey.Id || header.Algorithm != AlgorithmHS256 {
+			t.Errorf("got header %v, expected kid %q", header, newKey.Id)
+		}
+	})
+	t.Run("old tokens still verify", func(t *testing.T) {
+		_, err := VerifyJWT(keys, oldToken, now)
+		assertError(t, err, nil)
+	})
+	t.Run("duplicate key id", func(t *testing.T) {
+		assertError(t, keys.Add(oldKey), duplicateKeyErr)
+	})
+	t.Run("short secret", func(t *testing.T) {
+		short := SigningKey{Id: "short", Algorithm: AlgorithmHS256, Secret: []byte("secret")}
+
+		assertError(t, keys.Rotate(short), shortSecretErr)
+	})
+	t.Run("retired key", func(t *testing.T) {
+		keys.Remove(oldKey.Id)
+
+		_, err := VerifyJWT(keys, oldToken, now)
+		assertError(t, err, unknownKeyErr)
+		_, err = VerifyJWT(keys, newToken, now)
+		assertError(t, err, nil)
+	})
+}
+
+func TestPublicKeySet(t *testing.T) {
+	now := time.Now()
+	claims := Claims{Subject: "someone", IssuedAt: now.Unix(), ExpiresAt: now.Add(time.Minute).Unix()}
+	edKey, _ := NewEd25519Key("ed-1")
+	hmacKey, _ := NewHMACKey("hs-1")
+	keys := NewKeySet()
+	keys.Add(hmacKey)
+	keys.Rotate(edKey)
+	token, _ := SignJWT(keys, claims)
+
+	public := keys.Public()
+
+	_, err := VerifyJWT(public, token, now)
+	assertError(t, err, nil)
+	if _, found := public.key(hmacKey.Id); found {
+		t.Error("expected HMAC secrets to be left out of the public keys")
+	}
+	public.current = edKey.Id
+	_, err = SignJWT(public, claims)
+	assertError(t, err, noSigningKeyErr)
+}
+
+func TestRefreshToken(t *testing.T) {
+	users, user := sessionTestUsers()
+	clock := &fakeClock{now: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)}
+	issuer := jwtTestIssuer(t, clock)
+
+	first, err := issuer.Issue(user)
+	assertError(t, err, nil)
+
+	t.Run("access token", func(t *testing.T) {
+		claims, err := issuer.Verify(first.AccessToken)
+
+		assertError(t, err, nil)
+		if claims.Subject != user.Id.String() || claims.Username != user.Username {
+			t.Errorf("got claims %v, expected them to name %v", claims, user)
+		}
+		clock.now = clock.now.Add(issuer.AccessTTL)
+		_, err = issuer.Verify(first.AccessToken)
+		assertError(t, err, expiredTokenErr)
+	})
+
+	var second TokenPair
+	t.Run("rotates on use", func(t *testing.T) {
+		second, err = issuer.Refresh(users, first.RefreshToken)
+
+		assertError(t, err, nil)
+		if second.RefreshToken == first.RefreshToken {
+			t.Error("expected a new refresh token")
+		}
+		_, err = issuer.Verify(second.AccessToken)
+		assertError(t, err, nil)
+	})
+
+	t.Run("reuse revokes the family", func(t *testing.T) {
+		other, _ := issuer.Issue(user)
+
+		_, err := issuer.Refresh(users, first.RefreshToken)
+		assertError(t, err, refreshTokenReusedErr)
+
+		_, err = issuer.Refresh(users, second.RefreshToken)
+		assertError(t, err, invalidRefreshTokenErr)
+		_, err = issuer.Refresh(users, other.RefreshToken)
+		assertError(t, err, nil)
+	})
+}
+
+func TestRefreshTokenExpiry(t *testing.T) {
+	users, user := sessionTestUsers()
+	clock := &fakeClock{now: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)}
+	issuer := jwtTestIssuer(t, clock)
+	pair, _ := issuer.Issue(user)
+	tests := []struct {
+		name           string
+		token          string
+		advance        time.Duration
+		expected_error error
+	}{
+		{name: "unknown token", token: "nope", advance: 0, expected_error: invalidRefreshTokenErr},
+		{name: "expired token", token: pair.RefreshToken, advance: issuer.RefreshTTL, expected_error: invalidRefreshTokenErr},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clock.now = clock.now.Add(test.advance)
+
+			_, err := issuer.Refresh(users, test.token)
+
+			assertError(t, err, test.expected_error)
+		})
+	}
+}
+
+func TestRefreshTokenRevocation(t *testing.T) {
+	users, user := sessionTestUsers()
+	issuer := jwtTestIssuer(t, &fakeClock{now: time.Now()})
+
+	t.Run("revoke user", func(t *testing.T) {
+		pair, _ := issuer.Issue(user)
+
+		issuer.RevokeUser(user.Id)
+
+		_, err := issuer.Refresh(users, pair.RefreshToken)
+		assertError(t, err, invalidRefreshTokenErr)
+	})
+
+	t.Run("deleted user", func(t *testing.T) {
+		pair, _ := issuer.Issue(User{Id: uuid.New(), Username: "gone"})
+
+		_, err := issuer.Refresh(users, pair.RefreshToken)
+		assertError(t, err, invalidRefreshTokenErr)
+	})
+
+	t.Run("user who cannot log in", func(t *testing.T) {
+		pair, _ := issuer.Issue(user)
+		users.UsersByUsername["tester"].PasswordResetRequired = true
+
+		_, err := issuer.Refresh(users, pair.RefreshToken)
+		assertError(t, err, passwordResetForcedErr)
+
+		users.UsersByUsername["tester"].PasswordResetRequired = false
+		_, err = issuer.Refresh(users, pair.RefreshToken)
+		assertError(t, err, invalidRefreshTokenErr)
+	})
+
+	t.Run("with the sessions of the user", func(t *testing.T) {
+		sessions := NewSessions(DefaultSessionIdleTimeout, DefaultSessionMaxLifetime)
+		sessions.UseTokenIssuer(issuer)
+		pair, _ := issuer.Issue(user)
+
+		sessions.RevokeAll(user.Id)
+
+		_, err := issuer.Refresh(users, pair.RefreshToken)
+		assertError(t, err, invalidRefreshTokenErr)
+	})
+}
+
+//helpers
+
+func jwtTestIssuer(t *testing.T, clock *fakeClock) *TokenIssuer {
+	t.Helper()
+	key, err := NewEd25519Key("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := NewKeySet()
+	keys.Rotate(key)
+	issuer := NewTokenIssuer(keys, "todo_app")
+	issuer.Now = clock.Now
+	return issuer
+}
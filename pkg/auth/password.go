@@ -0,0 +1,267 @@
+package auth
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// bcrypt refuses passwords longer than this many bytes
+	maxPasswordBytes   = 72
+	passPersonalErr    = PasswordErr("Password cannot contain your username or email")
+	passCommonErr      = PasswordErr("Password is too common, please choose another one")
+	passNoLowerErr     = PasswordErr("Password must contain at least one lowercase letter")
+	passNoUpperErr     = PasswordErr("Password must contain at least one uppercase letter")
+	passNoDigitErr     = PasswordErr("Password must contain at least one number")
+	invalidMinLenErr   = PolicyErr("Password policy needs a minimum length of at least 1")
+	invalidMaxLenErr   = PolicyErr("Password policy maximum length must be 0 or between the minimum length and 72")
+	noSymbolsErr       = PolicyErr("Password policy requires a symbol but allows none")
+	invalidRepeatedErr = PolicyErr("Password policy maximum repeated characters cannot be negative")
+)
+
+// PasswordPolicy is what passwords must look like when they are set, on
+// registration, reset or change. Passwords set before keep working.
+type PasswordPolicy struct {
+	MinLength int
+	// MaxLength of 0 means up to 72 bytes, the most bcrypt reads
+	MaxLength     int
+	RequireLower  bool
+	RequireUpper  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	// Symbols are the characters that count as symbols
+	Symbols string
+	// MaxRepeated is how many times in a row a character can appear, 0 for
+	// no limit
+	MaxRepeated int
+	// RejectPersonal refuses passwords containing the username or the part
+	// of the email before the @
+	RejectPersonal bool
+	Blocklist      Blocklist
+}
+
+var (
+	DefaultPasswordPolicy = PasswordPolicy{
+		MinLength:      8,
+		MaxLength:      64,
+		RequireLower:   true,
+		RequireUpper:   true,
+		RequireDigit:   true,
+		RequireSymbol:  true,
+		Symbols:        "@$!%*?&",
+		MaxRepeated:    3,
+		RejectPersonal: true,
+	}
+	passwordPolicy = DefaultPasswordPolicy
+)
+
+type PolicyErr string
+
+func (e PolicyErr) Error() string {
+	return string(e)
+}
+
+// PasswordRulesErr lists every rule of the password policy a password broke.
+type PasswordRulesErr []PasswordErr
+
+func (e PasswordRulesErr) Error() string {
+	rules := make([]string, len(e))
+	for i, rule := range e {
+		rules[i] = string(rule)
+	}
+	return strings.Join(rules, "; ")
+}
+
+// UsePasswordPolicy sets the policy new passwords are checked against, by
+// default DefaultPasswordPolicy.
+func UsePasswordPolicy(policy PasswordPolicy) error {
+	err := policy.validate()
+	if err != nil {
+		return err
+	}
+	passwordPolicy = policy
+	return nil
+}
+
+// CurrentPasswordPolicy is the policy set with UsePasswordPolicy, e.g. to
+// describe it to users.
+func CurrentPasswordPolicy() PasswordPolicy {
+	return passwordPolicy
+}
+
+func (policy PasswordPolicy) validate() error {
+	if policy.MinLength < 1 {
+		return invalidMinLenErr
+	}
+	if policy.MaxLength != 0 && (policy.MaxLength < policy.MinLength || policy.MaxLength > maxPasswordBytes) {
+		return invalidMaxLenErr
+	}
+	if policy.RequireSymbol && policy.Symbols == "" {
+		return noSymbolsErr
+	}
+	if policy.MaxRepeated < 0 {
+		return invalidRepeatedErr
+	}
+	return nil
+}
+
+func (policy PasswordPolicy) maxLength() int {
+	if policy.MaxLength == 0 {
+		return maxPasswordBytes
+	}
+	return policy.MaxLength
+}
+
+// Validate checks password against every rule of the policy, username and
+// email are those of the user setting it. It returns a PasswordRulesErr with
+// all the rules that failed.
+func (policy PasswordPolicy) Validate(password, username, email string) error {
+	var failed PasswordRulesErr
+	length := utf8.RuneCountInString(password)
+	if length < policy.MinLength {
+		failed = append(failed, PasswordErr(fmt.Sprintf("Password must have at least %d characters", policy.MinLength)))
+	}
+	if length > policy.maxLength() || len(password) > maxPasswordBytes {
+		failed = append(failed, PasswordErr(fmt.Sprintf("Password must have at most %d characters", policy.maxLength())))
+	}
+	var lower, upper, digit, symbol bool
+	for _, char := range password {
+		lower = lower || unicode.IsLower(char)
+		upper = upper || unicode.IsUpper(char)
+		digit = digit || unicode.IsDigit(char)
+		symbol = symbol || strings.ContainsRune(policy.Symbols, char)
+	}
+	if policy.RequireLower && !lower {
+		failed = append(failed, passNoLowerErr)
+	}
+	if policy.RequireUpper && !upper {
+		failed = append(failed, passNoUpperErr)
+	}
+	if policy.RequireDigit && !digit {
+		failed = append(failed, passNoDigitErr)
+	}
+	if policy.RequireSymbol && !symbol {
+		failed = append(failed, PasswordErr("Password must contain at least one of the following symbols: "+policy.Symbols))
+	}
+	if policy.MaxRepeated > 0 && longestRun(password) > policy.MaxRepeated {
+		failed = append(failed, PasswordErr(fmt.Sprintf("Password cannot repeat a character more than %d times in a row", policy.MaxRepeated)))
+	}
+	if policy.RejectPersonal && containsPersonal(password, username, email) {
+		failed = append(failed, passPersonalErr)
+	}
+	if policy.Blocklist.Contains(password) {
+		failed = append(failed, passCommonErr)
+	}
+	if len(failed) > 0 {
+		return failed
+	}
+	return nil
+}
+
+// Describe tells users what the policy asks for before they choose a
+// password.
+func (policy PasswordPolicy) Describe() string {
+	var letters string
+	switch {
+	case policy.RequireUpper && policy.RequireLower:
+		letters = "one uppercase and lowercase letter"
+	case policy.RequireUpper:
+		letters = "one uppercase letter"
+	case policy.RequireLower:
+		letters = "one lowercase letter"
+	}
+	var classes []string
+	if letters != "" {
+		classes = append(classes, letters)
+	}
+	if policy.RequireDigit {
+		classes = append(classes, "one number")
+	}
+	if policy.RequireSymbol {
+		classes = append(classes, "one of the following symbols: "+policy.Symbols)
+	}
+	description := fmt.Sprintf("Password must have between %d and %d characters", policy.MinLength, policy.maxLength())
+	if len(classes) == 0 {
+		return description
+	}
+	if len(classes) > 1 {
+		classes = []string{strings.Join(classes[:len(classes)-1], ", ") + " and " + classes[len(classes)-1]}
+	}
+	return description + ", and must contain at least " + classes[0]
+}
+
+func longestRun(password string) int {
+	longest, run := 0, 0
+	var previous rune
+	for i, char := range []rune(password) {
+		if i > 0 && char == previous {
+			run++
+		} else {
+			run = 1
+		}
+		previous = char
+		longest = max(longest, run)
+	}
+	return longest
+}
+
+// containsPersonal tells whether password contains the username or the local
+// part of the email, ignoring case. Parts shorter than 3 characters are too
+// likely to show up by chance.
+func containsPersonal(password, username, email string) bool {
+	password = fold(password)
+	local, _, _ := strings.Cut(email, "@")
+	for _, part := range []string{username, local} {
+		part = fold(part)
+		if utf8.RuneCountInString(part) >= 3 && strings.Contains(password, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// Blocklist is a set of passwords too common to be allowed, compared without
+// case.
+type Blocklist map[string]struct{}
+
+// ReadBlocklist reads one password per line, blank lines and lines starting
+// with # are skipped.
+func ReadBlocklist(r io.Reader) (Blocklist, error) {
+	blocklist := make(Blocklist)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		blocklist[fold(line)] = struct{}{}
+	}
+	return blocklist, scanner.Err()
+}
+
+// LoadBlocklist reads the blocklist at path, a missing file is an empty list.
+func LoadBlocklist(path string) (Blocklist, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return Blocklist{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ReadBlocklist(file)
+}
+
+func (blocklist Blocklist) Contains(password string) bool {
+	_, found := blocklist[fold(password)]
+	return found
+}
+
+func validatePassword(password, username, email string) error {
+	return passwordPolicy.Validate(password, username, email)
+}
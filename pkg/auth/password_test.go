@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPasswordPolicy(t *testing.T) {
+	policy := PasswordPolicy{
+		MinLength:      6,
+		MaxLength:      12,
+		RequireDigit:   true,
+		RequireSymbol:  true,
+		Symbols:        "#-_",
+		MaxRepeated:    2,
+		RejectPersonal: true,
+		Blocklist:      Blocklist{"letmein#1": {}},
+	}
+	tests := []struct {
+		name           string
+		password       string
+		expected_error error
+	}{
+		{name: "valid password", password: "plain#42", expected_error: nil},
+		{name: "symbol outside the set", password: "plain!42", expected_error: PasswordRulesErr{"Password must contain at least one of the following symbols: #-_"}},
+		{name: "too long", password: "plain#4200000", expected_error: PasswordRulesErr{"Password must have at most 12 characters", "Password cannot repeat a character more than 2 times in a row"}},
+		{name: "length counts characters", password: "ñandú#1", expected_error: nil},
+		{name: "repeated characters", password: "plaaain#4", expected_error: PasswordRulesErr{"Password cannot repeat a character more than 2 times in a row"}},
+		{name: "contains username", password: "Tester#42", expected_error: PasswordRulesErr{passPersonalErr}},
+		{name: "contains email", password: "xx#1MAILxx", expected_error: PasswordRulesErr{passPersonalErr}},
+		{name: "blocklisted", password: "LetMeIn#1", expected_error: PasswordRulesErr{passCommonErr}},
+		{name: "every failed rule", password: "aaa", expected_error: PasswordRulesErr{"Password must have at least 6 characters", passNoDigitErr, "Password must contain at least one of the following symbols: #-_", "Password cannot repeat a character more than 2 times in a row"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := policy.Validate(test.password, "tester", "mail@gmail.com")
+
+			assertError(t, err, test.expected_error)
+		})
+	}
+}
+
+func TestUsePasswordPolicy(t *testing.T) {
+	t.Cleanup(func() { UsePasswordPolicy(DefaultPasswordPolicy) })
+	tests := []struct {
+		name           string
+		change         func(policy *PasswordPolicy)
+		expected_error error
+	}{
+		{name: "no minimum", change: func(policy *PasswordPolicy) { policy.MinLength = 0 }, expected_error: invalidMinLenErr},
+		{name: "maximum below minimum", change: func(policy *PasswordPolicy) { policy.MaxLength = 4 }, expected_error: invalidMaxLenErr},
+		{name: "maximum over what bcrypt reads", change: func(policy *PasswordPolicy) { policy.MaxLength = 100 }, expected_error: invalidMaxLenErr},
+		{name: "symbols required but none allowed", change: func(policy *PasswordPolicy) { policy.Symbols = "" }, expected_error: noSymbolsErr},
+		{name: "negative repeats", change: func(policy *PasswordPolicy) { policy.MaxRepeated = -1 }, expected_error: invalidRepeatedErr},
+		{name: "valid policy", change: func(policy *PasswordPolicy) { policy.MinLength = 12 }, expected_error: nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			policy := DefaultPasswordPolicy
+			test.change(&policy)
+
+			err := UsePasswordPolicy(policy)
+
+			assertError(t, err, test.expected_error)
+		})
+	}
+	users := UserDatabase{UsersByEmail: map[string]*User{}, UsersByUsername: map[string]*User{}}
+	_, err := users.RegisterUser("mail@gmail.com", "tester", "Abc12345!")
+	assertError(t, err, PasswordRulesErr{"Password must have at least 12 characters"})
+}
+
+func TestDescribePasswordPolicy(t *testing.T) {
+	got := DefaultPasswordPolicy.Describe()
+
+	expected := "Password must have between 8 and 64 characters, and must contain at least one uppercase and lowercase letter, one number and one of the following symbols: @$!%*?&"
+	if got != expected {
+		t.Errorf("got %q, expected %q", got, expected)
+	}
+}
+
+func TestReadBlocklist(t *testing.T) {
+	blocklist, err := ReadBlocklist(strings.NewReader("# most common first\nPassword1!\n\n  qwerty  \n"))
+
+	assertError(t, err, nil)
+	if len(blocklist) != 2 || !blocklist.Contains("password1!") || !blocklist.Contains("QWERTY") || blocklist.Contains("# most common first") {
+		t.Errorf("got %v", blocklist)
+	}
+
+	t.Run("missing file", func(t *testing.T) {
+		blocklist, err := LoadBlocklist(t.TempDir() + "/missing.txt")
+
+		assertError(t, err, nil)
+		if len(blocklist) != 0 {
+			t.Errorf("expected an empty blocklist, got %v", blocklist)
+		}
+	})
+}
+
+//helpers
+
+// the messages of DefaultPasswordPolicy
+const (
+	passMinLengthErr = PasswordErr("Password must have at least 8 characters")
+	passNoSymbolErr  = PasswordErr("Password must contain at least one of the following symbols: @$!%*?&")
+)
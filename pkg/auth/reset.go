@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"sync"
+	"time"
+	"todo_app/pkg/audit"
+	"todo_app/pkg/mailer"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultResetTokenTTL = time.Hour
+	invalidResetTokenErr = TokenErr("Reset code is invalid, was already used or has expired")
+)
+
+type resetToken struct {
+	UserId  uuid.UUID
+	Expires time.Time
+}
+
+// PasswordResets hands out single use codes to set a new password without
+// the old one. Codes are only kept hashed and in memory, a restart simply
+// means asking for a new one.
+type PasswordResets struct {
+	TTL    time.Duration
+	Now    func() time.Time
+	mu     sync.Mutex
+	tokens map[string]*resetToken
+}
+
+func NewPasswordResets(ttl time.Duration) *PasswordResets {
+	return &PasswordResets{TTL: ttl, Now: time.Now, tokens: make(map[string]*resetToken)}
+}
+
+// Request mails a reset code to the user with the given email or username.
+// Callers should not tell the person asking whether the user exists.
+func (resets *PasswordResets) Request(users UserDatabase, sender mailer.Mailer, id string) error {
+	user, err := users.getUser(id)
+	if err != nil {
+		return userNotFoundErr
+	}
+	secret := make([]byte, 32)
+	_, err = rand.Read(secret)
+	if err != nil {
+		return err
+	}
+	token := base64.RawURLEncoding.EncodeToString(secret)
+
+	resets.mu.Lock()
+	now := resets.Now()
+	resets.prune(now)
+	resets.tokens[hashToken(token)] = &resetToken{UserId: user.Id, Expires: now.Add(resets.TTL)}
+	resets.mu.Unlock()
+
+	err = sender.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nUse this code to choose a new password:\n\n%s\n\nIt expires in %s. If you did not ask for it you can ignore this email.\n",
+			user.Username, token, resets.TTL),
+	})
+	if err != nil {
+		resets.mu.Lock()
+		delete(resets.tokens, hashToken(token))
+		resets.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// ResetPassword sets a new password with a code from Request. The password
+// has to follow the same rules as on registration. Every code of the user
+// stops working and, when sessions is not nil, every session is ended.
+func (resets *PasswordResets) ResetPassword(users UserDatabase, sessions *Sessions, token, password string) (User, error) {
+	resets.mu.Lock()
+	defer resets.mu.Unlock()
+	stored, found := resets.tokens[hashToken(token)]
+	if !found || !resets.Now().Before(stored.Expires) {
+		return User{}, invalidResetTokenErr
+	}
+	user, err := users.GetUserById(stored.UserId)
+	if err != nil {
+		delete(resets.tokens, hashToken(token))
+		return User{}, invalidResetTokenErr
+	}
+	err = validatePassword(password, user.Username, user.Email)
+	if err != nil {
+		return User{}, err
+	}
+	hashed, err := hashPassword(password)
+	if err != nil {
+		return User{}, err
+	}
+	user.Password = hashed
+	user.PasswordResetRequired = false
+	for hash, other := range resets.tokens {
+		if other.UserId == user.Id {
+			delete(resets.tokens, hash)
+		}
+	}
+	if sessions != nil {
+		sessions.RevokeAll(user.Id)
+	}
+	recordUserEvent(audit.PasswordReset, user, "", "", "", resets.Now())
+	return *user, nil
+}
+
+func (resets *PasswordResets) prune(now time.Time) {
+	for hash, stored := range resets.tokens {
+		if !now.Before(stored.Expires) {
+			delete(resets.tokens, hash)
+		}
+	}
+}
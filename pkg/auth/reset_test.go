@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"todo_app/pkg/mailer"
+)
+
+func TestRequestReset(t *testing.T) {
+	users, user := sessionTestUsers()
+	tests := []struct {
+		name           string
+		id             string
+		expected_mails int
+		expected_error error
+	}{
+		{name: "by email", id: user.Email, expected_mails: 1, expected_error: nil},
+		{name: "by username", id: user.Username, expected_mails: 1, expected_error: nil},
+		{name: "unknown user", id: "nobody", expected_mails: 0, expected_error: userNotFoundErr},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sent := &mailer.Memory{}
+			resets := NewPasswordResets(time.Hour)
+
+			err := resets.Request(users, sent, test.id)
+
+			assertError(t, err, test.expected_error)
+			if len(sent.Messages()) != test.expected_mails {
+				t.Fatalf("got %d mails, expected %d", len(sent.Messages()), test.expected_mails)
+			}
+			if test.expected_mails > 0 && sent.Messages()[0].To != user.Email {
+				t.Errorf("expected the mail to go to %q, got %v", user.Email, sent.Messages()[0])
+			}
+		})
+	}
+
+	t.Run("mail failure", func(t *testing.T) {
+		resets := NewPasswordResets(time.Hour)
+		broken := UserDatabase{UsersByEmail: map[string]*User{}, UsersByUsername: map[string]*User{"nomail": {Username: "nomail"}}}
+
+		err := resets.Request(broken, &mailer.Memory{}, "nomail")
+
+		assertError(t, err, mailer.NoRecipientErr)
+		if len(resets.tokens) != 0 {
+			t.Errorf("expected no code to be kept when it could not be sent, got %d", len(resets.tokens))
+		}
+	})
+}
+
+func TestResetPassword(t *testing.T) {
+	users := UserDatabase{UsersByEmail: map[string]*User{}, UsersByUsername: map[string]*User{}}
+	user, _ := users.RegisterUser("mail@gmail.com", "tester", "Abc12345!")
+	clock := &fakeClock{now: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)}
+	resets := NewPasswordResets(time.Hour)
+	resets.Now = clock.Now
+	sessions := NewSessions(time.Hour, 24*time.Hour)
+	sessionToken, _, _ := sessions.Create(user)
+	sent := &mailer.Memory{}
+	resets.Request(users, sent, "tester")
+	code := resetCode(t, sent)
+	tests := []struct {
+		name           string
+		token          string
+		password       string
+		expected_error error
+	}{
+		{name: "unknown code", token: "nope", password: "Xyz98765!", expected_error: invalidResetTokenErr},
+		{name: "weak password", token: code, password: "password", expected_error: PasswordRulesErr{passNoUpperErr, passNoDigitErr, passNoSymbolErr}},
+		{name: "short password", token: code, password: "Ab1!", expected_error: PasswordRulesErr{passMinLengthErr}},
+		{name: "valid reset", token: code, password: "Xyz98765!", expected_error: nil},
+		{name: "code used twice", token: code, password: "Other123!", expected_error: invalidResetTokenErr},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := resets.ResetPassword(users, sessions, test.token, test.password)
+
+			assertError(t, err, test.expected_error)
+		})
+	}
+
+	_, err := LogIn(users, NewLoginChallenges(), "tester", "Xyz98765!")
+	assertError(t, err, nil)
+	_, err = LogIn(users, NewLoginChallenges(), "mail@gmail.com", "Abc12345!")
+	assertError(t, err, wrongPasswordErr)
+	_, err = sessions.Lookup(users, sessionToken)
+	assertError(t, err, invalidSessionErr)
+}
+
+func TestResetCodeExpiry(t *testing.T) {
+	users, _ := sessionTestUsers()
+	clock := &fakeClock{now: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)}
+	resets := NewPasswordResets(time.Hour)
+	resets.Now = clock.Now
+	sent := &mailer.Memory{}
+	resets.Request(users, sent, "tester")
+	first := resetCode(t, sent)
+	resets.Request(users, &mailer.Memory{}, "tester")
+
+	clock.now = clock.now.Add(time.Hour)
+	_, err := resets.ResetPassword(users, nil, first, "Xyz98765!")
+
+	assertError(t, err, invalidResetTokenErr)
+}
+
+//helpers
+
+func resetCode(t testing.TB, sent *mailer.Memory) string {
+	t.Helper()
+	messages := sent.Messages()
+	if len(messages) == 0 {
+		t.Fatal("expected a reset mail")
+	}
+	_, after, _ := strings.Cut(messages[len(messages)-1].Body, "new password:\n\n")
+	code, _, _ := strings.Cut(after, "\n")
+	if code == "" {
+		t.Fatalf("expected a code in %q", messages[len(messages)-1].Body)
+	}
+	return code
+}
@@ -0,0 +1,90 @@
+package auth
+
+import "strings"
+
+// Role decides what a user may do besides managing their own tasks and
+// account.
+type Role int
+
+const (
+	RoleUser Role = iota
+	RoleAdmin
+)
+
+// Permission is an action on other users' accounts.
+type Permission int
+
+const (
+	PermissionListUsers Permission = iota
+	PermissionDisableUsers
+	PermissionResetPasswords
+	PermissionViewTaskCounts
+	PermissionManageRoles
+	PermissionViewEvents
+	PermissionUnlockLogins
+)
+
+const (
+	invalidRoleErr         = PolicyErr("Role must be user or admin")
+	permissionDeniedErr    = PermissionErr("You are not allowed to do that")
+	accountDisabledErr     = AccessErr("This account has been disabled, please contact an administrator")
+	passwordResetForcedErr = AccessErr("An administrator asked you to choose a new password, please use Forgot password")
+	selfAdministrationErr  = PermissionErr("Administrators cannot disable themselves or take away their own role")
+	adminExistsErr         = PermissionErr("There is an administrator already, ask them to give the role")
+)
+
+var (
+	roleNames       = []string{"user", "admin"}
+	rolePermissions = map[Role][]Permission{
+		RoleAdmin: {PermissionListUsers, PermissionDisableUsers, PermissionResetPasswords, PermissionViewTaskCounts, PermissionManageRoles, PermissionViewEvents, PermissionUnlockLogins},
+	}
+)
+
+// PermissionErr is returned when a user tries something their role does not
+// allow.
+type PermissionErr string
+
+func (e PermissionErr) Error() string {
+	return string(e)
+}
+
+// AccessErr is returned when an administrator restricted an account.
+type AccessErr string
+
+func (e AccessErr) Error() string {
+	return string(e)
+}
+
+// ParseRole reads a role by name: user or admin.
+func ParseRole(value string) (Role, error) {
+	for i, name := range roleNames {
+		if strings.EqualFold(strings.TrimSpace(value), name) {
+			return Role(i), nil
+		}
+	}
+	return RoleUser, invalidRoleErr
+}
+
+func (role Role) String() string {
+	if role < 0 || int(role) >= len(roleNames) {
+		return roleNames[RoleUser]
+	}
+	return roleNames[role]
+}
+
+// Can tells whether user's role grants permission.
+func Can(user User, permission Permission) bool {
+	for _, granted := range rolePermissions[user.Role] {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
+
+func authorize(user User, permission Permission) error {
+	if !Can(user, permission) {
+		return permissionDeniedErr
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package auth
+
+import "testing"
+
+func TestParseRole(t *testing.T) {
+	tests := []struct {
+		name           string
+		input          string
+		expected       Role
+		expected_error error
+	}{
+		{name: "user", input: "user", expected: RoleUser, expected_error: nil},
+		{name: "admin in another case", input: " Admin ", expected: RoleAdmin, expected_error: nil},
+		{name: "unknown role", input: "owner", expected: RoleUser, expected_error: invalidRoleErr},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseRole(test.input)
+
+			assertError(t, err, test.expected_error)
+			if got != test.expected {
+				t.Errorf("got %v, expected %v", got, test.expected)
+			}
+		})
+	}
+}
+
+func TestCan(t *testing.T) {
+	tests := []struct {
+		name       string
+		role       Role
+		permission Permission
+		expected   bool
+	}{
+		{name: "users cannot list users", role: RoleUser, permission: PermissionListUsers, expected: false},
+		{name: "users cannot manage roles", role: RoleUser, permission: PermissionManageRoles, expected: false},
+		{name: "admins can disable users", role: RoleAdmin, permission: PermissionDisableUsers, expected: true},
+		{name: "admins can view task counts", role: RoleAdmin, permission: PermissionViewTaskCounts, expected: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := Can(User{Role: test.role}, test.permission)
+
+			if got != test.expected {
+				t.Errorf("got %v, expected %v", got, test.expected)
+			}
+		})
+	}
+}
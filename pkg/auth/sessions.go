@@ -0,0 +1,232 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultSessionIdleTimeout = 24 * time.Hour
+	DefaultSessionMaxLifetime = 30 * 24 * time.Hour
+	invalidSessionErr         = SessionErr("Session is invalid or has expired")
+	missingTokenErr           = SessionErr("A session token is required")
+)
+
+type SessionErr string
+
+func (e SessionErr) Error() string {
+	return string(e)
+}
+
+// Session is kept server side under the SHA-256 of its token, the token itself
+// is only known to the client it was issued to.
+type Session struct {
+	TokenHash string
+	UserId    uuid.UUID
+	Created   time.Time
+	Expires   time.Time
+}
+
+// Sessions issues and checks session tokens. Every successful lookup pushes
+// the expiry IdleTimeout further (sliding renewal), but never past
+// MaxLifetime after the session was created.
+type Sessions struct {
+	IdleTimeout time.Duration
+	MaxLifetime time.Duration
+	Now         func() time.Time
+	mu          sync.Mutex
+	sessions    map[string]*Session
+	issuer      *TokenIssuer
+}
+
+func NewSessions(idleTimeout, maxLifetime time.Duration) *Sessions {
+	return &Sessions{IdleTimeout: idleTimeout, MaxLifetime: maxLifetime, Now: time.Now, sessions: make(map[string]*Session)}
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func (sessions *Sessions) expiry(session *Session, now time.Time) time.Time {
+	expires := now.Add(sessions.IdleTimeout)
+	limit := session.Created.Add(sessions.MaxLifetime)
+	if expires.After(limit) {
+		return limit
+	}
+	return expires
+}
+
+// Create starts a session for user and returns the token to hand to the
+// client.
+func (sessions *Sessions) Create(user User) (string, Session, error) {
+	secret := make([]byte, 32)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return "", Session{}, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(secret)
+
+	sessions.mu.Lock()
+	defer sessions.mu.Unlock()
+	now := sessions.Now()
+	sessions.prune(now)
+	session := Session{TokenHash: hashToken(token), UserId: user.Id, Created: now}
+	session.Expires = sessions.expiry(&session, now)
+	sessions.sessions[session.TokenHash] = &session
+	return token, session, nil
+}
+
+// Lookup returns the user a token was issued to and renews its session.
+func (sessions *Sessions) Lookup(users UserDatabase, token string) (User, error) {
+	sessions.mu.Lock()
+	defer sessions.mu.Unlock()
+	now := sessions.Now()
+	session, found := sessions.sessions[hashToken(token)]
+	if !found {
+		return User{}, invalidSessionErr
+	}
+	if !now.Before(session.Expires) {
+		delete(sessions.sessions, session.TokenHash)
+		return User{}, invalidSessionErr
+	}
+	user, err := users.GetUserById(session.UserId)
+	if err != nil || user.Disabled {
+		delete(sessions.sessions, session.TokenHash)
+		return User{}, invalidSessionErr
+	}
+	session.Expires = sessions.expiry(session, now)
+	return *user, nil
+}
+
+// Revoke ends the session of a token, e.g. on logout.
+func (sessions *Sessions) Revoke(token string) error {
+	sessions.mu.Lock()
+	defer sessions.mu.Unlock()
+	_, found := sessions.sessions[hashToken(token)]
+	if !found {
+		return invalidSessionErr
+	}
+	delete(sessions.sessions, hashToken(token))
+	return nil
+}
+
+// UseTokenIssuer has RevokeAll revoke the refresh tokens issuer handed to the
+// user too, so disabling an account or changing its password ends every way
+// back in.
+func (sessions *Sessions) UseTokenIssuer(issuer *TokenIssuer) {
+	sessions.mu.Lock()
+	defer sessions.mu.Unlock()
+	sessions.issuer = issuer
+}
+
+// RevokeAll ends every session of a user and returns how many there were.
+func (sessions *Sessions) RevokeAll(userId uuid.UUID) int {
+	sessions.mu.Lock()
+	revoked := 0
+	for hash, session := range sessions.sessions {
+		if session.UserId == userId {
+			delete(sessions.sessions, hash)
+			revoked++
+		}
+	}
+	issuer := sessions.issuer
+	sessions.mu.Unlock()
+	if issuer != nil {
+		issuer.RevokeUser(userId)
+	}
+	return revoked
+}
+
+// List returns the sessions that have not expired, oldest first, so they can
+// be saved.
+func (sessions *Sessions) List() []Session {
+	sessions.mu.Lock()
+	defer sessions.mu.Unlock()
+	sessions.prune(sessions.Now())
+	list := make([]Session, 0, len(sessions.sessions))
+	for _, session := range sessions.sessions {
+		list = append(list, *session)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Created.Before(list[j].Created) })
+	return list
+}
+
+// Restore puts back a session returned by List, e.g. after a restart.
+func (sessions *Sessions) Restore(session Session) {
+	sessions.mu.Lock()
+	defer sessions.mu.Unlock()
+	sessions.sessions[session.TokenHash] = &session
+}
+
+func (sessions *Sessions) prune(now time.Time) {
+	for hash, session := range sessions.sessions {
+		if !now.Before(session.Expires) {
+			delete(sessions.sessions, hash)
+		}
+	}
+}
+
+type contextKey int
+
+const (
+	userContextKey contextKey = iota
+	tokenContextKey
+)
+
+// BearerToken returns the token of an "Authorization: Bearer <token>" header.
+func BearerToken(r *http.Request) (string, error) {
+	scheme, token, found := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return "", missingTokenErr
+	}
+	return strings.TrimSpace(token), nil
+}
+
+// Middleware only lets requests with a valid bearer token through to next,
+// with the user available through UserFromContext. Other requests are passed
+// to onError, or get a plain 401 when onError is nil.
+func (sessions *Sessions) Middleware(users UserDatabase, onError func(w http.ResponseWriter, r *http.Request, err error)) func(next http.Handler) http.Handler {
+	if onError == nil {
+		onError = func(w http.ResponseWriter, r *http.Request, err error) {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+		}
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, err := BearerToken(r)
+			if err != nil {
+				onError(w, r, err)
+				return
+			}
+			user, err := sessions.Lookup(users, token)
+			if err != nil {
+				onError(w, r, err)
+				return
+			}
+			ctx := context.WithValue(r.Context(), userContextKey, user)
+			ctx = context.WithValue(ctx, tokenContextKey, token)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func UserFromContext(ctx context.Context) (User, bool) {
+	user, found := ctx.Value(userContextKey).(User)
+	return user, found
+}
+
+func TokenFromContext(ctx context.Context) (string, bool) {
+	token, found := ctx.Value(tokenContextKey).(string)
+	return token, found
+}
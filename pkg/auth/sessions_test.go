@@ -0,0 +1,174 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestSessionLookup(t *testing.T) {
+	users, user := sessionTestUsers()
+	clock := &fakeClock{now: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)}
+	sessions := NewSessions(time.Hour, 3*time.Hour)
+	sessions.Now = clock.Now
+	token, _, _ := sessions.Create(user)
+
+	tests := []struct {
+		name           string
+		advance        time.Duration
+		token          string
+		expected_error error
+	}{
+		{name: "fresh session", advance: 0, token: token, expected_error: nil},
+		{name: "renewed before idle timeout", advance: 50 * time.Minute, token: token, expected_error: nil},
+		{name: "still valid thanks to renewal", advance: 50 * time.Minute, token: token, expected_error: nil},
+		{name: "renewed again", advance: 50 * time.Minute, token: token, expected_error: nil},
+		{name: "unknown token", advance: 0, token: "not-a-token", expected_error: invalidSessionErr},
+		{name: "past max lifetime before idle timeout", advance: 40 * time.Minute, token: token, expected_error: invalidSessionErr},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clock.now = clock.now.Add(test.advance)
+
+			got, err := sessions.Lookup(users, test.token)
+
+			assertError(t, err, test.expected_error)
+			if err == nil {
+				assertUsers(t, got, user)
+			}
+		})
+	}
+}
+
+func TestSessionIdleTimeout(t *testing.T) {
+	users, user := sessionTestUsers()
+	clock := &fakeClock{now: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)}
+	sessions := NewSessions(time.Hour, 24*time.Hour)
+	sessions.Now = clock.Now
+	token, session, _ := sessions.Create(user)
+
+	if session.TokenHash == token || len(token) < 40 {
+		t.Fatalf("expected a long random token stored hashed, got %q", token)
+	}
+	clock.now = clock.now.Add(time.Hour)
+
+	_, err := sessions.Lookup(users, token)
+
+	assertError(t, err, invalidSessionErr)
+	if len(sessions.List()) != 0 {
+		t.Errorf("expected expired session to be removed, got %v", sessions.List())
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	users, user := sessionTestUsers()
+	other := User{Id: uuid.New(), Email: "other@gmail.com", Username: "other"}
+	sessions := NewSessions(time.Hour, 24*time.Hour)
+	first, _, _ := sessions.Create(user)
+	second, _, _ := sessions.Create(user)
+	sessions.Create(other)
+
+	t.Run("logout", func(t *testing.T) {
+		assertError(t, sessions.Revoke(first), nil)
+
+		_, err := sessions.Lookup(users, first)
+		assertError(t, err, invalidSessionErr)
+		assertError(t, sessions.Revoke(first), invalidSessionErr)
+	})
+
+	t.Run("revoke all", func(t *testing.T) {
+		sessions.Create(user)
+
+		revoked := sessions.RevokeAll(user.Id)
+
+		if revoked != 2 {
+			t.Errorf("expected 2 sessions revoked, got %d", revoked)
+		}
+		_, err := sessions.Lookup(users, second)
+		assertError(t, err, invalidSessionErr)
+		if len(sessions.List()) != 1 {
+			t.Errorf("expected only the other user's session to be left, got %d", len(sessions.List()))
+		}
+	})
+}
+
+func TestRestoreSession(t *testing.T) {
+	users, user := sessionTestUsers()
+	sessions := NewSessions(time.Hour, 24*time.Hour)
+	token, _, _ := sessions.Create(user)
+
+	restarted := NewSessions(time.Hour, 24*time.Hour)
+	for _, session := range sessions.List() {
+		restarted.Restore(session)
+	}
+	got, err := restarted.Lookup(users, token)
+
+	assertError(t, err, nil)
+	assertUsers(t, got, user)
+}
+
+func TestSessionMiddleware(t *testing.T) {
+	users, user := sessionTestUsers()
+	sessions := NewSessions(time.Hour, 24*time.Hour)
+	token, _, _ := sessions.Create(user)
+	handler := sessions.Middleware(users, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, found := UserFromContext(r.Context())
+		if !found {
+			t.Fatal("expected the user in the request context")
+		}
+		w.Write([]byte(got.Username))
+	}))
+	tests := []struct {
+		name            string
+		header          string
+		expected_status int
+	}{
+		{name: "valid token", header: "Bearer " + token, expected_status: http.StatusOK},
+		{name: "lowercase scheme", header: "bearer " + token, expected_status: http.StatusOK},
+		{name: "no header", header: "", expected_status: http.StatusUnauthorized},
+		{name: "wrong scheme", header: "Basic " + token, expected_status: http.StatusUnauthorized},
+		{name: "invalid token", header: "Bearer nope", expected_status: http.StatusUnauthorized},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			if test.header != "" {
+				request.Header.Set("Authorization", test.header)
+			}
+			response := httptest.NewRecorder()
+
+			handler.ServeHTTP(response, request)
+
+			if response.Code != test.expected_status {
+				t.Fatalf("got status %d, expected %d", response.Code, test.expected_status)
+			}
+			if test.expected_status == http.StatusOK && response.Body.String() != user.Username {
+				t.Errorf("got body %q, expected %q", response.Body.String(), user.Username)
+			}
+		})
+	}
+}
+
+//helpers
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (clock *fakeClock) Now() time.Time {
+	return clock.now
+}
+
+func sessionTestUsers() (UserDatabase, User) {
+	user := User{Id: uuid.New(), Email: "mail@gmail.com", Username: "tester", Password: "hash"}
+	users := UserDatabase{
+		UsersByEmail:    map[string]*User{user.Email: &user},
+		UsersByUsername: map[string]*User{user.Username: &user},
+	}
+	return users, user
+}
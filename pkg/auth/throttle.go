@@ -0,0 +1,213 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"todo_app/pkg/audit"
+)
+
+// LockedErr is returned instead of checking the password while an account or
+// the source of the request is locked after too many failed logins.
+type LockedErr struct {
+	Until time.Time
+}
+
+func (e LockedErr) Error() string {
+	return fmt.Sprintf("Too many failed login attempts, try again after %s", e.Until.Format("15:04:05"))
+}
+
+// ThrottlePolicy sets how failed logins are slowed down. The first
+// FreeFailures cost nothing, every one after that doubles the wait starting
+// at BaseDelay, and MaxFailures lock out for Lockout. Failures are forgotten
+// Lockout after the last one.
+type ThrottlePolicy struct {
+	FreeFailures int
+	MaxFailures  int
+	BaseDelay    time.Duration
+	Lockout      time.Duration
+}
+
+var (
+	DefaultAccountPolicy = ThrottlePolicy{FreeFailures: 3, MaxFailures: 10, BaseDelay: time.Second, Lockout: 15 * time.Minute}
+	// many users can share an address, so sources get more room
+	DefaultSourcePolicy = ThrottlePolicy{FreeFailures: 10, MaxFailures: 50, BaseDelay: time.Second, Lockout: 15 * time.Minute}
+)
+
+func (policy ThrottlePolicy) delay(failures int) time.Duration {
+	if failures >= policy.MaxFailures {
+		return policy.Lockout
+	}
+	if failures <= policy.FreeFailures {
+		return 0
+	}
+	delay := policy.BaseDelay
+	for i := policy.FreeFailures + 1; i < failures && delay < policy.Lockout; i++ {
+		delay *= 2
+	}
+	return min(delay, policy.Lockout)
+}
+
+type failedLogins struct {
+	Failures    int
+	LastFailure time.Time
+	Until       time.Time
+}
+
+// LoginThrottle counts failed logins per account and per source, e.g. the
+// address of a client, and refuses to check passwords while either of them
+// is locked. Challenges keeps its logins waiting for a second factor.
+type LoginThrottle struct {
+	Account    ThrottlePolicy
+	Source     ThrottlePolicy
+	Now        func() time.Time
+	Challenges *LoginChallenges
+	mu         sync.Mutex
+	accounts   map[string]*failedLogins
+	sources    map[string]*failedLogins
+}
+
+func NewLoginThrottle() *LoginThrottle {
+	return &LoginThrottle{
+		Account:    DefaultAccountPolicy,
+		Source:     DefaultSourcePolicy,
+		Now:        time.Now,
+		Challenges: NewLoginChallenges(),
+		accounts:   make(map[string]*failedLogins),
+		sources:    make(map[string]*failedLogins),
+	}
+}
+
+// accountKey makes the email and username of a user share their failures.
+// Ids of unknown users are counted too, so locking can't tell them apart.
+func accountKey(users UserDatabase, id string) string {
+	user, err := users.getUser(id)
+	if err != nil {
+		return "unknown:" + fold(id)
+	}
+	return user.Id.String()
+}
+
+// LogIn is LogIn with failed attempts counted against the account and source.
+func (throttle *LoginThrottle) LogIn(users UserDatabase, id, password, source string) (User, error) {
+	throttle.mu.Lock()
+	defer throttle.mu.Unlock()
+	now := throttle.Now()
+	account := accountKey(users, id)
+	err := throttle.locked(account, source, now)
+	if err != nil {
+		known, _ := users.getUser(id)
+		recordLogin(known, id, source, err, now)
+		return User{}, err
+	}
+
+	user, err := logIn(users, throttle.Challenges, id, password, source, now)
+	if err == wrongPasswordErr || err == userNotFoundErr {
+		known, _ := users.getUser(id)
+		throttle.failLogin(account, source, known, id, now)
+		return User{}, err
+	}
+	// the account keeps its failures until the second factor is right too
+	if _, required := err.(SecondFactorRequired); required {
+		return user, err
+	}
+	// the password was right even if the user can't log in yet, so the
+	// account starts over; the source doesn't, or an attacker could reset it
+	// by logging into an account of their own
+	delete(throttle.accounts, account)
+	return user, err
+}
+
+// CompleteLogIn is CompleteLogIn with wrong codes counted like wrong
+// passwords, so codes can't be guessed either.
+func (throttle *LoginThrottle) CompleteLogIn(users UserDatabase, challenge, code, source string) (User, error) {
+	throttle.mu.Lock()
+	defer throttle.mu.Unlock()
+	now := throttle.Now()
+	userId, found := throttle.Challenges.user(strings.TrimSpace(challenge))
+	if !found {
+		return User{}, invalidChallengeErr
+	}
+	account := userId.String()
+	known, _ := users.GetUserById(userId)
+	err := throttle.locked(account, source, now)
+	if err != nil {
+		recordLogin(known, "", source, err, now)
+		return User{}, err
+	}
+
+	user, err := throttle.Challenges.complete(users, challenge, code, source, now)
+	if err == wrongCodeErr {
+		throttle.failLogin(account, source, known, "", now)
+		return User{}, err
+	}
+	if err == nil {
+		delete(throttle.accounts, account)
+	}
+	return user, err
+}
+
+func (throttle *LoginThrottle) locked(account, source string, now time.Time) error {
+	until := now
+	for _, record := range []*failedLogins{throttle.accounts[account], throttle.sources[source]} {
+		if record != nil && record.Until.After(until) {
+			until = record.Until
+		}
+	}
+	if until.After(now) {
+		return LockedErr{Until: until}
+	}
+	return nil
+}
+
+// failLogin counts a failed login against account and source and records a
+// lockout for each of them it locks.
+func (throttle *LoginThrottle) failLogin(account, source string, user *User, login string, now time.Time) {
+	if throttle.fail(throttle.accounts, account, throttle.Account, now) {
+		recordUserEvent(audit.Lockout, user, login, source, "account", now)
+	}
+	if throttle.fail(throttle.sources, source, throttle.Source, now) {
+		recordUserEvent(audit.Lockout, user, login, source, "source", now)
+	}
+}
+
+// fail counts a failure against key and tells whether it locked key out.
+// Records policy has forgotten are dropped on the way, so keys that stop
+// failing don't pile up.
+func (throttle *LoginThrottle) fail(records map[string]*failedLogins, key string, policy ThrottlePolicy, now time.Time) bool {
+	for other, record := range records {
+		if now.Sub(record.LastFailure) >= policy.Lockout {
+			delete(records, other)
+		}
+	}
+	record, found := records[key]
+	if !found {
+		record = &failedLogins{}
+		records[key] = record
+	}
+	record.Failures++
+	record.LastFailure = now
+	record.Until = now.Add(policy.delay(record.Failures))
+	return record.Failures == policy.MaxFailures
+}
+
+// Unlock forgets the failed logins of the user with the given email or
+// username, for an admin to let them back in, see UnlockLogins.
+func (throttle *LoginThrottle) Unlock(users UserDatabase, id string) error {
+	user, err := users.getUser(id)
+	if err != nil {
+		return userNotFoundErr
+	}
+	throttle.mu.Lock()
+	defer throttle.mu.Unlock()
+	delete(throttle.accounts, user.Id.String())
+	return nil
+}
+
+// UnlockSource forgets the failed logins of a source.
+func (throttle *LoginThrottle) UnlockSource(source string) {
+	throttle.mu.Lock()
+	defer throttle.mu.Unlock()
+	delete(throttle.sources, source)
+}
@@ -0,0 +1,173 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestThrottlePolicyDelay(t *testing.T) {
+	policy := ThrottlePolicy{FreeFailures: 2, MaxFailures: 6, BaseDelay: time.Second, Lockout: 15 * time.Minute}
+	tests := []struct {
+		name     string
+		failures int
+		expected time.Duration
+	}{
+		{name: "free failure", failures: 2, expected: 0},
+		{name: "first delay", failures: 3, expected: time.Second},
+		{name: "doubles", failures: 5, expected: 4 * time.Second},
+		{name: "locked out", failures: 6, expected: 15 * time.Minute},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := policy.delay(test.failures)
+
+			if got != test.expected {
+				t.Errorf("got %s, expected %s", got, test.expected)
+			}
+		})
+	}
+
+	t.Run("delay never passes the lockout", func(t *testing.T) {
+		policy := ThrottlePolicy{FreeFailures: 0, MaxFailures: 100, BaseDelay: time.Second, Lockout: time.Minute}
+
+		if got := policy.delay(99); got != time.Minute {
+			t.Errorf("got %s, expected %s", got, time.Minute)
+		}
+	})
+}
+
+func TestLoginThrottle(t *testing.T) {
+	users := UserDatabase{UsersByEmail: map[string]*User{}, UsersByUsername: map[string]*User{}}
+	users.RegisterUser("mail@gmail.com", "tester", "Abc12345!")
+	start := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	newThrottle := func(clock *fakeClock) *LoginThrottle {
+		throttle := NewLoginThrottle()
+		throttle.Account = ThrottlePolicy{FreeFailures: 1, MaxFailures: 3, BaseDelay: time.Second, Lockout: time.Minute}
+		throttle.Source = ThrottlePolicy{FreeFailures: 4, MaxFailures: 5, BaseDelay: time.Second, Lockout: time.Hour}
+		throttle.Now = clock.Now
+		return throttle
+	}
+
+	t.Run("backoff then lockout", func(t *testing.T) {
+		clock := &fakeClock{now: start}
+		throttle := newThrottle(clock)
+
+		_, err := throttle.LogIn(users, "tester", "wrong", "a")
+		assertError(t, err, wrongPasswordErr)
+		_, err = throttle.LogIn(users, "tester", "wrong", "a")
+		assertError(t, err, wrongPasswordErr)
+		_, err = throttle.LogIn(users, "tester", "Abc12345!", "a")
+		assertError(t, err, LockedErr{Until: start.Add(time.Second)})
+
+		clock.now = start.Add(time.Second)
+		_, err = throttle.LogIn(users, "mail@gmail.com", "wrong", "a")
+		assertError(t, err, wrongPasswordErr)
+		_, err = throttle.LogIn(users, "tester", "Abc12345!", "a")
+		assertError(t, err, LockedErr{Until: clock.now.Add(time.Minute)})
+
+		clock.now = clock.now.Add(time.Minute)
+		_, err = throttle.LogIn(users, "tester", "Abc12345!", "a")
+		assertError(t, err, nil)
+	})
+
+	t.Run("success starts the account over", func(t *testing.T) {
+		clock := &fakeClock{now: start}
+		throttle := newThrottle(clock)
+
+		throttle.LogIn(users, "tester", "wrong", "a")
+		throttle.LogIn(users, "tester", "Abc12345!", "a")
+		_, err := throttle.LogIn(users, "tester", "wrong", "a")
+
+		assertError(t, err, wrongPasswordErr)
+		_, err = throttle.LogIn(users, "tester", "Abc12345!", "a")
+		assertError(t, err, nil)
+	})
+
+	t.Run("old failures are forgotten", func(t *testing.T) {
+		clock := &fakeClock{now: start}
+		throttle := newThrottle(clock)
+
+		throttle.LogIn(users, "tester", "wrong", "a")
+		clock.now = clock.now.Add(time.Minute)
+		throttle.LogIn(users, "tester", "wrong", "a")
+		_, err := throttle.LogIn(users, "tester", "Abc12345!", "a")
+
+		assertError(t, err, nil)
+	})
+
+	t.Run("source is locked across accounts", func(t *testing.T) {
+		clock := &fakeClock{now: start}
+		throttle := newThrottle(clock)
+
+		for _, id := range []string{"one", "two", "three", "four", "five"} {
+			_, err := throttle.LogIn(users, id, "wrong", "a")
+			assertError(t, err, userNotFoundErr)
+		}
+		_, err := throttle.LogIn(users, "tester", "Abc12345!", "a")
+		assertError(t, err, LockedErr{Until: start.Add(time.Hour)})
+		_, err = throttle.LogIn(users, "tester", "Abc12345!", "b")
+		assertError(t, err, nil)
+
+		throttle.UnlockSource("a")
+		_, err = throttle.LogIn(users, "tester", "Abc12345!", "a")
+		assertError(t, err, nil)
+	})
+
+	t.Run("forgotten failures are dropped", func(t *testing.T) {
+		clock := &fakeClock{now: start}
+		throttle := newThrottle(clock)
+
+		for _, id := range []string{"one", "two", "three"} {
+			throttle.LogIn(users, id, "wrong", id)
+		}
+		clock.now = start.Add(time.Hour)
+		throttle.LogIn(users, "four", "wrong", "four")
+
+		if len(throttle.accounts) != 1 || len(throttle.sources) != 1 {
+			t.Errorf("expected only the last failure to be kept, got %d accounts and %d sources", len(throttle.accounts), len(throttle.sources))
+		}
+	})
+
+	t.Run("unknown accounts are counted too", func(t *testing.T) {
+		clock := &fakeClock{now: start}
+		throttle := newThrottle(clock)
+
+		throttle.LogIn(users, "Nobody", "wrong", "a")
+		throttle.LogIn(users, "Nobody", "wrong", "a")
+		_, err := throttle.LogIn(users, "nobody", "wrong", "b")
+
+		assertError(t, err, LockedErr{Until: start.Add(time.Second)})
+	})
+
+	t.Run("wrong second factor codes are counted", func(t *testing.T) {
+		users, codes, _ := totpTestUsers(t)
+		clock := &fakeClock{now: start}
+		throttle := newThrottle(clock)
+
+		_, err := throttle.LogIn(users, "tester", "Abc12345!", "a")
+		required, ok := err.(SecondFactorRequired)
+		if !ok {
+			t.Fatalf("expected a second factor to be required, got %q", err)
+		}
+		throttle.CompleteLogIn(users, required.Challenge, "000000", "a")
+		throttle.CompleteLogIn(users, required.Challenge, "000000", "a")
+		_, err = throttle.CompleteLogIn(users, required.Challenge, codes[0], "a")
+
+		assertError(t, err, LockedErr{Until: start.Add(time.Second)})
+	})
+
+	t.Run("admin unlock", func(t *testing.T) {
+		clock := &fakeClock{now: start}
+		throttle := newThrottle(clock)
+		for range 3 {
+			throttle.LogIn(users, "tester", "wrong", "a")
+		}
+
+		assertError(t, throttle.Unlock(users, "mail@gmail.com"), nil)
+		_, err := throttle.LogIn(users, "tester", "Abc12345!", "b")
+
+		assertError(t, err, nil)
+		assertError(t, throttle.Unlock(users, "nobody"), userNotFoundErr)
+	})
+}
@@ -0,0 +1,371 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Codes follow RFC 6238 with the defaults every authenticator app supports:
+// HMAC-SHA1, six digits and a new code every 30 seconds.
+const (
+	TOTPDigits        = 6
+	TOTPPeriod        = 30 * time.Second
+	RecoveryCodeCount = 10
+	// SecondFactorTTL is how long the second factor can take after the
+	// password was right
+	SecondFactorTTL = 5 * time.Minute
+	// codes of the steps either side of now are accepted too, for clocks
+	// that are a bit off
+	totpSkew            = 1
+	wrongCodeErr        = SecondFactorErr("Incorrect code")
+	invalidSecretErr    = SecondFactorErr("Two-factor secret is not valid")
+	totpEnabledErr      = SecondFactorErr("Two-factor authentication is already on")
+	totpNotEnabledErr   = SecondFactorErr("Two-factor authentication is not on")
+	invalidChallengeErr = SecondFactorErr("Login took too long or had too many wrong codes, please log in again")
+	recoveryCodeLength  = 10
+	// wrong codes a challenge takes before the password has to be entered
+	// again
+	maxChallengeAttempts = 5
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+type SecondFactorErr string
+
+func (e SecondFactorErr) Error() string {
+	return string(e)
+}
+
+// SecondFactorRequired is returned by LogIn when the password was right but
+// the user has two-factor authentication on. CompleteLogIn finishes the login
+// with Challenge and a code, until Expires.
+type SecondFactorRequired struct {
+	Challenge string
+	Expires   time.Time
+}
+
+func (e SecondFactorRequired) Error() string {
+	return "Please enter the code from your authenticator app or a recovery code"
+}
+
+// challenge is a login waiting for its second factor, kept under the SHA-256
+// of the token handed out for it like sessions.
+type challenge struct {
+	UserId   uuid.UUID
+	Expires  time.Time
+	Attempts int
+}
+
+// LoginChallenges are the logins waiting for their second factor. They are
+// only kept in memory, a restart means entering the password again.
+type LoginChallenges struct {
+	mu      sync.Mutex
+	pending map[string]*challenge
+}
+
+func NewLoginChallenges() *LoginChallenges {
+	return &LoginChallenges{pending: make(map[string]*challenge)}
+}
+
+// add stands for the right password of user until the second factor is
+// checked, or SecondFactorTTL after now.
+func (challenges *LoginChallenges) add(user *User, now time.Time) (SecondFactorRequired, error) {
+	secret := make([]byte, 32)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return SecondFactorRequired{}, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(secret)
+	expires := now.Add(SecondFactorTTL)
+	challenges.mu.Lock()
+	defer challenges.mu.Unlock()
+	for hash, pending := range challenges.pending {
+		if !now.Before(pending.Expires) {
+			delete(challenges.pending, hash)
+		}
+	}
+	challenges.pending[hashToken(token)] = &challenge{UserId: user.Id, Expires: expires}
+	return SecondFactorRequired{Challenge: token, Expires: expires}, nil
+}
+
+// user tells whose login a challenge is, without redeeming it.
+func (challenges *LoginChallenges) user(token string) (uuid.UUID, bool) {
+	challenges.mu.Lock()
+	defer challenges.mu.Unlock()
+	pending, found := challenges.pending[hashToken(token)]
+	if !found {
+		return uuid.Nil, false
+	}
+	return pending.UserId, true
+}
+
+// HasTOTP tells whether user needs a second factor to log in.
+func (user User) HasTOTP() bool {
+	return user.TOTPSecret != ""
+}
+
+// NewTOTPSecret returns a random base32 secret to be shown to the user, it is
+// only stored once EnableTOTP confirms the user's app produces its codes.
+func NewTOTPSecret() (string, error) {
+	secret := make([]byte, 20)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(secret), nil
+}
+
+// TOTPURI returns the otpauth:// URI authenticator apps read from a QR code.
+func TOTPURI(issuer, account, secret string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(TOTPDigits))
+	query.Set("period", fmt.Sprint(int(TOTPPeriod/time.Second)))
+	label := url.PathEscape(issuer + ":" + account)
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+// TOTPCode returns the code of secret at the given time.
+func TOTPCode(secret string, at time.Time) (string, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return hotp(key, totpStep(at)), nil
+}
+
+func decodeSecret(secret string) ([]byte, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(strings.ReplaceAll(secret, " ", "")))
+	if err != nil || len(key) == 0 {
+		return nil, invalidSecretErr
+	}
+	return key, nil
+}
+
+func totpStep(at time.Time) int64 {
+	return at.Unix() / int64(TOTPPeriod/time.Second)
+}
+
+// hotp is the HMAC-based one-time password of RFC 4226.
+func hotp(key []byte, counter int64) string {
+	message := make([]byte, 8)
+	binary.BigEndian.PutUint64(message, uint64(counter))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(message)
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	modulo := uint32(1)
+	for range TOTPDigits {
+		modulo *= 10
+	}
+	return fmt.Sprintf("%0*d", TOTPDigits, value%modulo)
+}
+
+// matchTOTP returns the step code was made for, codes of lastStep or earlier
+// were used already and don't match.
+func matchTOTP(secret, code string, now time.Time, lastStep int64) (int64, bool) {
+	key, err := decodeSecret(secret)
+	if err != nil || len(code) != TOTPDigits {
+		return 0, false
+	}
+	current := totpStep(now)
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if step <= lastStep {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(hotp(key, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// checkSecondFactor accepts a code of the user's app or one of their
+// recovery codes, each usable once.
+func checkSecondFactor(user *User, code string, now time.Time) error {
+	code = strings.TrimSpace(code)
+	step, ok := matchTOTP(user.TOTPSecret, code, now, user.TOTPLastStep)
+	if ok {
+		user.TOTPLastStep = step
+		return nil
+	}
+	// app codes are not worth the slow hashes of the recovery codes
+	recovery := normalizeRecoveryCode(code)
+	if len(recovery) != recoveryCodeLength {
+		return wrongCodeErr
+	}
+	for i, hash := range user.RecoveryCodes {
+		if compareRecoveryCode(hash, recovery) {
+			user.RecoveryCodes = append(user.RecoveryCodes[:i:i], user.RecoveryCodes[i+1:]...)
+			return nil
+		}
+	}
+	return wrongCodeErr
+}
+
+// compareRecoveryCode tells whether code is the one hashed. Codes made before
+// they were hashed like passwords are an unsalted SHA-256, which every hash
+// of a PasswordHasher is told apart from by its leading $.
+func compareRecoveryCode(hash, code string) bool {
+	if !strings.HasPrefix(hash, "$") {
+		return subtle.ConstantTimeCompare([]byte(hash), []byte(hashToken(code))) == 1
+	}
+	return comparePassword(hash, code)
+}
+
+// CompleteLogIn finishes a login LogIn answered with SecondFactorRequired,
+// with the challenge it carried. A challenge logs in once and is dropped after
+// too many wrong codes, when it expires or when the user can no longer log in.
+func CompleteLogIn(users UserDatabase, challenges *LoginChallenges, challenge, code string, now time.Time) (User, error) {
+	return challenges.complete(users, challenge, code, "", now)
+}
+
+// complete is CompleteLogIn for a login from source, which is recorded along
+// with it.
+func (challenges *LoginChallenges) complete(users UserDatabase, token, code, source string, now time.Time) (User, error) {
+	challenges.mu.Lock()
+	defer challenges.mu.Unlock()
+	hash := hashToken(strings.TrimSpace(token))
+	pending, found := challenges.pending[hash]
+	if !found || !now.Before(pending.Expires) {
+		delete(challenges.pending, hash)
+		return User{}, invalidChallengeErr
+	}
+	user, err := users.GetUserById(pending.UserId)
+	if err != nil {
+		delete(challenges.pending, hash)
+		return User{}, userNotFoundErr
+	}
+	// the account may have been restricted since the password was checked
+	err = CanLogIn(*user)
+	if err == nil && !user.HasTOTP() {
+		err = totpNotEnabledErr
+	}
+	if err != nil {
+		delete(challenges.pending, hash)
+		recordLogin(user, user.Username, source, err, now)
+		return User{}, err
+	}
+	err = checkSecondFactor(user, code, now)
+	recordLogin(user, user.Username, source, err, now)
+	if err != nil {
+		pending.Attempts++
+		if pending.Attempts >= maxChallengeAttempts {
+			delete(challenges.pending, hash)
+		}
+		return User{}, err
+	}
+	delete(challenges.pending, hash)
+	return *user, nil
+}
+
+// EnableTOTP turns on two-factor authentication with a secret from
+// NewTOTPSecret once code shows the user's app has it. It returns the
+// recovery codes, which are only kept hashed like passwords so they can't be
+// shown again.
+func EnableTOTP(users UserDatabase, id, secret, code string, now time.Time) ([]string, error) {
+	user, err := users.getUser(id)
+	if err != nil {
+		return nil, userNotFoundErr
+	}
+	if user.HasTOTP() {
+		return nil, totpEnabledErr
+	}
+	_, err = decodeSecret(secret)
+	if err != nil {
+		return nil, err
+	}
+	step, ok := matchTOTP(secret, strings.TrimSpace(code), now, 0)
+	if !ok {
+		return nil, wrongCodeErr
+	}
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	user.TOTPSecret = secret
+	user.TOTPLastStep = step
+	user.RecoveryCodes = hashes
+	return codes, nil
+}
+
+// DisableTOTP turns off two-factor authentication, asking for a code so a
+// left open session is not enough.
+func DisableTOTP(users UserDatabase, id, code string, now time.Time) error {
+	user, err := users.getUser(id)
+	if err != nil {
+		return userNotFoundErr
+	}
+	if !user.HasTOTP() {
+		return totpNotEnabledErr
+	}
+	err = checkSecondFactor(user, code, now)
+	if err != nil {
+		return err
+	}
+	user.TOTPSecret = ""
+	user.TOTPLastStep = 0
+	user.RecoveryCodes = nil
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of a user, the old ones
+// stop working.
+func RegenerateRecoveryCodes(users UserDatabase, id, code string, now time.Time) ([]string, error) {
+	user, err := users.getUser(id)
+	if err != nil {
+		return nil, userNotFoundErr
+	}
+	if !user.HasTOTP() {
+		return nil, totpNotEnabledErr
+	}
+	err = checkSecondFactor(user, code, now)
+	if err != nil {
+		return nil, err
+	}
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	user.RecoveryCodes = hashes
+	return codes, nil
+}
+
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, RecoveryCodeCount)
+	hashes := make([]string, RecoveryCodeCount)
+	for i := range codes {
+		secret := make([]byte, 8)
+		_, err := rand.Read(secret)
+		if err != nil {
+			return nil, nil, err
+		}
+		code := strings.ToLower(totpEncoding.EncodeToString(secret))[:recoveryCodeLength]
+		codes[i] = code[:recoveryCodeLength/2] + "-" + code[recoveryCodeLength/2:]
+		hashes[i], err = hashPassword(code)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
@@ -0,0 +1,268 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTOTPCode(t *testing.T) {
+	// the SHA1 vectors of RFC 6238, which uses eight digits, cut to six
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	tests := []struct {
+		name     string
+		at       int64
+		expected string
+	}{
+		{name: "59", at: 59, expected: "287082"},
+		{name: "1111111109", at: 1111111109, expected: "081804"},
+		{name: "1234567890", at: 1234567890, expected: "005924"},
+		{name: "2000000000", at: 2000000000, expected: "279037"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := TOTPCode(secret, time.Unix(test.at, 0))
+
+			assertError(t, err, nil)
+			if got != test.expected {
+				t.Errorf("got %q, expected %q", got, test.expected)
+			}
+		})
+	}
+
+	t.Run("invalid secret", func(t *testing.T) {
+		_, err := TOTPCode("not base32!", time.Unix(59, 0))
+
+		assertError(t, err, invalidSecretErr)
+	})
+}
+
+func TestTOTPURI(t *testing.T) {
+	got := TOTPURI("Todo App", "tester", "JBSWY3DPEHPK3PXP")
+
+	expected := "otpauth://totp/Todo%20App:tester?algorithm=SHA1&digits=6&issuer=Todo+App&period=30&secret=JBSWY3DPEHPK3PXP"
+	if got != expected {
+		t.Errorf("got %q, expected %q", got, expected)
+	}
+}
+
+func TestEnableTOTP(t *testing.T) {
+	users := UserDatabase{UsersByEmail: map[string]*User{}, UsersByUsername: map[string]*User{}}
+	users.RegisterUser("mail@gmail.com", "tester", "Abc12345!")
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	secret, err := NewTOTPSecret()
+	assertError(t, err, nil)
+	code, _ := TOTPCode(secret, now)
+	tests := []struct {
+		name           string
+		secret         string
+		code           string
+		expected_error error
+	}{
+		{name: "invalid secret", secret: "not base32!", code: code, expected_error: invalidSecretErr},
+		{name: "wrong code", secret: secret, code: "000000", expected_error: wrongCodeErr},
+		{name: "valid code", secret: secret, code: code, expected_error: nil},
+		{name: "already on", secret: secret, code: code, expected_error: totpEnabledErr},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			codes, err := EnableTOTP(users, "tester", test.secret, test.code, now)
+
+			assertError(t, err, test.expected_error)
+			if err == nil && len(codes) != RecoveryCodeCount {
+				t.Errorf("got %d recovery codes, expected %d", len(codes), RecoveryCodeCount)
+			}
+		})
+	}
+	user := users.UsersByUsername["tester"]
+	if !user.HasTOTP() || len(user.RecoveryCodes) != RecoveryCodeCount {
+		t.Fatalf("expected two-factor to be stored on the user, got %v", user)
+	}
+	for _, hash := range user.RecoveryCodes {
+		if !strings.HasPrefix(hash, "$2a$") {
+			t.Errorf("expected recovery codes to be hashed like passwords, got %q", hash)
+		}
+	}
+}
+
+func TestTwoStepLogIn(t *testing.T) {
+	users, codes, start := totpTestUsers(t)
+	challenges := NewLoginChallenges()
+	user := *users.UsersByUsername["tester"]
+	codeAt := func(at time.Time) string {
+		code, _ := TOTPCode(user.TOTPSecret, at)
+		return code
+	}
+
+	t.Run("password asks for a second factor", func(t *testing.T) {
+		got, err := LogIn(users, NewLoginChallenges(), "tester", "Abc12345!")
+
+		required, ok := err.(SecondFactorRequired)
+		if !ok || required.Challenge == "" {
+			t.Fatalf("expected a challenge for the second factor, got %v", err)
+		}
+		if got.Id == user.Id {
+			t.Error("expected no user before the second factor")
+		}
+	})
+
+	tests := []struct {
+		name           string
+		code           string
+		now            time.Time
+		expected_error error
+	}{
+		{name: "code of the step it was enrolled at", code: codeAt(start), now: start, expected_error: wrongCodeErr},
+		{name: "code of the previous step", code: codeAt(start.Add(TOTPPeriod)), now: start.Add(2 * TOTPPeriod), expected_error: nil},
+		{name: "same code again", code: codeAt(start.Add(TOTPPeriod)), now: start.Add(2 * TOTPPeriod), expected_error: wrongCodeErr},
+		{name: "code of the next step", code: codeAt(start.Add(4 * TOTPPeriod)), now: start.Add(3 * TOTPPeriod), expected_error: nil},
+		{name: "code too old", code: codeAt(start.Add(5 * TOTPPeriod)), now: start.Add(10 * TOTPPeriod), expected_error: wrongCodeErr},
+		{name: "recovery code", code: " " + strings.ToUpper(strings.ReplaceAll(codes[0], "-", "")) + "\n", now: start, expected_error: nil},
+		{name: "recovery code used twice", code: codes[0], now: start, expected_error: wrongCodeErr},
+		{name: "other recovery code", code: codes[1], now: start, expected_error: nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := CompleteLogIn(users, challenges, challengeAt(t, users, challenges, test.now), test.code, test.now)
+
+			assertError(t, err, test.expected_error)
+			if err == nil && got.Id != user.Id {
+				t.Errorf("got %v, expected the user", got)
+			}
+		})
+	}
+	if len(users.UsersByUsername["tester"].RecoveryCodes) != RecoveryCodeCount-2 {
+		t.Errorf("expected used recovery codes to be removed, got %d left", len(users.UsersByUsername["tester"].RecoveryCodes))
+	}
+}
+
+func TestLegacyRecoveryCodes(t *testing.T) {
+	users, _, start := totpTestUsers(t)
+	challenges := NewLoginChallenges()
+	users.UsersByUsername["tester"].RecoveryCodes = []string{hashToken("abcde12345")}
+
+	_, err := CompleteLogIn(users, challenges, challengeAt(t, users, challenges, start), "abcde-12345", start)
+
+	assertError(t, err, nil)
+	if len(users.UsersByUsername["tester"].RecoveryCodes) != 0 {
+		t.Errorf("expected the recovery code to be used up")
+	}
+}
+
+func TestLogInChallenge(t *testing.T) {
+	users, _, start := totpTestUsers(t)
+	challenges := NewLoginChallenges()
+	user := users.UsersByUsername["tester"]
+	code, _ := TOTPCode(user.TOTPSecret, start.Add(TOTPPeriod))
+
+	t.Run("unknown challenge", func(t *testing.T) {
+		_, err := CompleteLogIn(users, challenges, user.Id.String(), code, start)
+
+		assertError(t, err, invalidChallengeErr)
+	})
+
+	t.Run("challenge kept elsewhere", func(t *testing.T) {
+		challenge := challengeAt(t, users, NewLoginChallenges(), start)
+
+		_, err := CompleteLogIn(users, challenges, challenge, code, start)
+
+		assertError(t, err, invalidChallengeErr)
+	})
+
+	t.Run("expired challenge", func(t *testing.T) {
+		challenge := challengeAt(t, users, challenges, start)
+
+		_, err := CompleteLogIn(users, challenges, challenge, code, start.Add(SecondFactorTTL))
+
+		assertError(t, err, invalidChallengeErr)
+	})
+
+	t.Run("challenge used twice", func(t *testing.T) {
+		challenge := challengeAt(t, users, challenges, start)
+		_, err := CompleteLogIn(users, challenges, challenge, code, start.Add(TOTPPeriod))
+		assertError(t, err, nil)
+
+		_, err = CompleteLogIn(users, challenges, challenge, code, start.Add(TOTPPeriod))
+
+		assertError(t, err, invalidChallengeErr)
+	})
+
+	t.Run("too many wrong codes", func(t *testing.T) {
+		challenge := challengeAt(t, users, challenges, start)
+		for range maxChallengeAttempts {
+			CompleteLogIn(users, challenges, challenge, "000000", start)
+		}
+
+		_, err := CompleteLogIn(users, challenges, challenge, code, start)
+
+		assertError(t, err, invalidChallengeErr)
+	})
+
+	t.Run("user disabled after the password", func(t *testing.T) {
+		challenge := challengeAt(t, users, challenges, start)
+		user.Disabled = true
+		defer func() { user.Disabled = false }()
+
+		_, err := CompleteLogIn(users, challenges, challenge, code, start)
+
+		assertError(t, err, accountDisabledErr)
+		_, err = CompleteLogIn(users, challenges, challenge, code, start)
+		assertError(t, err, invalidChallengeErr)
+	})
+}
+
+func TestDisableTOTP(t *testing.T) {
+	users, codes, start := totpTestUsers(t)
+
+	assertError(t, DisableTOTP(users, "tester", "000000", start), wrongCodeErr)
+	assertError(t, DisableTOTP(users, "tester", codes[0], start), nil)
+	assertError(t, DisableTOTP(users, "tester", codes[1], start), totpNotEnabledErr)
+	_, err := LogIn(users, NewLoginChallenges(), "tester", "Abc12345!")
+	assertError(t, err, nil)
+}
+
+func TestRegenerateRecoveryCodes(t *testing.T) {
+	users, codes, start := totpTestUsers(t)
+	challenges := NewLoginChallenges()
+
+	fresh, err := RegenerateRecoveryCodes(users, "tester", codes[0], start)
+
+	assertError(t, err, nil)
+	_, err = CompleteLogIn(users, challenges, challengeAt(t, users, challenges, start), codes[1], start)
+	assertError(t, err, wrongCodeErr)
+	_, err = CompleteLogIn(users, challenges, challengeAt(t, users, challenges, start), fresh[0], start)
+	assertError(t, err, nil)
+}
+
+//helpers
+
+// totpTestUsers returns users with "tester" enrolled at the time returned,
+// and the recovery codes of the enrollment.
+func totpTestUsers(t testing.TB) (UserDatabase, []string, time.Time) {
+	t.Helper()
+	users := UserDatabase{UsersByEmail: map[string]*User{}, UsersByUsername: map[string]*User{}}
+	users.RegisterUser("mail@gmail.com", "tester", "Abc12345!")
+	start := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	secret, _ := NewTOTPSecret()
+	code, _ := TOTPCode(secret, start)
+	codes, err := EnableTOTP(users, "tester", secret, code, start)
+	if err != nil {
+		t.Fatalf("could not enroll test user: %q", err)
+	}
+	return users, codes, start
+}
+
+// challengeAt returns the challenge of a login of "tester" at now, kept in
+// challenges.
+func challengeAt(t testing.TB, users UserDatabase, challenges *LoginChallenges, now time.Time) string {
+	t.Helper()
+	_, err := logIn(users, challenges, "tester", "Abc12345!", "", now)
+	required, ok := err.(SecondFactorRequired)
+	if !ok {
+		t.Fatalf("expected the second factor to be required, got %v", err)
+	}
+	return required.Challenge
+}
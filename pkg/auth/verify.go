@@ -0,0 +1,190 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"todo_app/pkg/mailer"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultVerificationTTL = 48 * time.Hour
+	invalidVerificationErr = TokenErr("Verification code is invalid or has expired")
+	alreadyVerifiedErr     = VerificationErr("This email has already been verified")
+	unverifiedEmailErr     = VerificationErr("Please verify your email first, check your inbox for the code we sent you")
+	invalidEnforcementErr  = VerificationErr("Email verification must be off, tasks or login")
+)
+
+// Enforcement is what unverified users are kept from doing: nothing, adding
+// tasks, or logging in at all, which also keeps them from adding tasks.
+type Enforcement int
+
+const (
+	VerificationOptional Enforcement = iota
+	VerificationForTasks
+	VerificationForLogin
+)
+
+var (
+	enforcementNames = []string{"off", "tasks", "login"}
+	enforcement      = VerificationOptional
+)
+
+type VerificationErr string
+
+func (e VerificationErr) Error() string {
+	return string(e)
+}
+
+// ParseEnforcement reads an enforcement by name: off, tasks or login.
+func ParseEnforcement(value string) (Enforcement, error) {
+	for i, name := range enforcementNames {
+		if strings.EqualFold(strings.TrimSpace(value), name) {
+			return Enforcement(i), nil
+		}
+	}
+	return VerificationOptional, invalidEnforcementErr
+}
+
+// RequireVerifiedEmail sets what unverified users cannot do, by default they
+// can do everything.
+func RequireVerifiedEmail(level Enforcement) {
+	enforcement = level
+}
+
+// CanLogIn tells whether user is allowed to log in, LogIn already checks it.
+func CanLogIn(user User) error {
+	if user.Disabled {
+		return accountDisabledErr
+	}
+	if user.PasswordResetRequired {
+		return passwordResetForcedErr
+	}
+	if enforcement >= VerificationForLogin && !user.Verified {
+		return unverifiedEmailErr
+	}
+	return nil
+}
+
+// CanCreateTasks tells whether user is allowed to add tasks.
+func CanCreateTasks(user User) error {
+	if enforcement >= VerificationForTasks && !user.Verified {
+		return unverifiedEmailErr
+	}
+	return nil
+}
+
+// Verification is a code mailed to a user, kept under the SHA-256 of the code.
+type Verification struct {
+	TokenHash string
+	UserId    uuid.UUID
+	Expires   time.Time
+}
+
+// EmailVerifications mails codes that prove a user owns their email address.
+type EmailVerifications struct {
+	TTL           time.Duration
+	Now           func() time.Time
+	mu            sync.Mutex
+	verifications map[string]*Verification
+}
+
+func NewEmailVerifications(ttl time.Duration) *EmailVerifications {
+	return &EmailVerifications{TTL: ttl, Now: time.Now, verifications: make(map[string]*Verification)}
+}
+
+// Send mails a new code to the user with the given email or username. Codes
+// sent before keep working until they expire.
+func (verifications *EmailVerifications) Send(users UserDatabase, sender mailer.Mailer, id string) error {
+	user, err := users.getUser(id)
+	if err != nil {
+		return userNotFoundErr
+	}
+	if user.Verified {
+		return alreadyVerifiedErr
+	}
+	secret := make([]byte, 32)
+	_, err = rand.Read(secret)
+	if err != nil {
+		return err
+	}
+	token := base64.RawURLEncoding.EncodeToString(secret)
+
+	verifications.mu.Lock()
+	now := verifications.Now()
+	verifications.prune(now)
+	verifications.verifications[hashToken(token)] = &Verification{TokenHash: hashToken(token), UserId: user.Id, Expires: now.Add(verifications.TTL)}
+	verifications.mu.Unlock()
+
+	err = sender.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email",
+		Body: fmt.Sprintf("Hi %s,\n\nUse this code to verify your email:\n\n%s\n\nIt expires in %s.\n",
+			user.Username, token, verifications.TTL),
+	})
+	if err != nil {
+		verifications.mu.Lock()
+		delete(verifications.verifications, hashToken(token))
+		verifications.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Verify marks the user a code was sent to as verified. Every code of that
+// user stops working.
+func (verifications *EmailVerifications) Verify(users UserDatabase, token string) (User, error) {
+	verifications.mu.Lock()
+	defer verifications.mu.Unlock()
+	verification, found := verifications.verifications[hashToken(strings.TrimSpace(token))]
+	if !found || !verifications.Now().Before(verification.Expires) {
+		return User{}, invalidVerificationErr
+	}
+	user, err := users.GetUserById(verification.UserId)
+	if err != nil {
+		delete(verifications.verifications, verification.TokenHash)
+		return User{}, invalidVerificationErr
+	}
+	user.Verified = true
+	for hash, other := range verifications.verifications {
+		if other.UserId == user.Id {
+			delete(verifications.verifications, hash)
+		}
+	}
+	return *user, nil
+}
+
+// List returns the codes that have not expired, oldest first, so they can be
+// saved.
+func (verifications *EmailVerifications) List() []Verification {
+	verifications.mu.Lock()
+	defer verifications.mu.Unlock()
+	verifications.prune(verifications.Now())
+	list := make([]Verification, 0, len(verifications.verifications))
+	for _, verification := range verifications.verifications {
+		list = append(list, *verification)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Expires.Before(list[j].Expires) })
+	return list
+}
+
+// Restore puts back a code returned by List, e.g. after a restart.
+func (verifications *EmailVerifications) Restore(verification Verification) {
+	verifications.mu.Lock()
+	defer verifications.mu.Unlock()
+	verifications.verifications[verification.TokenHash] = &verification
+}
+
+func (verifications *EmailVerifications) prune(now time.Time) {
+	for hash, verification := range verifications.verifications {
+		if !now.Before(verification.Expires) {
+			delete(verifications.verifications, hash)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"todo_app/pkg/mailer"
+)
+
+func TestSendVerification(t *testing.T) {
+	users, user := sessionTestUsers()
+	verified := User{Email: "done@gmail.com", Username: "done", Verified: true}
+	users.UsersByUsername[verified.Username] = &verified
+	tests := []struct {
+		name           string
+		id             string
+		expected_mails int
+		expected_error error
+	}{
+		{name: "by username", id: user.Username, expected_mails: 1, expected_error: nil},
+		{name: "by email", id: user.Email, expected_mails: 1, expected_error: nil},
+		{name: "unknown user", id: "nobody", expected_mails: 0, expected_error: userNotFoundErr},
+		{name: "already verified", id: verified.Username, expected_mails: 0, expected_error: alreadyVerifiedErr},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sent := &mailer.Memory{}
+			verifications := NewEmailVerifications(time.Hour)
+
+			err := verifications.Send(users, sent, test.id)
+
+			assertError(t, err, test.expected_error)
+			if len(sent.Messages()) != test.expected_mails {
+				t.Errorf("got %d mails, expected %d", len(sent.Messages()), test.expected_mails)
+			}
+		})
+	}
+}
+
+func TestVerifyEmail(t *testing.T) {
+	users, user := sessionTestUsers()
+	clock := &fakeClock{now: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)}
+	verifications := NewEmailVerifications(time.Hour)
+	verifications.Now = clock.Now
+	sent := &mailer.Memory{}
+	verifications.Send(users, sent, user.Username)
+	expired := verificationCode(t, sent)
+	clock.now = clock.now.Add(time.Hour)
+	verifications.Send(users, sent, user.Username)
+	code := verificationCode(t, sent)
+	tests := []struct {
+		name           string
+		token          string
+		expected_error error
+	}{
+		{name: "expired code", token: expired, expected_error: invalidVerificationErr},
+		{name: "unknown code", token: "nope", expected_error: invalidVerificationErr},
+		{name: "valid code", token: code + "\n", expected_error: nil},
+		{name: "code used twice", token: code, expected_error: invalidVerificationErr},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := verifications.Verify(users, test.token)
+
+			assertError(t, err, test.expected_error)
+			if err == nil && !got.Verified {
+				t.Errorf("expected the user to be verified, got %v", got)
+			}
+		})
+	}
+	if !users.UsersByUsername[user.Username].Verified {
+		t.Error("expected the verified state to be stored on the user")
+	}
+}
+
+func TestVerificationEnforcement(t *testing.T) {
+	users := UserDatabase{UsersByEmail: map[string]*User{}, UsersByUsername: map[string]*User{}}
+	users.RegisterUser("mail@gmail.com", "tester", "Abc12345!")
+	users.RegisterUser("other@gmail.com", "verified", "Abc12345!")
+	users.UsersByUsername["verified"].Verified = true
+	t.Cleanup(func() { RequireVerifiedEmail(VerificationOptional) })
+	tests := []struct {
+		name                 string
+		level                string
+		username             string
+		expected_login_error error
+		expected_task_error  error
+	}{
+		{name: "off", level: "off", username: "tester", expected_login_error: nil, expected_task_error: nil},
+		{name: "tasks", level: "tasks", username: "tester", expected_login_error: nil, expected_task_error: unverifiedEmailErr},
+		{name: "login", level: "Login", username: "tester", expected_login_error: unverifiedEmailErr, expected_task_error: unverifiedEmailErr},
+		{name: "login when verified", level: "login", username: "verified", expected_login_error: nil, expected_task_error: nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			level, err := ParseEnforcement(test.level)
+			assertError(t, err, nil)
+			RequireVerifiedEmail(level)
+
+			_, err = LogIn(users, NewLoginChallenges(), test.username, "Abc12345!")
+			assertError(t, err, test.expected_login_error)
+			err = CanCreateTasks(*users.UsersByUsername[test.username])
+			assertError(t, err, test.expected_task_error)
+		})
+	}
+
+	t.Run("wrong password is still reported first", func(t *testing.T) {
+		_, err := LogIn(users, NewLoginChallenges(), "tester", "wrong")
+		assertError(t, err, wrongPasswordErr)
+	})
+
+	t.Run("unknown enforcement", func(t *testing.T) {
+		_, err := ParseEnforcement("always")
+		assertError(t, err, invalidEnforcementErr)
+	})
+}
+
+func TestRestoreVerification(t *testing.T) {
+	users, user := sessionTestUsers()
+	verifications := NewEmailVerifications(time.Hour)
+	sent := &mailer.Memory{}
+	verifications.Send(users, sent, user.Username)
+
+	restarted := NewEmailVerifications(time.Hour)
+	for _, verification := range verifications.List() {
+		restarted.Restore(verification)
+	}
+	_, err := restarted.Verify(users, verificationCode(t, sent))
+
+	assertError(t, err, nil)
+}
+
+//helpers
+
+func verificationCode(t testing.TB, sent *mailer.Memory) string {
+	t.Helper()
+	messages := sent.Messages()
+	if len(messages) == 0 {
+		t.Fatal("expected a verification mail")
+	}
+	_, after, _ := strings.Cut(messages[len(messages)-1].Body, "verify your email:\n\n")
+	code, _, _ := strings.Cut(after, "\n")
+	if code == "" {
+		t.Fatalf("expected a code in %q", messages[len(messages)-1].Body)
+	}
+	return code
+}
@@ -0,0 +1,182 @@
+package blobs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+var hashRegex = regexp.MustCompile("^[a-f0-9]{64}$")
+
+const (
+	DefaultMaxSize  = 10 << 20
+	BlobTooLargeErr = BlobError("File exceeds the maximum attachment size")
+	BlobNotFoundErr = BlobError("Blob not found")
+	InvalidHashErr  = BlobError("Blob hash is not a valid SHA-256 digest")
+
+	// UploadGrace is how old an unfinished upload has to be before Sweep
+	// takes it for one left behind by a crash, not one another process is
+	// still writing.
+	UploadGrace  = time.Hour
+	uploadPrefix = "upload-"
+)
+
+type BlobError string
+
+func (err BlobError) Error() string {
+	return string(err)
+}
+
+// Store keeps file contents on disk named after their SHA-256 digest, so the
+// same file attached twice is only stored once. refs counts how many
+// attachments point at each blob; a blob is removed when its count drops to 0.
+// A Store can be used from several goroutines, e.g. by the API server.
+type Store struct {
+	Dir     string
+	MaxSize int64
+	mu      sync.Mutex
+	refs    map[string]int
+}
+
+func NewStore(dir string, maxSize int64) (*Store, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{Dir: dir, MaxSize: maxSize, refs: make(map[string]int)}, nil
+}
+
+func (store *Store) path(hash string) string {
+	return filepath.Join(store.Dir, hash[:2], hash)
+}
+
+// Put copies content into the store and takes a reference on the resulting
+// blob. Content larger than MaxSize is rejected and nothing is kept.
+func (store *Store) Put(content io.Reader) (string, int64, error) {
+	tmp, err := os.CreateTemp(store.Dir, uploadPrefix+"*")
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hasher), io.LimitReader(content, store.MaxSize+1))
+	if err != nil {
+		return "", 0, err
+	}
+	if size > store.MaxSize {
+		return "", 0, BlobTooLargeErr
+	}
+	hash := hex.EncodeToString(hasher.Sum(nil))
+
+	// a blob can't be removed by Release between finding it and counting it
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	_, statErr := os.Stat(store.path(hash))
+	if os.IsNotExist(statErr) {
+		err = os.MkdirAll(filepath.Dir(store.path(hash)), 0755)
+		if err != nil {
+			return "", 0, err
+		}
+		err = tmp.Close()
+		if err != nil {
+			return "", 0, err
+		}
+		err = os.Rename(tmp.Name(), store.path(hash))
+		if err != nil {
+			return "", 0, err
+		}
+	}
+	store.refs[hash]++
+	return hash, size, nil
+}
+
+// Retain takes a reference on a blob that is already on disk, used when
+// loading saved attachments.
+func (store *Store) Retain(hash string) error {
+	if !hashRegex.MatchString(hash) {
+		return InvalidHashErr
+	}
+	_, err := os.Stat(store.path(hash))
+	if err != nil {
+		return BlobNotFoundErr
+	}
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	store.refs[hash]++
+	return nil
+}
+
+// Release drops a reference and deletes the blob once nothing points at it.
+func (store *Store) Release(hash string) error {
+	if !hashRegex.MatchString(hash) {
+		return InvalidHashErr
+	}
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	count, found := store.refs[hash]
+	if !found {
+		return BlobNotFoundErr
+	}
+	if count > 1 {
+		store.refs[hash] = count - 1
+		return nil
+	}
+	delete(store.refs, hash)
+	err := os.Remove(store.path(hash))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (store *Store) Open(hash string) (*os.File, error) {
+	if !hashRegex.MatchString(hash) {
+		return nil, InvalidHashErr
+	}
+	file, err := os.Open(store.path(hash))
+	if err != nil {
+		return nil, BlobNotFoundErr
+	}
+	return file, nil
+}
+
+func (store *Store) Refs(hash string) int {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	return store.refs[hash]
+}
+
+// Sweep removes blobs nobody holds a reference to, e.g. ones left behind when
+// the app exited before saving the task that pointed at them, and uploads
+// older than UploadGrace.
+func (store *Store) Sweep() error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	return filepath.WalkDir(store.Dir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		name := entry.Name()
+		if hashRegex.MatchString(name) && store.refs[name] > 0 {
+			return nil
+		}
+		if strings.HasPrefix(name, uploadPrefix) {
+			info, err := entry.Info()
+			if err != nil || time.Since(info.ModTime()) < UploadGrace {
+				return nil
+			}
+		}
+		return os.Remove(path)
+	})
+}
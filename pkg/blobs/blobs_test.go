@@ -0,0 +1,188 @@
+package blobs
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPut(t *testing.T) {
+	store := newTestStore(t, 16)
+	tests := []struct {
+		name           string
+		input          string
+		expected_hash  string
+		expected_error error
+	}{
+		{name: "small file", input: "hello", expected_hash: "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", expected_error: nil},
+		{name: "empty file", input: "", expected_hash: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", expected_error: nil},
+		{name: "file at size limit", input: "aaaaaaaaaaaaaaaa", expected_hash: "0c0beacef8877bbf2416eb00f2b5dc96354e26dd1df5517320459b1236860f8c", expected_error: nil},
+		{name: "file above size limit", input: "aaaaaaaaaaaaaaaaa", expected_hash: "", expected_error: BlobTooLargeErr},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hash, size, err := store.Put(strings.NewReader(test.input))
+
+			if err != test.expected_error {
+				t.Fatalf("unexpected error, got %q, expected %q", err, test.expected_error)
+			}
+			if hash != test.expected_hash {
+				t.Errorf("got hash %q, expected %q", hash, test.expected_hash)
+			}
+			if err == nil {
+				if size != int64(len(test.input)) {
+					t.Errorf("got size %d, expected %d", size, len(test.input))
+				}
+				assertContent(t, store, hash, test.input)
+			}
+		})
+	}
+}
+
+func TestDedup(t *testing.T) {
+	store := newTestStore(t, DefaultMaxSize)
+
+	first, _, _ := store.Put(strings.NewReader("same bytes"))
+	second, _, _ := store.Put(strings.NewReader("same bytes"))
+
+	if first != second {
+		t.Fatalf("expected identical content to share a blob, got %q and %q", first, second)
+	}
+	if store.Refs(first) != 2 {
+		t.Errorf("expected 2 references, got %d", store.Refs(first))
+	}
+}
+
+func TestRelease(t *testing.T) {
+	store := newTestStore(t, DefaultMaxSize)
+	hash, _, _ := store.Put(strings.NewReader("log file"))
+	store.Put(strings.NewReader("log file"))
+
+	t.Run("blob kept while referenced", func(t *testing.T) {
+		err := store.Release(hash)
+
+		assertError(t, err, nil)
+		assertContent(t, store, hash, "log file")
+	})
+
+	t.Run("blob removed with last reference", func(t *testing.T) {
+		err := store.Release(hash)
+
+		assertError(t, err, nil)
+		_, openErr := store.Open(hash)
+		assertError(t, openErr, BlobNotFoundErr)
+	})
+
+	t.Run("unknown blob", func(t *testing.T) {
+		err := store.Release(hash)
+
+		assertError(t, err, BlobNotFoundErr)
+	})
+
+	t.Run("invalid hash", func(t *testing.T) {
+		err := store.Release("../users.csv")
+
+		assertError(t, err, InvalidHashErr)
+	})
+}
+
+func TestConcurrentPutAndRelease(t *testing.T) {
+	store := newTestStore(t, DefaultMaxSize)
+	hash, _, _ := store.Put(strings.NewReader("log file"))
+
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			put, _, err := store.Put(strings.NewReader("log file"))
+			assertError(t, err, nil)
+			assertError(t, store.Release(put), nil)
+		}()
+	}
+	wg.Wait()
+
+	if store.Refs(hash) != 1 {
+		t.Fatalf("expected the first reference to be left, got %d", store.Refs(hash))
+	}
+	assertContent(t, store, hash, "log file")
+}
+
+func TestRetainAndSweep(t *testing.T) {
+	dir := t.TempDir()
+	store, _ := NewStore(dir, DefaultMaxSize)
+	kept, _, _ := store.Put(strings.NewReader("kept"))
+	orphan, _, _ := store.Put(strings.NewReader("orphan"))
+
+	reloaded, _ := NewStore(dir, DefaultMaxSize)
+	assertError(t, reloaded.Retain(kept), nil)
+	assertError(t, reloaded.Retain(strings.Repeat("0", 64)), BlobNotFoundErr)
+
+	err := reloaded.Sweep()
+	if err != nil {
+		t.Fatalf("unexpected error sweeping store: %q", err)
+	}
+
+	assertContent(t, reloaded, kept, "kept")
+	_, openErr := reloaded.Open(orphan)
+	assertError(t, openErr, BlobNotFoundErr)
+}
+
+func TestSweepUploads(t *testing.T) {
+	store := newTestStore(t, DefaultMaxSize)
+	writing := filepath.Join(store.Dir, uploadPrefix+"writing")
+	crashed := filepath.Join(store.Dir, uploadPrefix+"crashed")
+	os.WriteFile(writing, []byte("half"), 0644)
+	os.WriteFile(crashed, []byte("half"), 0644)
+	old := time.Now().Add(-UploadGrace)
+	os.Chtimes(crashed, old, old)
+
+	err := store.Sweep()
+
+	assertError(t, err, nil)
+	if _, err := os.Stat(writing); err != nil {
+		t.Errorf("expected an upload in progress to be kept, got %q", err)
+	}
+	if _, err := os.Stat(crashed); !os.IsNotExist(err) {
+		t.Errorf("expected an old upload to be removed, got %v", err)
+	}
+}
+
+//helpers
+
+func newTestStore(t testing.TB, maxSize int64) *Store {
+	t.Helper()
+	store, err := NewStore(t.TempDir(), maxSize)
+	if err != nil {
+		t.Fatalf("could not create store: %q", err)
+	}
+	return store
+}
+
+func assertError(t testing.TB, actual_error, expected_error error) {
+	t.Helper()
+	if actual_error != expected_error {
+		t.Errorf("got %q, expected %q", actual_error, expected_error)
+	}
+}
+
+func assertContent(t testing.TB, store *Store, hash, expected_content string) {
+	t.Helper()
+	file, err := store.Open(hash)
+	if err != nil {
+		t.Fatalf("could not open blob %q: %q", hash, err)
+	}
+	defer file.Close()
+	content, _ := io.ReadAll(file)
+	if string(content) != expected_content {
+		t.Errorf("got content %q, expected %q", content, expected_content)
+	}
+	if _, err := os.Stat(store.path(hash)); err != nil {
+		t.Errorf("expected blob file on disk: %q", err)
+	}
+}
@@ -0,0 +1,234 @@
+package board
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"todo_app/pkg/tasks"
+	"unicode/utf8"
+)
+
+const (
+	ColumnNotFoundErr = BoardError("Column not found")
+	ColumnExistsErr   = BoardError("The board already has a column for that status")
+	ColumnFullErr     = BoardError("Column has reached its WIP limit")
+	ColumnStatusErr   = BoardError("Column status cannot be empty")
+	WIPLimitErr       = BoardError("WIP limit cannot be negative")
+	minColumnWidth    = 12
+)
+
+type BoardError string
+
+func (err BoardError) Error() string {
+	return string(err)
+}
+
+// Column groups every task whose TaskStatus equals Status. A WIPLimit of 0
+// means the column takes any number of tasks.
+type Column struct {
+	Status   string
+	Title    string
+	WIPLimit int
+}
+
+type Board struct {
+	Columns []Column
+}
+
+func Default() Board {
+	return Board{Columns: []Column{
+		{Status: "pending", Title: "Pending"},
+		{Status: "complete", Title: "Complete"},
+	}}
+}
+
+// FindColumn looks a column up by its position (starting at 1), status or
+// title, ignoring case.
+func (board Board) FindColumn(name string) (int, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	position, err := strconv.Atoi(name)
+	if err == nil && position >= 1 && position <= len(board.Columns) {
+		return position - 1, nil
+	}
+	for i, column := range board.Columns {
+		if strings.ToLower(column.Status) == name || strings.ToLower(column.Title) == name {
+			return i, nil
+		}
+	}
+	return -1, ColumnNotFoundErr
+}
+
+func (board *Board) AddColumn(status, title string, wipLimit int) error {
+	status = strings.ToLower(strings.TrimSpace(status))
+	if status == "" {
+		return ColumnStatusErr
+	}
+	if wipLimit < 0 {
+		return WIPLimitErr
+	}
+	for _, column := range board.Columns {
+		if column.Status == status {
+			return ColumnExistsErr
+		}
+	}
+	if strings.TrimSpace(title) == "" {
+		title = status
+	}
+	board.Columns = append(board.Columns, Column{Status: status, Title: strings.TrimSpace(title), WIPLimit: wipLimit})
+	return nil
+}
+
+func (board *Board) RemoveColumn(name string) error {
+	i, err := board.FindColumn(name)
+	if err != nil {
+		return err
+	}
+	board.Columns = append(board.Columns[:i], board.Columns[i+1:]...)
+	return nil
+}
+
+func (board *Board) SetWIPLimit(name string, wipLimit int) error {
+	if wipLimit < 0 {
+		return WIPLimitErr
+	}
+	i, err := board.FindColumn(name)
+	if err != nil {
+		return err
+	}
+	board.Columns[i].WIPLimit = wipLimit
+	return nil
+}
+
+// Layout returns the tasks of each column ordered by task number, plus the
+// tasks whose status has no column on the board.
+func (board Board) Layout(taskList tasks.TaskList) ([][]*tasks.Task, []*tasks.Task) {
+	columns := make([][]*tasks.Task, len(board.Columns))
+	var other []*tasks.Task
+	for _, task := range sortedTasks(taskList) {
+		placed := false
+		for i, column := range board.Columns {
+			if strings.EqualFold(task.TaskStatus, column.Status) {
+				columns[i] = append(columns[i], task)
+				placed = true
+				break
+			}
+		}
+		if !placed {
+			other = append(other, task)
+		}
+	}
+	return columns, other
+}
+
+// Move puts a task in a column by changing its status, unless the column is
+// already at its WIP limit.
+func (board Board) Move(taskList tasks.TaskList, id int, columnName string) error {
+	task, err := taskList.GetTask(id)
+	if err != nil {
+		return err
+	}
+	i, err := board.FindColumn(columnName)
+	if err != nil {
+		return err
+	}
+	column := board.Columns[i]
+	if strings.EqualFold(task.TaskStatus, column.Status) {
+		return nil
+	}
+	columns, _ := board.Layout(taskList)
+	if column.WIPLimit > 0 && len(columns[i]) >= column.WIPLimit {
+		return ColumnFullErr
+	}
+	task.TaskStatus = column.Status
+	return nil
+}
+
+// Render draws the board as side by side columns sharing width characters.
+// When the columns would get too narrow to read fewer are drawn per row.
+func (board Board) Render(out io.Writer, taskList tasks.TaskList, width int) {
+	columns, other := board.Layout(taskList)
+	titles := make([]string, 0, len(board.Columns)+1)
+	for i, column := range board.Columns {
+		title := fmt.Sprintf("%s (%d)", column.Title, len(columns[i]))
+		if column.WIPLimit > 0 {
+			title = fmt.Sprintf("%s (%d/%d)", column.Title, len(columns[i]), column.WIPLimit)
+		}
+		titles = append(titles, title)
+	}
+	if len(other) > 0 {
+		titles = append(titles, fmt.Sprintf("Other (%d)", len(other)))
+		columns = append(columns, other)
+	}
+	if len(columns) == 0 {
+		fmt.Fprintln(out, "The board has no columns")
+		return
+	}
+
+	perRow := len(columns)
+	for perRow > 1 && columnWidth(width, perRow) < minColumnWidth {
+		perRow--
+	}
+	cellWidth := columnWidth(width, perRow)
+	for first := 0; first < len(columns); first += perRow {
+		last := min(first+perRow, len(columns))
+		separator := "+" + strings.Repeat(strings.Repeat("-", cellWidth)+"+", last-first)
+		fmt.Fprintln(out, separator)
+		writeRow(out, titles[first:last], cellWidth)
+		fmt.Fprintln(out, separator)
+		height := 0
+		for _, column := range columns[first:last] {
+			height = max(height, len(column))
+		}
+		for line := 0; line < height; line++ {
+			cells := make([]string, last-first)
+			for i, column := range columns[first:last] {
+				if line < len(column) {
+					cells[i] = fmt.Sprintf("#%d %s", column[line].Id, strings.TrimSpace(column[line].Name))
+				}
+			}
+			writeRow(out, cells, cellWidth)
+		}
+		fmt.Fprintln(out, separator)
+	}
+}
+
+// columnWidth is never below 3, the borders and one character, so terminals
+// narrower than that get lines that wrap instead of nothing.
+func columnWidth(width, columns int) int {
+	return max((width-1)/columns-1, 3)
+}
+
+func writeRow(out io.Writer, cells []string, cellWidth int) {
+	var row strings.Builder
+	row.WriteString("|")
+	for _, cell := range cells {
+		cell = truncate(cell, cellWidth-2)
+		row.WriteString(" " + cell + strings.Repeat(" ", cellWidth-2-utf8.RuneCountInString(cell)) + " |")
+	}
+	fmt.Fprintln(out, row.String())
+}
+
+func truncate(text string, width int) string {
+	if width < 1 {
+		return ""
+	}
+	if utf8.RuneCountInString(text) <= width {
+		return text
+	}
+	runes := []rune(text)
+	if width <= 3 {
+		return string(runes[:width])
+	}
+	return string(runes[:width-3]) + "..."
+}
+
+func sortedTasks(taskList tasks.TaskList) []*tasks.Task {
+	sorted := make([]*tasks.Task, 0, len(taskList))
+	for _, task := range taskList {
+		sorted = append(sorted, task)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Id < sorted[j].Id })
+	return sorted
+}
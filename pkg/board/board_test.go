@@ -0,0 +1,163 @@
+package board
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"todo_app/pkg/tasks"
+	"unicode/utf8"
+)
+
+func TestFindColumn(t *testing.T) {
+	board := Board{Columns: []Column{{Status: "pending", Title: "To do"}, {Status: "doing", Title: "In progress"}}}
+	tests := []struct {
+		name           string
+		input          string
+		expected_index int
+		expected_error error
+	}{
+		{name: "by status", input: "doing", expected_index: 1, expected_error: nil},
+		{name: "by title mixed case", input: "In Progress", expected_index: 1, expected_error: nil},
+		{name: "by position", input: "1", expected_index: 0, expected_error: nil},
+		{name: "position out of range", input: "3", expected_index: -1, expected_error: ColumnNotFoundErr},
+		{name: "unknown column", input: "done", expected_index: -1, expected_error: ColumnNotFoundErr},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			index, err := board.FindColumn(test.input)
+
+			if err != test.expected_error {
+				t.Fatalf("unexpected error, got %q, expected %q", err, test.expected_error)
+			}
+			if index != test.expected_index {
+				t.Errorf("got column %d, expected %d", index, test.expected_index)
+			}
+		})
+	}
+}
+
+func TestAddColumn(t *testing.T) {
+	board := Default()
+	tests := []struct {
+		name           string
+		status         string
+		limit          int
+		expected_error error
+	}{
+		{name: "new column", status: "Doing", limit: 2, expected_error: nil},
+		{name: "existing status", status: "pending", limit: 0, expected_error: ColumnExistsErr},
+		{name: "empty status", status: " ", limit: 0, expected_error: ColumnStatusErr},
+		{name: "negative limit", status: "review", limit: -1, expected_error: WIPLimitErr},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := board.AddColumn(test.status, "", test.limit)
+
+			if err != test.expected_error {
+				t.Fatalf("unexpected error, got %q, expected %q", err, test.expected_error)
+			}
+		})
+	}
+
+	if len(board.Columns) != 3 || board.Columns[2] != (Column{Status: "doing", Title: "doing", WIPLimit: 2}) {
+		t.Errorf("column not stored accurately, got %v", board.Columns)
+	}
+}
+
+func TestMove(t *testing.T) {
+	board := Default()
+	board.AddColumn("doing", "Doing", 1)
+	taskList := newTaskList(
+		&tasks.Task{Id: 1, Name: "a", TaskStatus: "pending"},
+		&tasks.Task{Id: 2, Name: "b", TaskStatus: "pending"},
+	)
+	tests := []struct {
+		name            string
+		task_id         int
+		column          string
+		expected_error  error
+		expected_status string
+	}{
+		{name: "into empty column", task_id: 1, column: "doing", expected_error: nil, expected_status: "doing"},
+		{name: "into full column", task_id: 2, column: "doing", expected_error: ColumnFullErr, expected_status: "pending"},
+		{name: "within the same column", task_id: 1, column: "Doing", expected_error: nil, expected_status: "doing"},
+		{name: "unknown column", task_id: 2, column: "review", expected_error: ColumnNotFoundErr, expected_status: "pending"},
+		{name: "nonexisting task", task_id: 3, column: "pending", expected_error: tasks.TaskNotFoundErr},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := board.Move(taskList, test.task_id, test.column)
+
+			if err != test.expected_error {
+				t.Fatalf("unexpected error, got %q, expected %q", err, test.expected_error)
+			}
+			if task, found := taskList[test.task_id]; found && task.TaskStatus != test.expected_status {
+				t.Errorf("got status %q, expected %q", task.TaskStatus, test.expected_status)
+			}
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	board := Default()
+	board.AddColumn("doing", "Doing", 2)
+	taskList := newTaskList(
+		&tasks.Task{Id: 1, Name: "write the kanban board renderer", TaskStatus: "pending"},
+		&tasks.Task{Id: 2, Name: "test it", TaskStatus: "doing"},
+		&tasks.Task{Id: 3, Name: "ship it", TaskStatus: "blocked"},
+	)
+
+	t.Run("columns fit the width", func(t *testing.T) {
+		var out strings.Builder
+		board.Render(&out, taskList, 60)
+
+		for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+			if utf8.RuneCountInString(line) > 60 {
+				t.Errorf("line is wider than the terminal: %q", line)
+			}
+		}
+		for _, expected := range []string{"Pending (1)", "Doing (1/2)", "Other (1)", "#2 test it", "#1 write"} {
+			if !strings.Contains(out.String(), expected) {
+				t.Errorf("expected board to contain %q, got\n%s", expected, out.String())
+			}
+		}
+	})
+
+	t.Run("narrow terminal wraps columns", func(t *testing.T) {
+		var out strings.Builder
+		board.Render(&out, taskList, 30)
+
+		for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+			if utf8.RuneCountInString(line) > 30 {
+				t.Errorf("line is wider than the terminal: %q", line)
+			}
+			if strings.Contains(line, "Pending") && strings.Contains(line, "Other") {
+				t.Errorf("expected columns to be split over several rows, got\n%s", out.String())
+			}
+		}
+	})
+
+	for _, width := range []int{0, 1, 3, 5} {
+		t.Run(fmt.Sprintf("width %d", width), func(t *testing.T) {
+			var out strings.Builder
+			board.Render(&out, taskList, width)
+
+			if !strings.Contains(out.String(), "| P |") {
+				t.Errorf("expected one character columns, got\n%s", out.String())
+			}
+		})
+	}
+}
+
+//helpers
+
+func newTaskList(taskSlice ...*tasks.Task) tasks.TaskList {
+	taskList := tasks.TaskList{}
+	for _, task := range taskSlice {
+		taskList[task.Id] = task
+	}
+	return taskList
+}
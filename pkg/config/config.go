@@ -0,0 +1,565 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"todo_app/pkg/auth"
+	"todo_app/pkg/tasks"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	UnknownKeyErr        = ConfigError("unknown setting")
+	SyntaxErr            = ConfigError("expected key = value")
+	TableErr             = ConfigError("tables are not supported, settings go at the top level")
+	DuplicateKeyErr      = ConfigError("set more than once")
+	QuotedErr            = ConfigError("value must be a quoted string")
+	NumberErr            = ConfigError("value must be a whole number")
+	EmptyDataDirErr      = ConfigError("data directory cannot be empty")
+	InvalidStorageErr    = ConfigError("storage must be one of: csv")
+	InvalidDateFormatErr = ConfigError("date format must have dd, mm and yyyy once each, separated by -, / or .")
+	InvalidBcryptErr     = ConfigError("bcrypt cost must be between 4 and 31")
+	InvalidHashErr       = ConfigError("password hash must be bcrypt or argon2id")
+	InvalidArgon2Err     = ConfigError("must be a whole number of at least 1")
+	InvalidDurationErr   = ConfigError("must be a positive duration like 90m, 24h or 30d")
+	InvalidMinLengthErr  = ConfigError("password min length must be between 1 and 64")
+	EmptySymbolsErr      = ConfigError("password symbols cannot be empty")
+)
+
+const (
+	// FileEnv names a config file to read instead of the one in the XDG
+	// config dir, like the -config flag.
+	FileEnv  = "TODO_CONFIG"
+	fileName = "todo_app/config.toml"
+	// dataDirName is the data folder in XDG_DATA_HOME.
+	dataDirName = "todo_app"
+)
+
+type ConfigError string
+
+func (err ConfigError) Error() string {
+	return string(err)
+}
+
+// KeyErr is a setting with a wrong value. Source is where it was set: the
+// file and line, the environment variable or the flag.
+type KeyErr struct {
+	Source string
+	Key    string
+	Err    error
+}
+
+func (err KeyErr) Error() string {
+	return fmt.Sprintf("%s: %s: %s", err.Source, err.Key, err.Err)
+}
+
+func (err KeyErr) Unwrap() error {
+	return err.Err
+}
+
+// Config is everything about the app that can be set without changing code.
+type Config struct {
+	// DataDir is the folder with users.csv, tasks.csv and the other files.
+	DataDir string
+	// Storage is how the data is kept, csv is the only backend for now.
+	Storage string
+	// DateFormat is how task dates are typed and shown, like dd-mm-yyyy or
+	// yyyy/mm/dd. They are always kept as dd-mm-yyyy.
+	DateFormat string
+	// DefaultSort is the order tasks are listed in, see tasks.SortOrders.
+	DefaultSort string
+	// PasswordHash is how new passwords are hashed, bcrypt with BcryptCost
+	// or argon2id with Argon2Time passes over Argon2Memory KiB.
+	PasswordHash       string
+	BcryptCost         int
+	Argon2Time         uint32
+	Argon2Memory       uint32
+	SessionIdleTimeout time.Duration
+	SessionMaxLifetime time.Duration
+	// PasswordMinLength and PasswordSymbols change auth.DefaultPasswordPolicy
+	// for new passwords.
+	PasswordMinLength int
+	PasswordSymbols   string
+}
+
+func Default() Config {
+	return Config{
+		DataDir:            defaultDataDir(os.Getenv),
+		Storage:            "csv",
+		DateFormat:         "dd-mm-yyyy",
+		DefaultSort:        "id",
+		PasswordHash:       "bcrypt",
+		BcryptCost:         12,
+		Argon2Time:         3,
+		Argon2Memory:       64 * 1024,
+		SessionIdleTimeout: 24 * time.Hour,
+		SessionMaxLifetime: 30 * 24 * time.Hour,
+		PasswordMinLength:  auth.DefaultPasswordPolicy.MinLength,
+		PasswordSymbols:    auth.DefaultPasswordPolicy.Symbols,
+	}
+}
+
+// setting is one key of the config file with the environment variable and
+// flag that override it.
+type setting struct {
+	key    string
+	env    string
+	flag   string
+	usage  string
+	number bool
+	set    func(cfg *Config, value string) error
+	get    func(cfg Config) string
+}
+
+var settings = []setting{
+	{
+		key: "data_dir", env: "TODO_DATA_DIR", flag: "data", usage: "folder with the users and tasks files",
+		set: func(cfg *Config, value string) error {
+			if strings.TrimSpace(value) == "" {
+				return EmptyDataDirErr
+			}
+			cfg.DataDir = value
+			return nil
+		},
+		get: func(cfg Config) string { return cfg.DataDir },
+	},
+	{
+		key: "storage", env: "TODO_STORAGE", flag: "storage", usage: "how the data is kept: csv",
+		set: func(cfg *Config, value string) error {
+			if strings.ToLower(strings.TrimSpace(value)) != "csv" {
+				return InvalidStorageErr
+			}
+			cfg.Storage = "csv"
+			return nil
+		},
+		get: func(cfg Config) string { return cfg.Storage },
+	},
+	{
+		key: "date_format", env: "TODO_DATE_FORMAT", flag: "date-format", usage: "how task dates are typed and shown, like dd-mm-yyyy or yyyy/mm/dd",
+		set: func(cfg *Config, value string) error {
+			value = strings.ToLower(strings.TrimSpace(value))
+			_, err := dateLayout(value)
+			if err != nil {
+				return err
+			}
+			cfg.DateFormat = value
+			return nil
+		},
+		get: func(cfg Config) string { return cfg.DateFormat },
+	},
+	{
+		key: "default_sort", env: "TODO_DEFAULT_SORT", flag: "sort", usage: "order tasks are listed in: " + strings.Join(tasks.SortOrders, ", "),
+		set: func(cfg *Config, value string) error {
+			order, err := tasks.ParseSortOrder(value)
+			if err != nil {
+				return err
+			}
+			cfg.DefaultSort = order
+			return nil
+		},
+		get: func(cfg Config) string { return cfg.DefaultSort },
+	},
+	{
+		key: "password_hash", env: "TODO_PASSWORD_HASH", flag: "password-hash", usage: "how new passwords are hashed: bcrypt or argon2id, older hashes are upgraded on login",
+		set: func(cfg *Config, value string) error {
+			value = strings.ToLower(strings.TrimSpace(value))
+			if value != "bcrypt" && value != "argon2id" {
+				return InvalidHashErr
+			}
+			cfg.PasswordHash = value
+			return nil
+		},
+		get: func(cfg Config) string { return cfg.PasswordHash },
+	},
+	{
+		key: "bcrypt_cost", env: "TODO_BCRYPT_COST", flag: "bcrypt-cost", usage: "work factor of bcrypt password hashes", number: true,
+		set: func(cfg *Config, value string) error {
+			cost, err := strconv.Atoi(strings.TrimSpace(value))
+			if err != nil {
+				return NumberErr
+			}
+			if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+				return InvalidBcryptErr
+			}
+			cfg.BcryptCost = cost
+			return nil
+		},
+		get: func(cfg Config) string { return strconv.Itoa(cfg.BcryptCost) },
+	},
+	{
+		key: "argon2_time", env: "TODO_ARGON2_TIME", flag: "argon2-time", usage: "passes of argon2id over its memory", number: true,
+		set: func(cfg *Config, value string) (err error) {
+			cfg.Argon2Time, err = parseArgon2(value, cfg.Argon2Time)
+			return err
+		},
+		get: func(cfg Config) string { return strconv.FormatUint(uint64(cfg.Argon2Time), 10) },
+	},
+	{
+		key: "argon2_memory", env: "TODO_ARGON2_MEMORY", flag: "argon2-memory", usage: "KiB of memory argon2id uses per hash", number: true,
+		set: func(cfg *Config, value string) (err error) {
+			cfg.Argon2Memory, err = parseArgon2(value, cfg.Argon2Memory)
+			return err
+		},
+		get: func(cfg Config) string { return strconv.FormatUint(uint64(cfg.Argon2Memory), 10) },
+	},
+	{
+		key: "session_idle_timeout", env: "TODO_SESSION_IDLE_TIMEOUT", flag: "session-idle-timeout", usage: "how long an unused session lasts",
+		set: func(cfg *Config, value string) (err error) {
+			cfg.SessionIdleTimeout, err = parseDuration(value, cfg.SessionIdleTimeout)
+			return err
+		},
+		get: func(cfg Config) string { return formatDuration(cfg.SessionIdleTimeout) },
+	},
+	{
+		key: "session_max_lifetime", env: "TODO_SESSION_MAX_LIFETIME", flag: "session-max-lifetime", usage: "how long a session lasts however much it is used",
+		set: func(cfg *Config, value string) (err error) {
+			cfg.SessionMaxLifetime, err = parseDuration(value, cfg.SessionMaxLifetime)
+			return err
+		},
+		get: func(cfg Config) string { return formatDuration(cfg.SessionMaxLifetime) },
+	},
+	{
+		key: "password_min_length", env: "TODO_PASSWORD_MIN_LENGTH", flag: "password-min-length", usage: "fewest characters new passwords can have", number: true,
+		set: func(cfg *Config, value string) error {
+			length, err := strconv.Atoi(strings.TrimSpace(value))
+			if err != nil {
+				return NumberErr
+			}
+			if length < 1 || length > auth.DefaultPasswordPolicy.MaxLength {
+				return InvalidMinLengthErr
+			}
+			cfg.PasswordMinLength = length
+			return nil
+		},
+		get: func(cfg Config) string { return strconv.Itoa(cfg.PasswordMinLength) },
+	},
+	{
+		key: "password_symbols", env: "TODO_PASSWORD_SYMBOLS", flag: "password-symbols", usage: "characters that count as symbols in new passwords",
+		set: func(cfg *Config, value string) error {
+			if value == "" {
+				return EmptySymbolsErr
+			}
+			cfg.PasswordSymbols = value
+			return nil
+		},
+		get: func(cfg Config) string { return cfg.PasswordSymbols },
+	},
+}
+
+// Load works out the settings, each from the first place that has it: a flag
+// in args, an environment variable, the config file or the default. It adds
+// the flags, and -config to name the file, to flags and returns the
+// arguments after them.
+//
+// The file is the one given with -config, else the one in TODO_CONFIG, else
+// todo_app/config.toml in XDG_CONFIG_HOME (~/.config by default), which does
+// not have to exist. A relative data_dir in the file is relative to the file.
+func Load(flags *flag.FlagSet, args []string, getenv func(string) string) (Config, []string, error) {
+	cfg := Default()
+	cfg.DataDir = defaultDataDir(getenv)
+	path := flags.String("config", "", "config file to read, instead of "+FileEnv+" or ~/.config/"+fileName)
+	values := make([]*string, len(settings))
+	for i, setting := range settings {
+		values[i] = flags.String(setting.flag, "", fmt.Sprintf("%s, overrides %s (default %s)", setting.usage, setting.env, setting.get(cfg)))
+	}
+	err := flags.Parse(args)
+	if err != nil {
+		return Config{}, nil, err
+	}
+
+	explicit := *path != "" || getenv(FileEnv) != ""
+	if *path == "" {
+		*path = filePath(getenv)
+	}
+	if *path != "" {
+		err = readFile(&cfg, *path)
+		if err != nil && (explicit || !errors.Is(err, os.ErrNotExist)) {
+			return Config{}, nil, err
+		}
+	}
+	for _, setting := range settings {
+		value := getenv(setting.env)
+		if value == "" {
+			continue
+		}
+		err = setting.set(&cfg, value)
+		if err != nil {
+			return Config{}, nil, KeyErr{Source: setting.env, Key: setting.key, Err: err}
+		}
+	}
+	set := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	for i, setting := range settings {
+		if !set[setting.flag] {
+			continue
+		}
+		err = setting.set(&cfg, *values[i])
+		if err != nil {
+			return Config{}, nil, KeyErr{Source: "-" + setting.flag, Key: setting.key, Err: err}
+		}
+	}
+	return cfg, flags.Args(), nil
+}
+
+// filePath is the config file to read when none is named, empty when there
+// is no home folder to look in.
+func filePath(getenv func(string) string) string {
+	if path := getenv(FileEnv); path != "" {
+		return path
+	}
+	if dir := getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, fileName)
+	}
+	if home := getenv("HOME"); home != "" {
+		return filepath.Join(home, ".config", fileName)
+	}
+	return ""
+}
+
+// defaultDataDir is todo_app in XDG_DATA_HOME (~/.local/share by default),
+// or a data folder next to the executable when there is no home folder, so
+// the data is found wherever the app is started from.
+func defaultDataDir(getenv func(string) string) string {
+	if dir := getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, dataDirName)
+	}
+	if home := getenv("HOME"); home != "" {
+		return filepath.Join(home, ".local", "share", dataDirName)
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return "data"
+	}
+	return filepath.Join(filepath.Dir(executable), "data")
+}
+
+func readFile(cfg *Config, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return parse(cfg, file, path)
+}
+
+// parse reads the subset of TOML the settings need: one key = value per line,
+// strings in double or single quotes, numbers bare and comments after #.
+// name is used to say where a wrong setting is.
+func parse(cfg *Config, in io.Reader, name string) error {
+	content, err := io.ReadAll(in)
+	if err != nil {
+		return err
+	}
+	seen := make(map[string]bool)
+	for i, line := range strings.Split(string(content), "\n") {
+		source := fmt.Sprintf("%s:%d", name, i+1)
+		line = strings.TrimSpace(stripComment(line))
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			return KeyErr{Source: source, Key: line, Err: TableErr}
+		}
+		key, value, found := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !found || key == "" {
+			return KeyErr{Source: source, Key: line, Err: SyntaxErr}
+		}
+		setting, found := lookup(key)
+		if !found {
+			return KeyErr{Source: source, Key: key, Err: UnknownKeyErr}
+		}
+		if seen[key] {
+			return KeyErr{Source: source, Key: key, Err: DuplicateKeyErr}
+		}
+		seen[key] = true
+		value, err = unquote(strings.TrimSpace(value), setting.number)
+		if err == nil {
+			if key == "data_dir" && value != "" && !filepath.IsAbs(value) {
+				value = filepath.Join(filepath.Dir(name), value)
+			}
+			err = setting.set(cfg, value)
+		}
+		if err != nil {
+			return KeyErr{Source: source, Key: key, Err: err}
+		}
+	}
+	return nil
+}
+
+func lookup(key string) (setting, bool) {
+	for _, setting := range settings {
+		if setting.key == key {
+			return setting, true
+		}
+	}
+	return setting{}, false
+}
+
+// stripComment cuts line at the first # that is not in a string.
+func stripComment(line string) string {
+	var quote rune
+	for i, r := range line {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote == 0 && (r == '"' || r == '\''):
+			quote = r
+		case quote == 0 && r == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// unquote reads a TOML string, or a bare number when number is set.
+func unquote(value string, number bool) (string, error) {
+	if len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'' {
+		return value[1 : len(value)-1], nil
+	}
+	if strings.HasPrefix(value, `"`) {
+		unquoted, err := strconv.Unquote(value)
+		if err != nil {
+			return "", SyntaxErr
+		}
+		return unquoted, nil
+	}
+	if number {
+		return value, nil
+	}
+	return "", QuotedErr
+}
+
+// parseArgon2 reads a parameter of argon2id, which all have to be at least 1.
+func parseArgon2(value string, current uint32) (uint32, error) {
+	parsed, err := strconv.ParseUint(strings.TrimSpace(value), 10, 32)
+	if err != nil || parsed < 1 {
+		return current, InvalidArgon2Err
+	}
+	return uint32(parsed), nil
+}
+
+// parseDuration reads durations like 90m or 24h, and days like 30d.
+func parseDuration(value string, current time.Duration) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	var duration time.Duration
+	var err error
+	if days, found := strings.CutSuffix(value, "d"); found {
+		var count int
+		count, err = strconv.Atoi(days)
+		duration = time.Duration(count) * 24 * time.Hour
+	} else {
+		duration, err = time.ParseDuration(value)
+	}
+	if err != nil || duration <= 0 {
+		return current, InvalidDurationErr
+	}
+	return duration, nil
+}
+
+func formatDuration(duration time.Duration) string {
+	if duration%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", duration/(24*time.Hour))
+	}
+	return duration.String()
+}
+
+// dateLayout turns a format like dd-mm-yyyy into a layout of the time
+// package.
+func dateLayout(format string) (string, error) {
+	parts := strings.FieldsFunc(format, func(r rune) bool { return r == '-' || r == '/' || r == '.' })
+	separators := strings.Map(func(r rune) rune {
+		if r == 'd' || r == 'm' || r == 'y' {
+			return -1
+		}
+		return r
+	}, format)
+	if len(parts) != 3 || len(separators) != 2 {
+		return "", InvalidDateFormatErr
+	}
+	layout := format
+	for _, part := range []struct{ format, layout string }{{"yyyy", "2006"}, {"mm", "01"}, {"dd", "02"}} {
+		if strings.Count(format, part.format) != 1 {
+			return "", InvalidDateFormatErr
+		}
+		layout = strings.Replace(layout, part.format, part.layout, 1)
+	}
+	for _, part := range parts {
+		if part != "dd" && part != "mm" && part != "yyyy" {
+			return "", InvalidDateFormatErr
+		}
+	}
+	return layout, nil
+}
+
+// DateLayout is DateFormat as a layout of the time package.
+func (cfg Config) DateLayout() string {
+	layout, err := dateLayout(cfg.DateFormat)
+	if err != nil {
+		return tasks.DateLayout
+	}
+	return layout
+}
+
+// ParseDate reads a task date typed in DateFormat and returns it the way
+// tasks keep it.
+func (cfg Config) ParseDate(value string) (string, error) {
+	date, err := time.Parse(cfg.DateLayout(), strings.TrimSpace(value))
+	if err != nil {
+		return "", tasks.TaskDateErr
+	}
+	return date.Format(tasks.DateLayout), nil
+}
+
+// FormatDate shows a date kept by tasks in DateFormat. Dates that cannot be
+// read are shown as they are.
+func (cfg Config) FormatDate(date string) string {
+	parsed, err := time.Parse(tasks.DateLayout, date)
+	if err != nil {
+		return date
+	}
+	return parsed.Format(cfg.DateLayout())
+}
+
+// PasswordHasher is the hasher PasswordHash names, with the cost or
+// parameters of the settings.
+func (cfg Config) PasswordHasher() auth.PasswordHasher {
+	if cfg.PasswordHash == "argon2id" {
+		hasher := auth.DefaultArgon2idHasher
+		hasher.Time = cfg.Argon2Time
+		hasher.Memory = cfg.Argon2Memory
+		return hasher
+	}
+	return auth.BcryptHasher{Cost: cfg.BcryptCost}
+}
+
+// PasswordPolicy is auth.DefaultPasswordPolicy with the minimum length and
+// symbols of the settings, the blocklist is left for the caller to load.
+func (cfg Config) PasswordPolicy() auth.PasswordPolicy {
+	policy := auth.DefaultPasswordPolicy
+	policy.MinLength = cfg.PasswordMinLength
+	policy.Symbols = cfg.PasswordSymbols
+	return policy
+}
+
+// Help describes the settings for the usage of a command, with the name of
+// each in the config file and the environment.
+func Help() string {
+	var help strings.Builder
+	cfg := Default()
+	fmt.Fprintf(&help, "  -config file\n        config file to read, also %s, default ~/.config/%s\n", FileEnv, fileName)
+	for _, setting := range settings {
+		fmt.Fprintf(&help, "  -%s value\n        %s, default %s\n        %s in the config file, %s in the environment\n", setting.flag, setting.usage, setting.get(cfg), setting.key, setting.env)
+	}
+	return help.String()
+}
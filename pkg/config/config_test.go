@@ -0,0 +1,265 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"todo_app/pkg/auth"
+	"todo_app/pkg/tasks"
+)
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	writeConfig(t, filepath.Join(dir, "todo_app", "config.toml"), `
+# settings for the test
+data_dir = "/srv/todo"
+default_sort = 'date'   # soonest first
+bcrypt_cost = 10
+session_idle_timeout = "2h"
+`)
+	tests := []struct {
+		name     string
+		args     []string
+		env      map[string]string
+		expected func(cfg *Config)
+		rest     []string
+	}{
+		{name: "file", expected: func(cfg *Config) {}},
+		{name: "environment over the file", env: map[string]string{"TODO_BCRYPT_COST": "11", "TODO_DATE_FORMAT": "yyyy/mm/dd"}, expected: func(cfg *Config) {
+			cfg.BcryptCost = 11
+			cfg.DateFormat = "yyyy/mm/dd"
+		}},
+		{name: "flags over the environment", args: []string{"-bcrypt-cost", "13", "--sort=name", "list", "--tag", "x"}, env: map[string]string{"TODO_BCRYPT_COST": "11"}, expected: func(cfg *Config) {
+			cfg.BcryptCost = 13
+			cfg.DefaultSort = "name"
+		}, rest: []string{"list", "--tag", "x"}},
+		{name: "days", env: map[string]string{"TODO_SESSION_MAX_LIFETIME": "7d"}, expected: func(cfg *Config) {
+			cfg.SessionMaxLifetime = 7 * 24 * time.Hour
+		}},
+		{name: "argon2id", args: []string{"-password-hash", "Argon2id", "-argon2-memory", "19456"}, env: map[string]string{"TODO_ARGON2_TIME": "2"}, expected: func(cfg *Config) {
+			cfg.PasswordHash = "argon2id"
+			cfg.Argon2Time = 2
+			cfg.Argon2Memory = 19456
+		}, rest: []string{}},
+		{name: "password policy", args: []string{"-password-min-length", "12"}, env: map[string]string{"TODO_PASSWORD_SYMBOLS": "#-_"}, expected: func(cfg *Config) {
+			cfg.PasswordMinLength = 12
+			cfg.PasswordSymbols = "#-_"
+		}, rest: []string{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.env = withEnv(test.env, "XDG_CONFIG_HOME", dir)
+
+			got, rest, err := Load(testFlags(), test.args, testEnv(test.env))
+
+			if err != nil {
+				t.Fatalf("unexpected error %q", err)
+			}
+			expected := Default()
+			expected.DataDir, expected.DefaultSort, expected.BcryptCost, expected.SessionIdleTimeout = "/srv/todo", "date", 10, 2*time.Hour
+			test.expected(&expected)
+			if got != expected {
+				t.Errorf("got %+v, expected %+v", got, expected)
+			}
+			if !reflect.DeepEqual(rest, test.rest) {
+				t.Errorf("got arguments %q, expected %q", rest, test.rest)
+			}
+		})
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "custom.toml")
+	writeConfig(t, path, `data_dir = "data"`)
+
+	tests := []struct {
+		name           string
+		args           []string
+		env            map[string]string
+		expected       string
+		expected_error error
+	}{
+		{name: "no file", env: map[string]string{"HOME": dir}, expected: filepath.Join(dir, ".local", "share", "todo_app")},
+		{name: "XDG data home", env: map[string]string{"HOME": dir, "XDG_DATA_HOME": filepath.Join(dir, "share")}, expected: filepath.Join(dir, "share", "todo_app")},
+		{name: "named with the flag", args: []string{"-config", path}, expected: filepath.Join(dir, "data")},
+		{name: "named in the environment", env: map[string]string{FileEnv: path}, expected: filepath.Join(dir, "data")},
+		{name: "named file missing", args: []string{"-config", filepath.Join(dir, "missing.toml")}, expected_error: os.ErrNotExist},
+		{name: "unknown flag", args: []string{"-colour", "red"}, expected_error: errors.New("flag provided but not defined: -colour")},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, _, err := Load(testFlags(), test.args, testEnv(test.env))
+
+			if test.expected_error != nil {
+				if err == nil || (!errors.Is(err, test.expected_error) && err.Error() != test.expected_error.Error()) {
+					t.Fatalf("got %q, expected %q", err, test.expected_error)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %q", err)
+			}
+			if got.DataDir != test.expected {
+				t.Errorf("got %q, expected %q", got.DataDir, test.expected)
+			}
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name           string
+		file           string
+		args           []string
+		env            map[string]string
+		expected       string
+		expected_error error
+	}{
+		{name: "unknown key", file: "data_dir = 'x'\ncolour = 'red'", expected: "config.toml:2: colour", expected_error: UnknownKeyErr},
+		{name: "no value", file: "storage", expected: "config.toml:1: storage", expected_error: SyntaxErr},
+		{name: "table", file: "[session]", expected: "config.toml:1: [session]", expected_error: TableErr},
+		{name: "set twice", file: "storage = 'csv'\nstorage = 'csv'", expected: "config.toml:2: storage", expected_error: DuplicateKeyErr},
+		{name: "unquoted string", file: "date_format = dd-mm-yyyy", expected: "config.toml:1: date_format", expected_error: QuotedErr},
+		{name: "unfinished string", file: `data_dir = "x`, expected: "config.toml:1: data_dir", expected_error: SyntaxErr},
+		{name: "storage", file: "\n\nstorage = 'sqlite'", expected: "config.toml:3: storage", expected_error: InvalidStorageErr},
+		{name: "date format", file: "date_format = 'dd-mm-yy'", expected: "config.toml:1: date_format", expected_error: InvalidDateFormatErr},
+		{name: "sort", file: "default_sort = 'colour'", expected: "config.toml:1: default_sort", expected_error: tasks.InvalidSortErr},
+		{name: "bcrypt cost", file: "bcrypt_cost = 40", expected: "config.toml:1: bcrypt_cost", expected_error: InvalidBcryptErr},
+		{name: "bcrypt cost not a number", file: "bcrypt_cost = 'high'", expected: "config.toml:1: bcrypt_cost", expected_error: NumberErr},
+		{name: "password hash", file: "password_hash = 'md5'", expected: "config.toml:1: password_hash", expected_error: InvalidHashErr},
+		{name: "argon2 time", file: "argon2_time = 0", expected: "config.toml:1: argon2_time", expected_error: InvalidArgon2Err},
+		{name: "argon2 memory", env: map[string]string{"TODO_ARGON2_MEMORY": "lots"}, expected: "TODO_ARGON2_MEMORY: argon2_memory", expected_error: InvalidArgon2Err},
+		{name: "duration", file: "session_max_lifetime = '-1h'", expected: "config.toml:1: session_max_lifetime", expected_error: InvalidDurationErr},
+		{name: "environment", env: map[string]string{"TODO_SESSION_IDLE_TIMEOUT": "soon"}, expected: "TODO_SESSION_IDLE_TIMEOUT: session_idle_timeout", expected_error: InvalidDurationErr},
+		{name: "flag", args: []string{"-data", ""}, expected: "-data: data_dir", expected_error: EmptyDataDirErr},
+		{name: "password min length", file: "password_min_length = 0", expected: "config.toml:1: password_min_length", expected_error: InvalidMinLengthErr},
+		{name: "password symbols", file: "password_symbols = ''", expected: "config.toml:1: password_symbols", expected_error: EmptySymbolsErr},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.toml")
+			writeConfig(t, path, test.file)
+
+			_, _, err := Load(testFlags(), test.args, testEnv(withEnv(test.env, FileEnv, path)))
+
+			var keyErr KeyErr
+			if !errors.As(err, &keyErr) || !errors.Is(err, test.expected_error) {
+				t.Fatalf("got %q, expected %q", err, test.expected_error)
+			}
+			if !strings.Contains(err.Error(), test.expected) {
+				t.Errorf("got %q, expected it to point at %q", err, test.expected)
+			}
+		})
+	}
+}
+
+func TestDates(t *testing.T) {
+	tests := []struct {
+		name           string
+		format         string
+		input          string
+		expected       string
+		expected_error error
+	}{
+		{name: "default", format: "dd-mm-yyyy", input: "31-12-2030", expected: "31-12-2030", expected_error: nil},
+		{name: "year first", format: "yyyy/mm/dd", input: " 2030/12/31 ", expected: "31-12-2030", expected_error: nil},
+		{name: "month first", format: "mm.dd.yyyy", input: "12.31.2030", expected: "31-12-2030", expected_error: nil},
+		{name: "wrong format", format: "yyyy/mm/dd", input: "31-12-2030", expected: "", expected_error: tasks.TaskDateErr},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := Default()
+			cfg.DateFormat = test.format
+
+			got, err := cfg.ParseDate(test.input)
+
+			if err != test.expected_error {
+				t.Fatalf("got %q, expected %q", err, test.expected_error)
+			}
+			if got != test.expected {
+				t.Errorf("got %q, expected %q", got, test.expected)
+			}
+			if err == nil && cfg.FormatDate(got) != strings.TrimSpace(test.input) {
+				t.Errorf("got %q shown as %q, expected %q", got, cfg.FormatDate(got), strings.TrimSpace(test.input))
+			}
+		})
+	}
+}
+
+func TestPasswordHasher(t *testing.T) {
+	cfg := Default()
+	cfg.BcryptCost = 10
+	cfg.Argon2Time = 2
+	cfg.Argon2Memory = 19456
+
+	if got := cfg.PasswordHasher(); got != (auth.BcryptHasher{Cost: 10}) {
+		t.Errorf("got %+v, expected bcrypt with the cost of the settings", got)
+	}
+	cfg.PasswordHash = "argon2id"
+	expected := auth.DefaultArgon2idHasher
+	expected.Time, expected.Memory = 2, 19456
+	if got := cfg.PasswordHasher(); got != expected {
+		t.Errorf("got %+v, expected %+v", got, expected)
+	}
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	cfg := Default()
+	cfg.PasswordMinLength = 12
+	cfg.PasswordSymbols = "#-_"
+
+	got := cfg.PasswordPolicy()
+
+	expected := auth.DefaultPasswordPolicy
+	expected.MinLength, expected.Symbols = 12, "#-_"
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %+v, expected %+v", got, expected)
+	}
+	if err := auth.UsePasswordPolicy(Default().PasswordPolicy()); err != nil {
+		t.Errorf("unexpected error %q for the default policy", err)
+	}
+}
+
+//helpers
+
+func testFlags() *flag.FlagSet {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	return flags
+}
+
+func testEnv(env map[string]string) func(string) string {
+	return func(key string) string {
+		return env[key]
+	}
+}
+
+func withEnv(env map[string]string, key, value string) map[string]string {
+	copied := map[string]string{key: value}
+	for k, v := range env {
+		copied[k] = v
+	}
+	return copied
+}
+
+func writeConfig(t testing.TB, path, content string) {
+	t.Helper()
+	err := os.MkdirAll(filepath.Dir(path), 0o755)
+	if err == nil {
+		err = os.WriteFile(path, []byte(content), 0o644)
+	}
+	if err != nil {
+		t.Fatalf("could not write config file: %q", err)
+	}
+}
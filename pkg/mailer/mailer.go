@@ -0,0 +1,159 @@
+package mailer
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	InvalidHeaderErr = MailError("Recipient and subject cannot contain line breaks")
+	NoRecipientErr   = MailError("Message has no recipient")
+)
+
+type MailError string
+
+func (err MailError) Error() string {
+	return string(err)
+}
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends messages to users, e.g. password reset codes.
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// format renders msg as a plain text email. Line breaks are refused in the
+// headers so user input cannot add headers of its own.
+func format(from string, msg Message, date time.Time) ([]byte, error) {
+	if msg.To == "" {
+		return nil, NoRecipientErr
+	}
+	for _, header := range []string{from, msg.To, msg.Subject} {
+		if strings.ContainsAny(header, "\r\n") {
+			return nil, InvalidHeaderErr
+		}
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&buf, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	body := strings.ReplaceAll(strings.ReplaceAll(msg.Body, "\r\n", "\n"), "\n", "\r\n")
+	buf.WriteString(body)
+	if !strings.HasSuffix(body, "\r\n") {
+		buf.WriteString("\r\n")
+	}
+	return buf.Bytes(), nil
+}
+
+// FromEnv sends mail through the SMTP server in TODO_SMTP_ADDR when it is set,
+// logging in with TODO_SMTP_USERNAME and TODO_SMTP_PASSWORD if given.
+// Otherwise messages are written to files in dir.
+func FromEnv(dir string) (Mailer, error) {
+	from := os.Getenv("TODO_SMTP_FROM")
+	if from == "" {
+		from = "todo_app@localhost"
+	}
+	addr := os.Getenv("TODO_SMTP_ADDR")
+	if addr != "" {
+		return NewSMTP(addr, from, os.Getenv("TODO_SMTP_USERNAME"), os.Getenv("TODO_SMTP_PASSWORD")), nil
+	}
+	return NewFile(dir, from)
+}
+
+// SMTP sends messages through an SMTP server. Username and password are
+// optional, without them no authentication is attempted.
+type SMTP struct {
+	Addr     string
+	From     string
+	Username string
+	Password string
+}
+
+func NewSMTP(addr, from, username, password string) *SMTP {
+	return &SMTP{Addr: addr, From: from, Username: username, Password: password}
+}
+
+func (mailer *SMTP) Send(msg Message) error {
+	data, err := format(mailer.From, msg, time.Now())
+	if err != nil {
+		return err
+	}
+	var auth smtp.Auth
+	if mailer.Username != "" {
+		host, _, err := net.SplitHostPort(mailer.Addr)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", mailer.Username, mailer.Password, host)
+	}
+	return smtp.SendMail(mailer.Addr, auth, mailer.From, []string{msg.To}, data)
+}
+
+// File writes every message to its own .eml file in Dir instead of sending
+// it, for running the app locally.
+type File struct {
+	Dir  string
+	From string
+}
+
+func NewFile(dir, from string) (*File, error) {
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, err
+	}
+	return &File{Dir: dir, From: from}, nil
+}
+
+func (mailer *File) Send(msg Message) error {
+	now := time.Now()
+	data, err := format(mailer.From, msg, now)
+	if err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(mailer.Dir, now.Format("20060102-150405")+"-*.eml")
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	if err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Memory keeps the messages it is given, for tests.
+type Memory struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+func (mailer *Memory) Send(msg Message) error {
+	if msg.To == "" {
+		return NoRecipientErr
+	}
+	mailer.mu.Lock()
+	defer mailer.mu.Unlock()
+	mailer.messages = append(mailer.messages, msg)
+	return nil
+}
+
+func (mailer *Memory) Messages() []Message {
+	mailer.mu.Lock()
+	defer mailer.mu.Unlock()
+	return append([]Message(nil), mailer.messages...)
+}
@@ -0,0 +1,170 @@
+package mailer
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormat(t *testing.T) {
+	date := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name           string
+		msg            Message
+		expected       string
+		expected_error error
+	}{
+		{
+			name:     "plain message",
+			msg:      Message{To: "mail@gmail.com", Subject: "Hi", Body: "line one\nline two"},
+			expected: "From: app@todo.local\r\nTo: mail@gmail.com\r\nSubject: Hi\r\nDate: Sat, 01 Jun 2024 12:00:00 +0000\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\nline one\r\nline two\r\n",
+		},
+		{name: "no recipient", msg: Message{Subject: "Hi"}, expected_error: NoRecipientErr},
+		{name: "header injection in subject", msg: Message{To: "mail@gmail.com", Subject: "Hi\r\nBcc: other@gmail.com"}, expected_error: InvalidHeaderErr},
+		{name: "header injection in recipient", msg: Message{To: "mail@gmail.com\nBcc: other@gmail.com"}, expected_error: InvalidHeaderErr},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := format("app@todo.local", test.msg, date)
+
+			if err != test.expected_error {
+				t.Fatalf("unexpected error, got %q, expected %q", err, test.expected_error)
+			}
+			if string(got) != test.expected {
+				t.Errorf("got %q, expected %q", got, test.expected)
+			}
+		})
+	}
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	mailer, err := NewFile(dir, "app@todo.local")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mailer.Send(Message{To: "a@gmail.com", Subject: "first", Body: "1"})
+	mailer.Send(Message{To: "b@gmail.com", Subject: "second", Body: "2"})
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if len(files) != 2 {
+		t.Fatalf("expected a file per message, got %v", files)
+	}
+	content, _ := os.ReadFile(files[0])
+	if !strings.Contains(string(content), "Subject: ") {
+		t.Errorf("expected an email in the file, got %q", content)
+	}
+}
+
+func TestMemoryMailer(t *testing.T) {
+	mailer := &Memory{}
+	msg := Message{To: "a@gmail.com", Subject: "first", Body: "1"}
+
+	err := mailer.Send(msg)
+
+	if err != nil || len(mailer.Messages()) != 1 || mailer.Messages()[0] != msg {
+		t.Errorf("expected the message to be kept, got %v and %q", mailer.Messages(), err)
+	}
+	if mailer.Send(Message{}) != NoRecipientErr {
+		t.Error("expected messages without recipient to be refused")
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Run("no server configured", func(t *testing.T) {
+		t.Setenv("TODO_SMTP_ADDR", "")
+
+		got, err := FromEnv(t.TempDir())
+
+		if _, ok := got.(*File); !ok || err != nil {
+			t.Errorf("expected a file mailer, got %T and %q", got, err)
+		}
+	})
+
+	t.Run("server configured", func(t *testing.T) {
+		t.Setenv("TODO_SMTP_ADDR", "smtp.gmail.com:587")
+		t.Setenv("TODO_SMTP_FROM", "app@todo.local")
+
+		got, err := FromEnv(t.TempDir())
+
+		smtp, ok := got.(*SMTP)
+		if !ok || err != nil || smtp.Addr != "smtp.gmail.com:587" || smtp.From != "app@todo.local" {
+			t.Errorf("expected an SMTP mailer, got %v and %q", got, err)
+		}
+	})
+}
+
+func TestSMTPMailer(t *testing.T) {
+	addr, received := fakeSMTPServer(t)
+	mailer := NewSMTP(addr, "app@todo.local", "", "")
+
+	err := mailer.Send(Message{To: "mail@gmail.com", Subject: "Reset", Body: "your code"})
+
+	if err != nil {
+		t.Fatalf("expected no error, got %q", err)
+	}
+	got := <-received
+	for _, expected := range []string{"MAIL FROM:<app@todo.local>", "RCPT TO:<mail@gmail.com>", "Subject: Reset", "your code"} {
+		if !strings.Contains(got, expected) {
+			t.Errorf("expected %q in the conversation, got %q", expected, got)
+		}
+	}
+}
+
+//helpers
+
+// fakeSMTPServer accepts a single message and sends back everything the
+// client said.
+func fakeSMTPServer(t *testing.T) (string, chan string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var conversation strings.Builder
+		reader := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+		reply("220 localhost ESMTP")
+		inData := false
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				break
+			}
+			conversation.WriteString(line)
+			switch {
+			case inData && line == ".\r\n":
+				inData = false
+				reply("250 OK")
+			case inData:
+			case strings.HasPrefix(line, "EHLO"), strings.HasPrefix(line, "HELO"):
+				reply("250 localhost")
+			case strings.HasPrefix(line, "DATA"):
+				inData = true
+				reply("354 go ahead")
+			case strings.HasPrefix(line, "QUIT"):
+				reply("221 bye")
+				received <- conversation.String()
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+		received <- conversation.String()
+	}()
+	return listener.Addr().String(), received
+}
@@ -0,0 +1,433 @@
+package oidc
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultFlowTTL          = 10 * time.Minute
+	DefaultTimeout          = 10 * time.Second
+	clockLeeway             = time.Minute
+	maxResponseSize         = 1 << 20
+	InvalidDiscoveryErr     = OIDCError("Identity provider configuration is invalid or does not match its issuer")
+	ProviderErr             = OIDCError("Identity provider could not be reached or refused the request")
+	InvalidStateErr         = OIDCError("Login is unknown, was already finished or took too long, please start again")
+	InvalidIDTokenErr       = OIDCError("Identity provider returned an invalid ID token")
+	ExpiredIDTokenErr       = OIDCError("ID token has expired")
+	UnknownSigningKeyErr    = OIDCError("ID token was signed with a key the identity provider does not publish")
+	UnsupportedAlgorithmErr = OIDCError("ID token signing algorithm is not supported")
+)
+
+type OIDCError string
+
+func (err OIDCError) Error() string {
+	return string(err)
+}
+
+// Provider is what discovery tells about an identity provider.
+type Provider struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Discover reads the configuration the identity provider publishes under
+// issuer. The issuer it claims has to be the one asked for, or its tokens
+// could not be checked against it.
+func Discover(client *http.Client, issuer string) (Provider, error) {
+	var provider Provider
+	err := getJSON(client, strings.TrimSuffix(issuer, "/")+"/.well-known/openid-configuration", &provider)
+	if err != nil {
+		return Provider{}, err
+	}
+	if provider.Issuer != issuer || provider.AuthorizationEndpoint == "" || provider.TokenEndpoint == "" || provider.JWKSURI == "" {
+		return Provider{}, InvalidDiscoveryErr
+	}
+	return provider, nil
+}
+
+// Config is how this app is registered with the identity provider.
+type Config struct {
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	// Scopes are asked for on top of openid, by default email and profile
+	Scopes []string
+}
+
+// IDToken holds the claims of a verified ID token this app uses.
+type IDToken struct {
+	Issuer            string   `json:"iss"`
+	Subject           string   `json:"sub"`
+	Audience          Audience `json:"aud"`
+	AuthorizedParty   string   `json:"azp,omitempty"`
+	ExpiresAt         int64    `json:"exp"`
+	IssuedAt          int64    `json:"iat"`
+	Nonce             string   `json:"nonce,omitempty"`
+	Email             string   `json:"email,omitempty"`
+	EmailVerified     Bool     `json:"email_verified,omitempty"`
+	PreferredUsername string   `json:"preferred_username,omitempty"`
+	Name              string   `json:"name,omitempty"`
+}
+
+// Audience is the aud claim, which can be one string or a list of them.
+type Audience []string
+
+func (audience *Audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if json.Unmarshal(data, &single) == nil {
+		*audience = Audience{single}
+		return nil
+	}
+	var list []string
+	err := json.Unmarshal(data, &list)
+	*audience = list
+	return err
+}
+
+func (audience Audience) MarshalJSON() ([]byte, error) {
+	if len(audience) == 1 {
+		return json.Marshal(audience[0])
+	}
+	return json.Marshal([]string(audience))
+}
+
+// Bool is a boolean claim, which some providers send as the string "true" or
+// "false".
+type Bool bool
+
+func (value *Bool) UnmarshalJSON(data []byte) error {
+	var quoted string
+	if json.Unmarshal(data, &quoted) != nil {
+		return json.Unmarshal(data, (*bool)(value))
+	}
+	if quoted != "true" && quoted != "false" {
+		return InvalidIDTokenErr
+	}
+	*value = quoted == "true"
+	return nil
+}
+
+func (audience Audience) contains(clientID string) bool {
+	for _, value := range audience {
+		if value == clientID {
+			return true
+		}
+	}
+	return false
+}
+
+// flow is a login that went to the identity provider and has not come back
+// yet, kept under its state.
+type flow struct {
+	Nonce       string
+	Verifier    string
+	RedirectURL string
+	Expires     time.Time
+}
+
+// RelyingParty logs users in through an identity provider with the
+// authorization code flow and PKCE. Logins in progress are only kept in
+// memory, a restart means starting them again.
+type RelyingParty struct {
+	Provider Provider
+	Config   Config
+	Client   *http.Client
+	FlowTTL  time.Duration
+	Now      func() time.Time
+	mu       sync.Mutex
+	flows    map[string]*flow
+	keys     map[string]*rsa.PublicKey
+}
+
+// NewClient returns a client that gives up on an identity provider after
+// DefaultTimeout, so a login never waits on it forever.
+func NewClient() *http.Client {
+	return &http.Client{Timeout: DefaultTimeout}
+}
+
+func NewRelyingParty(provider Provider, config Config) *RelyingParty {
+	return &RelyingParty{
+		Provider: provider,
+		Config:   config,
+		Client:   NewClient(),
+		FlowTTL:  DefaultFlowTTL,
+		Now:      time.Now,
+		flows:    make(map[string]*flow),
+		keys:     make(map[string]*rsa.PublicKey),
+	}
+}
+
+// AuthCodeURL starts a login and returns where to send the user, and the
+// state the identity provider sends back along with the code. redirectURL
+// replaces Config.RedirectURL when it is not empty, e.g. for a port only
+// known once the app listens on it.
+func (rp *RelyingParty) AuthCodeURL(redirectURL string) (string, string, error) {
+	if redirectURL == "" {
+		redirectURL = rp.Config.RedirectURL
+	}
+	state, err := randomString()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := randomString()
+	if err != nil {
+		return "", "", err
+	}
+	verifier, err := randomString()
+	if err != nil {
+		return "", "", err
+	}
+	challenge := sha256.Sum256([]byte(verifier))
+	scopes := rp.Config.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"email", "profile"}
+	}
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {rp.Config.ClientID},
+		"redirect_uri":          {redirectURL},
+		"scope":                 {strings.Join(append([]string{"openid"}, scopes...), " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+
+	rp.mu.Lock()
+	now := rp.Now()
+	rp.prune(now)
+	rp.flows[state] = &flow{Nonce: nonce, Verifier: verifier, RedirectURL: redirectURL, Expires: now.Add(rp.FlowTTL)}
+	rp.mu.Unlock()
+
+	separator := "?"
+	if strings.Contains(rp.Provider.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return rp.Provider.AuthorizationEndpoint + separator + query.Encode(), state, nil
+}
+
+// Exchange finishes the login started with state, trading code for an ID
+// token and verifying it. Every state can only be used once.
+func (rp *RelyingParty) Exchange(state, code string) (IDToken, error) {
+	rp.mu.Lock()
+	started, found := rp.flows[state]
+	delete(rp.flows, state)
+	rp.mu.Unlock()
+	if !found || !rp.Now().Before(started.Expires) {
+		return IDToken{}, InvalidStateErr
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {started.RedirectURL},
+		"client_id":     {rp.Config.ClientID},
+		"code_verifier": {started.Verifier},
+	}
+	request, err := http.NewRequest(http.MethodPost, rp.Provider.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return IDToken{}, err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if rp.Config.ClientSecret != "" {
+		request.SetBasicAuth(url.QueryEscape(rp.Config.ClientID), url.QueryEscape(rp.Config.ClientSecret))
+	}
+	var tokens struct {
+		IDToken string `json:"id_token"`
+	}
+	err = doJSON(rp.Client, request, &tokens)
+	if err != nil {
+		return IDToken{}, err
+	}
+	return rp.Verify(tokens.IDToken, started.Nonce)
+}
+
+// Verify checks an ID token was signed by the identity provider for this app
+// and is still valid, and that it carries nonce.
+func (rp *RelyingParty) Verify(token, nonce string) (IDToken, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return IDToken{}, InvalidIDTokenErr
+	}
+	var header struct {
+		Algorithm string `json:"alg"`
+		KeyId     string `json:"kid"`
+	}
+	err := decodeSegment(parts[0], &header)
+	if err != nil {
+		return IDToken{}, InvalidIDTokenErr
+	}
+	// OpenID Connect requires every provider to support RS256, checking
+	// nothing else keeps tokens from choosing a weaker algorithm
+	if header.Algorithm != "RS256" {
+		return IDToken{}, UnsupportedAlgorithmErr
+	}
+	key, err := rp.signingKey(header.KeyId)
+	if err != nil {
+		return IDToken{}, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return IDToken{}, InvalidIDTokenErr
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) != nil {
+		return IDToken{}, InvalidIDTokenErr
+	}
+	var claims IDToken
+	err = decodeSegment(parts[1], &claims)
+	if err != nil {
+		return IDToken{}, InvalidIDTokenErr
+	}
+	now := rp.Now()
+	if claims.Issuer != rp.Provider.Issuer || claims.Subject == "" || !claims.Audience.contains(rp.Config.ClientID) || claims.Nonce != nonce {
+		return IDToken{}, InvalidIDTokenErr
+	}
+	if len(claims.Audience) > 1 && claims.AuthorizedParty != rp.Config.ClientID {
+		return IDToken{}, InvalidIDTokenErr
+	}
+	if !now.Before(time.Unix(claims.ExpiresAt, 0).Add(clockLeeway)) {
+		return IDToken{}, ExpiredIDTokenErr
+	}
+	if time.Unix(claims.IssuedAt, 0).After(now.Add(clockLeeway)) {
+		return IDToken{}, InvalidIDTokenErr
+	}
+	return claims, nil
+}
+
+// signingKey returns the key with the given id, fetching the keys again when
+// it is unknown as the identity provider may have rotated them.
+func (rp *RelyingParty) signingKey(id string) (*rsa.PublicKey, error) {
+	rp.mu.Lock()
+	key, found := rp.keys[id]
+	rp.mu.Unlock()
+	if found {
+		return key, nil
+	}
+	keys, err := fetchKeys(rp.Client, rp.Provider.JWKSURI)
+	if err != nil {
+		return nil, err
+	}
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	rp.keys = keys
+	key, found = keys[id]
+	if !found {
+		return nil, UnknownSigningKeyErr
+	}
+	return key, nil
+}
+
+// JSONWebKey is a public key of a JWKS, only RSA keys are read.
+type JSONWebKey struct {
+	KeyType   string `json:"kty"`
+	KeyId     string `json:"kid"`
+	Use       string `json:"use,omitempty"`
+	Algorithm string `json:"alg,omitempty"`
+	Modulus   string `json:"n"`
+	Exponent  string `json:"e"`
+}
+
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// NewJSONWebKey publishes the public half of an RSA key, e.g. for a stand-in
+// identity provider.
+func NewJSONWebKey(id string, key *rsa.PublicKey) JSONWebKey {
+	return JSONWebKey{
+		KeyType:   "RSA",
+		KeyId:     id,
+		Use:       "sig",
+		Algorithm: "RS256",
+		Modulus:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		Exponent:  base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+func fetchKeys(client *http.Client, jwksURI string) (map[string]*rsa.PublicKey, error) {
+	var set JSONWebKeySet
+	err := getJSON(client, jwksURI, &set)
+	if err != nil {
+		return nil, err
+	}
+	keys := make(map[string]*rsa.PublicKey)
+	for _, key := range set.Keys {
+		if key.KeyType != "RSA" || (key.Use != "" && key.Use != "sig") {
+			continue
+		}
+		modulus, err := base64.RawURLEncoding.DecodeString(key.Modulus)
+		if err != nil {
+			continue
+		}
+		exponent, err := base64.RawURLEncoding.DecodeString(key.Exponent)
+		if err != nil || len(exponent) > 4 {
+			continue
+		}
+		keys[key.KeyId] = &rsa.PublicKey{N: new(big.Int).SetBytes(modulus), E: int(new(big.Int).SetBytes(exponent).Int64())}
+	}
+	return keys, nil
+}
+
+func (rp *RelyingParty) prune(now time.Time) {
+	for state, started := range rp.flows {
+		if !now.Before(started.Expires) {
+			delete(rp.flows, state)
+		}
+	}
+}
+
+func getJSON(client *http.Client, url string, value any) error {
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	return doJSON(client, request, value)
+}
+
+func doJSON(client *http.Client, request *http.Request, value any) error {
+	request.Header.Set("Accept", "application/json")
+	response, err := client.Do(request)
+	if err != nil {
+		return ProviderErr
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return ProviderErr
+	}
+	err = json.NewDecoder(io.LimitReader(response.Body, maxResponseSize)).Decode(value)
+	if err != nil {
+		return ProviderErr
+	}
+	return nil
+}
+
+func decodeSegment(segment string, value any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, value)
+}
+
+func randomString() (string, error) {
+	secret := make([]byte, 32)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(secret), nil
+}
@@ -0,0 +1,196 @@
+package oidc_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+	"todo_app/pkg/oidc"
+	"todo_app/pkg/oidc/oidctest"
+)
+
+func TestDiscover(t *testing.T) {
+	provider := newTestProvider(t)
+
+	got, err := oidc.Discover(http.DefaultClient, provider.Issuer())
+	assertError(t, err, nil)
+	if got.Issuer != provider.Issuer() || got.TokenEndpoint != provider.Issuer()+"/token" {
+		t.Errorf("got %v, expected the endpoints of the provider", got)
+	}
+
+	_, err = oidc.Discover(http.DefaultClient, provider.Issuer()+"/")
+	assertError(t, err, oidc.InvalidDiscoveryErr)
+	_, err = oidc.Discover(http.DefaultClient, provider.Issuer()+"/nowhere")
+	assertError(t, err, oidc.ProviderErr)
+}
+
+func TestLogin(t *testing.T) {
+	provider := newTestProvider(t)
+	provider.SetUser(oidctest.User{Subject: "1234", Email: "mail@gmail.com", EmailVerified: true, PreferredUsername: "tester"})
+	rp := newTestRelyingParty(t, provider)
+
+	authURL, state, err := rp.AuthCodeURL("")
+	assertError(t, err, nil)
+	if !strings.Contains(authURL, "code_challenge_method=S256") || !strings.Contains(authURL, "scope=openid+email+profile") {
+		t.Errorf("expected a PKCE request for the default scopes, got %q", authURL)
+	}
+	back, err := provider.Approve(authURL)
+	assertError(t, err, nil)
+	if back.Query().Get("state") != state {
+		t.Fatalf("expected the state back, got %q", back)
+	}
+	token, err := rp.Exchange(state, back.Query().Get("code"))
+	assertError(t, err, nil)
+
+	if token.Subject != "1234" || token.Email != "mail@gmail.com" || !token.EmailVerified || token.PreferredUsername != "tester" {
+		t.Errorf("got %v, expected the claims of the user", token)
+	}
+	_, err = rp.Exchange(state, back.Query().Get("code"))
+	assertError(t, err, oidc.InvalidStateErr)
+}
+
+func TestLoginFlowExpiry(t *testing.T) {
+	provider := newTestProvider(t)
+	rp := newTestRelyingParty(t, provider)
+	now := time.Now()
+	rp.Now = func() time.Time { return now }
+
+	authURL, state, _ := rp.AuthCodeURL("")
+	back, _ := provider.Approve(authURL)
+	now = now.Add(oidc.DefaultFlowTTL)
+	_, err := rp.Exchange(state, back.Query().Get("code"))
+
+	assertError(t, err, oidc.InvalidStateErr)
+}
+
+func TestInvalidIDTokens(t *testing.T) {
+	tests := []struct {
+		name           string
+		tamper         func(claims *oidc.IDToken)
+		expected_error error
+	}{
+		{name: "another issuer", tamper: func(claims *oidc.IDToken) { claims.Issuer = "https://evil.example" }, expected_error: oidc.InvalidIDTokenErr},
+		{name: "another audience", tamper: func(claims *oidc.IDToken) { claims.Audience = oidc.Audience{"other-app"} }, expected_error: oidc.InvalidIDTokenErr},
+		{name: "several audiences without azp", tamper: func(claims *oidc.IDToken) { claims.Audience = append(claims.Audience, "other-app") }, expected_error: oidc.InvalidIDTokenErr},
+		{name: "several audiences with azp", tamper: func(claims *oidc.IDToken) {
+			claims.Audience = append(claims.Audience, "other-app")
+			claims.AuthorizedParty = "todo-app"
+		}, expected_error: nil},
+		{name: "another nonce", tamper: func(claims *oidc.IDToken) { claims.Nonce = "replayed" }, expected_error: oidc.InvalidIDTokenErr},
+		{name: "expired", tamper: func(claims *oidc.IDToken) { claims.ExpiresAt = time.Now().Add(-time.Hour).Unix() }, expected_error: oidc.ExpiredIDTokenErr},
+		{name: "issued in the future", tamper: func(claims *oidc.IDToken) { claims.IssuedAt = time.Now().Add(time.Hour).Unix() }, expected_error: oidc.InvalidIDTokenErr},
+		{name: "no subject", tamper: func(claims *oidc.IDToken) { claims.Subject = "" }, expected_error: oidc.InvalidIDTokenErr},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			provider := newTestProvider(t)
+			provider.SetUser(oidctest.User{Subject: "1234"})
+			provider.Tamper = test.tamper
+			rp := newTestRelyingParty(t, provider)
+
+			authURL, state, _ := rp.AuthCodeURL("")
+			back, _ := provider.Approve(authURL)
+			_, err := rp.Exchange(state, back.Query().Get("code"))
+
+			assertError(t, err, test.expected_error)
+		})
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	provider := newTestProvider(t)
+	rp := newTestRelyingParty(t, provider)
+	claims := oidc.IDToken{
+		Issuer: provider.Issuer(), Subject: "1234", Audience: oidc.Audience{"todo-app"},
+		ExpiresAt: time.Now().Add(time.Minute).Unix(), IssuedAt: time.Now().Unix(),
+	}
+	valid, _ := provider.SignIDToken(claims)
+	provider.KeyId = "rotated"
+	unknownKey, _ := provider.SignIDToken(claims)
+	provider.KeyId = "test-key"
+	key := provider.Key
+	provider.Key, _ = rsa.GenerateKey(rand.Reader, 2048)
+	forged, _ := provider.SignIDToken(claims)
+	provider.Key = key
+	parts := strings.Split(valid, ".")
+	noneAlgorithm := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","kid":"test-key"}`)) + "." + parts[1] + "."
+	tests := []struct {
+		name           string
+		token          string
+		expected_error error
+	}{
+		{name: "malformed", token: "not.a-token", expected_error: oidc.InvalidIDTokenErr},
+		{name: "unknown key", token: unknownKey, expected_error: oidc.UnknownSigningKeyErr},
+		{name: "unsigned", token: noneAlgorithm, expected_error: oidc.UnsupportedAlgorithmErr},
+		{name: "signed by another key", token: forged, expected_error: oidc.InvalidIDTokenErr},
+		{name: "valid", token: valid, expected_error: nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := rp.Verify(test.token, "")
+
+			assertError(t, err, test.expected_error)
+		})
+	}
+}
+
+func TestEmailVerified(t *testing.T) {
+	tests := []struct {
+		name           string
+		input          string
+		expected       oidc.Bool
+		expected_error error
+	}{
+		{name: "bool", input: `{"email_verified":true}`, expected: true, expected_error: nil},
+		{name: "string", input: `{"email_verified":"true"}`, expected: true, expected_error: nil},
+		{name: "false string", input: `{"email_verified":"false"}`, expected: false, expected_error: nil},
+		{name: "missing", input: `{}`, expected: false, expected_error: nil},
+		{name: "other string", input: `{"email_verified":"yes"}`, expected: false, expected_error: oidc.InvalidIDTokenErr},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var claims oidc.IDToken
+			err := json.Unmarshal([]byte(test.input), &claims)
+
+			assertError(t, err, test.expected_error)
+			if claims.EmailVerified != test.expected {
+				t.Errorf("got %v, expected %v", claims.EmailVerified, test.expected)
+			}
+		})
+	}
+}
+
+//helpers
+
+func newTestProvider(t testing.TB) *oidctest.Provider {
+	t.Helper()
+	provider, err := oidctest.NewProvider("todo-app", "secret")
+	if err != nil {
+		t.Fatalf("could not start the test provider: %q", err)
+	}
+	t.Cleanup(provider.Close)
+	return provider
+}
+
+func newTestRelyingParty(t testing.TB, provider *oidctest.Provider) *oidc.RelyingParty {
+	t.Helper()
+	discovered, err := oidc.Discover(http.DefaultClient, provider.Issuer())
+	if err != nil {
+		t.Fatalf("could not discover the test provider: %q", err)
+	}
+	return oidc.NewRelyingParty(discovered, oidc.Config{ClientID: "todo-app", ClientSecret: "secret", RedirectURL: "http://127.0.0.1/callback"})
+}
+
+func assertError(t testing.TB, got, expected error) {
+	t.Helper()
+	if got != expected {
+		t.Errorf("got %q, expected %q", got, expected)
+	}
+}
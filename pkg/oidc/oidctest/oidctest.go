@@ -0,0 +1,225 @@
+// Package oidctest runs a stand-in OpenID Connect identity provider in the
+// test process, so logins through one can be tested without a real one.
+package oidctest
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+	"todo_app/pkg/oidc"
+)
+
+// User is who the provider says logged in.
+type User struct {
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	PreferredUsername string
+	Name              string
+}
+
+type grant struct {
+	User        User
+	Challenge   string
+	RedirectURL string
+	Nonce       string
+}
+
+// Provider approves every login at its authorization endpoint as the user
+// set with SetUser, without showing a login page. Its ID tokens are signed
+// with RS256 by Key and last TokenTTL.
+type Provider struct {
+	Server       *httptest.Server
+	ClientID     string
+	ClientSecret string
+	Key          *rsa.PrivateKey
+	KeyId        string
+	TokenTTL     time.Duration
+	Now          func() time.Time
+	// Tamper changes the claims of every ID token before it is signed, to
+	// test how bad tokens are handled
+	Tamper func(claims *oidc.IDToken)
+	mu     sync.Mutex
+	user   User
+	codes  map[string]*grant
+}
+
+// NewProvider starts a provider that knows one client. Close it when done.
+func NewProvider(clientID, clientSecret string) (*Provider, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	provider := &Provider{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Key:          key,
+		KeyId:        "test-key",
+		TokenTTL:     5 * time.Minute,
+		Now:          time.Now,
+		codes:        make(map[string]*grant),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", provider.discovery)
+	mux.HandleFunc("GET /authorize", provider.authorize)
+	mux.HandleFunc("POST /token", provider.token)
+	mux.HandleFunc("GET /jwks", provider.jwks)
+	provider.Server = httptest.NewServer(mux)
+	return provider, nil
+}
+
+func (provider *Provider) Close() {
+	provider.Server.Close()
+}
+
+func (provider *Provider) Issuer() string {
+	return provider.Server.URL
+}
+
+// SetUser sets who the next logins are approved as.
+func (provider *Provider) SetUser(user User) {
+	provider.mu.Lock()
+	defer provider.mu.Unlock()
+	provider.user = user
+}
+
+// Approve follows authURL, from oidc.RelyingParty.AuthCodeURL, the way a
+// browser would and returns where the provider redirects back to, with the
+// code and state in its query.
+func (provider *Provider) Approve(authURL string) (*url.URL, error) {
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	response, err := client.Get(authURL)
+	if err != nil {
+		return nil, err
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusFound {
+		return nil, oidc.ProviderErr
+	}
+	return response.Location()
+}
+
+// SignIDToken signs claims the way the provider signs its ID tokens.
+func (provider *Provider) SignIDToken(claims oidc.IDToken) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": provider.KeyId})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, provider.Key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+func (provider *Provider) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, oidc.Provider{
+		Issuer:                provider.Issuer(),
+		AuthorizationEndpoint: provider.Issuer() + "/authorize",
+		TokenEndpoint:         provider.Issuer() + "/token",
+		JWKSURI:               provider.Issuer() + "/jwks",
+	})
+}
+
+func (provider *Provider) jwks(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, oidc.JSONWebKeySet{Keys: []oidc.JSONWebKey{oidc.NewJSONWebKey(provider.KeyId, &provider.Key.PublicKey)}})
+}
+
+func (provider *Provider) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	redirectURL, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil || query.Get("redirect_uri") == "" || query.Get("client_id") != provider.ClientID {
+		http.Error(w, "invalid client or redirect_uri", http.StatusBadRequest)
+		return
+	}
+	if query.Get("response_type") != "code" || query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		http.Error(w, "only the code flow with PKCE is supported", http.StatusBadRequest)
+		return
+	}
+	code := randomString()
+	provider.mu.Lock()
+	provider.codes[code] = &grant{User: provider.user, Challenge: query.Get("code_challenge"), RedirectURL: query.Get("redirect_uri"), Nonce: query.Get("nonce")}
+	provider.mu.Unlock()
+	back := redirectURL.Query()
+	back.Set("code", code)
+	back.Set("state", query.Get("state"))
+	redirectURL.RawQuery = back.Encode()
+	http.Redirect(w, r, redirectURL.String(), http.StatusFound)
+}
+
+func (provider *Provider) token(w http.ResponseWriter, r *http.Request) {
+	clientID, clientSecret, ok := r.BasicAuth()
+	if ok {
+		clientID, _ = url.QueryUnescape(clientID)
+		clientSecret, _ = url.QueryUnescape(clientSecret)
+	}
+	if clientID != provider.ClientID || clientSecret != provider.ClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	provider.mu.Lock()
+	granted, found := provider.codes[r.PostFormValue("code")]
+	delete(provider.codes, r.PostFormValue("code"))
+	provider.mu.Unlock()
+	challenge := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+	if r.PostFormValue("grant_type") != "authorization_code" || !found || granted.RedirectURL != r.PostFormValue("redirect_uri") ||
+		granted.Challenge != base64.RawURLEncoding.EncodeToString(challenge[:]) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+	now := provider.Now()
+	claims := oidc.IDToken{
+		Issuer:            provider.Issuer(),
+		Subject:           granted.User.Subject,
+		Audience:          oidc.Audience{provider.ClientID},
+		ExpiresAt:         now.Add(provider.TokenTTL).Unix(),
+		IssuedAt:          now.Unix(),
+		Nonce:             granted.Nonce,
+		Email:             granted.User.Email,
+		EmailVerified:     oidc.Bool(granted.User.EmailVerified),
+		PreferredUsername: granted.User.PreferredUsername,
+		Name:              granted.User.Name,
+	}
+	if provider.Tamper != nil {
+		provider.Tamper(&claims)
+	}
+	idToken, err := provider.SignIDToken(claims)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"expires_in":   int(provider.TokenTTL / time.Second),
+		"id_token":     idToken,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func randomString() string {
+	secret := make([]byte, 24)
+	rand.Read(secret)
+	return base64.RawURLEncoding.EncodeToString(secret)
+}
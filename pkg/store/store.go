@@ -0,0 +1,516 @@
+package store
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"todo_app/pkg/auth"
+	"todo_app/pkg/blobs"
+	"todo_app/pkg/tasks"
+
+	"github.com/google/uuid"
+)
+
+const InvalidRecordErr = StoreError("Data file contains an invalid record")
+
+type StoreError string
+
+func (err StoreError) Error() string {
+	return string(err)
+}
+
+// Collision is a group of users whose emails or usernames only differ in case
+// or in how accents are written, saved before users were looked up by the
+// canonical form. Only one of them could log in, so they have to be renamed.
+type Collision struct {
+	Field     string
+	Canonical string
+	Users     []auth.User
+}
+
+// CollisionErr is returned by LoadUsers for a users.csv with collisions.
+type CollisionErr []Collision
+
+func (err CollisionErr) Error() string {
+	var message strings.Builder
+	message.WriteString("users.csv has accounts that can't be told apart, rename all but one of each before starting:")
+	for _, collision := range err {
+		fmt.Fprintf(&message, "\n  %s %q:", collision.Field, collision.Canonical)
+		for _, user := range collision.Users {
+			fmt.Fprintf(&message, " %s (%s, %s)", user.Id, user.Email, user.Username)
+		}
+	}
+	return message.String()
+}
+
+// findCollisions groups users by the canonical form of their email and of
+// their username, in the order of the file.
+func findCollisions(list []*auth.User) CollisionErr {
+	var collisions CollisionErr
+	fields := []struct {
+		name      string
+		canonical func(auth.User) string
+	}{
+		{name: "email", canonical: func(user auth.User) string { return auth.CanonicalEmail(user.Email) }},
+		{name: "username", canonical: func(user auth.User) string { return auth.CanonicalUsername(user.Username) }},
+	}
+	for _, field := range fields {
+		groups := make(map[string][]auth.User)
+		var order []string
+		for _, user := range list {
+			key := field.canonical(*user)
+			if _, found := groups[key]; !found {
+				order = append(order, key)
+			}
+			groups[key] = append(groups[key], *user)
+		}
+		for _, key := range order {
+			if len(groups[key]) > 1 {
+				collisions = append(collisions, Collision{Field: field.name, Canonical: key, Users: groups[key]})
+			}
+		}
+	}
+	return collisions
+}
+
+// LoadUsers reads users.csv, a missing file is an empty database. Users saved
+// before emails were verified have no verified column and are unverified, the
+// same goes for the two-factor columns, users saved before roles are enabled
+// users without a forced reset and users saved before single sign-on have no
+// linked identities. Files written before users were looked up by canonical
+// form are checked for accounts that now collide, with a CollisionErr listing
+// them; the database is then incomplete and must not be saved.
+func LoadUsers(path string) (auth.UserDatabase, error) {
+	users := auth.UserDatabase{
+		UsersByEmail:    make(map[string]*auth.User),
+		UsersByUsername: make(map[string]*auth.User),
+	}
+	var list []*auth.User
+	err := readRecords(path, func(rec []string) error {
+		if len(rec) < 4 {
+			return InvalidRecordErr
+		}
+		id, err := uuid.Parse(rec[0])
+		if err != nil {
+			return InvalidRecordErr
+		}
+		user := auth.User{Id: id, Email: rec[1], Username: rec[2], Password: rec[3]}
+		if len(rec) > 4 {
+			user.Verified, err = strconv.ParseBool(rec[4])
+			if err != nil {
+				return InvalidRecordErr
+			}
+		}
+		if len(rec) > 7 && rec[5] != "" {
+			user.TOTPSecret = rec[5]
+			user.TOTPLastStep, err = strconv.ParseInt(rec[6], 10, 64)
+			if err != nil {
+				return InvalidRecordErr
+			}
+			if rec[7] != "" {
+				user.RecoveryCodes = strings.Split(rec[7], ";")
+			}
+		}
+		if len(rec) > 10 {
+			user.Role, err = auth.ParseRole(rec[8])
+			if err != nil {
+				return InvalidRecordErr
+			}
+			user.Disabled, err = strconv.ParseBool(rec[9])
+			if err != nil {
+				return InvalidRecordErr
+			}
+			user.PasswordResetRequired, err = strconv.ParseBool(rec[10])
+			if err != nil {
+				return InvalidRecordErr
+			}
+		}
+		if len(rec) > 11 && rec[11] != "" {
+			for _, linked := range strings.Split(rec[11], ";") {
+				issuer, subject, found := strings.Cut(linked, "|")
+				if !found {
+					return InvalidRecordErr
+				}
+				user.Identities = append(user.Identities, auth.Identity{Issuer: identityUnescaper.Replace(issuer), Subject: identityUnescaper.Replace(subject)})
+			}
+		}
+		list = append(list, &user)
+		return nil
+	})
+	if err != nil {
+		return users, err
+	}
+	// later users never replace earlier ones, whatever happens to the
+	// collisions the first account keeps working
+	for i := len(list) - 1; i >= 0; i-- {
+		users.UsersByEmail[auth.CanonicalEmail(list[i].Email)] = list[i]
+		users.UsersByUsername[auth.CanonicalUsername(list[i].Username)] = list[i]
+	}
+	collisions := findCollisions(list)
+	if len(collisions) > 0 {
+		return users, collisions
+	}
+	return users, nil
+}
+
+func SaveUsers(path string, users auth.UserDatabase) error {
+	var records [][]string
+	for _, user := range users.UsersByUsername {
+		records = append(records, []string{
+			user.Id.String(), user.Email, user.Username, user.Password, strconv.FormatBool(user.Verified),
+			user.TOTPSecret, strconv.FormatInt(user.TOTPLastStep, 10), strings.Join(user.RecoveryCodes, ";"),
+			user.Role.String(), strconv.FormatBool(user.Disabled), strconv.FormatBool(user.PasswordResetRequired),
+			formatIdentities(user.Identities),
+		})
+	}
+	return writeRecords(path, records)
+}
+
+// identityEscaper percent-encodes the separators of identities, and %
+// itself, so subjects can have any character.
+var (
+	identityEscaper   = strings.NewReplacer("%", "%25", "|", "%7C", ";", "%3B")
+	identityUnescaper = strings.NewReplacer("%25", "%", "%7C", "|", "%3B", ";")
+)
+
+// formatIdentities joins identities as issuer|subject pairs separated by ;
+func formatIdentities(identities []auth.Identity) string {
+	var pairs []string
+	for _, identity := range identities {
+		pairs = append(pairs, identityEscaper.Replace(identity.Issuer)+"|"+identityEscaper.Replace(identity.Subject))
+	}
+	return strings.Join(pairs, ";")
+}
+
+// LoadTasks reads tasks.csv. Columns added after the first six are optional so
+// files written by older versions still load.
+func LoadTasks(path string) (map[uuid.UUID]tasks.TaskList, error) {
+	userTasks := make(map[uuid.UUID]tasks.TaskList)
+	err := readRecords(path, func(rec []string) error {
+		if len(rec) < 6 {
+			return InvalidRecordErr
+		}
+		userId, err := uuid.Parse(rec[0])
+		if err != nil {
+			return InvalidRecordErr
+		}
+		id, err := strconv.Atoi(rec[1])
+		if err != nil {
+			return InvalidRecordErr
+		}
+		task := tasks.Task{Id: id, Name: rec[2], Description: rec[3], Date: rec[4], TaskStatus: rec[5]}
+		if len(rec) > 6 && rec[6] != "" {
+			task.Estimate, err = time.ParseDuration(rec[6])
+			if err != nil {
+				return InvalidRecordErr
+			}
+		}
+		if len(rec) > 7 {
+			task.Tags = tasks.SplitTags(rec[7])
+		}
+		if len(rec) > 8 {
+			task.Priority = rec[8]
+		}
+
+		taskList, found := userTasks[userId]
+		if !found {
+			taskList = make(tasks.TaskList)
+			userTasks[userId] = taskList
+		}
+		taskList[id] = &task
+		return nil
+	})
+	return userTasks, err
+}
+
+func SaveTasks(path string, userTasks map[uuid.UUID]tasks.TaskList) error {
+	var records [][]string
+	for userId, taskList := range userTasks {
+		for taskId, task := range taskList {
+			records = append(records, []string{
+				userId.String(),
+				strconv.Itoa(taskId),
+				strings.TrimSpace(task.Name),
+				strings.TrimSpace(task.Description),
+				strings.TrimSpace(task.Date),
+				strings.TrimSpace(task.TaskStatus),
+				task.Estimate.String(),
+				strings.Join(task.Tags, ","),
+				task.Priority,
+			})
+		}
+	}
+	return writeRecords(path, records)
+}
+
+// LoadAttachments adds the attachments saved by SaveAttachments to the tasks
+// of userTasks and takes a reference on their blobs in blobStore. Rows of
+// tasks that are gone and attachments whose blob is missing are dropped.
+func LoadAttachments(path string, userTasks map[uuid.UUID]tasks.TaskList, blobStore *blobs.Store) error {
+	return readRecords(path, func(rec []string) error {
+		if len(rec) < 5 {
+			return InvalidRecordErr
+		}
+		userId, err := uuid.Parse(rec[0])
+		if err != nil {
+			return InvalidRecordErr
+		}
+		taskId, err := strconv.Atoi(rec[1])
+		if err != nil {
+			return InvalidRecordErr
+		}
+		size, err := strconv.ParseInt(rec[4], 10, 64)
+		if err != nil {
+			return InvalidRecordErr
+		}
+		task, err := userTasks[userId].GetTask(taskId)
+		if err != nil {
+			return nil
+		}
+		err = blobStore.Retain(rec[3])
+		if err != nil {
+			return nil
+		}
+		task.Attachments = append(task.Attachments, tasks.Attachment{Name: rec[2], Hash: rec[3], Size: size})
+		return nil
+	})
+}
+
+func SaveAttachments(path string, userTasks map[uuid.UUID]tasks.TaskList) error {
+	var records [][]string
+	for userId, taskList := range userTasks {
+		for taskId, task := range taskList {
+			for _, attachment := range task.Attachments {
+				records = append(records, []string{userId.String(), strconv.Itoa(taskId), attachment.Name, attachment.Hash, strconv.FormatInt(attachment.Size, 10)})
+			}
+		}
+	}
+	return writeRecords(path, records)
+}
+
+// LoadTimeEntries adds the time entries saved by SaveTimeEntries to the tasks
+// of userTasks. Rows of tasks that are gone are dropped.
+func LoadTimeEntries(path string, userTasks map[uuid.UUID]tasks.TaskList) error {
+	return readRecords(path, func(rec []string) error {
+		if len(rec) < 4 {
+			return InvalidRecordErr
+		}
+		userId, err := uuid.Parse(rec[0])
+		if err != nil {
+			return InvalidRecordErr
+		}
+		taskId, err := strconv.Atoi(rec[1])
+		if err != nil {
+			return InvalidRecordErr
+		}
+		entry := tasks.TimeEntry{}
+		entry.Start, err = time.Parse(time.RFC3339, rec[2])
+		if err != nil {
+			return InvalidRecordErr
+		}
+		if rec[3] != "" {
+			entry.End, err = time.Parse(time.RFC3339, rec[3])
+			if err != nil {
+				return InvalidRecordErr
+			}
+		}
+		task, err := userTasks[userId].GetTask(taskId)
+		if err != nil {
+			return nil
+		}
+		task.TimeEntries = append(task.TimeEntries, entry)
+		return nil
+	})
+}
+
+// SaveTimeEntries writes the time entries of every task, running ones without
+// an end.
+func SaveTimeEntries(path string, userTasks map[uuid.UUID]tasks.TaskList) error {
+	var records [][]string
+	for userId, taskList := range userTasks {
+		for taskId, task := range taskList {
+			for _, entry := range task.TimeEntries {
+				end := ""
+				if !entry.Running() {
+					end = entry.End.Format(time.RFC3339)
+				}
+				records = append(records, []string{userId.String(), strconv.Itoa(taskId), entry.Start.Format(time.RFC3339), end})
+			}
+		}
+	}
+	return writeRecords(path, records)
+}
+
+func readRecords(path string, read func(rec []string) error) error {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	csvReader := csv.NewReader(file)
+	csvReader.FieldsPerRecord = -1
+	for {
+		rec, err := csvReader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		err = read(rec)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// writeRecords replaces the file in one step, so a crash while saving leaves
+// the previous version instead of a half written one.
+func writeRecords(path string, records [][]string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	csvWriter := csv.NewWriter(tmp)
+	err = csvWriter.WriteAll(records)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// LoadSessions restores the sessions saved by SaveSessions. Only token hashes
+// are stored, so a leaked file cannot be used to log in.
+func LoadSessions(path string, sessions *auth.Sessions) error {
+	return readRecords(path, func(rec []string) error {
+		if len(rec) < 4 {
+			return InvalidRecordErr
+		}
+		userId, err := uuid.Parse(rec[1])
+		if err != nil {
+			return InvalidRecordErr
+		}
+		created, err := time.Parse(time.RFC3339Nano, rec[2])
+		if err != nil {
+			return InvalidRecordErr
+		}
+		expires, err := time.Parse(time.RFC3339Nano, rec[3])
+		if err != nil {
+			return InvalidRecordErr
+		}
+		sessions.Restore(auth.Session{TokenHash: rec[0], UserId: userId, Created: created, Expires: expires})
+		return nil
+	})
+}
+
+func SaveSessions(path string, sessions *auth.Sessions) error {
+	var records [][]string
+	for _, session := range sessions.List() {
+		records = append(records, []string{session.TokenHash, session.UserId.String(), session.Created.Format(time.RFC3339Nano), session.Expires.Format(time.RFC3339Nano)})
+	}
+	return writeRecords(path, records)
+}
+
+// LoadAPIKeys restores the keys saved by SaveAPIKeys. Like sessions only the
+// hash of each key is stored.
+func LoadAPIKeys(path string, keys *auth.APIKeys) error {
+	return readRecords(path, func(rec []string) error {
+		if len(rec) < 8 {
+			return InvalidRecordErr
+		}
+		userId, err := uuid.Parse(rec[2])
+		if err != nil {
+			return InvalidRecordErr
+		}
+		var times [3]time.Time
+		for i, value := range rec[5:8] {
+			if value == "" {
+				continue
+			}
+			times[i], err = time.Parse(time.RFC3339Nano, value)
+			if err != nil {
+				return InvalidRecordErr
+			}
+		}
+		keys.Restore(auth.APIKey{
+			Hash:     rec[0],
+			Id:       rec[1],
+			UserId:   userId,
+			Name:     rec[3],
+			Scopes:   strings.Fields(rec[4]),
+			Created:  times[0],
+			Expires:  times[1],
+			LastUsed: times[2],
+		})
+		return nil
+	})
+}
+
+func SaveAPIKeys(path string, keys *auth.APIKeys) error {
+	var records [][]string
+	for _, key := range keys.All() {
+		records = append(records, []string{
+			key.Hash,
+			key.Id,
+			key.UserId.String(),
+			key.Name,
+			strings.Join(key.Scopes, " "),
+			formatTime(key.Created),
+			formatTime(key.Expires),
+			formatTime(key.LastUsed),
+		})
+	}
+	return writeRecords(path, records)
+}
+
+// formatTime leaves times that were never set empty.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+// LoadVerifications restores the codes saved by SaveVerifications, which are
+// only stored hashed.
+func LoadVerifications(path string, verifications *auth.EmailVerifications) error {
+	return readRecords(path, func(rec []string) error {
+		if len(rec) < 3 {
+			return InvalidRecordErr
+		}
+		userId, err := uuid.Parse(rec[1])
+		if err != nil {
+			return InvalidRecordErr
+		}
+		expires, err := time.Parse(time.RFC3339Nano, rec[2])
+		if err != nil {
+			return InvalidRecordErr
+		}
+		verifications.Restore(auth.Verification{TokenHash: rec[0], UserId: userId, Expires: expires})
+		return nil
+	})
+}
+
+func SaveVerifications(path string, verifications *auth.EmailVerifications) error {
+	var records [][]string
+	for _, verification := range verifications.List() {
+		records = append(records, []string{verification.TokenHash, verification.UserId.String(), verification.Expires.Format(time.RFC3339Nano)})
+	}
+	return writeRecords(path, records)
+}
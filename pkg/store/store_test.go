@@ -0,0 +1,367 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"todo_app/pkg/auth"
+	"todo_app/pkg/blobs"
+	"todo_app/pkg/mailer"
+	"todo_app/pkg/tasks"
+
+	"github.com/google/uuid"
+)
+
+func TestUsersRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.csv")
+	user := auth.User{Id: uuid.New(), Email: "mail@gmail.com", Username: "tester", Password: "$2a$12$hash"}
+	verified := auth.User{Id: uuid.New(), Email: "other@gmail.com", Username: "verified", Password: "$2a$12$hash", Verified: true}
+	twoFactor := auth.User{
+		Id: uuid.New(), Email: "totp@gmail.com", Username: "totp", Password: "$2a$12$hash",
+		TOTPSecret: "JBSWY3DPEHPK3PXP", TOTPLastStep: 57000000, RecoveryCodes: []string{"hash1", "hash2"},
+	}
+	admin := auth.User{
+		Id: uuid.New(), Email: "admin@gmail.com", Username: "admin", Password: "$2a$12$hash",
+		Role: auth.RoleAdmin, Disabled: true, PasswordResetRequired: true,
+		Identities: []auth.Identity{{Issuer: "https://sso.example.com", Subject: "1234"}, {Issuer: "https://other.example.com", Subject: "ab|cd;ef%7C"}},
+	}
+	users := auth.UserDatabase{
+		UsersByEmail:    map[string]*auth.User{user.Email: &user, verified.Email: &verified, twoFactor.Email: &twoFactor, admin.Email: &admin},
+		UsersByUsername: map[string]*auth.User{user.Username: &user, verified.Username: &verified, twoFactor.Username: &twoFactor, admin.Username: &admin},
+	}
+
+	err := SaveUsers(path, users)
+	if err != nil {
+		t.Fatalf("expected no error saving, got %q", err)
+	}
+	loaded, err := LoadUsers(path)
+	if err != nil {
+		t.Fatalf("expected no error loading, got %q", err)
+	}
+
+	if !reflect.DeepEqual(loaded, users) {
+		t.Errorf("got %v, expected %v", loaded, users)
+	}
+}
+
+func TestTasksRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.csv")
+	userId := uuid.New()
+	userTasks := map[uuid.UUID]tasks.TaskList{userId: {
+		1: &tasks.Task{Id: 1, Name: "a", Description: "b", Date: "31-03-2024", TaskStatus: "pending", Estimate: 90 * time.Minute, Tags: []string{"docs", "qa"}, Priority: "high"},
+		2: &tasks.Task{Id: 2, Name: "c", Description: "", Date: "01-04-2024", TaskStatus: "complete"},
+	}}
+
+	err := SaveTasks(path, userTasks)
+	if err != nil {
+		t.Fatalf("expected no error saving, got %q", err)
+	}
+	loaded, err := LoadTasks(path)
+	if err != nil {
+		t.Fatalf("expected no error loading, got %q", err)
+	}
+
+	if !reflect.DeepEqual(loaded, userTasks) {
+		t.Errorf("got %v, expected %v", loaded[userId], userTasks[userId])
+	}
+}
+
+func TestAttachmentsRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "attachments.csv")
+	blobStore, _ := blobs.NewStore(filepath.Join(dir, "blobs"), blobs.DefaultMaxSize)
+	hash, size, _ := blobStore.Put(strings.NewReader("notes"))
+	userId := uuid.New()
+	attachments := []tasks.Attachment{{Name: "notes.txt", Hash: hash, Size: size}}
+	userTasks := map[uuid.UUID]tasks.TaskList{userId: {1: &tasks.Task{Id: 1, Name: "a", Attachments: attachments}}}
+
+	err := SaveAttachments(path, userTasks)
+	if err != nil {
+		t.Fatalf("expected no error saving, got %q", err)
+	}
+	reloaded, _ := blobs.NewStore(filepath.Join(dir, "blobs"), blobs.DefaultMaxSize)
+	loaded := map[uuid.UUID]tasks.TaskList{userId: {1: &tasks.Task{Id: 1, Name: "a"}}}
+	err = LoadAttachments(path, loaded, reloaded)
+	if err != nil {
+		t.Fatalf("expected no error loading, got %q", err)
+	}
+
+	if !reflect.DeepEqual(loaded[userId][1].Attachments, attachments) || reloaded.Refs(hash) != 1 {
+		t.Errorf("got %v with %d references, expected %v", loaded[userId][1].Attachments, reloaded.Refs(hash), attachments)
+	}
+}
+
+func TestTimeEntriesRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "time_entries.csv")
+	start := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	entries := []tasks.TimeEntry{{Start: start, End: start.Add(time.Hour)}, {Start: start.Add(2 * time.Hour)}}
+	userId := uuid.New()
+	userTasks := map[uuid.UUID]tasks.TaskList{userId: {1: &tasks.Task{Id: 1, Name: "a", TimeEntries: entries}}}
+
+	err := SaveTimeEntries(path, userTasks)
+	if err != nil {
+		t.Fatalf("expected no error saving, got %q", err)
+	}
+	loaded := map[uuid.UUID]tasks.TaskList{userId: {1: &tasks.Task{Id: 1, Name: "a"}}}
+	err = LoadTimeEntries(path, loaded)
+	if err != nil {
+		t.Fatalf("expected no error loading, got %q", err)
+	}
+
+	if !reflect.DeepEqual(loaded[userId][1].TimeEntries, entries) {
+		t.Errorf("got %v, expected %v", loaded[userId][1].TimeEntries, entries)
+	}
+}
+
+func TestLoadAttachmentsAndTimeEntries(t *testing.T) {
+	tests := []struct {
+		name           string
+		load           func(path string, userTasks map[uuid.UUID]tasks.TaskList) error
+		input          string
+		expected_error error
+	}{
+		{name: "attachment of a deleted task", load: loadAttachments, input: "147537d4-69cb-4508-9880-af0168d55f29,2,notes.txt," + strings.Repeat("a", 64) + ",5\n", expected_error: nil},
+		{name: "attachment with missing columns", load: loadAttachments, input: "147537d4-69cb-4508-9880-af0168d55f29,1,notes.txt\n", expected_error: InvalidRecordErr},
+		{name: "attachment of an invalid user id", load: loadAttachments, input: "me,1,notes.txt," + strings.Repeat("a", 64) + ",5\n", expected_error: InvalidRecordErr},
+		{name: "time entry with missing columns", load: LoadTimeEntries, input: "147537d4-69cb-4508-9880-af0168d55f29,1\n", expected_error: InvalidRecordErr},
+		{name: "time entry with an invalid start", load: LoadTimeEntries, input: "147537d4-69cb-4508-9880-af0168d55f29,1,yesterday,\n", expected_error: InvalidRecordErr},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "rows.csv")
+			os.WriteFile(path, []byte(test.input), 0644)
+			userId := uuid.MustParse("147537d4-69cb-4508-9880-af0168d55f29")
+			userTasks := map[uuid.UUID]tasks.TaskList{userId: {1: &tasks.Task{Id: 1, Name: "a"}}}
+
+			err := test.load(path, userTasks)
+
+			if err != test.expected_error {
+				t.Errorf("unexpected error, got %q, expected %q", err, test.expected_error)
+			}
+		})
+	}
+}
+
+func TestSessionsRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.csv")
+	user := auth.User{Id: uuid.New(), Email: "mail@gmail.com", Username: "tester"}
+	users := auth.UserDatabase{
+		UsersByEmail:    map[string]*auth.User{user.Email: &user},
+		UsersByUsername: map[string]*auth.User{user.Username: &user},
+	}
+	sessions := auth.NewSessions(time.Hour, 24*time.Hour)
+	token, _, _ := sessions.Create(user)
+
+	err := SaveSessions(path, sessions)
+	if err != nil {
+		t.Fatalf("expected no error saving, got %q", err)
+	}
+	loaded := auth.NewSessions(time.Hour, 24*time.Hour)
+	err = LoadSessions(path, loaded)
+	if err != nil {
+		t.Fatalf("expected no error loading, got %q", err)
+	}
+
+	got, err := loaded.Lookup(users, token)
+	if err != nil || got.Id != user.Id {
+		t.Errorf("expected the saved session to still be valid, got %v and %q", got, err)
+	}
+	content, _ := os.ReadFile(path)
+	if strings.Contains(string(content), token) {
+		t.Error("expected the token itself not to be saved")
+	}
+}
+
+func TestAPIKeysRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "apikeys.csv")
+	user := auth.User{Id: uuid.New(), Email: "mail@gmail.com", Username: "tester"}
+	users := auth.UserDatabase{
+		UsersByEmail:    map[string]*auth.User{user.Email: &user},
+		UsersByUsername: map[string]*auth.User{user.Username: &user},
+	}
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	keys := auth.NewAPIKeys()
+	keys.Now = func() time.Time { return now }
+	token, _, _ := keys.Create(user, "cron job", []string{auth.ScopeTasksRead}, now.Add(time.Hour))
+	keys.Create(user, "forever", auth.Scopes, time.Time{})
+
+	err := SaveAPIKeys(path, keys)
+	if err != nil {
+		t.Fatalf("expected no error saving, got %q", err)
+	}
+	loaded := auth.NewAPIKeys()
+	loaded.Now = keys.Now
+	err = LoadAPIKeys(path, loaded)
+	if err != nil {
+		t.Fatalf("expected no error loading, got %q", err)
+	}
+
+	if !reflect.DeepEqual(loaded.All(), keys.All()) {
+		t.Errorf("got %v, expected %v", loaded.All(), keys.All())
+	}
+	_, _, err = loaded.Verify(users, token, auth.ScopeTasksRead)
+	if err != nil {
+		t.Errorf("expected the saved key to still be valid, got %q", err)
+	}
+	content, _ := os.ReadFile(path)
+	if strings.Contains(string(content), token) {
+		t.Error("expected the key itself not to be saved")
+	}
+}
+
+func TestVerificationsRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "verifications.csv")
+	user := auth.User{Id: uuid.New(), Email: "mail@gmail.com", Username: "tester"}
+	users := auth.UserDatabase{
+		UsersByEmail:    map[string]*auth.User{user.Email: &user},
+		UsersByUsername: map[string]*auth.User{user.Username: &user},
+	}
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	verifications := auth.NewEmailVerifications(time.Hour)
+	verifications.Now = func() time.Time { return now }
+	verifications.Send(users, &mailer.Memory{}, user.Username)
+
+	err := SaveVerifications(path, verifications)
+	if err != nil {
+		t.Fatalf("expected no error saving, got %q", err)
+	}
+	loaded := auth.NewEmailVerifications(time.Hour)
+	loaded.Now = verifications.Now
+	err = LoadVerifications(path, loaded)
+	if err != nil {
+		t.Fatalf("expected no error loading, got %q", err)
+	}
+
+	if !reflect.DeepEqual(loaded.List(), verifications.List()) {
+		t.Errorf("got %v, expected %v", loaded.List(), verifications.List())
+	}
+}
+
+func TestLoadUsers(t *testing.T) {
+	tests := []struct {
+		name           string
+		input          string
+		expected_error error
+	}{
+		{name: "file from before verification", input: "147537d4-69cb-4508-9880-af0168d55f29,mail@gmail.com,tester,hash\n", expected_error: nil},
+		{name: "invalid verified column", input: "147537d4-69cb-4508-9880-af0168d55f29,mail@gmail.com,tester,hash,maybe\n", expected_error: InvalidRecordErr},
+		{name: "file from before two-factor", input: "147537d4-69cb-4508-9880-af0168d55f29,mail@gmail.com,tester,hash,true\n", expected_error: nil},
+		{name: "invalid two-factor step", input: "147537d4-69cb-4508-9880-af0168d55f29,mail@gmail.com,tester,hash,true,JBSWY3DPEHPK3PXP,soon,\n", expected_error: InvalidRecordErr},
+		{name: "file from before roles", input: "147537d4-69cb-4508-9880-af0168d55f29,mail@gmail.com,tester,hash,true,,0,\n", expected_error: nil},
+		{name: "unknown role", input: "147537d4-69cb-4508-9880-af0168d55f29,mail@gmail.com,tester,hash,true,,0,,owner,false,false\n", expected_error: InvalidRecordErr},
+		{name: "invalid disabled column", input: "147537d4-69cb-4508-9880-af0168d55f29,mail@gmail.com,tester,hash,true,,0,,admin,no,false\n", expected_error: InvalidRecordErr},
+		{name: "file from before single sign-on", input: "147537d4-69cb-4508-9880-af0168d55f29,mail@gmail.com,tester,hash,true,,0,,admin,false,false\n", expected_error: nil},
+		{name: "invalid identity", input: "147537d4-69cb-4508-9880-af0168d55f29,mail@gmail.com,tester,hash,true,,0,,admin,false,false,https://sso.example.com\n", expected_error: InvalidRecordErr},
+		{name: "missing columns", input: "147537d4-69cb-4508-9880-af0168d55f29,mail@gmail.com\n", expected_error: InvalidRecordErr},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "users.csv")
+			os.WriteFile(path, []byte(test.input), 0644)
+
+			_, err := LoadUsers(path)
+
+			if err != test.expected_error {
+				t.Errorf("unexpected error, got %q, expected %q", err, test.expected_error)
+			}
+		})
+	}
+}
+
+func TestLoadUsersCanonical(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.csv")
+	os.WriteFile(path, []byte("147537d4-69cb-4508-9880-af0168d55f29,Test@Mail.COM,TestUser,hash\n"), 0644)
+
+	users, err := LoadUsers(path)
+
+	if err != nil {
+		t.Fatalf("expected no error, got %q", err)
+	}
+	user, found := users.UsersByEmail["test@mail.com"]
+	if !found || users.UsersByUsername["testuser"] != user {
+		t.Fatalf("expected the user under its canonical email and username, got %v", users)
+	}
+	if user.Email != "Test@Mail.COM" || user.Username != "TestUser" {
+		t.Errorf("expected the display form to be kept, got %v", user)
+	}
+}
+
+func TestLoadUsersCollisions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.csv")
+	input := "147537d4-69cb-4508-9880-af0168d55f29,test@mail.com,tester,hash\n" +
+		"2584aadf-e6a9-474b-9c8b-6b2d66d9d50b,Test@Mail.com,other,hash\n" +
+		"262a7d71-1978-47af-9c81-afa13d65c00e,third@mail.com,Tester,hash\n" +
+		"dfa2a655-94a2-467d-a7ea-2d2f6caccdc7,fourth@mail.com,fourth,hash\n"
+	os.WriteFile(path, []byte(input), 0644)
+
+	users, err := LoadUsers(path)
+
+	collisions, ok := err.(CollisionErr)
+	if !ok || len(collisions) != 2 {
+		t.Fatalf("expected two collisions, got %q", err)
+	}
+	expected := []struct {
+		field, canonical string
+		ids              []string
+	}{
+		{field: "email", canonical: "test@mail.com", ids: []string{"147537d4-69cb-4508-9880-af0168d55f29", "2584aadf-e6a9-474b-9c8b-6b2d66d9d50b"}},
+		{field: "username", canonical: "tester", ids: []string{"147537d4-69cb-4508-9880-af0168d55f29", "262a7d71-1978-47af-9c81-afa13d65c00e"}},
+	}
+	for i, collision := range collisions {
+		if collision.Field != expected[i].field || collision.Canonical != expected[i].canonical || len(collision.Users) != 2 ||
+			collision.Users[0].Id.String() != expected[i].ids[0] || collision.Users[1].Id.String() != expected[i].ids[1] {
+			t.Errorf("got %v, expected %v", collision, expected[i])
+		}
+	}
+	if users.UsersByEmail["test@mail.com"].Username != "tester" {
+		t.Errorf("expected the first account to keep its email, got %v", users.UsersByEmail["test@mail.com"])
+	}
+}
+
+func TestLoadTasks(t *testing.T) {
+	tests := []struct {
+		name           string
+		input          string
+		expected_error error
+	}{
+		{name: "file from before estimates", input: "147537d4-69cb-4508-9880-af0168d55f29,1,test,test,09-09-2009,pending\n", expected_error: nil},
+		{name: "invalid user id", input: "me,1,test,test,09-09-2009,pending\n", expected_error: InvalidRecordErr},
+		{name: "invalid task number", input: "147537d4-69cb-4508-9880-af0168d55f29,one,test,test,09-09-2009,pending\n", expected_error: InvalidRecordErr},
+		{name: "missing columns", input: "147537d4-69cb-4508-9880-af0168d55f29,1,test\n", expected_error: InvalidRecordErr},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "tasks.csv")
+			os.WriteFile(path, []byte(test.input), 0644)
+
+			_, err := LoadTasks(path)
+
+			if err != test.expected_error {
+				t.Errorf("unexpected error, got %q, expected %q", err, test.expected_error)
+			}
+		})
+	}
+
+	t.Run("missing file", func(t *testing.T) {
+		userTasks, err := LoadTasks(filepath.Join(t.TempDir(), "tasks.csv"))
+
+		if err != nil || len(userTasks) != 0 {
+			t.Errorf("expected an empty database, got %v and %q", userTasks, err)
+		}
+	})
+}
+
+// loadAttachments is LoadAttachments with a store of its own.
+func loadAttachments(path string, userTasks map[uuid.UUID]tasks.TaskList) error {
+	blobStore, err := blobs.NewStore(filepath.Join(filepath.Dir(path), "blobs"), blobs.DefaultMaxSize)
+	if err != nil {
+		return err
+	}
+	return LoadAttachments(path, userTasks, blobStore)
+}
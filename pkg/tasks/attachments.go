@@ -0,0 +1,88 @@
+package tasks
+
+import (
+	"io"
+	"strings"
+)
+
+const (
+	AttachmentNotFoundErr = TaskError("Attachment not found")
+	AttachmentExistsErr   = TaskError("Task already has an attachment with that name")
+	AttachmentNameErr     = TaskError("Attachment cannot have an empty name")
+	NoAttachmentStoreErr  = TaskError("Attachments are not available")
+)
+
+type Attachment struct {
+	Name string
+	Hash string
+	Size int64
+}
+
+// BlobStore is where attachment contents live; tasks only keep the hash.
+type BlobStore interface {
+	Put(content io.Reader) (hash string, size int64, err error)
+	Release(hash string) error
+}
+
+var blobStore BlobStore
+
+// UseBlobStore sets the store attachments are written to and released from
+// when a task is deleted.
+func UseBlobStore(store BlobStore) {
+	blobStore = store
+}
+
+func (tasks TaskList) Attach(id int, name string, content io.Reader) (Attachment, error) {
+	if blobStore == nil {
+		return Attachment{}, NoAttachmentStoreErr
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return Attachment{}, AttachmentNameErr
+	}
+	task, err := tasks.GetTask(id)
+	if err != nil {
+		return Attachment{}, err
+	}
+	_, err = task.GetAttachment(name)
+	if err == nil {
+		return Attachment{}, AttachmentExistsErr
+	}
+	hash, size, err := blobStore.Put(content)
+	if err != nil {
+		return Attachment{}, err
+	}
+	attachment := Attachment{Name: name, Hash: hash, Size: size}
+	task.Attachments = append(task.Attachments, attachment)
+	return attachment, nil
+}
+
+func (tasks TaskList) Detach(id int, name string) error {
+	task, err := tasks.GetTask(id)
+	if err != nil {
+		return err
+	}
+	for i, attachment := range task.Attachments {
+		if attachment.Name == name {
+			task.Attachments = append(task.Attachments[:i], task.Attachments[i+1:]...)
+			return releaseBlob(attachment.Hash)
+		}
+	}
+	return AttachmentNotFoundErr
+}
+
+func (t Task) GetAttachment(name string) (Attachment, error) {
+	for _, attachment := range t.Attachments {
+		if attachment.Name == name {
+			return attachment, nil
+		}
+	}
+	return Attachment{}, AttachmentNotFoundErr
+}
+
+func releaseBlob(hash string) error {
+	if blobStore == nil {
+		return nil
+	}
+	return blobStore.Release(hash)
+}
@@ -0,0 +1,143 @@
+package tasks
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestAttach(t *testing.T) {
+	store := useFakeBlobStore(t)
+	sampleTask := Task{Id: 1, Name: "Fix crash", Description: "see log", Date: "31-05-2024", TaskStatus: "pending"}
+	taskList := TaskList{sampleTask.Id: &sampleTask}
+	tests := []struct {
+		name           string
+		task_id        int
+		file_name      string
+		expected_error error
+	}{
+		{name: "new attachment", task_id: 1, file_name: "crash.log", expected_error: nil},
+		{name: "duplicate name", task_id: 1, file_name: "crash.log", expected_error: AttachmentExistsErr},
+		{name: "empty name", task_id: 1, file_name: " ", expected_error: AttachmentNameErr},
+		{name: "nonexisting task", task_id: 2, file_name: "other.log", expected_error: TaskNotFoundErr},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			attachment, err := taskList.Attach(test.task_id, test.file_name, strings.NewReader("boom"))
+
+			if err != test.expected_error {
+				t.Fatalf("unexpected error, got %q, expected %q", err, test.expected_error)
+			}
+			if err == nil {
+				stored, _ := sampleTask.GetAttachment(test.file_name)
+				if stored != attachment || attachment.Size != 4 {
+					t.Errorf("attachment not stored accurately, got %v", stored)
+				}
+			}
+		})
+	}
+
+	if store.refs["boom"] != 1 {
+		t.Errorf("expected one reference to the blob, got %d", store.refs["boom"])
+	}
+}
+
+func TestAttachWithoutStore(t *testing.T) {
+	UseBlobStore(nil)
+	taskList := TaskList{1: &Task{Id: 1, Name: "a"}}
+
+	_, err := taskList.Attach(1, "file", strings.NewReader("data"))
+
+	if err != NoAttachmentStoreErr {
+		t.Errorf("unexpected error, got %q, expected %q", err, NoAttachmentStoreErr)
+	}
+}
+
+func TestDetach(t *testing.T) {
+	store := useFakeBlobStore(t)
+	taskList := TaskList{1: &Task{Id: 1, Name: "a"}}
+	taskList.Attach(1, "screenshot.png", strings.NewReader("png"))
+
+	t.Run("existing attachment", func(t *testing.T) {
+		err := taskList.Detach(1, "screenshot.png")
+
+		if err != nil {
+			t.Fatalf("expected no error, got %q", err)
+		}
+		if len(taskList[1].Attachments) != 0 || store.refs["png"] != 0 {
+			t.Error("attachment was not released")
+		}
+	})
+
+	t.Run("nonexisting attachment", func(t *testing.T) {
+		err := taskList.Detach(1, "screenshot.png")
+
+		if err != AttachmentNotFoundErr {
+			t.Errorf("unexpected error, got %q, expected %q", err, AttachmentNotFoundErr)
+		}
+	})
+}
+
+func TestDeleteTaskReleasesAttachments(t *testing.T) {
+	store := useFakeBlobStore(t)
+	taskList := TaskList{1: &Task{Id: 1, Name: "a"}, 2: &Task{Id: 2, Name: "b"}}
+	taskList.Attach(1, "shared.log", strings.NewReader("shared"))
+	taskList.Attach(2, "shared.log", strings.NewReader("shared"))
+
+	err := taskList.DeleteTask(1)
+
+	if err != nil {
+		t.Fatalf("expected no error, got %q", err)
+	}
+	if store.refs["shared"] != 1 {
+		t.Errorf("expected blob to still be referenced once, got %d", store.refs["shared"])
+	}
+}
+
+func TestDeleteTaskReleaseFails(t *testing.T) {
+	store := useFakeBlobStore(t)
+	taskList := TaskList{1: &Task{Id: 1, Name: "a"}}
+	taskList.Attach(1, "first.log", strings.NewReader("first"))
+	taskList.Attach(1, "second.log", strings.NewReader("second"))
+	store.releaseErr = os.ErrPermission
+
+	err := taskList.DeleteTask(1)
+
+	if err != os.ErrPermission {
+		t.Fatalf("got %q, expected the release error", err)
+	}
+	if store.refs["first"] != 0 || store.refs["second"] != 0 {
+		t.Errorf("expected every blob to be released, got %v", store.refs)
+	}
+}
+
+//helpers
+
+type fakeBlobStore struct {
+	refs       map[string]int
+	releaseErr error
+}
+
+func (store *fakeBlobStore) Put(content io.Reader) (string, int64, error) {
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return "", 0, err
+	}
+	store.refs[string(data)]++
+	return string(data), int64(len(data)), nil
+}
+
+func (store *fakeBlobStore) Release(hash string) error {
+	store.refs[hash]--
+	return store.releaseErr
+}
+
+func useFakeBlobStore(t testing.TB) *fakeBlobStore {
+	t.Helper()
+	store := &fakeBlobStore{refs: make(map[string]int)}
+	UseBlobStore(store)
+	t.Cleanup(func() { UseBlobStore(nil) })
+	return store
+}
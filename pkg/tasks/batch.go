@@ -0,0 +1,202 @@
+package tasks
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	InvalidSelectionErr = TaskError("Selection must be task numbers and ranges like 3-7,9 or a filter like status:pending,tag:release")
+	EmptySelectionErr   = TaskError("No tasks match the selection")
+	BatchFailedErr      = TaskError("No changes were made because some of the tasks could not be updated")
+	maxRangeSize        = 1000
+)
+
+// BatchResult tells what happened to one task of a batch, Err is nil when the
+// task was updated.
+type BatchResult struct {
+	TaskId int
+	Err    error
+}
+
+// Select turns a selection into the task numbers it covers. A selection is
+// either numbers and ranges ("3-7,9") or a filter ("status:pending,tag:qa").
+// Ranges may include numbers with no task, batches report those as not found.
+func (tasks TaskList) Select(selection string) ([]int, error) {
+	selection = strings.TrimSpace(selection)
+	if selection == "" {
+		return nil, InvalidSelectionErr
+	}
+	var ids []int
+	var err error
+	if strings.Contains(selection, ":") {
+		ids, err = tasks.filter(selection)
+	} else {
+		ids, err = parseRanges(selection)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, EmptySelectionErr
+	}
+	return ids, nil
+}
+
+func parseRanges(selection string) ([]int, error) {
+	seen := make(map[int]bool)
+	var ids []int
+	for _, part := range strings.Split(selection, ",") {
+		bounds := strings.SplitN(strings.TrimSpace(part), "-", 2)
+		first, err := strconv.Atoi(strings.TrimSpace(bounds[0]))
+		if err != nil {
+			return nil, InvalidSelectionErr
+		}
+		last := first
+		if len(bounds) == 2 {
+			last, err = strconv.Atoi(strings.TrimSpace(bounds[1]))
+			if err != nil {
+				return nil, InvalidSelectionErr
+			}
+		}
+		if first < 1 || last < first || last-first >= maxRangeSize {
+			return nil, InvalidSelectionErr
+		}
+		for id := first; id <= last; id++ {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
+
+func (tasks TaskList) filter(selection string) ([]int, error) {
+	criteria := make(map[string]string)
+	for _, part := range strings.Split(selection, ",") {
+		key, value, found := strings.Cut(part, ":")
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.ToLower(strings.TrimSpace(value))
+		if !found || value == "" {
+			return nil, InvalidSelectionErr
+		}
+		switch key {
+		case "status", "tag", "priority", "name":
+			criteria[key] = value
+		default:
+			return nil, InvalidSelectionErr
+		}
+	}
+
+	var ids []int
+	for id, task := range tasks {
+		if status, found := criteria["status"]; found && strings.ToLower(task.TaskStatus) != status {
+			continue
+		}
+		if tag, found := criteria["tag"]; found && !task.HasTag(tag) {
+			continue
+		}
+		if priority, found := criteria["priority"]; found && task.Priority != priority {
+			continue
+		}
+		if name, found := criteria["name"]; found && !strings.Contains(strings.ToLower(task.Name), name) {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
+
+// batch works out the new value of every selected task with update before
+// changing any of them, so the batch is applied to all of the tasks or none.
+func (tasks TaskList) batch(ids []int, update func(task Task) (Task, error)) ([]BatchResult, error) {
+	results := make([]BatchResult, len(ids))
+	updated := make([]Task, len(ids))
+	failed := false
+	for i, id := range ids {
+		results[i].TaskId = id
+		task, err := tasks.GetTask(id)
+		if err == nil {
+			updated[i], err = update(*task)
+		}
+		if err != nil {
+			results[i].Err = err
+			failed = true
+		}
+	}
+	if failed {
+		return results, BatchFailedErr
+	}
+	for i, id := range ids {
+		*tasks[id] = updated[i]
+	}
+	return results, nil
+}
+
+func (tasks TaskList) BatchComplete(ids []int) ([]BatchResult, error) {
+	return tasks.batch(ids, func(task Task) (Task, error) {
+		task.TaskStatus = "complete"
+		return task, nil
+	})
+}
+
+func (tasks TaskList) BatchDelete(ids []int) ([]BatchResult, error) {
+	results, err := tasks.batch(ids, func(task Task) (Task, error) {
+		return task, nil
+	})
+	if err != nil {
+		return results, err
+	}
+	for i, id := range ids {
+		results[i].Err = tasks.DeleteTask(id)
+	}
+	return results, nil
+}
+
+// BatchRetag adds and removes tags on every selected task.
+func (tasks TaskList) BatchRetag(ids []int, add, remove []string) ([]BatchResult, error) {
+	removed := NormalizeTags(remove)
+	return tasks.batch(ids, func(task Task) (Task, error) {
+		var kept []string
+		for _, tag := range task.Tags {
+			drop := false
+			for _, removedTag := range removed {
+				drop = drop || tag == removedTag
+			}
+			if !drop {
+				kept = append(kept, tag)
+			}
+		}
+		task.Tags = NormalizeTags(append(kept, add...))
+		return task, nil
+	})
+}
+
+// BatchReschedule moves the date of every selected task by days, which can be
+// negative to bring them forward.
+func (tasks TaskList) BatchReschedule(ids []int, days int) ([]BatchResult, error) {
+	return tasks.batch(ids, func(task Task) (Task, error) {
+		date, err := time.Parse(DateLayout, strings.TrimSpace(task.Date))
+		if err != nil {
+			return Task{}, TaskDateErr
+		}
+		task.Date = date.AddDate(0, 0, days).Format(DateLayout)
+		return task, nil
+	})
+}
+
+func (tasks TaskList) BatchSetPriority(ids []int, priority string) ([]BatchResult, error) {
+	priority, err := ParsePriority(priority)
+	if err != nil {
+		return nil, err
+	}
+	return tasks.batch(ids, func(task Task) (Task, error) {
+		task.Priority = priority
+		return task, nil
+	})
+}
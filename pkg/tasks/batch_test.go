@@ -0,0 +1,151 @@
+package tasks
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSelect(t *testing.T) {
+	taskList := TaskList{
+		1: &Task{Id: 1, Name: "Write docs", TaskStatus: "pending", Tags: []string{"docs"}},
+		2: &Task{Id: 2, Name: "Fix bug", TaskStatus: "complete", Priority: "high"},
+		3: &Task{Id: 3, Name: "Release docs", TaskStatus: "pending", Tags: []string{"docs", "release"}, Priority: "high"},
+	}
+	tests := []struct {
+		name           string
+		input          string
+		expected_ids   []int
+		expected_error error
+	}{
+		{name: "single number", input: "2", expected_ids: []int{2}, expected_error: nil},
+		{name: "ranges and numbers", input: "3-5, 1,4", expected_ids: []int{1, 3, 4, 5}, expected_error: nil},
+		{name: "status filter", input: "status:pending", expected_ids: []int{1, 3}, expected_error: nil},
+		{name: "combined filter", input: "tag:docs,priority:HIGH", expected_ids: []int{3}, expected_error: nil},
+		{name: "name filter", input: "name:fix", expected_ids: []int{2}, expected_error: nil},
+		{name: "filter without matches", input: "tag:qa", expected_ids: nil, expected_error: EmptySelectionErr},
+		{name: "unknown filter", input: "owner:me", expected_ids: nil, expected_error: InvalidSelectionErr},
+		{name: "backwards range", input: "7-3", expected_ids: nil, expected_error: InvalidSelectionErr},
+		{name: "not a number", input: "three", expected_ids: nil, expected_error: InvalidSelectionErr},
+		{name: "huge range", input: "1-1000000", expected_ids: nil, expected_error: InvalidSelectionErr},
+		{name: "empty", input: " ", expected_ids: nil, expected_error: InvalidSelectionErr},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ids, err := taskList.Select(test.input)
+
+			if err != test.expected_error {
+				t.Fatalf("unexpected error, got %q, expected %q", err, test.expected_error)
+			}
+			if !reflect.DeepEqual(ids, test.expected_ids) {
+				t.Errorf("got %v, expected %v", ids, test.expected_ids)
+			}
+		})
+	}
+}
+
+func TestBatchIsAtomic(t *testing.T) {
+	taskList := TaskList{
+		1: &Task{Id: 1, Name: "a", Date: "01-01-2024", TaskStatus: "pending"},
+		2: &Task{Id: 2, Name: "b", Date: "not a date", TaskStatus: "pending"},
+	}
+
+	results, err := taskList.BatchReschedule([]int{1, 2, 3}, 1)
+
+	if err != BatchFailedErr {
+		t.Fatalf("unexpected error, got %q, expected %q", err, BatchFailedErr)
+	}
+	expected := []BatchResult{{TaskId: 1}, {TaskId: 2, Err: TaskDateErr}, {TaskId: 3, Err: TaskNotFoundErr}}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("got %v, expected %v", results, expected)
+	}
+	if taskList[1].Date != "01-01-2024" {
+		t.Errorf("expected task 1 to be left alone, its date is %q", taskList[1].Date)
+	}
+}
+
+func TestBatchOperations(t *testing.T) {
+	newTaskList := func() TaskList {
+		return TaskList{
+			1: &Task{Id: 1, Name: "a", Date: "31-01-2024", TaskStatus: "pending", Tags: []string{"docs", "qa"}},
+			2: &Task{Id: 2, Name: "b", Date: "28-02-2024", TaskStatus: "pending"},
+		}
+	}
+
+	t.Run("complete", func(t *testing.T) {
+		taskList := newTaskList()
+
+		_, err := taskList.BatchComplete([]int{1, 2})
+
+		assertBatchError(t, err, nil)
+		if taskList[1].TaskStatus != "complete" || taskList[2].TaskStatus != "complete" {
+			t.Error("expected every task to be complete")
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		taskList := newTaskList()
+
+		_, err := taskList.BatchDelete([]int{1, 2})
+
+		assertBatchError(t, err, nil)
+		if len(taskList) != 0 {
+			t.Errorf("expected every task to be deleted, %d left", len(taskList))
+		}
+	})
+
+	t.Run("delete with missing task", func(t *testing.T) {
+		taskList := newTaskList()
+
+		_, err := taskList.BatchDelete([]int{1, 5})
+
+		assertBatchError(t, err, BatchFailedErr)
+		if len(taskList) != 2 {
+			t.Errorf("expected no task to be deleted, %d left", len(taskList))
+		}
+	})
+
+	t.Run("retag", func(t *testing.T) {
+		taskList := newTaskList()
+
+		_, err := taskList.BatchRetag([]int{1, 2}, []string{"Release"}, []string{"qa"})
+
+		assertBatchError(t, err, nil)
+		if !reflect.DeepEqual(taskList[1].Tags, []string{"docs", "release"}) || !reflect.DeepEqual(taskList[2].Tags, []string{"release"}) {
+			t.Errorf("got tags %q and %q", taskList[1].Tags, taskList[2].Tags)
+		}
+	})
+
+	t.Run("reschedule", func(t *testing.T) {
+		taskList := newTaskList()
+
+		_, err := taskList.BatchReschedule([]int{1, 2}, 1)
+
+		assertBatchError(t, err, nil)
+		if taskList[1].Date != "01-02-2024" || taskList[2].Date != "29-02-2024" {
+			t.Errorf("got dates %q and %q", taskList[1].Date, taskList[2].Date)
+		}
+	})
+
+	t.Run("priority", func(t *testing.T) {
+		taskList := newTaskList()
+
+		_, err := taskList.BatchSetPriority([]int{1, 2}, "High")
+
+		assertBatchError(t, err, nil)
+		if taskList[1].Priority != "high" || taskList[2].Priority != "high" {
+			t.Error("expected every task to be high priority")
+		}
+		_, err = taskList.BatchSetPriority([]int{1}, "whenever")
+		assertBatchError(t, err, InvalidPriorityErr)
+	})
+}
+
+//helpers
+
+func assertBatchError(t testing.TB, actual_error, expected_error error) {
+	t.Helper()
+	if actual_error != expected_error {
+		t.Fatalf("unexpected error, got %q, expected %q", actual_error, expected_error)
+	}
+}
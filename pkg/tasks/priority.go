@@ -0,0 +1,30 @@
+package tasks
+
+import "strings"
+
+const InvalidPriorityErr = TaskError("Priority must be one of: low, medium, high")
+
+var priorities = []string{"low", "medium", "high"}
+
+func ParsePriority(priority string) (string, error) {
+	priority = strings.ToLower(strings.TrimSpace(priority))
+	for _, valid := range priorities {
+		if priority == valid {
+			return priority, nil
+		}
+	}
+	return "", InvalidPriorityErr
+}
+
+func (tasks TaskList) SetPriority(id int, priority string) error {
+	task, err := tasks.GetTask(id)
+	if err != nil {
+		return err
+	}
+	priority, err = ParsePriority(priority)
+	if err != nil {
+		return err
+	}
+	task.Priority = priority
+	return nil
+}
@@ -0,0 +1,32 @@
+package tasks
+
+import "testing"
+
+func TestSetPriority(t *testing.T) {
+	taskList := TaskList{1: &Task{Id: 1, Name: "a"}}
+	tests := []struct {
+		name              string
+		task_id           int
+		input             string
+		expected_error    error
+		expected_priority string
+	}{
+		{name: "valid priority", task_id: 1, input: "high", expected_error: nil, expected_priority: "high"},
+		{name: "mixed case", task_id: 1, input: " Low ", expected_error: nil, expected_priority: "low"},
+		{name: "invalid priority", task_id: 1, input: "urgent", expected_error: InvalidPriorityErr, expected_priority: "low"},
+		{name: "nonexisting task", task_id: 2, input: "high", expected_error: TaskNotFoundErr, expected_priority: "low"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := taskList.SetPriority(test.task_id, test.input)
+
+			if err != test.expected_error {
+				t.Fatalf("unexpected error, got %q, expected %q", err, test.expected_error)
+			}
+			if taskList[1].Priority != test.expected_priority {
+				t.Errorf("got priority %q, expected %q", taskList[1].Priority, test.expected_priority)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package tasks
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+const InvalidSortErr = TaskError("Sort order must be one of: id, date, priority, name")
+
+var SortOrders = []string{"id", "date", "priority", "name"}
+
+func ParseSortOrder(order string) (string, error) {
+	order = strings.ToLower(strings.TrimSpace(order))
+	for _, valid := range SortOrders {
+		if order == valid {
+			return order, nil
+		}
+	}
+	return "", InvalidSortErr
+}
+
+// Sorted returns the tasks in order: by number, by date soonest first, by
+// priority highest first or by name. Ties are broken by number and an
+// unknown order sorts by number.
+func (tasks TaskList) Sorted(order string) []*Task {
+	sorted := make([]*Task, 0, len(tasks))
+	for _, task := range tasks {
+		sorted = append(sorted, task)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		switch order {
+		case "date":
+			if !dueDate(*a).Equal(dueDate(*b)) {
+				return dueDate(*a).Before(dueDate(*b))
+			}
+		case "priority":
+			if priorityRank(a.Priority) != priorityRank(b.Priority) {
+				return priorityRank(a.Priority) > priorityRank(b.Priority)
+			}
+		case "name":
+			nameA, nameB := strings.ToLower(strings.TrimSpace(a.Name)), strings.ToLower(strings.TrimSpace(b.Name))
+			if nameA != nameB {
+				return nameA < nameB
+			}
+		}
+		return a.Id < b.Id
+	})
+	return sorted
+}
+
+// dueDate is when task is due, tasks with a date that cannot be read go last.
+func dueDate(task Task) time.Time {
+	date, err := time.Parse(DateLayout, task.Date)
+	if err != nil {
+		return time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+	}
+	return date
+}
+
+// priorityRank is 0 for tasks without a priority and grows with it.
+func priorityRank(priority string) int {
+	for i, valid := range priorities {
+		if priority == valid {
+			return i + 1
+		}
+	}
+	return 0
+}
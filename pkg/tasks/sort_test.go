@@ -0,0 +1,65 @@
+package tasks
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSorted(t *testing.T) {
+	taskList := TaskList{
+		1: &Task{Id: 1, Name: "write report", Date: "03-01-2030", Priority: "low"},
+		2: &Task{Id: 2, Name: "Buy milk", Date: "01-02-2030"},
+		3: &Task{Id: 3, Name: "call mum", Date: "01-01-2030", Priority: "high"},
+		4: &Task{Id: 4, Name: "Answer mail", Date: "03-01-2030", Priority: "high"},
+	}
+	tests := []struct {
+		name     string
+		order    string
+		expected []int
+	}{
+		{name: "by number", order: "id", expected: []int{1, 2, 3, 4}},
+		{name: "by date", order: "date", expected: []int{3, 1, 4, 2}},
+		{name: "by priority", order: "priority", expected: []int{3, 4, 1, 2}},
+		{name: "by name", order: "name", expected: []int{4, 2, 3, 1}},
+		{name: "unknown order", order: "colour", expected: []int{1, 2, 3, 4}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got []int
+			for _, task := range taskList.Sorted(test.order) {
+				got = append(got, task.Id)
+			}
+
+			if !reflect.DeepEqual(got, test.expected) {
+				t.Errorf("got %v, expected %v", got, test.expected)
+			}
+		})
+	}
+}
+
+func TestParseSortOrder(t *testing.T) {
+	tests := []struct {
+		name           string
+		input          string
+		expected       string
+		expected_error error
+	}{
+		{name: "valid order", input: "date", expected: "date", expected_error: nil},
+		{name: "mixed case", input: " Priority ", expected: "priority", expected_error: nil},
+		{name: "invalid order", input: "colour", expected: "", expected_error: InvalidSortErr},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseSortOrder(test.input)
+
+			if err != test.expected_error {
+				t.Fatalf("unexpected error, got %q, expected %q", err, test.expected_error)
+			}
+			if got != test.expected {
+				t.Errorf("got %q, expected %q", got, test.expected)
+			}
+		})
+	}
+}
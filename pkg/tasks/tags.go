@@ -0,0 +1,52 @@
+package tasks
+
+import (
+	"sort"
+	"strings"
+)
+
+// NormalizeTags lowercases and trims tags, dropping empty and repeated ones.
+func NormalizeTags(tags []string) []string {
+	seen := make(map[string]bool)
+	normalized := []string{}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	sort.Strings(normalized)
+	return normalized
+}
+
+func (tasks TaskList) SetTags(id int, tags []string) error {
+	task, err := tasks.GetTask(id)
+	if err != nil {
+		return err
+	}
+	task.Tags = NormalizeTags(tags)
+	return nil
+}
+
+func (t Task) HasTag(tag string) bool {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	for _, taskTag := range t.Tags {
+		if taskTag == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// SplitTags reads tags written as a comma separated list.
+func SplitTags(tags string) []string {
+	var split []string
+	for _, tag := range strings.Split(tags, ",") {
+		if strings.TrimSpace(tag) != "" {
+			split = append(split, strings.TrimSpace(tag))
+		}
+	}
+	return split
+}
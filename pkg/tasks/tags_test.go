@@ -0,0 +1,45 @@
+package tasks
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    []string
+		expected []string
+	}{
+		{name: "already normalized", input: []string{"release", "backend"}, expected: []string{"backend", "release"}},
+		{name: "mixed case and spaces", input: []string{" Release ", "BACKEND"}, expected: []string{"backend", "release"}},
+		{name: "repeated tags", input: []string{"release", "Release"}, expected: []string{"release"}},
+		{name: "empty tags", input: []string{"", " "}, expected: []string{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := NormalizeTags(test.input)
+
+			if !reflect.DeepEqual(got, test.expected) {
+				t.Errorf("got %q, expected %q", got, test.expected)
+			}
+		})
+	}
+}
+
+func TestSetTags(t *testing.T) {
+	taskList := TaskList{1: &Task{Id: 1, Name: "a"}}
+
+	err := taskList.SetTags(1, []string{"Release", "qa"})
+
+	if err != nil {
+		t.Fatalf("expected no error, got %q", err)
+	}
+	if !taskList[1].HasTag("release") || !taskList[1].HasTag("QA") || taskList[1].HasTag("backend") {
+		t.Errorf("tags not stored accurately, got %q", taskList[1].Tags)
+	}
+	if err := taskList.SetTags(2, nil); err != TaskNotFoundErr {
+		t.Errorf("unexpected error, got %q, expected %q", err, TaskNotFoundErr)
+	}
+}
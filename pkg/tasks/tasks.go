@@ -9,6 +9,10 @@ import (
 	"github.com/google/uuid"
 )
 
+// DateLayout is how tasks keep their dates, whatever layout they are typed
+// and shown in.
+const DateLayout = "02-01-2006"
+
 const (
 	TaskNotFoundErr = TaskError("Task not found")
 	TaskDateErr     = TaskError("Date format is invalid")
@@ -24,6 +28,11 @@ type Task struct {
 	Description string
 	Date        string
 	TaskStatus  string
+	Priority    string
+	Tags        []string
+	Attachments []Attachment
+	Estimate    time.Duration
+	TimeEntries []TimeEntry
 }
 
 type TaskError string
@@ -44,9 +53,9 @@ func (tasks TaskList) GetTask(id int) (*Task, error) {
 
 func (tasks TaskList) AddTask(name, description, date string) (Task, error) {
 	if name != "" {
-		_, err := time.Parse("02-01-2006", date)
+		_, err := time.Parse(DateLayout, date)
 		if err == nil {
-			newId := len(tasks) + 1
+			newId := tasks.nextId()
 			task := Task{Id: newId, Name: name, Description: description, Date: date, TaskStatus: "pending"}
 			tasks[newId] = &task
 			return task, nil
@@ -59,12 +68,31 @@ func (tasks TaskList) AddTask(name, description, date string) (Task, error) {
 	}
 }
 
+// nextId is one more than the highest task number, so numbers freed by
+// deleted tasks are never handed out while a later task still has its own.
+func (tasks TaskList) nextId() int {
+	highest := 0
+	for id := range tasks {
+		highest = max(highest, id)
+	}
+	return highest + 1
+}
+
 func (tasks TaskList) DeleteTask(id int) error {
-	_, err := tasks.GetTask(id)
+	task, err := tasks.GetTask(id)
 	taskFound := err == nil
 	if taskFound {
 		delete(tasks, id)
-		return nil
+		// every blob is released even if one fails, the first error is
+		// returned
+		var releaseErr error
+		for _, attachment := range task.Attachments {
+			err := releaseBlob(attachment.Hash)
+			if err != nil && releaseErr == nil {
+				releaseErr = err
+			}
+		}
+		return releaseErr
 	}
 	return err
 }
@@ -82,7 +110,7 @@ func (tasks TaskList) UpdateField(id int, field, new_value string) (Task, error)
 			task.Description = new_value
 			return *task, nil
 		case "date", "3":
-			_, err := time.Parse("02-01-2006", new_value)
+			_, err := time.Parse(DateLayout, new_value)
 			if err == nil {
 				task.Date = new_value
 				return *task, nil
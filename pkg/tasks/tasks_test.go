@@ -62,6 +62,22 @@ func TestAddTask(t *testing.T) {
 	}
 }
 
+func TestAddTaskAfterDelete(t *testing.T) {
+	taskList := TaskList{}
+	taskList.AddTask("first", "", "01-01-2030")
+	taskList.AddTask("second", "", "01-01-2030")
+	taskList.DeleteTask(1)
+
+	added, err := taskList.AddTask("third", "", "01-01-2030")
+
+	if err != nil {
+		t.Fatalf("unexpected error %q", err)
+	}
+	if added.Id != 3 || taskList[2].Name != "second" {
+		t.Errorf("got task #%d and %v, expected #3 with the second task kept", added.Id, taskList)
+	}
+}
+
 func TestDeleteTask(t *testing.T) {
 	sampleTask := Task{Id: 1, Name: "Make a test function", Description: "Jesse we need to test", Date: "31/05/2024", TaskStatus: "pending"}
 	taskList := TaskList{sampleTask.Id: &sampleTask}
@@ -0,0 +1,171 @@
+package tasks
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	NoFieldsErr       = TaskError("Task must start with its fields between two --- lines")
+	FieldSyntaxErr    = TaskError("Fields are written as name: value")
+	UnknownFieldErr   = TaskError("Field must be one of: name, date, priority, tags, status")
+	DuplicateFieldErr = TaskError("Field is set more than once")
+	InvalidStatusErr  = TaskError("Status must be one of: pending, complete")
+)
+
+// FieldErr is a problem with one line of a task written as text. Line is 0
+// when the field is missing.
+type FieldErr struct {
+	Line  int
+	Field string
+	Err   error
+}
+
+// TextErr is everything wrong with a task written as text, see ParseText.
+type TextErr []FieldErr
+
+func (err TextErr) Error() string {
+	problems := make([]string, len(err))
+	for i, problem := range err {
+		switch {
+		case problem.Line > 0 && problem.Field != "":
+			problems[i] = fmt.Sprintf("line %d, %s: %s", problem.Line, problem.Field, problem.Err)
+		case problem.Line > 0:
+			problems[i] = fmt.Sprintf("line %d: %s", problem.Line, problem.Err)
+		default:
+			problems[i] = fmt.Sprintf("%s: %s", problem.Field, problem.Err)
+		}
+	}
+	return strings.Join(problems, "\n")
+}
+
+// FormatText writes task as text to be edited by hand: its fields between
+// --- lines, then the description. The date is written with dateLayout.
+func FormatText(task Task, dateLayout string) string {
+	date, err := time.Parse(DateLayout, task.Date)
+	if err == nil {
+		task.Date = date.Format(dateLayout)
+	}
+	status := task.TaskStatus
+	if status == "" {
+		status = "pending"
+	}
+	var text strings.Builder
+	text.WriteString("---\n")
+	for _, field := range [][2]string{
+		{"name", strings.TrimSpace(task.Name)},
+		{"date", task.Date},
+		{"priority", task.Priority},
+		{"tags", strings.Join(task.Tags, ", ")},
+		{"status", status},
+	} {
+		text.WriteString(strings.TrimRight(field[0]+": "+field[1], " ") + "\n")
+	}
+	text.WriteString("---\n")
+	text.WriteString(task.Description)
+	if task.Description != "" && !strings.HasSuffix(task.Description, "\n") {
+		text.WriteString("\n")
+	}
+	return text.String()
+}
+
+// ParseText reads a task written like FormatText does, with its date in
+// dateLayout. Lines starting with # before the description are left out,
+// the description is everything after the fields. Every problem found is
+// returned in a TextErr. The task has no number.
+func ParseText(text, dateLayout string) (Task, error) {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	start := 0
+	for start < len(lines) && isComment(lines[start]) {
+		start++
+	}
+	if start == len(lines) || strings.TrimSpace(lines[start]) != "---" {
+		return Task{}, TextErr{{Line: min(start+1, len(lines)), Err: NoFieldsErr}}
+	}
+	end := start + 1
+	for end < len(lines) && strings.TrimSpace(lines[end]) != "---" {
+		end++
+	}
+	if end == len(lines) {
+		return Task{}, TextErr{{Line: start + 1, Err: NoFieldsErr}}
+	}
+
+	task := Task{TaskStatus: "pending"}
+	var problems TextErr
+	seen := make(map[string]bool)
+	for i := start + 1; i < end; i++ {
+		if isComment(lines[i]) {
+			continue
+		}
+		field, value, found := strings.Cut(lines[i], ":")
+		field, value = strings.ToLower(strings.TrimSpace(field)), strings.TrimSpace(value)
+		if !found || field == "" {
+			problems = append(problems, FieldErr{Line: i + 1, Err: FieldSyntaxErr})
+			continue
+		}
+		if seen[field] {
+			problems = append(problems, FieldErr{Line: i + 1, Field: field, Err: DuplicateFieldErr})
+			continue
+		}
+		seen[field] = true
+		err := task.setTextField(field, value, dateLayout)
+		if err != nil {
+			problems = append(problems, FieldErr{Line: i + 1, Field: field, Err: err})
+		}
+	}
+	if !seen["name"] {
+		problems = append(problems, FieldErr{Field: "name", Err: TaskNameErr})
+	}
+	if !seen["date"] {
+		problems = append(problems, FieldErr{Field: "date", Err: TaskDateErr})
+	}
+	if len(problems) > 0 {
+		return Task{}, problems
+	}
+	task.Description = strings.TrimSpace(strings.Join(lines[end+1:], "\n"))
+	return task, nil
+}
+
+func (task *Task) setTextField(field, value, dateLayout string) error {
+	switch field {
+	case "name":
+		if value == "" {
+			return TaskNameErr
+		}
+		task.Name = value
+	case "date":
+		date, err := time.Parse(dateLayout, value)
+		if err != nil {
+			return TaskDateErr
+		}
+		task.Date = date.Format(DateLayout)
+	case "priority":
+		if value == "" {
+			return nil
+		}
+		priority, err := ParsePriority(value)
+		if err != nil {
+			return err
+		}
+		task.Priority = priority
+	case "tags":
+		if value != "" {
+			task.Tags = NormalizeTags(SplitTags(value))
+		}
+	case "status":
+		status := strings.ToLower(value)
+		if status != "pending" && status != "complete" {
+			return InvalidStatusErr
+		}
+		task.TaskStatus = status
+	default:
+		return UnknownFieldErr
+	}
+	return nil
+}
+
+func isComment(line string) bool {
+	line = strings.TrimSpace(line)
+	return line == "" || strings.HasPrefix(line, "#")
+}
@@ -0,0 +1,85 @@
+package tasks
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestFormatText(t *testing.T) {
+	task := Task{Id: 3, Name: "Write report", Description: "for the team\nby friday", Date: "31-12-2030", TaskStatus: "pending", Priority: "high", Tags: []string{"q4", "work"}}
+	expected := "---\nname: Write report\ndate: 2030/12/31\npriority: high\ntags: q4, work\nstatus: pending\n---\nfor the team\nby friday\n"
+
+	got := FormatText(task, "2006/01/02")
+
+	if got != expected {
+		t.Fatalf("got %q, expected %q", got, expected)
+	}
+	parsed, err := ParseText(got, "2006/01/02")
+	if err != nil {
+		t.Fatalf("unexpected error %q", err)
+	}
+	task.Id = 0
+	if !reflect.DeepEqual(parsed, task) {
+		t.Errorf("got %v back, expected %v", parsed, task)
+	}
+}
+
+func TestParseText(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected Task
+	}{
+		{name: "only the required fields", input: "---\nname: Call mum\ndate: 01-01-2030\n---\n", expected: Task{Name: "Call mum", Date: "01-01-2030", TaskStatus: "pending"}},
+		{name: "comments and blank lines", input: "# written in an editor\n\n---\n# the name\nName:  Call: mum \n\ndate: 01-01-2030\npriority:\ntags:\n---\n\n# not a comment\n\n", expected: Task{Name: "Call: mum", Description: "# not a comment", Date: "01-01-2030", TaskStatus: "pending"}},
+		{name: "complete", input: "---\nname: a\ndate: 01-01-2030\nstatus: Complete\ntags: B, a, b\n---", expected: Task{Name: "a", Date: "01-01-2030", TaskStatus: "complete", Tags: []string{"a", "b"}}},
+		{name: "windows line endings", input: "---\r\nname: a\r\ndate: 01-01-2030\r\n---\r\nline one\r\nline two\r\n", expected: Task{Name: "a", Description: "line one\nline two", Date: "01-01-2030", TaskStatus: "pending"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseText(test.input, "02-01-2006")
+
+			if err != nil {
+				t.Fatalf("unexpected error %q", err)
+			}
+			if !reflect.DeepEqual(got, test.expected) {
+				t.Errorf("got %v, expected %v", got, test.expected)
+			}
+		})
+	}
+}
+
+func TestParseTextErrors(t *testing.T) {
+	tests := []struct {
+		name           string
+		input          string
+		expected_error TextErr
+	}{
+		{name: "empty", input: "", expected_error: TextErr{{Line: 1, Err: NoFieldsErr}}},
+		{name: "no fields", input: "Call mum\n", expected_error: TextErr{{Line: 1, Err: NoFieldsErr}}},
+		{name: "fields not closed", input: "# task\n---\nname: a\n", expected_error: TextErr{{Line: 2, Err: NoFieldsErr}}},
+		{name: "missing fields", input: "---\n---\n", expected_error: TextErr{{Field: "name", Err: TaskNameErr}, {Field: "date", Err: TaskDateErr}}},
+		{name: "every problem", input: "---\nname:\ndate: 2030-01-01\npriority: urgent\nstatus: done\ncolour: red\nno colon\nname: b\n---\n", expected_error: TextErr{
+			{Line: 2, Field: "name", Err: TaskNameErr},
+			{Line: 3, Field: "date", Err: TaskDateErr},
+			{Line: 4, Field: "priority", Err: InvalidPriorityErr},
+			{Line: 5, Field: "status", Err: InvalidStatusErr},
+			{Line: 6, Field: "colour", Err: UnknownFieldErr},
+			{Line: 7, Err: FieldSyntaxErr},
+			{Line: 8, Field: "name", Err: DuplicateFieldErr},
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ParseText(test.input, "02-01-2006")
+
+			var got TextErr
+			if !errors.As(err, &got) || !reflect.DeepEqual(got, test.expected_error) {
+				t.Errorf("got %q, expected %q", err, test.expected_error)
+			}
+		})
+	}
+}
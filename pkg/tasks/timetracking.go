@@ -0,0 +1,167 @@
+package tasks
+
+import (
+	"sort"
+	"time"
+)
+
+const (
+	TimerRunningErr     = TaskError("A timer is already running, stop it before starting another one")
+	NoTimerRunningErr   = TaskError("There is no timer running")
+	InvalidTimeEntryErr = TaskError("Time entry must end after it starts")
+	InvalidEstimateErr  = TaskError("Estimate cannot be negative")
+)
+
+type TimeEntry struct {
+	Start time.Time
+	End   time.Time
+}
+
+func (entry TimeEntry) Running() bool {
+	return entry.End.IsZero()
+}
+
+// Duration of the entry, counting a running timer up to now.
+func (entry TimeEntry) Duration(now time.Time) time.Duration {
+	if entry.Running() {
+		return now.Sub(entry.Start)
+	}
+	return entry.End.Sub(entry.Start)
+}
+
+// RunningTimer returns the task whose timer is running, a TaskList belongs to
+// a single user so there is at most one.
+func (tasks TaskList) RunningTimer() (*Task, error) {
+	for _, task := range tasks {
+		for _, entry := range task.TimeEntries {
+			if entry.Running() {
+				return task, nil
+			}
+		}
+	}
+	return nil, NoTimerRunningErr
+}
+
+func (tasks TaskList) StartTimer(id int, now time.Time) error {
+	task, err := tasks.GetTask(id)
+	if err != nil {
+		return err
+	}
+	_, err = tasks.RunningTimer()
+	if err == nil {
+		return TimerRunningErr
+	}
+	task.TimeEntries = append(task.TimeEntries, TimeEntry{Start: now})
+	return nil
+}
+
+func (tasks TaskList) StopTimer(now time.Time) (Task, TimeEntry, error) {
+	task, err := tasks.RunningTimer()
+	if err != nil {
+		return Task{}, TimeEntry{}, err
+	}
+	for i, entry := range task.TimeEntries {
+		if entry.Running() {
+			if now.Before(entry.Start) {
+				return Task{}, TimeEntry{}, InvalidTimeEntryErr
+			}
+			task.TimeEntries[i].End = now
+			return *task, task.TimeEntries[i], nil
+		}
+	}
+	return Task{}, TimeEntry{}, NoTimerRunningErr
+}
+
+func (tasks TaskList) AddTimeEntry(id int, start, end time.Time) (TimeEntry, error) {
+	task, err := tasks.GetTask(id)
+	if err != nil {
+		return TimeEntry{}, err
+	}
+	if !end.After(start) {
+		return TimeEntry{}, InvalidTimeEntryErr
+	}
+	entry := TimeEntry{Start: start, End: end}
+	task.TimeEntries = append(task.TimeEntries, entry)
+	return entry, nil
+}
+
+func (tasks TaskList) SetEstimate(id int, estimate time.Duration) error {
+	task, err := tasks.GetTask(id)
+	if err != nil {
+		return err
+	}
+	if estimate < 0 {
+		return InvalidEstimateErr
+	}
+	task.Estimate = estimate
+	return nil
+}
+
+func (t Task) TimeSpent(now time.Time) time.Duration {
+	var total time.Duration
+	for _, entry := range t.TimeEntries {
+		total += entry.Duration(now)
+	}
+	return total
+}
+
+// OverEstimate is how much time was spent beyond the estimate, negative when
+// there is still time left.
+func (t Task) OverEstimate(now time.Time) time.Duration {
+	return t.TimeSpent(now) - t.Estimate
+}
+
+type TimesheetRow struct {
+	TaskId int
+	Name   string
+	Days   [7]time.Duration
+	Total  time.Duration
+}
+
+// WeekStart returns midnight of the Monday of the week day falls in.
+func WeekStart(day time.Time) time.Time {
+	midnight := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	offset := (int(midnight.Weekday()) + 6) % 7
+	return midnight.AddDate(0, 0, -offset)
+}
+
+// Timesheet spreads the time logged during the week starting at weekStart over
+// its days, splitting entries that cross midnight. Tasks with no time that
+// week are left out.
+func (tasks TaskList) Timesheet(weekStart time.Time, now time.Time) []TimesheetRow {
+	var rows []TimesheetRow
+	for id, task := range tasks {
+		row := TimesheetRow{TaskId: id, Name: task.Name}
+		for _, entry := range task.TimeEntries {
+			end := entry.End
+			if entry.Running() {
+				end = now
+			}
+			for day := 0; day < 7; day++ {
+				dayStart := weekStart.AddDate(0, 0, day)
+				dayEnd := weekStart.AddDate(0, 0, day+1)
+				spent := overlap(entry.Start, end, dayStart, dayEnd)
+				row.Days[day] += spent
+				row.Total += spent
+			}
+		}
+		if row.Total > 0 {
+			rows = append(rows, row)
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].TaskId < rows[j].TaskId })
+	return rows
+}
+
+func overlap(start, end, rangeStart, rangeEnd time.Time) time.Duration {
+	if start.Before(rangeStart) {
+		start = rangeStart
+	}
+	if end.After(rangeEnd) {
+		end = rangeEnd
+	}
+	if !end.After(start) {
+		return 0
+	}
+	return end.Sub(start)
+}
@@ -0,0 +1,129 @@
+package tasks
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStartTimer(t *testing.T) {
+	now := time.Date(2024, 6, 3, 9, 0, 0, 0, time.UTC)
+	taskList := TaskList{1: &Task{Id: 1, Name: "a"}, 2: &Task{Id: 2, Name: "b"}}
+	tests := []struct {
+		name           string
+		input          int
+		expected_error error
+	}{
+		{name: "first timer", input: 1, expected_error: nil},
+		{name: "second timer on another task", input: 2, expected_error: TimerRunningErr},
+		{name: "second timer on same task", input: 1, expected_error: TimerRunningErr},
+		{name: "nonexisting task", input: 3, expected_error: TaskNotFoundErr},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := taskList.StartTimer(test.input, now)
+
+			if err != test.expected_error {
+				t.Fatalf("unexpected error, got %q, expected %q", err, test.expected_error)
+			}
+		})
+	}
+}
+
+func TestStopTimer(t *testing.T) {
+	start := time.Date(2024, 6, 3, 9, 0, 0, 0, time.UTC)
+	taskList := TaskList{1: &Task{Id: 1, Name: "a"}}
+
+	t.Run("no timer running", func(t *testing.T) {
+		_, _, err := taskList.StopTimer(start)
+
+		if err != NoTimerRunningErr {
+			t.Fatalf("unexpected error, got %q, expected %q", err, NoTimerRunningErr)
+		}
+	})
+
+	t.Run("running timer", func(t *testing.T) {
+		taskList.StartTimer(1, start)
+
+		task, entry, err := taskList.StopTimer(start.Add(90 * time.Minute))
+
+		if err != nil {
+			t.Fatalf("expected no error, got %q", err)
+		}
+		if entry.Duration(start) != 90*time.Minute || task.TimeSpent(start) != 90*time.Minute {
+			t.Errorf("expected 1h30m tracked, got %v", entry.Duration(start))
+		}
+		if err := taskList.StartTimer(1, start.Add(2*time.Hour)); err != nil {
+			t.Errorf("expected to be able to start a new timer, got %q", err)
+		}
+	})
+}
+
+func TestAddTimeEntry(t *testing.T) {
+	start := time.Date(2024, 6, 3, 9, 0, 0, 0, time.UTC)
+	taskList := TaskList{1: &Task{Id: 1, Name: "a"}}
+	tests := []struct {
+		name           string
+		task_id        int
+		end            time.Time
+		expected_error error
+	}{
+		{name: "valid entry", task_id: 1, end: start.Add(time.Hour), expected_error: nil},
+		{name: "end before start", task_id: 1, end: start.Add(-time.Hour), expected_error: InvalidTimeEntryErr},
+		{name: "empty entry", task_id: 1, end: start, expected_error: InvalidTimeEntryErr},
+		{name: "nonexisting task", task_id: 2, end: start.Add(time.Hour), expected_error: TaskNotFoundErr},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := taskList.AddTimeEntry(test.task_id, start, test.end)
+
+			if err != test.expected_error {
+				t.Fatalf("unexpected error, got %q, expected %q", err, test.expected_error)
+			}
+		})
+	}
+}
+
+func TestOverEstimate(t *testing.T) {
+	start := time.Date(2024, 6, 3, 9, 0, 0, 0, time.UTC)
+	taskList := TaskList{1: &Task{Id: 1, Name: "a"}}
+	taskList.SetEstimate(1, 2*time.Hour)
+	taskList.AddTimeEntry(1, start, start.Add(time.Hour))
+	taskList.StartTimer(1, start.Add(2*time.Hour))
+
+	got := taskList[1].OverEstimate(start.Add(4 * time.Hour))
+
+	if got != time.Hour {
+		t.Errorf("got %v over the estimate, expected %v", got, time.Hour)
+	}
+	if err := taskList.SetEstimate(1, -time.Hour); err != InvalidEstimateErr {
+		t.Errorf("unexpected error, got %q, expected %q", err, InvalidEstimateErr)
+	}
+}
+
+func TestTimesheet(t *testing.T) {
+	wednesday := time.Date(2024, 6, 5, 15, 0, 0, 0, time.UTC)
+	monday := WeekStart(wednesday)
+	if !monday.Equal(time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("got week start %v, expected monday the 3rd", monday)
+	}
+	taskList := TaskList{1: &Task{Id: 1, Name: "a"}, 2: &Task{Id: 2, Name: "b"}, 3: &Task{Id: 3, Name: "c"}}
+	taskList.AddTimeEntry(1, monday.Add(23*time.Hour), monday.Add(25*time.Hour))
+	taskList.AddTimeEntry(1, monday.Add(-time.Hour), monday.Add(time.Hour))
+	taskList.AddTimeEntry(3, monday.AddDate(0, 0, 7), monday.AddDate(0, 0, 7).Add(time.Hour))
+	taskList.StartTimer(2, wednesday.Add(-30*time.Minute))
+
+	rows := taskList.Timesheet(monday, wednesday)
+
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(rows))
+	}
+	expected := [7]time.Duration{2 * time.Hour, time.Hour}
+	if rows[0].TaskId != 1 || rows[0].Days != expected || rows[0].Total != 3*time.Hour {
+		t.Errorf("got %v, expected task 1 split over monday and tuesday", rows[0])
+	}
+	if rows[1].TaskId != 2 || rows[1].Days[2] != 30*time.Minute {
+		t.Errorf("got %v, expected running timer counted on wednesday", rows[1])
+	}
+}
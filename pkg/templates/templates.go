@@ -0,0 +1,198 @@
+package templates
+
+import (
+	"encoding/json"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"todo_app/pkg/tasks"
+)
+
+var (
+	templateNameRegex = regexp.MustCompile("^[a-zA-Z0-9_.-]+$")
+	variableRegex     = regexp.MustCompile(`\{\{\s*([a-zA-Z0-9_]+)\s*\}\}`)
+)
+
+const (
+	TemplateNotFoundErr = TemplateError("Template not found")
+	TemplateNameErr     = TemplateError("Template name cannot be empty or contain spaces or special characters beside dots, dashes and underscores")
+	EmptyTemplateErr    = TemplateError("Template must have at least one task")
+	TemplateFileErr     = TemplateError("Template file is not in a valid format")
+)
+
+type TemplateError string
+
+func (err TemplateError) Error() string {
+	return string(err)
+}
+
+// MissingVariableErr lists the variables a template uses that were not given
+// a value when instantiating it.
+type MissingVariableErr []string
+
+func (err MissingVariableErr) Error() string {
+	return "Missing value for template variables: " + strings.Join(err, ", ")
+}
+
+// TaskTemplate describes one task of a template, DueOffset is the number of
+// days after the base date the task is due.
+type TaskTemplate struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	DueOffset   int      `json:"due_offset"`
+	Tags        []string `json:"tags,omitempty"`
+}
+
+type Template struct {
+	Name  string         `json:"name"`
+	Tasks []TaskTemplate `json:"tasks"`
+}
+
+// Library holds the templates of a single user by name.
+type Library map[string]*Template
+
+func (library Library) GetTemplate(name string) (*Template, error) {
+	template, found := library[name]
+	if found {
+		return template, nil
+	}
+	return nil, TemplateNotFoundErr
+}
+
+// SaveTemplate adds a template, replacing any other with the same name.
+func (library Library) SaveTemplate(template Template) error {
+	err := validateTemplate(template)
+	if err != nil {
+		return err
+	}
+	library[template.Name] = &template
+	return nil
+}
+
+func (library Library) DeleteTemplate(name string) error {
+	_, err := library.GetTemplate(name)
+	if err != nil {
+		return err
+	}
+	delete(library, name)
+	return nil
+}
+
+func (library Library) Names() []string {
+	names := make([]string, 0, len(library))
+	for name := range library {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Variables returns the names used as {{variable}} anywhere in the template.
+func (template Template) Variables() []string {
+	seen := make(map[string]bool)
+	variables := []string{}
+	for _, task := range template.Tasks {
+		fields := append([]string{task.Name, task.Description}, task.Tags...)
+		for _, field := range fields {
+			for _, match := range variableRegex.FindAllStringSubmatch(field, -1) {
+				if !seen[match[1]] {
+					seen[match[1]] = true
+					variables = append(variables, match[1])
+				}
+			}
+		}
+	}
+	sort.Strings(variables)
+	return variables
+}
+
+// Instantiate adds the tasks of the template to taskList, due relative to
+// base, with every {{variable}} replaced by its value. Either every task is
+// added or, if any of them is invalid, none are.
+func (template Template) Instantiate(taskList tasks.TaskList, base time.Time, values map[string]string) ([]tasks.Task, error) {
+	var missing MissingVariableErr
+	for _, variable := range template.Variables() {
+		if _, found := values[variable]; !found {
+			missing = append(missing, variable)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, missing
+	}
+
+	pending := make([]TaskTemplate, len(template.Tasks))
+	for i, task := range template.Tasks {
+		pending[i] = TaskTemplate{
+			Name:        strings.TrimSpace(substitute(task.Name, values)),
+			Description: substitute(task.Description, values),
+			DueOffset:   task.DueOffset,
+		}
+		for _, tag := range task.Tags {
+			pending[i].Tags = append(pending[i].Tags, substitute(tag, values))
+		}
+		if pending[i].Name == "" {
+			return nil, tasks.TaskNameErr
+		}
+	}
+
+	var created []tasks.Task
+	for _, task := range pending {
+		date := base.AddDate(0, 0, task.DueOffset).Format(tasks.DateLayout)
+		newTask, err := taskList.AddTask(task.Name, task.Description, date)
+		if err != nil {
+			for _, added := range created {
+				taskList.DeleteTask(added.Id)
+			}
+			return nil, err
+		}
+		taskList.SetTags(newTask.Id, task.Tags)
+		newTask.Tags = taskList[newTask.Id].Tags
+		created = append(created, newTask)
+	}
+	return created, nil
+}
+
+func substitute(text string, values map[string]string) string {
+	return variableRegex.ReplaceAllStringFunc(text, func(match string) string {
+		return values[variableRegex.FindStringSubmatch(match)[1]]
+	})
+}
+
+func validateTemplate(template Template) error {
+	if !templateNameRegex.MatchString(template.Name) {
+		return TemplateNameErr
+	}
+	if len(template.Tasks) == 0 {
+		return EmptyTemplateErr
+	}
+	for _, task := range template.Tasks {
+		if strings.TrimSpace(task.Name) == "" {
+			return tasks.TaskNameErr
+		}
+	}
+	return nil
+}
+
+// Export writes the template as JSON so it can be shared and imported back.
+func Export(out io.Writer, template Template) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(template)
+}
+
+func Import(in io.Reader) (Template, error) {
+	var template Template
+	decoder := json.NewDecoder(in)
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&template)
+	if err != nil {
+		return Template{}, TemplateFileErr
+	}
+	err = validateTemplate(template)
+	if err != nil {
+		return Template{}, err
+	}
+	return template, nil
+}
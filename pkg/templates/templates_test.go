@@ -0,0 +1,160 @@
+package templates
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"todo_app/pkg/tasks"
+)
+
+func TestSaveTemplate(t *testing.T) {
+	library := Library{}
+	tests := []struct {
+		name           string
+		input          Template
+		expected_error error
+	}{
+		{name: "valid template", input: releaseTemplate(), expected_error: nil},
+		{name: "name with spaces", input: Template{Name: "my release", Tasks: releaseTemplate().Tasks}, expected_error: TemplateNameErr},
+		{name: "empty name", input: Template{Name: "", Tasks: releaseTemplate().Tasks}, expected_error: TemplateNameErr},
+		{name: "no tasks", input: Template{Name: "empty"}, expected_error: EmptyTemplateErr},
+		{name: "task without name", input: Template{Name: "bad", Tasks: []TaskTemplate{{Name: " "}}}, expected_error: tasks.TaskNameErr},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := library.SaveTemplate(test.input)
+
+			if err != test.expected_error {
+				t.Fatalf("unexpected error, got %q, expected %q", err, test.expected_error)
+			}
+			if err == nil {
+				saved, _ := library.GetTemplate(test.input.Name)
+				if !reflect.DeepEqual(*saved, test.input) {
+					t.Errorf("got %v, expected %v", *saved, test.input)
+				}
+			}
+		})
+	}
+
+	if !reflect.DeepEqual(library.Names(), []string{"release"}) {
+		t.Errorf("got %q, expected only the valid template", library.Names())
+	}
+}
+
+func TestDeleteTemplate(t *testing.T) {
+	library := Library{}
+	library.SaveTemplate(releaseTemplate())
+
+	if err := library.DeleteTemplate("release"); err != nil {
+		t.Fatalf("expected no error, got %q", err)
+	}
+	if err := library.DeleteTemplate("release"); err != TemplateNotFoundErr {
+		t.Errorf("unexpected error, got %q, expected %q", err, TemplateNotFoundErr)
+	}
+}
+
+func TestVariables(t *testing.T) {
+	got := releaseTemplate().Variables()
+
+	if !reflect.DeepEqual(got, []string{"channel", "version"}) {
+		t.Errorf("got %q, expected channel and version", got)
+	}
+}
+
+func TestInstantiate(t *testing.T) {
+	base := time.Date(2024, 3, 30, 0, 0, 0, 0, time.UTC)
+
+	t.Run("all variables given", func(t *testing.T) {
+		taskList := tasks.TaskList{}
+
+		created, err := releaseTemplate().Instantiate(taskList, base, map[string]string{"version": "1.2", "channel": "Stable"})
+
+		if err != nil {
+			t.Fatalf("expected no error, got %q", err)
+		}
+		if len(created) != 2 || len(taskList) != 2 {
+			t.Fatalf("expected 2 tasks, got %d", len(taskList))
+		}
+		expected := tasks.Task{Id: 2, Name: "Announce 1.2", Description: "post on the Stable channel", Date: "02-04-2024", TaskStatus: "pending", Tags: []string{"release", "stable"}}
+		if !reflect.DeepEqual(*taskList[2], expected) || !reflect.DeepEqual(created[1], expected) {
+			t.Errorf("got %v, expected %v", *taskList[2], expected)
+		}
+		if taskList[1].Date != "30-03-2024" || taskList[1].Name != "Tag v1.2" {
+			t.Errorf("got %v, expected the tag task due on the base date", *taskList[1])
+		}
+	})
+
+	t.Run("missing variable", func(t *testing.T) {
+		taskList := tasks.TaskList{}
+
+		_, err := releaseTemplate().Instantiate(taskList, base, map[string]string{"version": "1.2"})
+
+		if !reflect.DeepEqual(err, MissingVariableErr{"channel"}) {
+			t.Fatalf("unexpected error, got %q", err)
+		}
+		if len(taskList) != 0 {
+			t.Errorf("expected no tasks to be added, got %d", len(taskList))
+		}
+	})
+
+	t.Run("variable leaves a task without name", func(t *testing.T) {
+		taskList := tasks.TaskList{}
+		template := Template{Name: "t", Tasks: []TaskTemplate{{Name: "first"}, {Name: "{{name}}"}}}
+
+		_, err := template.Instantiate(taskList, base, map[string]string{"name": ""})
+
+		if err != tasks.TaskNameErr {
+			t.Fatalf("unexpected error, got %q, expected %q", err, tasks.TaskNameErr)
+		}
+		if len(taskList) != 0 {
+			t.Errorf("expected no tasks to be added, got %d", len(taskList))
+		}
+	})
+}
+
+func TestExportImport(t *testing.T) {
+	var file bytes.Buffer
+
+	err := Export(&file, releaseTemplate())
+	if err != nil {
+		t.Fatalf("expected no error, got %q", err)
+	}
+	imported, err := Import(&file)
+	if err != nil {
+		t.Fatalf("expected no error, got %q", err)
+	}
+	if !reflect.DeepEqual(imported, releaseTemplate()) {
+		t.Errorf("got %v, expected %v", imported, releaseTemplate())
+	}
+
+	invalidFiles := []struct {
+		name           string
+		input          string
+		expected_error error
+	}{
+		{name: "not json", input: "name: release", expected_error: TemplateFileErr},
+		{name: "unknown field", input: `{"name": "release", "steps": []}`, expected_error: TemplateFileErr},
+		{name: "no tasks", input: `{"name": "release", "tasks": []}`, expected_error: EmptyTemplateErr},
+	}
+	for _, test := range invalidFiles {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Import(strings.NewReader(test.input))
+
+			if err != test.expected_error {
+				t.Errorf("unexpected error, got %q, expected %q", err, test.expected_error)
+			}
+		})
+	}
+}
+
+//helpers
+
+func releaseTemplate() Template {
+	return Template{Name: "release", Tasks: []TaskTemplate{
+		{Name: "Tag v{{version}}", Description: "git tag", DueOffset: 0, Tags: []string{"release"}},
+		{Name: "Announce {{ version }}", Description: "post on the {{channel}} channel", DueOffset: 3, Tags: []string{"release", "{{channel}}"}},
+	}}
+}
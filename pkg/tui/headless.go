@@ -0,0 +1,41 @@
+package tui
+
+import "strings"
+
+// Headless runs an App without a terminal, at a fixed size, so tests and
+// scripts can press keys and read the screen.
+type Headless struct {
+	App    *App
+	Width  int
+	Height int
+}
+
+func NewHeadless(app *App, width, height int) *Headless {
+	return &Headless{App: app, Width: width, Height: height}
+}
+
+// Press handles keys in order, stopping when the user quits.
+func (headless *Headless) Press(keys ...Key) {
+	for _, key := range keys {
+		if headless.App.Done() {
+			return
+		}
+		headless.App.Update(key)
+	}
+}
+
+// Send presses the keys of script, see Keys, and returns the screen after.
+func (headless *Headless) Send(script string) string {
+	headless.Press(Keys(script)...)
+	return headless.Screen()
+}
+
+// Screen is what the terminal would show, one line per row with the
+// padding at the end of the rows cut.
+func (headless *Headless) Screen() string {
+	lines := headless.App.View(headless.Width, headless.Height)
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " ")
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,148 @@
+package tui
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type KeyType int
+
+const (
+	KeyRune KeyType = iota
+	KeyEnter
+	KeyEscape
+	KeyBackspace
+	KeyTab
+	KeyBacktab
+	KeyUp
+	KeyDown
+	KeyLeft
+	KeyRight
+	KeyHome
+	KeyEnd
+	KeyCtrlC
+)
+
+// Key is one key press. Rune is only set for KeyRune.
+type Key struct {
+	Type KeyType
+	Rune rune
+}
+
+func Rune(r rune) Key {
+	return Key{Type: KeyRune, Rune: r}
+}
+
+// names are the keys scripts can write between angle brackets, see Keys.
+var names = map[string]KeyType{
+	"enter":   KeyEnter,
+	"esc":     KeyEscape,
+	"bs":      KeyBackspace,
+	"tab":     KeyTab,
+	"backtab": KeyBacktab,
+	"up":      KeyUp,
+	"down":    KeyDown,
+	"left":    KeyLeft,
+	"right":   KeyRight,
+	"home":    KeyHome,
+	"end":     KeyEnd,
+	"c-c":     KeyCtrlC,
+}
+
+// ParseKeys decodes what a terminal in raw mode sends for the keys pressed:
+// text as UTF-8 and special keys as control characters or escape sequences.
+// A lone escape byte is the escape key. Sequences for keys the app does not
+// use are dropped.
+func ParseKeys(input []byte) []Key {
+	var keys []Key
+	for len(input) > 0 {
+		switch b := input[0]; {
+		case b == 0x1b:
+			key, size := parseEscape(input)
+			if key.Type != KeyRune {
+				keys = append(keys, key)
+			}
+			input = input[size:]
+			continue
+		case b == '\r' || b == '\n':
+			keys = append(keys, Key{Type: KeyEnter})
+		case b == '\t':
+			keys = append(keys, Key{Type: KeyTab})
+		case b == 0x7f || b == 0x08:
+			keys = append(keys, Key{Type: KeyBackspace})
+		case b == 0x03:
+			keys = append(keys, Key{Type: KeyCtrlC})
+		case b < 0x20:
+			// other control keys do nothing
+		default:
+			r, size := utf8.DecodeRune(input)
+			if r != utf8.RuneError {
+				keys = append(keys, Rune(r))
+			}
+			input = input[size:]
+			continue
+		}
+		input = input[1:]
+	}
+	return keys
+}
+
+// parseEscape decodes the escape sequence input starts with and returns how
+// many bytes it took. Unknown sequences come back as a KeyRune to drop.
+func parseEscape(input []byte) (Key, int) {
+	if len(input) < 2 || (input[1] != '[' && input[1] != 'O') {
+		return Key{Type: KeyEscape}, 1
+	}
+	// the parameters are digits and separators, the final byte says the key
+	end := 2
+	for end < len(input) && (input[end] < 0x40 || input[end] > 0x7e) {
+		end++
+	}
+	if end == len(input) {
+		return Key{Type: KeyEscape}, 1
+	}
+	switch input[end] {
+	case 'A':
+		return Key{Type: KeyUp}, end + 1
+	case 'B':
+		return Key{Type: KeyDown}, end + 1
+	case 'C':
+		return Key{Type: KeyRight}, end + 1
+	case 'D':
+		return Key{Type: KeyLeft}, end + 1
+	case 'H':
+		return Key{Type: KeyHome}, end + 1
+	case 'F':
+		return Key{Type: KeyEnd}, end + 1
+	case 'Z':
+		return Key{Type: KeyBacktab}, end + 1
+	}
+	return Key{}, end + 1
+}
+
+// Keys turns a script into key presses: text is typed as is and special keys
+// are named between angle brackets, like "jj<enter>New name<esc>". A "<"
+// that does not start a known name is typed.
+func Keys(script string) []Key {
+	var keys []Key
+	for len(script) > 0 {
+		if script[0] == '<' {
+			end := strings.IndexByte(script, '>')
+			if end > 0 {
+				keyType, found := names[strings.ToLower(script[1:end])]
+				if found {
+					keys = append(keys, Key{Type: keyType})
+					script = script[end+1:]
+					continue
+				}
+			}
+		}
+		r, size := utf8.DecodeRuneInString(script)
+		if unicode.IsPrint(r) {
+			keys = append(keys, Rune(r))
+		}
+		script = script[size:]
+	}
+	return keys
+}
@@ -0,0 +1,41 @@
+package tui
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseKeys(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []Key
+	}{
+		{name: "text", input: "añ", expected: []Key{Rune('a'), Rune('ñ')}},
+		{name: "arrows", input: "\x1b[A\x1b[B\x1bOC\x1b[D", expected: []Key{{Type: KeyUp}, {Type: KeyDown}, {Type: KeyRight}, {Type: KeyLeft}}},
+		{name: "control keys", input: "\r\t\x7f\x03", expected: []Key{{Type: KeyEnter}, {Type: KeyTab}, {Type: KeyBackspace}, {Type: KeyCtrlC}}},
+		{name: "lone escape", input: "\x1bq", expected: []Key{{Type: KeyEscape}, Rune('q')}},
+		{name: "escape at the end", input: "\x1b", expected: []Key{{Type: KeyEscape}}},
+		{name: "unknown sequence", input: "\x1b[3~x", expected: []Key{Rune('x')}},
+		{name: "shift tab", input: "\x1b[Z", expected: []Key{{Type: KeyBacktab}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := ParseKeys([]byte(test.input))
+
+			if !reflect.DeepEqual(got, test.expected) {
+				t.Errorf("got %v, expected %v", got, test.expected)
+			}
+		})
+	}
+}
+
+func TestKeys(t *testing.T) {
+	got := Keys("j<Enter>a<b<c-c>")
+	expected := []Key{Rune('j'), {Type: KeyEnter}, Rune('a'), Rune('<'), Rune('b'), {Type: KeyCtrlC}}
+
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %v, expected %v", got, expected)
+	}
+}
@@ -0,0 +1,52 @@
+package tui
+
+import (
+	"io"
+	"strings"
+)
+
+// Run draws app on out and hands it the keys read from in until the user
+// quits or in ends. in and out should be a terminal in raw mode, size is
+// asked for its columns and rows before every frame so resizing works.
+func Run(app *App, in io.Reader, out io.Writer, size func() (int, int)) error {
+	// the alternate screen keeps what was on the terminal before
+	_, err := io.WriteString(out, "\x1b[?1049h\x1b[?25l")
+	if err != nil {
+		return err
+	}
+	defer io.WriteString(out, "\x1b[?25h\x1b[?1049l")
+	buffer := make([]byte, 256)
+	for !app.Done() {
+		err = draw(out, app.View(size()))
+		if err != nil {
+			return err
+		}
+		n, err := in.Read(buffer)
+		for _, key := range ParseKeys(buffer[:n]) {
+			app.Update(key)
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// draw writes the whole screen in one go so it does not flicker.
+func draw(out io.Writer, lines []string) error {
+	var screen strings.Builder
+	screen.WriteString("\x1b[H")
+	for i, line := range lines {
+		if i > 0 {
+			screen.WriteString("\r\n")
+		}
+		screen.WriteString(line)
+		screen.WriteString("\x1b[K")
+	}
+	screen.WriteString("\x1b[J")
+	_, err := io.WriteString(out, screen.String())
+	return err
+}
@@ -0,0 +1,545 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"todo_app/pkg/auth"
+	"todo_app/pkg/tasks"
+	"unicode/utf8"
+)
+
+const (
+	minWidth  = 40
+	minHeight = 8
+)
+
+type mode int
+
+const (
+	listMode mode = iota
+	filterMode
+	editMode
+	confirmMode
+)
+
+// The fields that can be edited, in the order Tab goes through them.
+const (
+	nameField = iota
+	descriptionField
+	dateField
+	fieldCount
+)
+
+var fieldLabels = [fieldCount]string{"Name", "Description", "Date"}
+
+// App is the full-screen mode for the tasks of one user. Keys go in through
+// Update and the screen comes out of View, so it runs the same in a terminal
+// (see Run) and in tests (see Headless). Changes are made to Tasks as they are
+// confirmed, saving them is up to the caller.
+type App struct {
+	Tasks tasks.TaskList
+	User  auth.User
+	// Today is the date, as dd-mm-yyyy, new tasks start with.
+	Today string
+	// DateLayout is how dates are typed and shown, tasks keep them as
+	// dd-mm-yyyy whatever it is.
+	DateLayout string
+	// Sort is the order of the list, see tasks.SortOrders.
+	Sort string
+
+	mode     mode
+	selected int
+	filter   string
+	// editing is the number of the task being edited, 0 for a new one.
+	editing int
+	field   int
+	values  [fieldCount][]rune
+	// confirming is the action waiting for a yes, "delete" or "complete".
+	confirming string
+	message    string
+	done       bool
+}
+
+func New(taskList tasks.TaskList, user auth.User, today time.Time) *App {
+	app := &App{Tasks: taskList, User: user, Today: today.Format(tasks.DateLayout), DateLayout: tasks.DateLayout, Sort: "id"}
+	app.fixSelection()
+	return app
+}
+
+// Done tells whether the user has quit.
+func (app *App) Done() bool {
+	return app.done
+}
+
+// Update handles one key press.
+func (app *App) Update(key Key) {
+	if key.Type == KeyCtrlC {
+		app.done = true
+		return
+	}
+	app.message = ""
+	switch app.mode {
+	case listMode:
+		app.updateList(key)
+	case filterMode:
+		app.updateFilter(key)
+	case editMode:
+		app.updateEdit(key)
+	case confirmMode:
+		app.updateConfirm(key)
+	}
+	app.fixSelection()
+}
+
+func (app *App) updateList(key Key) {
+	switch {
+	case key.Type == KeyUp || key == Rune('k'):
+		app.move(-1)
+	case key.Type == KeyDown || key == Rune('j'):
+		app.move(1)
+	case key.Type == KeyHome || key == Rune('g'):
+		app.move(-len(app.Tasks))
+	case key.Type == KeyEnd || key == Rune('G'):
+		app.move(len(app.Tasks))
+	case key == Rune('/'):
+		app.mode = filterMode
+	case key.Type == KeyEscape:
+		app.filter = ""
+	case key == Rune('a'):
+		err := auth.CanCreateTasks(app.User)
+		if err != nil {
+			app.message = err.Error()
+			return
+		}
+		app.startEdit(tasks.Task{Date: app.Today})
+	case key.Type == KeyEnter || key == Rune('e'):
+		task := app.current()
+		if task != nil {
+			app.startEdit(*task)
+		}
+	case key == Rune('c'):
+		task := app.current()
+		if task == nil {
+			return
+		}
+		if task.TaskStatus == "complete" {
+			app.message = fmt.Sprintf("Task #%d is already complete", task.Id)
+			return
+		}
+		app.mode, app.confirming = confirmMode, "complete"
+	case key == Rune('d'):
+		if app.current() != nil {
+			app.mode, app.confirming = confirmMode, "delete"
+		}
+	case key == Rune('q'):
+		app.done = true
+	}
+}
+
+func (app *App) updateFilter(key Key) {
+	switch key.Type {
+	case KeyRune:
+		app.filter += string(key.Rune)
+	case KeyBackspace:
+		_, size := utf8.DecodeLastRuneInString(app.filter)
+		app.filter = app.filter[:len(app.filter)-size]
+	case KeyEnter:
+		app.mode = listMode
+	case KeyEscape:
+		app.filter = ""
+		app.mode = listMode
+	}
+}
+
+func (app *App) startEdit(task tasks.Task) {
+	app.mode = editMode
+	app.editing = task.Id
+	app.field = nameField
+	app.values[nameField] = []rune(task.Name)
+	app.values[descriptionField] = []rune(task.Description)
+	app.values[dateField] = []rune(app.showDate(task.Date))
+}
+
+func (app *App) updateEdit(key Key) {
+	value := &app.values[app.field]
+	switch key.Type {
+	case KeyRune:
+		*value = append(*value, key.Rune)
+	case KeyBackspace:
+		if len(*value) > 0 {
+			*value = (*value)[:len(*value)-1]
+		}
+	case KeyTab, KeyDown:
+		app.field = (app.field + 1) % fieldCount
+	case KeyBacktab, KeyUp:
+		app.field = (app.field + fieldCount - 1) % fieldCount
+	case KeyEnter:
+		app.save()
+	case KeyEscape:
+		app.mode = listMode
+		app.message = "Nothing was changed"
+	}
+}
+
+// save adds or changes the task being edited. When a field is wrong nothing
+// changes and the form stays open to fix it.
+func (app *App) save() {
+	name := string(app.values[nameField])
+	description := string(app.values[descriptionField])
+	date := strings.TrimSpace(string(app.values[dateField]))
+	if strings.TrimSpace(name) == "" {
+		app.message = tasks.TaskNameErr.Error()
+		app.field = nameField
+		return
+	}
+	parsed, err := time.Parse(app.DateLayout, date)
+	if err != nil {
+		app.message = tasks.TaskDateErr.Error()
+		app.field = dateField
+		return
+	}
+	date = parsed.Format(tasks.DateLayout)
+	if app.editing == 0 {
+		task, err := app.Tasks.AddTask(name, description, date)
+		if err != nil {
+			app.message = err.Error()
+			return
+		}
+		app.selected = task.Id
+		app.message = fmt.Sprintf("Added task #%d", task.Id)
+	} else {
+		task, err := app.Tasks.GetTask(app.editing)
+		if err != nil {
+			app.message = err.Error()
+			return
+		}
+		task.Name, task.Description, task.Date = name, description, date
+		app.message = fmt.Sprintf("Saved task #%d", task.Id)
+	}
+	app.mode = listMode
+}
+
+func (app *App) updateConfirm(key Key) {
+	app.mode = listMode
+	task := app.current()
+	if task == nil || (key != Rune('y') && key != Rune('Y')) {
+		app.message = "Nothing was changed"
+		return
+	}
+	var err error
+	switch app.confirming {
+	case "delete":
+		// the task after it, or before it when it was the last, is selected next
+		visible := app.visible()
+		i := app.index(visible)
+		if i+1 < len(visible) {
+			app.selected = visible[i+1].Id
+		} else if i > 0 {
+			app.selected = visible[i-1].Id
+		}
+		err = app.Tasks.DeleteTask(task.Id)
+		app.message = fmt.Sprintf("Deleted task #%d", task.Id)
+	case "complete":
+		err = app.Tasks.CompleteTask(task.Id)
+		app.message = fmt.Sprintf("Completed task #%d", task.Id)
+	}
+	if err != nil {
+		app.message = err.Error()
+	}
+}
+
+// visible are the tasks matching the filter in the list order. Every word of
+// the filter has to be in the name, description, status or a tag of the task.
+func (app *App) visible() []*tasks.Task {
+	words := strings.Fields(strings.ToLower(app.filter))
+	visible := make([]*tasks.Task, 0, len(app.Tasks))
+	for _, task := range app.Tasks.Sorted(app.Sort) {
+		if matches(*task, words) {
+			visible = append(visible, task)
+		}
+	}
+	return visible
+}
+
+// showDate is a date kept by tasks in DateLayout.
+func (app *App) showDate(date string) string {
+	parsed, err := time.Parse(tasks.DateLayout, date)
+	if err != nil {
+		return date
+	}
+	return parsed.Format(app.DateLayout)
+}
+
+func matches(task tasks.Task, words []string) bool {
+	text := strings.ToLower(task.Name + "\n" + task.Description + "\n" + task.TaskStatus + "\n" + strings.Join(task.Tags, "\n"))
+	for _, word := range words {
+		if !strings.Contains(text, word) {
+			return false
+		}
+	}
+	return true
+}
+
+// index is the position of the selected task in visible, -1 if it is not.
+func (app *App) index(visible []*tasks.Task) int {
+	for i, task := range visible {
+		if task.Id == app.selected {
+			return i
+		}
+	}
+	return -1
+}
+
+func (app *App) move(delta int) {
+	visible := app.visible()
+	if len(visible) == 0 {
+		return
+	}
+	i := max(0, min(len(visible)-1, app.index(visible)+delta))
+	app.selected = visible[i].Id
+}
+
+// fixSelection selects the first task when the selected one was deleted or
+// filtered out.
+func (app *App) fixSelection() {
+	visible := app.visible()
+	if app.index(visible) >= 0 {
+		return
+	}
+	app.selected = 0
+	if len(visible) > 0 {
+		app.selected = visible[0].Id
+	}
+}
+
+// current is the selected task, nil when no task is shown.
+func (app *App) current() *tasks.Task {
+	task, err := app.Tasks.GetTask(app.selected)
+	if err != nil {
+		return nil
+	}
+	return task
+}
+
+// View draws the screen as height lines of width characters: a header, the
+// filter bar, the task list next to the details of the selected task and a
+// line with the keys to press or what the last one did.
+func (app *App) View(width, height int) []string {
+	lines := make([]string, 0, height)
+	if width < minWidth || height < minHeight {
+		for len(lines) < height {
+			lines = append(lines, fit("", width))
+		}
+		if height > 0 {
+			lines[0] = fit("Terminal too small", width)
+		}
+		return lines
+	}
+	visible := app.visible()
+	listWidth := width * 2 / 5
+	detailWidth := width - listWidth - 1
+	bodyHeight := height - 4
+
+	lines = append(lines, spread(" Tasks of "+app.User.Username, fmt.Sprintf("%d of %d shown ", len(visible), len(app.Tasks)), width))
+	filter := " Filter: " + app.filter
+	if app.mode == filterMode {
+		filter += "_"
+	}
+	lines = append(lines, fit(filter, width))
+	lines = append(lines, strings.Repeat("─", listWidth)+"┬"+strings.Repeat("─", detailWidth))
+	list := app.listPane(visible, listWidth, bodyHeight)
+	detail := app.detailPane(detailWidth, bodyHeight)
+	for i := 0; i < bodyHeight; i++ {
+		lines = append(lines, list[i]+"│"+detail[i])
+	}
+	if app.mode == confirmMode {
+		box := app.dialog(width)
+		top := 3 + (bodyHeight-len(box))/2
+		left := (width - utf8.RuneCountInString(box[0])) / 2
+		for i, row := range box {
+			lines[top+i] = overlay(lines[top+i], row, left)
+		}
+	}
+	return append(lines, fit(" "+app.footer(), width))
+}
+
+func (app *App) listPane(visible []*tasks.Task, width, height int) []string {
+	rows := make([]string, height)
+	offset := max(0, app.index(visible)-height+1)
+	for row := range rows {
+		text := ""
+		if offset+row < len(visible) {
+			task := visible[offset+row]
+			marker, check := "  ", "[ ]"
+			if task.Id == app.selected {
+				marker = "> "
+			}
+			if task.TaskStatus == "complete" {
+				check = "[x]"
+			}
+			text = fmt.Sprintf("%s%s #%d %s", marker, check, task.Id, strings.TrimSpace(task.Name))
+		} else if row == 0 && len(app.Tasks) == 0 {
+			text = "  No tasks yet, press a to add one"
+		} else if row == 0 {
+			text = "  No task matches the filter"
+		}
+		rows[row] = fit(text, width)
+	}
+	return rows
+}
+
+func (app *App) detailPane(width, height int) []string {
+	var text []string
+	task := app.current()
+	if app.mode == editMode {
+		title := "New task"
+		if app.editing != 0 {
+			title = fmt.Sprintf("Editing task #%d", app.editing)
+		}
+		text = append(text, title, "")
+		for field, label := range fieldLabels {
+			marker, cursor := "  ", ""
+			if field == app.field {
+				marker, cursor = "> ", "_"
+			}
+			// the end of a long value is shown, that is where typing goes
+			value := string(app.values[field]) + cursor
+			if room := width - 17; utf8.RuneCountInString(value) > room && room > 0 {
+				runes := []rune(value)
+				value = string(runes[len(runes)-room:])
+			}
+			text = append(text, fmt.Sprintf("%s%-13s %s", marker, label+":", value))
+		}
+	} else if task != nil {
+		priority, tags, description := task.Priority, strings.Join(task.Tags, ", "), task.Description
+		if priority == "" {
+			priority = "none"
+		}
+		if tags == "" {
+			tags = "none"
+		}
+		if strings.TrimSpace(description) == "" {
+			description = "none"
+		}
+		text = append(text,
+			fmt.Sprintf("Task #%d", task.Id),
+			"",
+			"Name:     "+strings.TrimSpace(task.Name),
+			"Status:   "+task.TaskStatus,
+			"Date:     "+app.showDate(task.Date),
+			"Priority: "+priority,
+			"Tags:     "+tags,
+		)
+		if len(task.Attachments) > 0 {
+			text = append(text, fmt.Sprintf("Files:    %d", len(task.Attachments)))
+		}
+		text = append(text, "", "Description:")
+		text = append(text, wrap(description, width-2)...)
+	}
+	rows := make([]string, height)
+	for row := range rows {
+		line := ""
+		if row < len(text) {
+			line = " " + text[row]
+		}
+		rows[row] = fit(line, width)
+	}
+	return rows
+}
+
+func (app *App) footer() string {
+	if app.message != "" {
+		return app.message
+	}
+	switch app.mode {
+	case filterMode:
+		return "Type to filter  Enter keep  Esc clear"
+	case editMode:
+		return "Tab next field  Enter save  Esc cancel"
+	case confirmMode:
+		return "y yes  n no"
+	}
+	return "↑↓ move  a add  e edit  c complete  d delete  / filter  q quit"
+}
+
+// dialog is the box asking to confirm the action on the selected task.
+func (app *App) dialog(width int) []string {
+	task := app.current()
+	verb := "Delete"
+	if app.confirming == "complete" {
+		verb = "Complete"
+	}
+	question := fmt.Sprintf("%s task #%d %q?", verb, task.Id, strings.TrimSpace(task.Name))
+	inner := min(utf8.RuneCountInString(question), width-8)
+	return []string{
+		"┌" + strings.Repeat("─", inner+2) + "┐",
+		"│ " + fit(question, inner) + " │",
+		"│ " + fit("y yes  n no", inner) + " │",
+		"└" + strings.Repeat("─", inner+2) + "┘",
+	}
+}
+
+// fit truncates or pads text to exactly width characters.
+func fit(text string, width int) string {
+	if width < 1 {
+		return ""
+	}
+	length := utf8.RuneCountInString(text)
+	if length <= width {
+		return text + strings.Repeat(" ", width-length)
+	}
+	runes := []rune(text)
+	if width <= 3 {
+		return string(runes[:width])
+	}
+	return string(runes[:width-3]) + "..."
+}
+
+// spread puts left at the start and right at the end of a line, dropping
+// right when both do not fit.
+func spread(left, right string, width int) string {
+	gap := width - utf8.RuneCountInString(left) - utf8.RuneCountInString(right)
+	if gap < 1 {
+		return fit(left, width)
+	}
+	return left + strings.Repeat(" ", gap) + right
+}
+
+// overlay writes box over line starting at column left.
+func overlay(line, box string, left int) string {
+	runes := []rune(line)
+	copy(runes[left:], []rune(box))
+	return string(runes)
+}
+
+// wrap breaks text into lines of at most width characters at spaces, keeping
+// the line breaks it has.
+func wrap(text string, width int) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			for utf8.RuneCountInString(word) > width {
+				if line != "" {
+					lines = append(lines, line)
+					line = ""
+				}
+				runes := []rune(word)
+				lines = append(lines, string(runes[:width]))
+				word = string(runes[width:])
+			}
+			switch {
+			case line == "":
+				line = word
+			case utf8.RuneCountInString(line)+1+utf8.RuneCountInString(word) <= width:
+				line += " " + word
+			default:
+				lines = append(lines, line)
+				line = word
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -0,0 +1,267 @@
+package tui
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+	"todo_app/pkg/auth"
+	"todo_app/pkg/tasks"
+	"unicode/utf8"
+)
+
+func TestNavigation(t *testing.T) {
+	tests := []struct {
+		name     string
+		script   string
+		expected int
+	}{
+		{name: "first task", script: "", expected: 1},
+		{name: "down", script: "j<down>", expected: 3},
+		{name: "past the end", script: "jjjjj", expected: 3},
+		{name: "up", script: "G<up>", expected: 2},
+		{name: "home", script: "jj<home>", expected: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			headless := newTestHeadless(t)
+
+			screen := headless.Send(test.script)
+
+			if headless.App.selected != test.expected {
+				t.Errorf("got task #%d selected, expected #%d", headless.App.selected, test.expected)
+			}
+			assertScreen(t, screen, "Task #"+string(rune('0'+test.expected)))
+		})
+	}
+}
+
+func TestFilter(t *testing.T) {
+	headless := newTestHeadless(t)
+
+	screen := headless.Send("/REPORT")
+	assertScreen(t, screen, "Filter: REPORT_", "#1 Write report", "#3 Read report", "2 of 3 shown")
+	if strings.Contains(screen, "Buy milk") {
+		t.Errorf("expected the filtered out task to be hidden, got\n%s", screen)
+	}
+
+	screen = headless.Send("<enter>j")
+	if headless.App.selected != 3 {
+		t.Errorf("expected to move between the filtered tasks, got #%d", headless.App.selected)
+	}
+	screen = headless.Send("<esc>")
+	assertScreen(t, screen, "3 of 3 shown")
+
+	screen = headless.Send("/nothing<enter>")
+	assertScreen(t, screen, "No task matches the filter")
+}
+
+func TestEdit(t *testing.T) {
+	tests := []struct {
+		name     string
+		script   string
+		expected tasks.Task
+		output   string
+	}{
+		{name: "name", script: "e<bs><bs><bs><bs><bs><bs>memo<enter>", expected: tasks.Task{Id: 1, Name: "Write memo", Description: "for the team", Date: "01-01-2030", TaskStatus: "pending"}, output: "Saved task #1"},
+		{name: "all fields", script: "<enter><tab> today<tab><bs>1<enter>", expected: tasks.Task{Id: 1, Name: "Write report", Description: "for the team today", Date: "01-01-2031", TaskStatus: "pending"}, output: "Saved task #1"},
+		{name: "cancelled", script: "ex<esc>", expected: tasks.Task{Id: 1, Name: "Write report", Description: "for the team", Date: "01-01-2030", TaskStatus: "pending"}, output: "Nothing was changed"},
+		{name: "wrong date", script: "ex<up>x<enter>", expected: tasks.Task{Id: 1, Name: "Write report", Description: "for the team", Date: "01-01-2030", TaskStatus: "pending"}, output: tasks.TaskDateErr.Error()},
+		{name: "empty name", script: "e<bs><bs><bs><bs><bs><bs><bs><bs><bs><bs><bs><bs><enter>", expected: tasks.Task{Id: 1, Name: "Write report", Description: "for the team", Date: "01-01-2030", TaskStatus: "pending"}, output: tasks.TaskNameErr.Error()},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			headless := newTestHeadless(t)
+
+			screen := headless.Send(test.script)
+
+			got := *headless.App.Tasks[1]
+			if got.Name != test.expected.Name || got.Description != test.expected.Description || got.Date != test.expected.Date {
+				t.Errorf("got %v, expected %v", got, test.expected)
+			}
+			assertScreen(t, screen, test.output)
+		})
+	}
+}
+
+func TestEditStaysOpenOnError(t *testing.T) {
+	headless := newTestHeadless(t)
+
+	screen := headless.Send("e<tab><tab><bs><enter>")
+	assertScreen(t, screen, "Editing task #1", "> Date:", tasks.TaskDateErr.Error())
+
+	screen = headless.Send("1<enter>")
+	assertScreen(t, screen, "Saved task #1")
+}
+
+func TestAdd(t *testing.T) {
+	headless := newTestHeadless(t)
+
+	screen := headless.Send("aCall mum<enter>")
+
+	task := headless.App.Tasks[4]
+	if task == nil || task.Name != "Call mum" || task.Date != "15-03-2030" {
+		t.Fatalf("expected the task to be added for today, got %v", task)
+	}
+	assertScreen(t, screen, "Added task #4", "> [ ] #4 Call mum")
+}
+
+func TestAddUnverified(t *testing.T) {
+	auth.RequireVerifiedEmail(auth.VerificationForTasks)
+	t.Cleanup(func() { auth.RequireVerifiedEmail(auth.VerificationOptional) })
+	headless := newTestHeadless(t)
+
+	screen := headless.Send("a")
+
+	if headless.App.mode != listMode {
+		t.Errorf("expected the form not to open")
+	}
+	assertScreen(t, screen, "Please verify your email first")
+}
+
+func TestConfirm(t *testing.T) {
+	tests := []struct {
+		name            string
+		script          string
+		expected_tasks  int
+		expected_status string
+		output          string
+	}{
+		{name: "delete", script: "dy", expected_tasks: 2, expected_status: "", output: "Deleted task #1"},
+		{name: "delete cancelled", script: "dn", expected_tasks: 3, expected_status: "pending", output: "Nothing was changed"},
+		{name: "complete", script: "cY", expected_tasks: 3, expected_status: "complete", output: "[x] #1 Write report"},
+		{name: "complete cancelled", script: "c<esc>", expected_tasks: 3, expected_status: "pending", output: "Nothing was changed"},
+		{name: "already complete", script: "cyc", expected_tasks: 3, expected_status: "complete", output: "Task #1 is already complete"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			headless := newTestHeadless(t)
+
+			screen := headless.Send(test.script)
+
+			if len(headless.App.Tasks) != test.expected_tasks {
+				t.Errorf("got %d tasks, expected %d", len(headless.App.Tasks), test.expected_tasks)
+			}
+			status := ""
+			if task := headless.App.Tasks[1]; task != nil {
+				status = task.TaskStatus
+			}
+			if status != test.expected_status {
+				t.Errorf("got status %q, expected %q", status, test.expected_status)
+			}
+			assertScreen(t, screen, test.output)
+		})
+	}
+}
+
+func TestConfirmDialog(t *testing.T) {
+	headless := newTestHeadless(t)
+
+	screen := headless.Send("jd")
+
+	assertScreen(t, screen, `│ Delete task #2 "Buy milk"? │`, "y yes  n no")
+	headless.Send("y")
+	if headless.App.selected != 3 {
+		t.Errorf("expected the next task to be selected, got #%d", headless.App.selected)
+	}
+}
+
+func TestSettings(t *testing.T) {
+	headless := newTestHeadless(t)
+	headless.App.DateLayout = "2006/01/02"
+	headless.App.Sort = "name"
+
+	screen := headless.Send("ge<tab><tab>")
+	assertScreen(t, screen, "Editing task #2", "2030/01/02_")
+
+	headless.Send("<bs>5<enter>")
+	if got := headless.App.Tasks[2].Date; got != "05-01-2030" {
+		t.Errorf("got %q, expected the date to be kept as dd-mm-yyyy", got)
+	}
+}
+
+func TestView(t *testing.T) {
+	tests := []struct {
+		name   string
+		width  int
+		height int
+		output string
+	}{
+		{name: "normal", width: 80, height: 24, output: "Tasks of tester"},
+		{name: "narrow", width: 40, height: 8, output: "Tasks of tester"},
+		{name: "too small", width: 30, height: 5, output: "Terminal too small"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			app := newTestHeadless(t).App
+			app.Tasks[1].Description = strings.Repeat("a very long description ", 20)
+
+			lines := app.View(test.width, test.height)
+
+			if len(lines) != test.height {
+				t.Fatalf("got %d lines, expected %d", len(lines), test.height)
+			}
+			for i, line := range lines {
+				if utf8.RuneCountInString(line) != test.width {
+					t.Errorf("line %d is %d wide, expected %d: %q", i, utf8.RuneCountInString(line), test.width, line)
+				}
+			}
+			assertScreen(t, strings.Join(lines, "\n"), test.output)
+		})
+	}
+}
+
+func TestQuit(t *testing.T) {
+	for _, script := range []string{"q", "e<c-c>"} {
+		headless := newTestHeadless(t)
+
+		headless.Send(script + "d")
+
+		if !headless.App.Done() || headless.App.mode == confirmMode {
+			t.Errorf("expected %q to quit", script)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	app := newTestHeadless(t).App
+	out := &bytes.Buffer{}
+
+	err := Run(app, strings.NewReader("\x1b[Bdy"), out, func() (int, int) { return 80, 24 })
+
+	if err != nil {
+		t.Fatalf("unexpected error %q", err)
+	}
+	if _, found := app.Tasks[2]; found {
+		t.Errorf("expected the second task to be deleted")
+	}
+	if !strings.Contains(out.String(), "Buy milk") || !strings.HasSuffix(out.String(), "\x1b[?1049l") {
+		t.Errorf("expected the screen to be drawn and the terminal restored, got %q", out)
+	}
+}
+
+//helpers
+
+// newTestHeadless is the app on three tasks at 80x24, on 15-03-2030.
+func newTestHeadless(t testing.TB) *Headless {
+	t.Helper()
+	taskList := tasks.TaskList{}
+	taskList.AddTask("Write report", "for the team", "01-01-2030")
+	taskList.AddTask("Buy milk", "", "02-01-2030")
+	taskList.AddTask("Read report", "", "03-01-2030")
+	user := auth.User{Username: "tester"}
+	return NewHeadless(New(taskList, user, time.Date(2030, 3, 15, 9, 0, 0, 0, time.UTC)), 80, 24)
+}
+
+func assertScreen(t testing.TB, screen string, expected ...string) {
+	t.Helper()
+	for _, text := range expected {
+		if !strings.Contains(screen, text) {
+			t.Errorf("expected %q on the screen, got\n%s", text, screen)
+		}
+	}
+}